@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto"
 	"crypto/x509"
 	"kscep/internal/client"
+	"kscep/internal/gmscep"
 	"kscep/internal/utils"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/emmansun/gmsm/sm2"
 	"github.com/pkg/errors"
 	"github.com/ploynomail/scep"
 	"go.uber.org/zap"
@@ -18,6 +21,7 @@ type runCfg struct {
 	dir          string
 	csrPath      string
 	keyPath      string
+	keyType      string
 	keyBits      int
 	selfSignPath string
 	certPath     string
@@ -45,7 +49,16 @@ func run(cfg runCfg) error {
 	if err != nil {
 		return err
 	}
-	key, err := utils.LoadOrMakeKey(cfg.keyPath, cfg.keyBits)
+	// SM2 keys enroll with GM/T 0089: SM3 signatures and SM4 envelopes
+	gm := cfg.keyType == keyTypeSM2
+	var key crypto.Signer
+	var sm2Key *sm2.PrivateKey
+	if gm {
+		sm2Key, err = utils.LoadOrMakeSM2Key(cfg.keyPath)
+		key = sm2Key
+	} else {
+		key, err = utils.LoadOrMakeKey(cfg.keyPath, cfg.keyBits)
+	}
 	if err != nil {
 		return err
 	}
@@ -80,13 +93,22 @@ func run(cfg runCfg) error {
 		self = s
 	}
 
-	resp, certNum, err := client.GetCACert(ctx, cfg.caCertMsg)
+	caCertMsg := cfg.caCertMsg
+	if gm && caCertMsg == "" {
+		caCertMsg = "SM2"
+	}
+	resp, certNum, err := client.GetCACert(ctx, caCertMsg)
 	if err != nil {
 		return err
 	}
 	var caCerts []*x509.Certificate
 	{
-		if certNum > 1 {
+		if gm {
+			caCerts, err = gmscep.CACerts(resp, certNum)
+			if err != nil {
+				return err
+			}
+		} else if certNum > 1 {
 			caCerts, err = scep.CACerts(resp)
 			if err != nil {
 				return err
@@ -130,12 +152,19 @@ func run(cfg runCfg) error {
 		}
 	}
 
-	msg, err := scep.NewCSRRequest(csr, tmpl, scep.WithCertsSelector(cfg.caCertsSelector))
+	var msg *scep.PKIMessage
+	if gm {
+		tmpl.Recipients = cfg.caCertsSelector.SelectCerts(caCerts)
+		msg, err = gmscep.NewCSRRequest(csr, tmpl)
+	} else {
+		msg, err = scep.NewCSRRequest(csr, tmpl, scep.WithCertsSelector(cfg.caCertsSelector))
+	}
 	if err != nil {
 		return errors.Wrap(err, "creating csr pkiMessage")
 	}
 
 	var respMsg *scep.PKIMessage
+	var gmRespMsg *gmscep.PKIMessage
 
 	for {
 		// loop in case we get a PENDING response which requires
@@ -146,7 +175,14 @@ func run(cfg runCfg) error {
 			return errors.Wrapf(err, "PKIOperation for %s", msgType)
 		}
 
-		respMsg, err = scep.ParsePKIMessage(respBytes, scep.WithCACerts(caCerts))
+		if gm {
+			gmRespMsg, err = gmscep.ParsePKIMessage(respBytes, caCerts)
+			if err == nil {
+				respMsg = &gmRespMsg.PKIMessage
+			}
+		} else {
+			respMsg, err = scep.ParsePKIMessage(respBytes, scep.WithCACerts(caCerts))
+		}
 		if err != nil {
 			return errors.Wrapf(err, "parsing pkiMessage response %s", msgType)
 		}
@@ -163,7 +199,12 @@ func run(cfg runCfg) error {
 		break // on scep.SUCCESS
 	}
 
-	if err := respMsg.DecryptPKIEnvelope(signerCert, key); err != nil {
+	if gm {
+		err = gmRespMsg.DecryptPKIEnvelope(signerCert, sm2Key)
+	} else {
+		err = respMsg.DecryptPKIEnvelope(signerCert, key)
+	}
+	if err != nil {
 		return errors.Wrapf(err, "decrypt pkiEnvelope, msgType: %s, status %s", msgType, respMsg.PKIStatus)
 	}
 
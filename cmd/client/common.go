@@ -11,10 +11,18 @@ import (
 
 const fingerprintHashType = crypto.SHA256
 
-func validateFlags(keyPath, serverURL, caFingerprint string, useKeyEnciphermentSelector bool) error {
+const (
+	keyTypeRSA = "rsa"
+	keyTypeSM2 = "sm2"
+)
+
+func validateFlags(keyPath, keyType, serverURL, caFingerprint string, useKeyEnciphermentSelector bool) error {
 	if keyPath == "" {
 		return errors.New("must specify private key path")
 	}
+	if keyType != keyTypeRSA && keyType != keyTypeSM2 {
+		return fmt.Errorf("invalid key-type flag parameter %q", keyType)
+	}
 	if serverURL == "" {
 		return errors.New("must specify server-url flag parameter")
 	}
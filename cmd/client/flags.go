@@ -5,6 +5,7 @@ var (
 	ChallengePassword       string //质询密码
 	PKeyPath                string //私钥路径
 	CertPath                string //证书路径
+	KeyType                 string //密钥类型
 	KeySize                 int    //密钥大小
 	Org                     string //证书组织
 	CName                   string //证书通用名称
@@ -25,6 +26,7 @@ func init() {
 	clientCmd.Flags().StringVarP(&ChallengePassword, "challenge-password", "c", "", "Challenge password")
 	clientCmd.Flags().StringVarP(&PKeyPath, "private-key", "k", ".", "Private key path")
 	clientCmd.Flags().StringVarP(&CertPath, "certificate", "t", "", "Certificate path")
+	clientCmd.Flags().StringVar(&KeyType, "key-type", keyTypeRSA, "Key type: rsa, or sm2 to enroll with GM/T 0089")
 	clientCmd.Flags().IntVarP(&KeySize, "key-size", "z", 2048, "Key size")
 	clientCmd.Flags().StringVarP(&Org, "organization", "o", "", "Certificate organization")
 	clientCmd.Flags().StringVarP(&CName, "common-name", "n", "", "Certificate common name")
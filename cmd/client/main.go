@@ -36,7 +36,7 @@ var clientCmd = &cobra.Command{
 		InitializeLogger(logFmt)
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := validateFlags(PKeyPath, ServerURL, CAFingerprint, KeyEnciphermentSelector); err != nil {
+		if err := validateFlags(PKeyPath, KeyType, ServerURL, CAFingerprint, KeyEnciphermentSelector); err != nil {
 			logger.Error("error validating flags", zap.Error(err))
 			os.Exit(1)
 		}
@@ -71,6 +71,7 @@ var clientCmd = &cobra.Command{
 		// - certPath: Path to the certificate file.
 		// - csrPath: Path to the certificate signing request file.
 		// - keyPath: Path to the private key file.
+		// - keyType: Type of the private key, rsa or sm2.
 		// - keyBits: Size of the private key in bits.
 		// - serverURL: URL of the server to connect to.
 		// - country: Country name for the CSR.
@@ -91,6 +92,7 @@ var clientCmd = &cobra.Command{
 			certPath:     CertPath,
 			csrPath:      csrPath,
 			keyPath:      PKeyPath,
+			keyType:      KeyType,
 			keyBits:      KeySize,
 
 			serverURL: ServerURL,
@@ -15,6 +15,9 @@ import (
 
 	_ "go.uber.org/automaxprocs"
 	"go.uber.org/zap"
+	// database/sql drivers for depot_type "sql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 // go build -ldflags "-X main.Version=x.y.z"
@@ -17,7 +17,9 @@ import (
 )
 
 import (
+	_ "github.com/lib/pq"
 	_ "go.uber.org/automaxprocs"
+	_ "modernc.org/sqlite"
 )
 
 // Injectors from wire.go:
@@ -30,12 +32,61 @@ func wireApp(confServer *conf.Server, confData *conf.Data, logger log.Logger) (*
 	if err != nil {
 		return nil, nil, err
 	}
-	scepcaRepo := data.NewSCEPCARepo(confData, dataData, logger)
+	scepcaRepo, err := data.NewSCEPCARepo(confData, dataData, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
 	scepcaUsecase := biz.NewSCEPCAUsecase(scepcaRepo, logger)
-	csrSignerUsecase := biz.NewCSRSignerUsecase(confData, logger)
-	scepUsecase := biz.NewSCEPUsecase(scepcaUsecase, csrSignerUsecase, logger)
-	scepService := service.NewSCEPService(scepUsecase, logger)
-	httpServer := server.NewGinhttpServer(confServer, logger, helloWorldService, scepService)
+	certificateRepo := data.NewCertificateRepo(dataData, logger)
+	certificateUsecase := biz.NewCertificateUsecase(certificateRepo, scepcaUsecase, logger)
+	metrics := biz.NewMetrics(confServer, certificateUsecase, logger)
+	csrSignerRepo := data.NewSigner(dataData, metrics, logger)
+	csrSignerUsecase, err := biz.NewCSRSignerUsecase(confData, csrSignerRepo, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	challengeRepo := data.NewChallengeRepo(dataData, logger)
+	challengeUsecase := biz.NewChallengeUsecase(confData, challengeRepo, logger)
+	pendingRepo := data.NewPendingRepo(dataData, logger)
+	approvalUsecase := biz.NewApprovalUsecase(confData, pendingRepo, csrSignerUsecase, logger)
+	csrPolicy, err := biz.NewCSRPolicy(confData)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	revocationRepo := data.NewRevocationRepo(dataData, logger)
+	revocationUsecase := biz.NewRevocationUsecase(confData, revocationRepo, scepcaUsecase, logger)
+	replayRepo, err := data.NewReplayRepo(confData, dataData, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	replayUsecase := biz.NewReplayUsecase(confData, replayRepo, logger)
+	caCaps, err := biz.NewCACaps(confData)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	scepUsecase := biz.NewSCEPUsecase(scepcaUsecase, csrSignerUsecase, challengeUsecase, approvalUsecase, csrPolicy, certificateUsecase, revocationUsecase, replayUsecase, caCaps, metrics, logger)
+	scepService := service.NewSCEPService(scepUsecase, challengeUsecase, approvalUsecase, metrics, logger)
+	revocationService := service.NewRevocationService(revocationUsecase, logger)
+	ocspUsecase, err := biz.NewOCSPUsecase(confData, scepcaUsecase, certificateRepo, revocationRepo, logger)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	ocspService := service.NewOCSPService(ocspUsecase, logger)
+	certificateService := service.NewCertificateService(certificateUsecase, logger)
+	estUsecase := biz.NewESTUsecase(scepUsecase, logger)
+	estService := service.NewESTService(estUsecase, logger)
+	serverTLSUsecase := biz.NewServerTLSUsecase(scepUsecase, logger)
+	httpServer, err := server.NewGinhttpServer(confServer, logger, helloWorldService, scepService, revocationService, ocspService, certificateService, estService, serverTLSUsecase, metrics)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
 	app := newApp(logger, httpServer)
 	return app, func() {
 		cleanup()
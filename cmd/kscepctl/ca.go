@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kscep/internal/biz"
+	"kscep/internal/data"
+	"kscep/internal/utils"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	caType    string //CA 类型
+	promoteAt string //下一个 CA 生效的时间
+)
+
+func init() {
+	caPromoteCmd.Flags().StringVarP(&caType, "type", "t", "RSA", "CA type to roll over: RSA, ECC or SM2")
+	caPromoteCmd.Flags().StringVarP(&promoteAt, "at", "a", "", "RFC 3339 time the next CA becomes current, eg: 2025-01-02T15:04:05Z (default now)")
+	caCmd.AddCommand(caPromoteCmd)
+}
+
+// caCmd groups the CA management commands
+var caCmd = &cobra.Command{
+	Use:   "ca",
+	Short: "ca subcommand manages the CAs of the depot",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// caPromoteCmd makes the staged next CA (<TYPE>.next.pem and
+// <TYPE>.next.key in the depot) the current CA.
+var caPromoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "promote the staged next CA to current, now or at a scheduled time",
+	Long: "promote makes the staged next CA (<TYPE>.next.pem and <TYPE>.next.key) the current CA.\n" +
+		"With --at the promotion is scheduled and carried out by the server once due, so\n" +
+		"clients can fetch the next CA with GetNextCACert until then.\n" +
+		"With the remote key provider the signing daemon keeps the keys: stage the next key\n" +
+		"there as <TYPE>.next.key too, the server signs with it once the CA is promoted.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := promote(); err != nil {
+			fmt.Fprintf(os.Stderr, "error promoting next CA: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func promote() error {
+	t := strings.ToUpper(caType)
+	if t == "" || !utils.IsInArray(biz.SupportedCaTypes, t) {
+		return biz.UnsupportedCaTypeErr
+	}
+	at := time.Now()
+	if promoteAt != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, promoteAt); err != nil {
+			return err
+		}
+	}
+	c, err := loadData()
+	if err != nil {
+		return err
+	}
+	logger := log.DefaultLogger
+	d, cleanup, err := data.NewData(c, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	repo, err := data.NewSCEPCARepo(c, d, logger)
+	if err != nil {
+		return err
+	}
+	uc := biz.NewSCEPCAUsecase(repo, logger)
+	return uc.PromoteNextCA(t, at)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"kscep/internal/conf"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/config/file"
+	"github.com/spf13/cobra"
+	// database/sql drivers for depot_type "sql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+var version = "0.0.1"
+
+// flagconf is the config path of the kscep server being operated on.
+var flagconf string
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&flagconf, "conf", "c", "../../configs", "config path of the kscep server, eg: -c config.yaml")
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(caCmd)
+}
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "version subcommand show kscepctl version info.",
+
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Stdout.WriteString(fmt.Sprintf("version: %s\n", version))
+	},
+}
+
+// rootCmd represents the base command when called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "kscepctl",
+	Short: "kscepctl operates the depot of a kscep server",
+	Long:  "kscepctl operates the depot of a kscep server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+// loadData reads the data section of the server configuration.
+func loadData() (*conf.Data, error) {
+	c := config.New(
+		config.WithSource(
+			file.NewSource(flagconf),
+		),
+	)
+	defer c.Close()
+	if err := c.Load(); err != nil {
+		return nil, err
+	}
+	var bc conf.Bootstrap
+	if err := c.Scan(&bc); err != nil {
+		return nil, err
+	}
+	if bc.Data == nil {
+		return nil, fmt.Errorf("no data section in %s", flagconf)
+	}
+	return bc.Data, nil
+}
+
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func main() {
+	Execute()
+}
@@ -0,0 +1,82 @@
+// signer is the signing daemon of the "remote" key provider. It keeps the
+// CA keys, NAME.key in the key directory, and signs and decrypts for the
+// kscep server over a Unix socket, so that the keys never enter the server.
+//
+// The passphrase of an encrypted key is read from the environment variable
+// KSCEP_CAPASS_<NAME>, eg: KSCEP_CAPASS_RSA.
+//
+// A CA rollover only moves the keys kept in the depot. The key of the next
+// CA is staged here as NAME.next.key: once the CA is promoted, the server
+// signs with it until NAME.key is replaced.
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"kscep/internal/keyprovider"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// capassEnvPrefix prefixes the environment variables of the key passphrases.
+const capassEnvPrefix = "KSCEP_CAPASS_"
+
+var (
+	flagSocket string
+	flagDir    string
+)
+
+func init() {
+	flag.StringVar(&flagSocket, "socket", "/run/kscep/signer.sock", "Unix socket to listen on")
+	flag.StringVar(&flagDir, "dir", "./keys", "directory of the CA keys, RSA.key, ECC.key and SM2.key")
+}
+
+// passphrases returns the key passphrases set in the environment by CA name.
+func passphrases() map[string][]byte {
+	passes := map[string][]byte{}
+	for _, kv := range os.Environ() {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(k, capassEnvPrefix) {
+			continue
+		}
+		passes[strings.TrimPrefix(k, capassEnvPrefix)] = []byte(v)
+	}
+	return passes
+}
+
+func main() {
+	flag.Parse()
+	l := log.NewHelper(log.With(log.NewStdLogger(os.Stdout), "module", "signer"))
+
+	// a socket left behind by a previous run
+	if err := os.Remove(flagSocket); err != nil && !os.IsNotExist(err) {
+		l.Fatal(err)
+	}
+	ln, err := net.Listen("unix", flagSocket)
+	if err != nil {
+		l.Fatal(err)
+	}
+	// only the kscep server, running as the same user, may use the keys
+	if err := os.Chmod(flagSocket, 0600); err != nil {
+		ln.Close()
+		l.Fatal(err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		l.Info("shutting down")
+		ln.Close()
+	}()
+
+	l.Infof("serving the keys of %s on %s", flagDir, flagSocket)
+	if err := keyprovider.Serve(ln, keyprovider.NewFileProvider(flagDir, passphrases())); err != nil {
+		l.Fatal(err)
+	}
+}
@@ -0,0 +1,227 @@
+package biz
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"kscep/internal/conf"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+)
+
+type PendingStatus string
+
+const (
+	StatusPending PendingStatus = "pending"
+	// StatusApproving marks a request claimed by an operator approval while
+	// its certificate is signed.
+	StatusApproving PendingStatus = "approving"
+	StatusIssued    PendingStatus = "issued"
+	StatusRejected  PendingStatus = "rejected"
+)
+
+// PendingRequest is a PKCSReq parked until an operator approves or rejects
+// it. It is keyed by the SCEP transactionID so the client can poll for it.
+type PendingRequest struct {
+	ID            string
+	TransactionID string
+	Profile       string
+	CSR           *x509.CertificateRequest
+	Status        PendingStatus
+	// CaType is the CA the client encrypted its request to; it issues the
+	// certificate on approval.
+	CaType CaType
+	// Reason is the operator supplied reason for a rejection.
+	Reason string
+	// Certificate is set once the request has been approved.
+	Certificate *x509.Certificate
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type PendingRepo interface {
+	SavePending(ctx context.Context, pr *PendingRequest) error
+	GetPending(ctx context.Context, id string) (*PendingRequest, error)
+	ListPending(ctx context.Context, status PendingStatus) ([]*PendingRequest, error)
+	// UpdatePending saves pr only while the stored request has the given
+	// status, and fails with PendingNotPendingErr otherwise.
+	UpdatePending(ctx context.Context, pr *PendingRequest, status PendingStatus) error
+}
+
+// PendingRequestID derives the id of the pending request for a SCEP
+// transactionID. Transaction IDs are base64 and not safe in URLs or file
+// names.
+func PendingRequestID(transactionID string) string {
+	sum := sha256.Sum256([]byte(transactionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// ApprovalUsecase parks enrollments for manual approval. Status transitions
+// are conditional updates in the depot, so a request is signed only once
+// even when replicas share the depot.
+type ApprovalUsecase struct {
+	repo   PendingRepo
+	signer *CSRSignerUsecase
+	manual bool
+	log    *log.Helper
+}
+
+func NewApprovalUsecase(c *conf.Data, repo PendingRepo, signer *CSRSignerUsecase, logger log.Logger) *ApprovalUsecase {
+	return &ApprovalUsecase{
+		repo:   repo,
+		signer: signer,
+		manual: c.GetManualApproval(),
+		log:    log.NewHelper(log.With(logger, "module", "usecase/scep/approval")),
+	}
+}
+
+// Manual reports whether enrollments require manual approval.
+func (uc *ApprovalUsecase) Manual() bool {
+	return uc.manual
+}
+
+// Park queues a CSR for approval. Parking a transaction twice returns the
+// request queued first.
+func (uc *ApprovalUsecase) Park(ctx context.Context, transactionID, profile string, caType CaType, csr *x509.CertificateRequest) (*PendingRequest, error) {
+	id := PendingRequestID(transactionID)
+	pr, err := uc.repo.GetPending(ctx, id)
+	if err == nil {
+		return pr, nil
+	}
+	if !errors.Is(err, PendingNotFoundErr) {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	pr = &PendingRequest{
+		ID:            id,
+		TransactionID: transactionID,
+		Profile:       profile,
+		CaType:        caType,
+		CSR:           csr,
+		Status:        StatusPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := uc.repo.SavePending(ctx, pr); err != nil {
+		return nil, err
+	}
+	uc.log.Infof("parked enrollment %s for %q awaiting approval", id, csr.Subject.CommonName)
+	return pr, nil
+}
+
+// Lookup returns the pending request for a SCEP transactionID.
+func (uc *ApprovalUsecase) Lookup(ctx context.Context, transactionID string) (*PendingRequest, error) {
+	return uc.repo.GetPending(ctx, PendingRequestID(transactionID))
+}
+
+func (uc *ApprovalUsecase) Get(ctx context.Context, id string) (*PendingRequest, error) {
+	return uc.repo.GetPending(ctx, id)
+}
+
+// List returns the requests with the given status, or all if status is empty.
+func (uc *ApprovalUsecase) List(ctx context.Context, status PendingStatus) ([]*PendingRequest, error) {
+	return uc.repo.ListPending(ctx, status)
+}
+
+// StaleApproval is how long a request may stay claimed as approving
+// without a certificate before another approval takes it over, as after an
+// approver stopped while signing.
+const StaleApproval = 5 * time.Minute
+
+// approvalRetries is how often the approving to issued update is tried.
+const approvalRetries = 3
+
+// Approve signs the parked CSR. The certificate is handed out when the
+// client polls for the transaction.
+//
+// The request is claimed as approving before it is signed, so a concurrent
+// approval or rejection fails with PendingNotPendingErr, and handed back to
+// the queue if signing fails. A request left approving is completed with
+// the certificate it was signed with, or signed again once it is stale.
+func (uc *ApprovalUsecase) Approve(ctx context.Context, id string) (*PendingRequest, error) {
+	pr, err := uc.repo.GetPending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if pr.Status == StatusApproving && pr.Certificate != nil {
+		return uc.issue(ctx, pr)
+	}
+	if pr.Status == StatusApproving && time.Since(pr.UpdatedAt) > StaleApproval {
+		uc.log.Warnf("requeueing enrollment %s, approving since %s", id, pr.UpdatedAt)
+		if err := uc.transition(ctx, pr, StatusApproving, StatusPending); err != nil {
+			return nil, err
+		}
+	}
+	if err := uc.transition(ctx, pr, StatusPending, StatusApproving); err != nil {
+		return nil, err
+	}
+	crt, err := uc.signer.SignCSR(ctx, pr.CaType, pr.Profile, &scep.CSRReqMessage{
+		RawDecrypted: pr.CSR.Raw,
+		CSR:          pr.CSR,
+	})
+	if err != nil {
+		if err := uc.transition(ctx, pr, StatusApproving, StatusPending); err != nil {
+			uc.log.Errorf("failed to requeue enrollment %s: %v", id, err)
+		}
+		return nil, err
+	}
+	pr.Certificate = crt
+	return uc.issue(ctx, pr)
+}
+
+// issue marks the approving pr, signed with pr.Certificate, as issued. If
+// that keeps failing the certificate is kept with the approving request, so
+// that polls return it and the next approval completes it.
+func (uc *ApprovalUsecase) issue(ctx context.Context, pr *PendingRequest) (*PendingRequest, error) {
+	var err error
+	for i := 0; i < approvalRetries; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(i) * 100 * time.Millisecond)
+		}
+		if err = uc.transition(ctx, pr, StatusApproving, StatusIssued); err == nil || errors.Is(err, PendingNotPendingErr) {
+			break
+		}
+	}
+	if err != nil {
+		if serr := uc.transition(ctx, pr, StatusApproving, StatusApproving); serr != nil {
+			uc.log.Errorf("failed to keep certificate %s with enrollment %s: %v", pr.Certificate.SerialNumber, pr.ID, serr)
+		}
+		return nil, err
+	}
+	uc.log.Infof("approved enrollment %s, issued certificate %s", pr.ID, pr.Certificate.SerialNumber)
+	return pr, nil
+}
+
+// Reject refuses the parked CSR; the client receives a FAILURE on its next
+// poll.
+func (uc *ApprovalUsecase) Reject(ctx context.Context, id, reason string) (*PendingRequest, error) {
+	pr, err := uc.repo.GetPending(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	pr.Reason = reason
+	if err := uc.transition(ctx, pr, StatusPending, StatusRejected); err != nil {
+		return nil, err
+	}
+	uc.log.Infof("rejected enrollment %s: %s", id, reason)
+	return pr, nil
+}
+
+// transition moves pr from one status to another, provided the depot still
+// holds it with status from.
+func (uc *ApprovalUsecase) transition(ctx context.Context, pr *PendingRequest, from, to PendingStatus) error {
+	if pr.Status != from {
+		return PendingNotPendingErr
+	}
+	pr.Status = to
+	pr.UpdatedAt = time.Now().UTC()
+	if err := uc.repo.UpdatePending(ctx, pr, from); err != nil {
+		pr.Status = from
+		return err
+	}
+	return nil
+}
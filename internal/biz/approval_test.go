@@ -0,0 +1,138 @@
+package biz
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"kscep/internal/conf"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// memPendingRepo keeps pending requests in memory. failIssued updates to
+// the issued status fail.
+type memPendingRepo struct {
+	reqs       map[string]PendingRequest
+	failIssued bool
+}
+
+func (r *memPendingRepo) SavePending(_ context.Context, pr *PendingRequest) error {
+	r.reqs[pr.ID] = *pr
+	return nil
+}
+
+func (r *memPendingRepo) GetPending(_ context.Context, id string) (*PendingRequest, error) {
+	pr, ok := r.reqs[id]
+	if !ok {
+		return nil, PendingNotFoundErr
+	}
+	return &pr, nil
+}
+
+func (r *memPendingRepo) ListPending(context.Context, PendingStatus) ([]*PendingRequest, error) {
+	return nil, nil
+}
+
+func (r *memPendingRepo) UpdatePending(_ context.Context, pr *PendingRequest, status PendingStatus) error {
+	stored, ok := r.reqs[pr.ID]
+	if !ok {
+		return PendingNotFoundErr
+	}
+	if stored.Status != status {
+		return PendingNotPendingErr
+	}
+	if r.failIssued && pr.Status == StatusIssued {
+		return errors.New("depot unavailable")
+	}
+	r.reqs[pr.ID] = *pr
+	return nil
+}
+
+// countingSigner issues self-signed certificates and counts them.
+type countingSigner struct {
+	key    *rsa.PrivateKey
+	signed int
+}
+
+func (s *countingSigner) SignCSRContext(_ context.Context, req *SignRequest) (*x509.Certificate, error) {
+	s.signed++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(s.signed)),
+		Subject:      req.CSR.CSR.Subject,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, req.CSR.CSR.PublicKey, s.key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func (s *countingSigner) WithAllowRenewalDays(int) {}
+func (s *countingSigner) WithValidityDays(int)     {}
+func (s *countingSigner) WithSeverAttrs()          {}
+
+func TestApprovalUsecase_Approve(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &countingSigner{key: key}
+	signerUc, err := NewCSRSignerUsecase(&conf.Data{}, signer, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &memPendingRepo{reqs: map[string]PendingRequest{}}
+	uc := NewApprovalUsecase(&conf.Data{ManualApproval: true}, repo, signerUc, log.DefaultLogger)
+	ctx := context.Background()
+	park := func(transactionID string) *PendingRequest {
+		t.Helper()
+		pr, err := uc.Park(ctx, transactionID, "", RsaCa, testCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: transactionID}}))
+		if err != nil {
+			t.Fatalf("Park() error = %v", err)
+		}
+		return pr
+	}
+
+	// Test a certificate whose request cannot be marked issued is kept
+	pr := park("tx-1")
+	repo.failIssued = true
+	if _, err := uc.Approve(ctx, pr.ID); err == nil {
+		t.Fatalf("Approve() error = nil while the depot fails")
+	}
+	stored, _ := repo.GetPending(ctx, pr.ID)
+	if stored.Status != StatusApproving || stored.Certificate == nil {
+		t.Fatalf("request after the failed update = %s with certificate %v, want approving with one", stored.Status, stored.Certificate != nil)
+	}
+
+	// Test the next approval completes it without signing again
+	repo.failIssued = false
+	approved, err := uc.Approve(ctx, pr.ID)
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if approved.Status != StatusIssued || !approved.Certificate.Equal(stored.Certificate) || signer.signed != 1 {
+		t.Errorf("Approve() = %s after %d signatures, want issued with the kept certificate after 1", approved.Status, signer.signed)
+	}
+
+	// Test a request claimed by another approval is left alone until stale
+	pr = park("tx-2")
+	claimed := repo.reqs[pr.ID]
+	claimed.Status, claimed.UpdatedAt = StatusApproving, time.Now()
+	repo.reqs[pr.ID] = claimed
+	if _, err := uc.Approve(ctx, pr.ID); !errors.Is(err, PendingNotPendingErr) {
+		t.Errorf("Approve() of a request being approved error = %v, want PendingNotPendingErr", err)
+	}
+	claimed.UpdatedAt = time.Now().Add(-2 * StaleApproval)
+	repo.reqs[pr.ID] = claimed
+	if approved, err := uc.Approve(ctx, pr.ID); err != nil || approved.Status != StatusIssued || approved.Certificate == nil {
+		t.Errorf("Approve() of a stale request = %v, %v, want issued", approved, err)
+	}
+}
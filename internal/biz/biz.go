@@ -8,4 +8,15 @@ var ProviderSet = wire.NewSet(
 	NewSCEPUsecase,
 	NewCSRSignerUsecase,
 	NewSCEPCAUsecase,
+	NewChallengeUsecase,
+	NewCSRPolicy,
+	NewCACaps,
+	NewApprovalUsecase,
+	NewRevocationUsecase,
+	NewCertificateUsecase,
+	NewOCSPUsecase,
+	NewReplayUsecase,
+	NewESTUsecase,
+	NewServerTLSUsecase,
+	NewMetrics,
 )
@@ -1,15 +1,49 @@
 package biz
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+
+	"kscep/internal/conf"
+	"kscep/internal/utils"
 
 	"github.com/go-kratos/kratos/v2/log"
 )
 
 type SCEPCARepo interface {
 	GetCert(t CaType) (*x509.Certificate, error)
-	GetKey(t CaType) (interface{}, error)
+	GetKey(t CaType) (crypto.Signer, error)
+	// GetAddlCA returns the additional CA certificates, intermediates and
+	// roots, that may complete the chains of the CAs.
 	GetAddlCA() ([]*x509.Certificate, error)
+	// GetRA returns the RA certificate and key of CA t, or MissingRaErr.
+	GetRA(t CaType) (*x509.Certificate, crypto.Signer, error)
+	// GetNextCert returns the certificate staged to replace CA t.
+	GetNextCert(t CaType) (*x509.Certificate, error)
+	// SchedulePromotion makes the next CA of type t the current one at the
+	// given time.
+	SchedulePromotion(t CaType, at time.Time) error
+}
+
+// CAPassphrases returns the passphrases of the encrypted CA keys by CA type
+// from the capass section, falling back to RSAsigerconfig.capass for RSA.
+func CAPassphrases(c *conf.Data) (map[CaType]string, error) {
+	passes := map[CaType]string{}
+	if pass := c.GetRSAsigerconfig().GetCapass(); pass != "" {
+		passes[RsaCa] = pass
+	}
+	for name, pass := range c.GetCapass() {
+		t := strings.ToUpper(name)
+		if t == "" || !utils.IsInArray(SupportedCaTypes, t) {
+			return nil, fmt.Errorf("capass: %w: %q", UnsupportedCaTypeErr, name)
+		}
+		passes[GetCaType(t)] = pass
+	}
+	return passes, nil
 }
 
 type SCEPCAUsecase struct {
@@ -29,10 +63,75 @@ func (svc *SCEPCAUsecase) GetCACert(t string) (*x509.Certificate, error) {
 	return svc.caRepo.GetCert(GetCaType(t))
 }
 
-func (svc *SCEPCAUsecase) GetCAKey(t string) (interface{}, error) {
+func (svc *SCEPCAUsecase) GetCAKey(t string) (crypto.Signer, error) {
 	return svc.caRepo.GetKey(GetCaType(t))
 }
 
 func (svc *SCEPCAUsecase) GetAddlCA() ([]*x509.Certificate, error) {
 	return svc.caRepo.GetAddlCA()
 }
+
+// GetCAChain returns the additional CA certificates that chain crt to its
+// root, starting with the issuer of crt. Additional certificates that are
+// not part of the chain are left out.
+func (svc *SCEPCAUsecase) GetCAChain(crt *x509.Certificate) ([]*x509.Certificate, error) {
+	addl, err := svc.caRepo.GetAddlCA()
+	if err != nil {
+		return nil, err
+	}
+	return caChain(crt, addl), nil
+}
+
+func caChain(crt *x509.Certificate, pool []*x509.Certificate) []*x509.Certificate {
+	var chain []*x509.Certificate
+	// every certificate is used once at most, which also ends cycles
+	for cur := crt; len(chain) < len(pool); {
+		if bytes.Equal(cur.RawIssuer, cur.RawSubject) && checkSignatureFrom(cur, cur) == nil {
+			break
+		}
+		next := issuerOf(cur, pool)
+		if next == nil {
+			break
+		}
+		chain = append(chain, next)
+		cur = next
+	}
+	return chain
+}
+
+// issuerOf returns the certificate of pool that signed crt.
+func issuerOf(crt *x509.Certificate, pool []*x509.Certificate) *x509.Certificate {
+	for _, c := range pool {
+		if c.Equal(crt) || !bytes.Equal(c.RawSubject, crt.RawIssuer) {
+			continue
+		}
+		if checkSignatureFrom(crt, c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// GetRA returns the RA certificate and key answering for the CA of type t,
+// or MissingRaErr if it has none.
+func (svc *SCEPCAUsecase) GetRA(t string) (*x509.Certificate, crypto.Signer, error) {
+	return svc.caRepo.GetRA(GetCaType(t))
+}
+
+func (svc *SCEPCAUsecase) GetNextCACert(t string) (*x509.Certificate, error) {
+	return svc.caRepo.GetNextCert(GetCaType(t))
+}
+
+// PromoteNextCA replaces the CA of type t by its staged successor at the
+// given time, or right away if at is not in the future.
+func (svc *SCEPCAUsecase) PromoteNextCA(t string, at time.Time) error {
+	if err := svc.caRepo.SchedulePromotion(GetCaType(t), at); err != nil {
+		return err
+	}
+	if at.After(time.Now()) {
+		svc.log.Infof("next %s CA scheduled to become current at %s", t, at.UTC().Format(time.RFC3339))
+	} else {
+		svc.log.Infof("next %s CA promoted to current", t)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package biz
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testIssueCA issues a CA certificate for cn from parent, or a self-signed
+// one if parent is nil.
+func testIssueCA(t *testing.T, cn string, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
+
+func TestCAChain(t *testing.T) {
+	root, rootKey := testIssueCA(t, "root", nil, nil)
+	inter, interKey := testIssueCA(t, "intermediate", root, rootKey)
+	ca, _ := testIssueCA(t, "ca", inter, interKey)
+	other, _ := testIssueCA(t, "other", nil, nil)
+	// same subject as the intermediate, but a different key
+	impostor, _ := testIssueCA(t, "intermediate", root, rootKey)
+
+	chain := caChain(ca, []*x509.Certificate{root, other, impostor, inter, ca})
+	if len(chain) != 2 || !chain[0].Equal(inter) || !chain[1].Equal(root) {
+		t.Fatalf("caChain() returned %d certificates, want the intermediate and the root", len(chain))
+	}
+	if chain := caChain(ca, []*x509.Certificate{root}); len(chain) != 0 {
+		t.Errorf("caChain() without the intermediate = %d certificates, want none", len(chain))
+	}
+	if chain := caChain(root, []*x509.Certificate{root, inter}); len(chain) != 0 {
+		t.Errorf("caChain() of a root = %d certificates, want none", len(chain))
+	}
+}
@@ -0,0 +1,68 @@
+package biz
+
+import (
+	"fmt"
+	"strings"
+
+	"kscep/internal/conf"
+	"kscep/internal/utils"
+)
+
+// capabilities that may be advertised by GetCACaps, RFC 8894 3.5.2 and
+// GM/T 0089 for SM3 and SM4.
+var knownCaps = []string{
+	"AES", "DES3", GetNextCACert, POSTPKIOperation, "Renewal", SCEPStandard,
+	"SHA-1", "SHA-256", "SHA-512", "SM3", "SM4", "Update",
+}
+
+// defaultCaps are the capabilities the server supports for each CA type:
+// the pkiMessage ciphers and digests it can decrypt and verify, and the
+// operations it answers. GetNextCACert depends on a staged CA and is not
+// part of them.
+var defaultCaps = map[CaType][]string{
+	RsaCa: {"AES", "DES3", POSTPKIOperation, "Renewal", SCEPStandard, "SHA-256", "SHA-512"},
+	EccCa: {"AES", "DES3", POSTPKIOperation, "Renewal", SCEPStandard, "SHA-256", "SHA-512"},
+	SM2Ca: {POSTPKIOperation, "Renewal", "SM3", "SM4"},
+}
+
+// CACaps are the GetCACaps capabilities of each CA type.
+type CACaps struct {
+	caps map[CaType][]string
+}
+
+// NewCACaps returns the default capabilities, replaced per CA type by the
+// caps section of the configuration.
+func NewCACaps(c *conf.Data) (*CACaps, error) {
+	caps := make(map[CaType][]string, len(defaultCaps))
+	for t, list := range defaultCaps {
+		caps[t] = list
+	}
+	for name, cc := range c.GetCaps() {
+		t := strings.ToUpper(name)
+		if t == "" || !utils.IsInArray(SupportedCaTypes, t) {
+			return nil, fmt.Errorf("caps: %w: %q", UnsupportedCaTypeErr, name)
+		}
+		var list []string
+		for _, capability := range cc.GetCaps() {
+			if !utils.IsInArray(knownCaps, capability) {
+				return nil, fmt.Errorf("caps: unknown %s capability %q", t, capability)
+			}
+			// advertised from the staged CA alone
+			if capability != GetNextCACert {
+				list = append(list, capability)
+			}
+		}
+		caps[GetCaType(t)] = list
+	}
+	return &CACaps{caps: caps}, nil
+}
+
+// Get returns the GetCACaps response for CA type t, one capability per
+// line. GetNextCACert is included if a next CA is staged.
+func (c *CACaps) Get(t CaType, nextCA bool) []byte {
+	list := c.caps[t]
+	if nextCA {
+		list = append([]string{GetNextCACert}, list...)
+	}
+	return []byte(strings.Join(list, "\n"))
+}
@@ -0,0 +1,41 @@
+package biz
+
+import (
+	"kscep/internal/conf"
+	"testing"
+)
+
+func TestCACaps(t *testing.T) {
+	caps, err := NewCACaps(&conf.Data{Caps: map[string]*conf.Data_Caps{
+		// CA types are matched case-insensitively like those of ra
+		"ecc": {Caps: []string{"GetNextCACert", "POSTPKIOperation", "SHA-256"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		t      CaType
+		nextCA bool
+		want   string
+	}{
+		{"rsa", RsaCa, false, "AES\nDES3\nPOSTPKIOperation\nRenewal\nSCEPStandard\nSHA-256\nSHA-512"},
+		{"sm2", SM2Ca, false, "POSTPKIOperation\nRenewal\nSM3\nSM4"},
+		{"sm2 with next CA", SM2Ca, true, "GetNextCACert\nPOSTPKIOperation\nRenewal\nSM3\nSM4"},
+		{"ecc override", EccCa, false, "POSTPKIOperation\nSHA-256"},
+		{"ecc override with next CA", EccCa, true, "GetNextCACert\nPOSTPKIOperation\nSHA-256"},
+	} {
+		if got := string(caps.Get(tt.t, tt.nextCA)); got != tt.want {
+			t.Errorf("%s: Get() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, cc := range []map[string]*conf.Data_Caps{
+		{"DSA": {Caps: []string{"AES"}}},
+		{"RSA": {Caps: []string{"AES", "MD5"}}},
+	} {
+		if _, err := NewCACaps(&conf.Data{Caps: cc}); err == nil {
+			t.Errorf("NewCACaps(%v) accepted an invalid override", cc)
+		}
+	}
+}
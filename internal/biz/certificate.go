@@ -0,0 +1,163 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type CertificateStatus string
+
+const (
+	CertificateValid   CertificateStatus = "valid"
+	CertificateRevoked CertificateStatus = "revoked"
+	CertificateExpired CertificateStatus = "expired"
+)
+
+// CertificateFilter selects issued certificates. Zero fields match every
+// certificate.
+type CertificateFilter struct {
+	CommonName    string
+	Serial        *big.Int
+	Status        CertificateStatus
+	ExpiresBefore time.Time
+	// CaType restricts the list to the certificates of one CA type.
+	CaType string
+	// Issuer is the raw subject of the issuing CA, set from CaType.
+	Issuer []byte
+}
+
+// IssuedCertificate is a certificate in the inventory.
+type IssuedCertificate struct {
+	Certificate *x509.Certificate
+	// CaType is empty if the certificate was not issued by a current CA.
+	CaType string
+	Status CertificateStatus
+	// RevokedAt and Reason are only set for revoked certificates.
+	RevokedAt time.Time
+	Reason    int
+}
+
+// CertificateCounts are the number of issued certificates by status and
+// CA type.
+type CertificateCounts struct {
+	Total    int
+	ByStatus map[CertificateStatus]int
+	ByCaType map[string]int
+}
+
+type CertificateRepo interface {
+	// GetCertificate returns the issued certificate with the given serial
+	// number, or CertNotFoundErr.
+	GetCertificate(ctx context.Context, serial *big.Int) (*x509.Certificate, error)
+	// ListCertificates returns the issued certificates matching f.
+	ListCertificates(ctx context.Context, f *CertificateFilter) ([]*IssuedCertificate, error)
+	// CountCertificates returns the number of issued certificates matching
+	// f.
+	CountCertificates(ctx context.Context, f *CertificateFilter) (int, error)
+}
+
+// CertificateUsecase looks up the certificates issued by the CAs.
+type CertificateUsecase struct {
+	repo CertificateRepo
+	ca   *SCEPCAUsecase
+	log  *log.Helper
+}
+
+func NewCertificateUsecase(repo CertificateRepo, ca *SCEPCAUsecase, logger log.Logger) *CertificateUsecase {
+	return &CertificateUsecase{
+		repo: repo,
+		ca:   ca,
+		log:  log.NewHelper(log.With(logger, "module", "usecase/scep/certificate")),
+	}
+}
+
+// Get returns the issued certificate with the given serial number.
+func (uc *CertificateUsecase) Get(ctx context.Context, serial *big.Int) (*x509.Certificate, error) {
+	return uc.repo.GetCertificate(ctx, serial)
+}
+
+// List returns the certificates matching f ordered by serial number,
+// skipping offset of them and returning at most limit, along with the
+// number of matching certificates. A limit of 0 returns all of them.
+func (uc *CertificateUsecase) List(ctx context.Context, f CertificateFilter, offset, limit int) ([]*IssuedCertificate, int, error) {
+	switch f.Status {
+	case "", CertificateValid, CertificateRevoked, CertificateExpired:
+	default:
+		return nil, 0, UnknownCertificateStatusErr
+	}
+	cas := uc.currentCAs()
+	if f.CaType != "" {
+		crt, ok := cas[f.CaType]
+		if !ok {
+			// a CA type without a CA has not issued anything
+			return nil, 0, nil
+		}
+		f.Issuer = crt.RawSubject
+	}
+	certs, err := uc.repo.ListCertificates(ctx, &f)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, c := range certs {
+		c.CaType = caTypeOf(c.Certificate, cas)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].Certificate.SerialNumber.Cmp(certs[j].Certificate.SerialNumber) < 0
+	})
+	total := len(certs)
+	if offset > total {
+		offset = total
+	}
+	certs = certs[offset:]
+	if limit > 0 && limit < len(certs) {
+		certs = certs[:limit]
+	}
+	return certs, total, nil
+}
+
+// Counts counts the issued certificates by status and CA type.
+func (uc *CertificateUsecase) Counts(ctx context.Context) (*CertificateCounts, error) {
+	certs, err := uc.repo.ListCertificates(ctx, &CertificateFilter{})
+	if err != nil {
+		return nil, err
+	}
+	cas := uc.currentCAs()
+	counts := &CertificateCounts{
+		Total:    len(certs),
+		ByStatus: map[CertificateStatus]int{CertificateValid: 0, CertificateRevoked: 0, CertificateExpired: 0},
+		ByCaType: map[string]int{},
+	}
+	for _, c := range certs {
+		counts.ByStatus[c.Status]++
+		if t := caTypeOf(c.Certificate, cas); t != "" {
+			counts.ByCaType[t]++
+		}
+	}
+	return counts, nil
+}
+
+// currentCAs returns the certificates of the configured CAs by type.
+func (uc *CertificateUsecase) currentCAs() map[string]*x509.Certificate {
+	cas := map[string]*x509.Certificate{}
+	for _, t := range []CaType{RsaCa, EccCa, SM2Ca} {
+		if crt, err := uc.ca.GetCACert(t.String()); err == nil && crt != nil {
+			cas[t.String()] = crt
+		}
+	}
+	return cas
+}
+
+func caTypeOf(crt *x509.Certificate, cas map[string]*x509.Certificate) string {
+	for t, ca := range cas {
+		if bytes.Equal(crt.RawIssuer, ca.RawSubject) {
+			return t
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package biz
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"kscep/internal/conf"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// DefaultChallengeTTL is the lifetime of a one-time challenge when neither
+// the request nor conf.Data.Challenge.Ttl specifies one.
+const DefaultChallengeTTL = time.Hour
+
+// Challenge is a one-time enrollment challenge kept in the depot.
+type Challenge struct {
+	Value string
+	// CommonName binds the challenge to the subject CN of the CSR.
+	CommonName string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	Consumed   bool
+}
+
+type ChallengeRepo interface {
+	CreateChallenge(ctx context.Context, ch *Challenge) error
+	GetChallenge(ctx context.Context, challenge string) (*Challenge, error)
+	ConsumeChallenge(ctx context.Context, challenge string) error
+	ReleaseChallenge(ctx context.Context, challenge string) error
+}
+
+// ChallengeRequest is the enrollment a challenge password is checked for.
+type ChallengeRequest struct {
+	Profile   string
+	Challenge string
+	CSR       *x509.CertificateRequest
+}
+
+// ChallengeValidator decides whether a challengePassword is acceptable for
+// an enrollment. Validators are consulted in order and the first one that
+// accepts the request wins.
+type ChallengeValidator interface {
+	ValidateChallenge(ctx context.Context, req *ChallengeRequest) (bool, error)
+}
+
+type ChallengeUsecase struct {
+	repo       ChallengeRepo
+	dynamic    bool
+	ttl        time.Duration
+	validators []ChallengeValidator
+	log        *log.Helper
+}
+
+// NewChallengeUsecase returns a ChallengeUsecase with the validators enabled
+// in conf.Data.Challenge. When no validator is configured challenge checking
+// is disabled.
+func NewChallengeUsecase(c *conf.Data, repo ChallengeRepo, logger log.Logger) *ChallengeUsecase {
+	cc := c.GetChallenge()
+	uc := &ChallengeUsecase{
+		repo:    repo,
+		dynamic: cc.GetDynamic(),
+		ttl:     DefaultChallengeTTL,
+		log:     log.NewHelper(log.With(logger, "module", "usecase/scep/challenge")),
+	}
+	if d := cc.GetTtl().AsDuration(); d > 0 {
+		uc.ttl = d
+	}
+	if cc.GetStatic() != "" || len(cc.GetProfiles()) > 0 {
+		uc.AddValidator(NewSharedSecretValidator(cc.GetStatic(), cc.GetProfiles()))
+	}
+	if uc.dynamic {
+		uc.AddValidator(NewDynamicChallengeValidator(repo))
+	}
+	if !uc.Enabled() {
+		uc.log.Warn("no challenge validator configured, challenge passwords are not checked")
+	}
+	return uc
+}
+
+// AddValidator appends a validator to the chain.
+func (uc *ChallengeUsecase) AddValidator(v ChallengeValidator) {
+	uc.validators = append(uc.validators, v)
+}
+
+// Enabled reports whether any validator is configured.
+func (uc *ChallengeUsecase) Enabled() bool {
+	return len(uc.validators) > 0
+}
+
+// Verify returns nil if one of the validators accepts the request, or if
+// challenge checking is disabled.
+func (uc *ChallengeUsecase) Verify(ctx context.Context, req *ChallengeRequest) error {
+	if !uc.Enabled() {
+		return nil
+	}
+	if req.Challenge == "" {
+		return ChallengeMismatchErr
+	}
+	for _, v := range uc.validators {
+		ok, err := v.ValidateChallenge(ctx, req)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return ChallengeMismatchErr
+}
+
+// Issue mints a one-time challenge valid for ttl (the configured default if
+// zero), optionally bound to the subject CN the device must request.
+func (uc *ChallengeUsecase) Issue(ctx context.Context, ttl time.Duration, cn string) (*Challenge, error) {
+	if !uc.dynamic {
+		return nil, DynamicChallengeDisabledErr
+	}
+	if ttl <= 0 {
+		ttl = uc.ttl
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	ch := &Challenge{
+		Value:      strings.ToUpper(hex.EncodeToString(b)),
+		CommonName: cn,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err := uc.repo.CreateChallenge(ctx, ch); err != nil {
+		return nil, err
+	}
+	uc.log.Infof("issued one-time challenge expiring at %s (cn=%q)", ch.ExpiresAt.Format(time.RFC3339), cn)
+	return ch, nil
+}
+
+// Consume marks a one-time challenge as used before a certificate is issued
+// for it. It fails with ChallengeConsumedErr if a concurrent enrollment got
+// there first. Shared secrets are not affected.
+func (uc *ChallengeUsecase) Consume(ctx context.Context, challenge string) error {
+	if !uc.dynamic || challenge == "" {
+		return nil
+	}
+	err := uc.repo.ConsumeChallenge(ctx, challenge)
+	if errors.Is(err, ChallengeNotFoundErr) {
+		return nil
+	}
+	return err
+}
+
+// Release gives back a one-time challenge consumed by an enrollment that
+// did not yield a certificate.
+func (uc *ChallengeUsecase) Release(ctx context.Context, challenge string) {
+	if !uc.dynamic || challenge == "" {
+		return
+	}
+	err := uc.repo.ReleaseChallenge(ctx, challenge)
+	if err != nil && !errors.Is(err, ChallengeNotFoundErr) {
+		uc.log.Errorf("failed to release challenge: %v", err)
+	}
+}
+
+// SharedSecretValidator accepts the secret configured for the profile, or
+// the static secret if the profile has none.
+type SharedSecretValidator struct {
+	static   string
+	profiles map[string]string
+}
+
+func NewSharedSecretValidator(static string, profiles map[string]string) *SharedSecretValidator {
+	return &SharedSecretValidator{
+		static:   static,
+		profiles: profiles,
+	}
+}
+
+func (v *SharedSecretValidator) ValidateChallenge(_ context.Context, req *ChallengeRequest) (bool, error) {
+	secret, ok := v.profiles[req.Profile]
+	if !ok {
+		secret = v.static
+	}
+	if secret == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(req.Challenge)) == 1, nil
+}
+
+// DynamicChallengeValidator accepts unexpired, unconsumed one-time
+// challenges stored in the depot.
+type DynamicChallengeValidator struct {
+	repo ChallengeRepo
+}
+
+func NewDynamicChallengeValidator(repo ChallengeRepo) *DynamicChallengeValidator {
+	return &DynamicChallengeValidator{repo: repo}
+}
+
+func (v *DynamicChallengeValidator) ValidateChallenge(ctx context.Context, req *ChallengeRequest) (bool, error) {
+	ch, err := v.repo.GetChallenge(ctx, req.Challenge)
+	if errors.Is(err, ChallengeNotFoundErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ch.Consumed || (!ch.ExpiresAt.IsZero() && time.Now().After(ch.ExpiresAt)) {
+		return false, nil
+	}
+	if ch.CommonName != "" && (req.CSR == nil || req.CSR.Subject.CommonName != ch.CommonName) {
+		return false, nil
+	}
+	return true, nil
+}
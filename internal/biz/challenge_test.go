@@ -0,0 +1,157 @@
+package biz
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"kscep/internal/conf"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type memChallengeRepo map[string]*Challenge
+
+func (r memChallengeRepo) CreateChallenge(_ context.Context, ch *Challenge) error {
+	r[ch.Value] = ch
+	return nil
+}
+
+func (r memChallengeRepo) GetChallenge(_ context.Context, challenge string) (*Challenge, error) {
+	ch, ok := r[challenge]
+	if !ok {
+		return nil, ChallengeNotFoundErr
+	}
+	return ch, nil
+}
+
+func (r memChallengeRepo) ConsumeChallenge(_ context.Context, challenge string) error {
+	ch, ok := r[challenge]
+	if !ok {
+		return ChallengeNotFoundErr
+	}
+	if ch.Consumed {
+		return ChallengeConsumedErr
+	}
+	ch.Consumed = true
+	return nil
+}
+
+func (r memChallengeRepo) ReleaseChallenge(_ context.Context, challenge string) error {
+	ch, ok := r[challenge]
+	if !ok {
+		return ChallengeNotFoundErr
+	}
+	ch.Consumed = false
+	return nil
+}
+
+func TestChallengeUsecase_Verify(t *testing.T) {
+	repo := memChallengeRepo{
+		"one-time": {ExpiresAt: time.Now().Add(time.Hour)},
+		"expired":  {ExpiresAt: time.Now().Add(-time.Hour)},
+		"consumed": {ExpiresAt: time.Now().Add(time.Hour), Consumed: true},
+		"bound":    {ExpiresAt: time.Now().Add(time.Hour), CommonName: "device-1"},
+	}
+	uc := NewChallengeUsecase(&conf.Data{
+		Challenge: &conf.Data_Challenge{
+			Static:   "static",
+			Profiles: map[string]string{"vpn": "vpn-secret"},
+			Dynamic:  true,
+		},
+	}, repo, log.DefaultLogger)
+
+	tests := []struct {
+		name      string
+		profile   string
+		challenge string
+		cn        string
+		wantErr   bool
+	}{
+		{"static secret", "", "static", "", false},
+		{"static secret on profile without secret", "wifi", "static", "", false},
+		{"profile secret overrides static", "vpn", "static", "", true},
+		{"profile secret", "vpn", "vpn-secret", "", false},
+		{"empty challenge", "", "", "", true},
+		{"wrong challenge", "", "wrong", "", true},
+		{"expired dynamic challenge", "", "expired", "", true},
+		{"consumed dynamic challenge", "", "consumed", "", true},
+		{"dynamic challenge", "", "one-time", "", false},
+		{"bound dynamic challenge", "", "bound", "device-1", false},
+		{"bound dynamic challenge with other cn", "", "bound", "device-2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := uc.Verify(context.Background(), &ChallengeRequest{
+				Profile:   tt.profile,
+				Challenge: tt.challenge,
+				CSR:       &x509.CertificateRequest{Subject: pkix.Name{CommonName: tt.cn}},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChallengeUsecase_Disabled(t *testing.T) {
+	uc := NewChallengeUsecase(&conf.Data{}, memChallengeRepo{}, log.DefaultLogger)
+	if uc.Enabled() {
+		t.Fatalf("Enabled() = true without configured validators")
+	}
+	if err := uc.Verify(context.Background(), &ChallengeRequest{}); err != nil {
+		t.Fatalf("Verify() error = %v, want nil when disabled", err)
+	}
+	if _, err := uc.Issue(context.Background(), 0, ""); err != DynamicChallengeDisabledErr {
+		t.Fatalf("Issue() error = %v, want %v", err, DynamicChallengeDisabledErr)
+	}
+}
+
+func TestChallengeUsecase_IssueAndConsume(t *testing.T) {
+	repo := memChallengeRepo{}
+	uc := NewChallengeUsecase(&conf.Data{
+		Challenge: &conf.Data_Challenge{Dynamic: true},
+	}, repo, log.DefaultLogger)
+
+	ch, err := uc.Issue(context.Background(), 0, "device-1")
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if len(ch.Value) != 32 {
+		t.Fatalf("Issue() challenge = %q, want 32 hex characters", ch.Value)
+	}
+	if ttl := ch.ExpiresAt.Sub(ch.CreatedAt); ttl != DefaultChallengeTTL {
+		t.Fatalf("Issue() ttl = %v, want %v", ttl, DefaultChallengeTTL)
+	}
+
+	req := &ChallengeRequest{
+		Challenge: ch.Value,
+		CSR:       &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}},
+	}
+	// validation alone does not use up the challenge
+	for i := 0; i < 2; i++ {
+		if err := uc.Verify(context.Background(), req); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+	}
+	if err := uc.Consume(context.Background(), ch.Value); err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if err := uc.Verify(context.Background(), req); err != ChallengeMismatchErr {
+		t.Fatalf("Verify() error = %v after Consume(), want %v", err, ChallengeMismatchErr)
+	}
+	// a concurrent enrollment cannot consume it a second time
+	if err := uc.Consume(context.Background(), ch.Value); err != ChallengeConsumedErr {
+		t.Fatalf("Consume() error = %v, want %v", err, ChallengeConsumedErr)
+	}
+	// an enrollment that failed gives it back
+	uc.Release(context.Background(), ch.Value)
+	if err := uc.Verify(context.Background(), req); err != nil {
+		t.Fatalf("Verify() error = %v after Release()", err)
+	}
+	// shared secrets are not stored, consuming them is a no-op
+	if err := uc.Consume(context.Background(), "static"); err != nil {
+		t.Fatalf("Consume() error = %v for unknown challenge", err)
+	}
+}
@@ -4,17 +4,32 @@ import "errors"
 
 const MaxPayloadSize = 2 << 20
 
-var DefaultCaps = []byte("Renewal\nSHA-1\nSHA-256\nAES\nDES3\nSCEPStandard\nPOSTPKIOperation")
-var GBT0089Caps = []byte("Renewal\nGetNextCACert\nPOSTPKIOperation\nSM3\nSM4")
-
 var (
-	UnsupportedCaTypeErr    = errors.New("unsupported CA type")
-	SupportedCaTypes        = []string{"RSA", "ECC", "SM2", ""}
-	MissingCaCertErr        = errors.New("missing CA certificate")
-	UnsupportedOperationErr = errors.New("unsupported operation")
-	MissingOperationErr     = errors.New("missing operation")
-	MissingMessageErr       = errors.New("missing message")
-	DepotConfigErr          = errors.New("depot config error")
+	UnsupportedCaTypeErr        = errors.New("unsupported CA type")
+	SupportedCaTypes            = []string{"RSA", "ECC", "SM2", ""}
+	MissingCaCertErr            = errors.New("missing CA certificate")
+	CAKeyMismatchErr            = errors.New("CA key does not match the CA certificate")
+	MissingNextCaCertErr        = errors.New("no next CA certificate staged")
+	MissingRaErr                = errors.New("no RA configured for the CA")
+	UnsupportedOperationErr     = errors.New("unsupported operation")
+	MissingOperationErr         = errors.New("missing operation")
+	MissingMessageErr           = errors.New("missing message")
+	DepotConfigErr              = errors.New("depot config error")
+	ChallengeMismatchErr        = errors.New("challenge password mismatch")
+	ChallengeNotFoundErr        = errors.New("challenge not found")
+	ChallengeConsumedErr        = errors.New("challenge already consumed")
+	DynamicChallengeDisabledErr = errors.New("dynamic challenges are disabled")
+	PendingNotFoundErr          = errors.New("pending request not found")
+	PendingNotPendingErr        = errors.New("request is no longer pending")
+	UnknownProfileErr           = errors.New("unknown certificate profile")
+	SANNotAllowedErr            = errors.New("SAN type not allowed by the certificate profile")
+	UnknownRecipientErr         = errors.New("pkiMessage is not encrypted to a configured CA")
+	CertNotFoundErr             = errors.New("certificate not found")
+	AlreadyRevokedErr           = errors.New("certificate already revoked")
+	InvalidRevocationReasonErr  = errors.New("invalid revocation reason")
+	UnknownCertificateStatusErr = errors.New("unknown certificate status")
+	NonceReusedErr              = errors.New("sender nonce already used")
+	TransactionNotFoundErr      = errors.New("transaction not found")
 )
 
 type CaType int
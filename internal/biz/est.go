@@ -0,0 +1,291 @@
+package biz
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"kscep/internal/utils"
+	"strings"
+
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+	"github.com/ploynomail/scep/cryptoutil"
+)
+
+var (
+	ESTAuthErr        = errors.New("EST client not authenticated")
+	ESTPendingErr     = errors.New("enrollment pending manual approval")
+	ESTTLSRequiredErr = errors.New("EST enrollment requires TLS")
+)
+
+// CSR signature algorithms asked for by csrattrs, by CA type
+var (
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSM2WithSM3      = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 501}
+)
+
+// ESTAuth is what an EST client authenticated with.
+type ESTAuth struct {
+	// Password is the HTTP basic auth password, checked as a SCEP
+	// challengePassword would be.
+	Password string
+	// ClientCert is the TLS client certificate, if any.
+	ClientCert *x509.Certificate
+}
+
+// ESTUsecase serves the EST (RFC 7030) operations. EST clients send bare
+// PKCS#10 CSRs over HTTPS, which go through the challenge, profile, policy,
+// approval and signer of SCEP enrollments, with the default profile.
+//
+// The optional EST label names the CA type, RSA if there is none.
+type ESTUsecase struct {
+	scep *SCEPUsecase
+	log  *log.Helper
+}
+
+func NewESTUsecase(scep *SCEPUsecase, logger log.Logger) *ESTUsecase {
+	return &ESTUsecase{
+		scep: scep,
+		log:  log.NewHelper(log.With(logger, "module", "usecase/est")),
+	}
+}
+
+// estCaType returns the CA type an EST label names.
+func estCaType(label string) (CaType, error) {
+	t := strings.ToUpper(label)
+	if !utils.IsInArray(SupportedCaTypes, t) {
+		return RsaCa, UnsupportedCaTypeErr
+	}
+	return GetCaType(t), nil
+}
+
+// CACerts returns the certs-only SignedData of the CA and its chain, see
+// RFC 7030 4.1.
+func (uc *ESTUsecase) CACerts(ctx context.Context, label string) ([]byte, error) {
+	t, err := estCaType(label)
+	if err != nil {
+		return nil, err
+	}
+	crt, err := uc.scep.caUsecase.GetCACert(t.String())
+	if err != nil || crt == nil {
+		uc.log.Errorf("failed to get CA cert: %v", err)
+		return nil, MissingCaCertErr
+	}
+	chain, err := uc.scep.caUsecase.GetCAChain(crt)
+	if err != nil {
+		return nil, err
+	}
+	return degenerateCertificates(append([]*x509.Certificate{crt}, chain...))
+}
+
+// CSRAttrs returns the CSR attributes of RFC 7030 4.5, the signature
+// algorithm the CA signs with.
+func (uc *ESTUsecase) CSRAttrs(ctx context.Context, label string) ([]byte, error) {
+	t, err := estCaType(label)
+	if err != nil {
+		return nil, err
+	}
+	oid := oidSHA256WithRSA
+	switch t {
+	case EccCa:
+		oid = oidECDSAWithSHA256
+	case SM2Ca:
+		oid = oidSM2WithSM3
+	}
+	return asn1.Marshal([]asn1.ObjectIdentifier{oid})
+}
+
+// SimpleEnroll issues a certificate for the DER CSR and returns it in a
+// certs-only SignedData, see RFC 7030 4.2.1. The client authenticates with
+// a challenge password, or with a valid certificate issued by the CA for
+// the subject and names it asks for again. Without a challenge configured
+// enrollment is open, as it is for SCEP.
+//
+// With manual approval the CSR is parked and ESTPendingErr returned until
+// an operator decides, the client retries with the same CSR.
+func (uc *ESTUsecase) SimpleEnroll(ctx context.Context, label string, der []byte, auth *ESTAuth) ([]byte, error) {
+	crt, err := uc.enroll(ctx, label, der, auth, false)
+	if err != nil {
+		return nil, err
+	}
+	return degenerateCertificates([]*x509.Certificate{crt})
+}
+
+// SimpleReenroll renews the TLS client certificate of auth, see RFC 7030
+// 4.2.2. The certificate is checked as the signer of a SCEP RenewalReq and
+// revoked as superseded once the new one is issued.
+func (uc *ESTUsecase) SimpleReenroll(ctx context.Context, label string, der []byte, auth *ESTAuth) ([]byte, error) {
+	crt, err := uc.enroll(ctx, label, der, auth, true)
+	if err != nil {
+		return nil, err
+	}
+	return degenerateCertificates([]*x509.Certificate{crt})
+}
+
+func (uc *ESTUsecase) enroll(ctx context.Context, label string, der []byte, auth *ESTAuth, renewal bool) (*x509.Certificate, error) {
+	svc := uc.scep
+	t, err := estCaType(label)
+	if err != nil {
+		return nil, err
+	}
+	caCrt, err := svc.caUsecase.GetCACert(t.String())
+	if err != nil || caCrt == nil {
+		uc.log.Errorf("failed to get CA cert: %v", err)
+		return nil, MissingCaCertErr
+	}
+	// smx509 reads SM2 CSRs as well
+	smCSR, err := smx509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, badRequest("parse CSR: %v", err)
+	}
+	if err := smCSR.CheckSignature(); err != nil {
+		return nil, badRequest("CSR signature: %v", err)
+	}
+	csr := smCSR.ToX509()
+
+	// the pending request is found by the public key, as SCEP clients
+	// derive their transactionID from it
+	id, err := cryptoutil.GenerateSubjectKeyID(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	transactionID := base64.StdEncoding.EncodeToString(id)
+	parking := svc.approval.Manual() && !renewal
+	if parking {
+		pr, err := svc.approval.Lookup(ctx, transactionID)
+		if err == nil {
+			return pendingCertificate(pr)
+		}
+		if !errors.Is(err, PendingNotFoundErr) {
+			return nil, err
+		}
+	}
+
+	challenge := ""
+	if renewal {
+		if auth.ClientCert == nil {
+			return nil, ESTAuthErr
+		}
+		if err := svc.checkRenewal(ctx, auth.ClientCert, csr, caCrt); err != nil {
+			return nil, err
+		}
+	} else {
+		if challenge, err = uc.authenticate(ctx, auth, csr, caCrt); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := svc.signer.Profile("")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.CheckSANs(csr); err != nil {
+		return nil, badRequest("%v", err)
+	}
+	if err := svc.policy.Evaluate(csr); err != nil {
+		return nil, err
+	}
+
+	// as for SCEP, the challenge is used up before signing
+	if err := svc.challenge.Consume(ctx, challenge); err != nil {
+		if errors.Is(err, ChallengeConsumedErr) {
+			return nil, fmt.Errorf("%w: %v", ESTAuthErr, err)
+		}
+		return nil, err
+	}
+
+	if parking {
+		if _, err := svc.approval.Park(ctx, transactionID, "", t, csr); err != nil {
+			svc.challenge.Release(ctx, challenge)
+			return nil, err
+		}
+		return nil, ESTPendingErr
+	}
+
+	crt, err := svc.signer.SignCSR(ctx, t, "", &scep.CSRReqMessage{RawDecrypted: der, CSR: csr})
+	if err == nil && crt == nil {
+		err = errors.New("no signed certificate")
+	}
+	if err != nil {
+		svc.challenge.Release(ctx, challenge)
+		return nil, err
+	}
+	if renewal {
+		err := svc.revocation.Revoke(ctx, auth.ClientCert.SerialNumber, "superseded")
+		if err != nil && !errors.Is(err, AlreadyRevokedErr) {
+			uc.log.Errorf("failed to revoke renewed certificate %s: %v", auth.ClientCert.SerialNumber.Text(16), err)
+		}
+	}
+	return crt, nil
+}
+
+// authenticate accepts a client certificate issued by caCrt for the subject
+// and names of csr, or else the basic auth password as challenge password,
+// which it returns to be consumed. A certificate for another identity does
+// not vouch for csr, or any enrolled device could enroll any subject.
+func (uc *ESTUsecase) authenticate(ctx context.Context, auth *ESTAuth, csr *x509.CertificateRequest, caCrt *x509.Certificate) (string, error) {
+	if auth.ClientCert != nil {
+		err := uc.scep.checkIssued(ctx, auth.ClientCert, caCrt)
+		if err == nil {
+			err = checkSameIdentity(csr, auth.ClientCert)
+		}
+		if err == nil {
+			return "", nil
+		}
+		uc.log.Warnf("ignoring EST client certificate: %v", err)
+	}
+	err := uc.scep.challenge.Verify(ctx, &ChallengeRequest{Challenge: auth.Password, CSR: csr})
+	if err != nil {
+		uc.log.Warnf("EST authentication failed for %q: %v", csr.Subject.CommonName, err)
+		return "", fmt.Errorf("%w: %v", ESTAuthErr, err)
+	}
+	return auth.Password, nil
+}
+
+// checkSameIdentity fails unless csr asks for the subject of crt and for no
+// subject alternative name that crt lacks.
+func checkSameIdentity(csr *x509.CertificateRequest, crt *x509.Certificate) error {
+	if csr.Subject.String() != crt.Subject.String() {
+		return fmt.Errorf("CSR subject %q is not the client certificate subject %q", csr.Subject, crt.Subject)
+	}
+	var names, allowed []string
+	names = append(names, csr.DNSNames...)
+	names = append(names, csr.EmailAddresses...)
+	allowed = append(allowed, crt.DNSNames...)
+	allowed = append(allowed, crt.EmailAddresses...)
+	for _, ip := range csr.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, ip := range crt.IPAddresses {
+		allowed = append(allowed, ip.String())
+	}
+	for _, u := range csr.URIs {
+		names = append(names, u.String())
+	}
+	for _, u := range crt.URIs {
+		allowed = append(allowed, u.String())
+	}
+	for _, name := range names {
+		if !utils.IsInArray(allowed, name) {
+			return fmt.Errorf("CSR name %q is not in the client certificate", name)
+		}
+	}
+	return nil
+}
+
+// pendingCertificate is the outcome of a parked EST enrollment.
+func pendingCertificate(pr *PendingRequest) (*x509.Certificate, error) {
+	switch pr.Status {
+	case StatusIssued:
+		return pr.Certificate, nil
+	case StatusRejected:
+		return nil, badRequest("enrollment %s was rejected: %s", pr.ID, pr.Reason)
+	default:
+		return nil, ESTPendingErr
+	}
+}
@@ -0,0 +1,138 @@
+package biz
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+
+	"github.com/emmansun/gmsm/pkcs"
+	smpkcs7 "github.com/emmansun/gmsm/pkcs7"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/ploynomail/pkcs7"
+)
+
+// GM/T 0089 clients sign their pkiMessages with SM2 and SM3 in a GM/T 0010
+// SignedData and envelope the CSR with SM4 to the SM2 CA. Neither the pkcs7
+// package nor crypto/x509 handle SM2, these messages and certificates go
+// through the gmsm packages instead.
+
+// gmSignedData reports whether data is a GM/T 0010 SignedData, or a
+// SignedData digested with SM3.
+func gmSignedData(data []byte) bool {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return false
+	}
+	if ci.ContentType.Equal(smpkcs7.SM2OIDSignedData) {
+		return true
+	}
+	if !ci.ContentType.Equal(pkcs7.OIDSignedData) {
+		return false
+	}
+	var sd struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	}
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return false
+	}
+	for _, alg := range sd.DigestAlgorithms {
+		if alg.Algorithm.Equal(smpkcs7.OIDDigestAlgorithmSM3) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGMRequest is parsePKIRequest for GM/T 0089 pkiMessages.
+func parseGMRequest(data []byte) (*pkiRequest, error) {
+	p7, err := smpkcs7.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, err
+	}
+	req := &pkiRequest{envelope: p7.Content, gm: true}
+	if err := req.readAttributes(p7); err != nil {
+		return nil, err
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return nil, errors.New("pkiMessage has no single signer certificate")
+	}
+	req.Signer = signer.ToX509()
+	for _, crt := range p7.Certificates {
+		req.certs = append(req.certs, crt.ToX509())
+	}
+	return req, nil
+}
+
+// gmDecrypt opens an SM4 pkcsPKIEnvelope encrypted to crt.
+func gmDecrypt(envelope []byte, crt *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	p7, err := smpkcs7.Parse(envelope)
+	if err != nil {
+		return nil, err
+	}
+	return p7.Decrypt((*smx509.Certificate)(crt), key)
+}
+
+// gmReply signs a GM/T 0010 CertRep with SM2 and SM3. deg, if any, is
+// enveloped with SM4 to recipients.
+func gmReply(crtAuth *x509.Certificate, keyAuth crypto.Signer, attrs []pkcs7.Attribute, deg []byte, recipients, certs []*x509.Certificate) ([]byte, error) {
+	var content []byte
+	if deg != nil {
+		var err error
+		content, err = smpkcs7.EncryptSM(pkcs.SM4CBC, deg, smCertificates(recipients))
+		if err != nil {
+			return nil, err
+		}
+	}
+	sd, err := smpkcs7.NewSMSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, crt := range certs {
+		sd.AddCertificate((*smx509.Certificate)(crt))
+	}
+	smAttrs := make([]smpkcs7.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		smAttrs = append(smAttrs, smpkcs7.Attribute{Type: attr.Type, Value: attr.Value})
+	}
+	if err := sd.AddSigner((*smx509.Certificate)(crtAuth), keyAuth, smpkcs7.SignerInfoConfig{ExtraSignedAttributes: smAttrs}); err != nil {
+		return nil, err
+	}
+	return sd.Finish()
+}
+
+// gmSignCertificates is the certs-only SignedData of GetNextCACert signed
+// by an SM2 CA.
+func gmSignCertificates(crtAuth *x509.Certificate, keyAuth crypto.Signer, certs ...*x509.Certificate) ([]byte, error) {
+	sd, err := smpkcs7.NewSMSignedData(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, crt := range certs {
+		sd.AddCertificate((*smx509.Certificate)(crt))
+	}
+	if err := sd.AddSigner((*smx509.Certificate)(crtAuth), keyAuth, smpkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	return sd.Finish()
+}
+
+func smCertificates(certs []*x509.Certificate) []*smx509.Certificate {
+	smCerts := make([]*smx509.Certificate, 0, len(certs))
+	for _, crt := range certs {
+		smCerts = append(smCerts, (*smx509.Certificate)(crt))
+	}
+	return smCerts
+}
+
+// checkSignatureFrom is x509.Certificate.CheckSignatureFrom, SM2 signatures
+// included.
+func checkSignatureFrom(crt, parent *x509.Certificate) error {
+	return (*smx509.Certificate)(crt).CheckSignatureFrom((*smx509.Certificate)(parent))
+}
@@ -0,0 +1,195 @@
+package biz
+
+import (
+	"context"
+	"kscep/internal/conf"
+	"strconv"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// DefaultExpiringDays is the window of kscep_certificates_expiring when
+// metrics.expiring_days is not set.
+const DefaultExpiringDays = 30
+
+// OperationPhase is a timed phase of a PKIOperation.
+type OperationPhase string
+
+const (
+	// PhaseDecrypt opens the pkcsPKIEnvelope of the request.
+	PhaseDecrypt OperationPhase = "decrypt"
+	// PhaseSign signs the issued certificate.
+	PhaseSign OperationPhase = "sign"
+	// PhaseDepotWrite stores the issued certificate in the depot.
+	PhaseDepotWrite OperationPhase = "depot_write"
+)
+
+// scepOperations are the operation label values, anything else a client
+// sends is counted as "unknown".
+var scepOperations = []string{"GetCACaps", "GetCACert", "GetNextCACert", "PKIOperation"}
+
+var (
+	messageTypeLabels = map[scep.MessageType]string{
+		scep.PKCSReq:    "PKCSReq",
+		scep.RenewalReq: "RenewalReq",
+		scep.UpdateReq:  "UpdateReq",
+		scep.CertPoll:   "CertPoll",
+		scep.GetCert:    "GetCert",
+		scep.GetCRL:     "GetCRL",
+	}
+	failInfoLabels = map[scep.FailInfo]string{
+		scep.BadAlg:          "badAlg",
+		scep.BadMessageCheck: "badMessageCheck",
+		scep.BadRequest:      "badRequest",
+		scep.BadTime:         "badTime",
+		scep.BadCertID:       "badCertID",
+	}
+)
+
+// Metrics are the Prometheus metrics of kscep. They are kept in a registry
+// of their own rather than the global one.
+type Metrics struct {
+	registry   *prometheus.Registry
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	operations *prometheus.CounterVec
+	phases     *prometheus.HistogramVec
+}
+
+// NewMetrics registers the SCEP metrics, and the certificate expiry gauges
+// read from the depot and the CAs on every scrape.
+func NewMetrics(c *conf.Server, certs *CertificateUsecase, logger log.Logger) *Metrics {
+	days := int(c.GetMetrics().GetExpiringDays())
+	if days <= 0 {
+		days = DefaultExpiringDays
+	}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kscep_scep_requests_total",
+			Help: "SCEP HTTP requests by operation and status code.",
+		}, []string{"operation", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kscep_scep_request_duration_seconds",
+			Help:    "Duration of SCEP HTTP requests by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "kscep_scep_pki_operations_total",
+			Help: "PKIOperation messages by message type and outcome: success, failure with its failInfo, pending, or error when no CertRep could be sent.",
+		}, []string{"message_type", "outcome", "fail_info"}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "kscep_scep_pki_operation_phase_seconds",
+			Help:    "Duration of the decrypt, sign and depot_write phases of PKIOperation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"phase"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.operations, m.phases,
+		&expiryCollector{
+			certs: certs,
+			days:  days,
+			log:   log.NewHelper(log.With(logger, "module", "usecase/metrics")),
+		},
+	)
+	return m
+}
+
+// Gatherer returns the registry of the metrics, to be served.
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.registry
+}
+
+// ObserveRequest counts a SCEP HTTP request for operation that was answered
+// with code after d.
+func (m *Metrics) ObserveRequest(operation string, code int, d time.Duration) {
+	known := false
+	for _, op := range scepOperations {
+		if op == operation {
+			known = true
+			break
+		}
+	}
+	if !known {
+		operation = "unknown"
+	}
+	m.requests.WithLabelValues(operation, strconv.Itoa(code)).Inc()
+	m.duration.WithLabelValues(operation).Observe(d.Seconds())
+}
+
+// ObservePhase records the duration of phase, which began at start.
+func (m *Metrics) ObservePhase(phase OperationPhase, start time.Time) {
+	m.phases.WithLabelValues(string(phase)).Observe(time.Since(start).Seconds())
+}
+
+// observePKIOperation counts the outcome of a PKIOperation. req is nil if
+// the pkiMessage could not be read, err set if no CertRep was built.
+func (m *Metrics) observePKIOperation(req *pkiRequest, err error) {
+	messageType := "unknown"
+	if req != nil {
+		if l, ok := messageTypeLabels[req.MessageType]; ok {
+			messageType = l
+		}
+	}
+	outcome, failInfo := "error", ""
+	if err == nil && req != nil {
+		switch req.status {
+		case scep.SUCCESS:
+			outcome = "success"
+		case scep.PENDING:
+			outcome = "pending"
+		case scep.FAILURE:
+			outcome, failInfo = "failure", failInfoLabels[req.failInfo]
+		}
+	}
+	m.operations.WithLabelValues(messageType, outcome, failInfo).Inc()
+}
+
+// expiryCollector reports the certificates about to expire and the expiry
+// of the CA certificates when scraped.
+type expiryCollector struct {
+	certs *CertificateUsecase
+	days  int
+	log   *log.Helper
+}
+
+var (
+	expiringDesc = prometheus.NewDesc("kscep_certificates_expiring",
+		"Valid issued certificates expiring within metrics.expiring_days, by CA type.",
+		[]string{"ca_type"}, nil)
+	caExpiryDesc = prometheus.NewDesc("kscep_ca_certificate_expiry_timestamp_seconds",
+		"Expiry of the CA certificate of each CA type as a Unix timestamp.",
+		[]string{"ca_type"}, nil)
+)
+
+func (c *expiryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- expiringDesc
+	ch <- caExpiryDesc
+}
+
+func (c *expiryCollector) Collect(ch chan<- prometheus.Metric) {
+	cas := c.certs.currentCAs()
+	for t, crt := range cas {
+		ch <- prometheus.MustNewConstMetric(caExpiryDesc, prometheus.GaugeValue, float64(crt.NotAfter.Unix()), t)
+	}
+	expiresBefore := time.Now().AddDate(0, 0, c.days)
+	for t, crt := range cas {
+		n, err := c.certs.repo.CountCertificates(context.Background(), &CertificateFilter{
+			Status:        CertificateValid,
+			ExpiresBefore: expiresBefore,
+			Issuer:        crt.RawSubject,
+		})
+		if err != nil {
+			c.log.Errorf("failed to count expiring certificates: %v", err)
+			ch <- prometheus.NewInvalidMetric(expiringDesc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(expiringDesc, prometheus.GaugeValue, float64(n), t)
+	}
+}
@@ -0,0 +1,434 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"kscep/internal/conf"
+	"kscep/internal/utils"
+	"sync"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/go-kratos/kratos/v2/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+// DefaultOCSPNextUpdate is the response lifetime used when
+// ocsp.next_update is not configured.
+const DefaultOCSPNextUpdate = time.Hour
+
+// maxOCSPNonceSize is the nonce limit of RFC 8954.
+const maxOCSPNonceSize = 32
+
+var oidOCSPNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+
+// ocspRequest is the outer structure of an OCSPRequest, see RFC 6960 4.1.1.
+// ocsp.ParseRequest drops the request extensions, the nonce is read from
+// here.
+type ocspRequest struct {
+	TBSRequest struct {
+		Version           int           `asn1:"explicit,tag:0,default:0,optional"`
+		RequestorName     asn1.RawValue `asn1:"explicit,tag:1,optional"`
+		RequestList       []asn1.RawValue
+		RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response struct {
+		ResponseType asn1.ObjectIdentifier
+		Response     []byte
+	} `asn1:"explicit,tag:0,optional"`
+}
+
+type basicOCSPResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// ocspSignatureHashes are the hashes of the signature algorithms
+// ocsp.CreateResponse signs with.
+var ocspSignatureHashes = map[string]crypto.Hash{
+	"1.2.840.113549.1.1.5":  crypto.SHA1,
+	"1.2.840.113549.1.1.11": crypto.SHA256,
+	"1.2.840.113549.1.1.12": crypto.SHA384,
+	"1.2.840.113549.1.1.13": crypto.SHA512,
+	"1.2.840.10045.4.1":     crypto.SHA1,
+	"1.2.840.10045.4.3.2":   crypto.SHA256,
+	"1.2.840.10045.4.3.3":   crypto.SHA384,
+	"1.2.840.10045.4.3.4":   crypto.SHA512,
+}
+
+// ocspResponder signs the responses for one CA type. cert is nil when the
+// CA signs them itself.
+type ocspResponder struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// ocspCacheKey identifies a response by the CertID it answers.
+type ocspCacheKey struct {
+	issuerKeyHash string
+	hash          crypto.Hash
+	serial        string
+}
+
+// ocspCacheEntry is a signed response and the status it was signed for.
+type ocspCacheEntry struct {
+	der        []byte
+	status     int
+	revokedAt  time.Time
+	reason     int
+	thisUpdate time.Time
+}
+
+type OCSPUsecase struct {
+	ca          *SCEPCAUsecase
+	certs       CertificateRepo
+	revocations RevocationRepo
+	// responders are the delegated responders by CA type.
+	responders map[CaType]*ocspResponder
+	nextUpdate time.Duration
+
+	mu    sync.Mutex
+	cache map[ocspCacheKey]*ocspCacheEntry
+
+	log *log.Helper
+}
+
+func NewOCSPUsecase(c *conf.Data, ca *SCEPCAUsecase, certs CertificateRepo, revocations RevocationRepo, logger log.Logger) (*OCSPUsecase, error) {
+	nextUpdate := c.GetOcsp().GetNextUpdate().AsDuration()
+	if nextUpdate <= 0 {
+		nextUpdate = DefaultOCSPNextUpdate
+	}
+	uc := &OCSPUsecase{
+		ca:          ca,
+		certs:       certs,
+		revocations: revocations,
+		responders:  map[CaType]*ocspResponder{},
+		nextUpdate:  nextUpdate,
+		cache:       map[ocspCacheKey]*ocspCacheEntry{},
+		log:         log.NewHelper(log.With(logger, "module", "usecase/scep/ocsp")),
+	}
+	for caType, r := range c.GetOcsp().GetResponders() {
+		if caType == "" || !utils.IsInArray(SupportedCaTypes, caType) {
+			return nil, fmt.Errorf("ocsp responder: %w: %q", UnsupportedCaTypeErr, caType)
+		}
+		crt, err := utils.LoadPEMCertFromFile(r.GetCert())
+		if err != nil {
+			return nil, fmt.Errorf("ocsp responder %s: %w", caType, err)
+		}
+		key, err := utils.LoadPEMKeyFromFile(r.GetKey())
+		if err != nil {
+			return nil, fmt.Errorf("ocsp responder %s: %w", caType, err)
+		}
+		if !hasExtKeyUsage(crt, x509.ExtKeyUsageOCSPSigning) {
+			return nil, fmt.Errorf("ocsp responder %s: certificate lacks the ocsp_signing extended key usage", caType)
+		}
+		uc.responders[GetCaType(caType)] = &ocspResponder{cert: crt, key: key}
+	}
+	return uc, nil
+}
+
+func hasExtKeyUsage(crt *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range crt.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// Respond answers the DER OCSPRequest req with a DER OCSPResponse. Only the
+// first certificate of the request list is answered. Responses without a
+// nonce are cached until the status of the certificate changes or half of
+// their lifetime has passed.
+func (uc *OCSPUsecase) Respond(ctx context.Context, req []byte) []byte {
+	r, err := ocsp.ParseRequest(req)
+	if err != nil {
+		uc.log.Warnf("malformed OCSP request: %v", err)
+		return ocsp.MalformedRequestErrorResponse
+	}
+	nonce, err := ocspNonce(req)
+	if err != nil {
+		uc.log.Warnf("malformed OCSP request: %v", err)
+		return ocsp.MalformedRequestErrorResponse
+	}
+	t, caCrt, ok := uc.issuer(r)
+	if !ok {
+		return ocsp.UnauthorizedErrorResponse
+	}
+	resp, err := uc.respond(ctx, r, nonce, t, caCrt)
+	if err != nil {
+		uc.log.Errorf("failed to answer OCSP request for %s: %v", r.SerialNumber.Text(16), err)
+		return ocsp.InternalErrorErrorResponse
+	}
+	return resp
+}
+
+func (uc *OCSPUsecase) respond(ctx context.Context, r *ocsp.Request, nonce *pkix.Extension, t CaType, caCrt *x509.Certificate) ([]byte, error) {
+	tmpl := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: r.SerialNumber,
+		IssuerHash:   r.HashAlgorithm,
+	}
+	crt, err := uc.certs.GetCertificate(ctx, r.SerialNumber)
+	switch {
+	case errors.Is(err, CertNotFoundErr):
+		tmpl.Status = ocsp.Unknown
+	case err != nil:
+		return nil, err
+	case !bytes.Equal(crt.RawIssuer, caCrt.RawSubject):
+		// same serial number, but issued by another CA
+		tmpl.Status = ocsp.Unknown
+	default:
+		rev, err := uc.revocations.GetRevocation(ctx, r.SerialNumber)
+		if err != nil {
+			return nil, err
+		}
+		if rev != nil {
+			tmpl.Status = ocsp.Revoked
+			tmpl.RevokedAt = rev.RevokedAt
+			tmpl.RevocationReason = rev.Reason
+		}
+	}
+
+	key := ocspCacheKey{
+		issuerKeyHash: string(r.IssuerKeyHash),
+		hash:          r.HashAlgorithm,
+		serial:        r.SerialNumber.Text(16),
+	}
+	// unknown serial numbers are not cached, they are unbounded
+	cacheable := nonce == nil && tmpl.Status != ocsp.Unknown
+	now := time.Now()
+	if cacheable {
+		if der := uc.cached(key, &tmpl, now); der != nil {
+			return der, nil
+		}
+	}
+
+	responder, err := uc.responder(t, caCrt)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ThisUpdate = now
+	tmpl.NextUpdate = now.Add(uc.nextUpdate)
+	tmpl.Certificate = responder.cert
+	responderCrt := caCrt
+	if responder.cert != nil {
+		responderCrt = responder.cert
+	}
+	var exts []pkix.Extension
+	if nonce != nil {
+		exts = append(exts, *nonce)
+	}
+	der, err := createOCSPResponse(caCrt, responderCrt, tmpl, responder.key, exts)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != nil {
+		return der, nil
+	}
+	if cacheable {
+		uc.mu.Lock()
+		uc.cache[key] = &ocspCacheEntry{
+			der:        der,
+			status:     tmpl.Status,
+			revokedAt:  tmpl.RevokedAt,
+			reason:     tmpl.RevocationReason,
+			thisUpdate: now,
+		}
+		uc.mu.Unlock()
+	}
+	return der, nil
+}
+
+// cached returns the cached response for key if it was signed for the
+// status in tmpl and is still fresh.
+func (uc *OCSPUsecase) cached(key ocspCacheKey, tmpl *ocsp.Response, now time.Time) []byte {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	e, ok := uc.cache[key]
+	if !ok {
+		return nil
+	}
+	if e.status != tmpl.Status || !e.revokedAt.Equal(tmpl.RevokedAt) || e.reason != tmpl.RevocationReason ||
+		now.After(e.thisUpdate.Add(uc.nextUpdate/2)) {
+		delete(uc.cache, key)
+		return nil
+	}
+	return e.der
+}
+
+// issuer finds the CA a request asks about by its name and key hash.
+func (uc *OCSPUsecase) issuer(r *ocsp.Request) (CaType, *x509.Certificate, bool) {
+	if !r.HashAlgorithm.Available() {
+		return 0, nil, false
+	}
+	for _, t := range []CaType{RsaCa, EccCa, SM2Ca} {
+		crt, err := uc.ca.GetCACert(t.String())
+		if err != nil || crt == nil {
+			continue
+		}
+		var spki struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(crt.RawSubjectPublicKeyInfo, &spki); err != nil {
+			continue
+		}
+		h := r.HashAlgorithm.New()
+		h.Write(crt.RawSubject)
+		nameHash := h.Sum(nil)
+		h.Reset()
+		h.Write(spki.PublicKey.RightAlign())
+		keyHash := h.Sum(nil)
+		if bytes.Equal(nameHash, r.IssuerNameHash) && bytes.Equal(keyHash, r.IssuerKeyHash) {
+			return t, crt, true
+		}
+	}
+	return 0, nil, false
+}
+
+// responder returns the delegated responder of CA type t, or the CA itself
+// if there is none or the delegated certificate was not issued by the
+// current CA, as after a rollover.
+func (uc *OCSPUsecase) responder(t CaType, caCrt *x509.Certificate) (*ocspResponder, error) {
+	if r, ok := uc.responders[t]; ok {
+		if err := checkSignatureFrom(r.cert, caCrt); err == nil {
+			return r, nil
+		}
+		uc.log.Warnf("delegated %s OCSP responder was not issued by the current CA, signing with the CA", t)
+	}
+	key, err := uc.ca.GetCAKey(t.String())
+	if err != nil {
+		return nil, err
+	}
+	return &ocspResponder{key: key}, nil
+}
+
+// ocspNonce returns the nonce extension of the DER OCSPRequest req, if any.
+func ocspNonce(req []byte) (*pkix.Extension, error) {
+	var r ocspRequest
+	if _, err := asn1.Unmarshal(req, &r); err != nil {
+		return nil, err
+	}
+	for _, ext := range r.TBSRequest.RequestExtensions {
+		if !ext.Id.Equal(oidOCSPNonce) {
+			continue
+		}
+		// the nonce is an OCTET STRING, some clients send it bare
+		var nonce []byte
+		if _, err := asn1.Unmarshal(ext.Value, &nonce); err != nil {
+			nonce = ext.Value
+		}
+		if len(nonce) == 0 || len(nonce) > maxOCSPNonceSize {
+			return nil, fmt.Errorf("nonce of %d octets", len(nonce))
+		}
+		return &pkix.Extension{Id: oidOCSPNonce, Value: ext.Value}, nil
+	}
+	return nil, nil
+}
+
+// createOCSPResponse is ocsp.CreateResponse with exts in the
+// responseExtensions and SM2 keys signing with SM2 and SM3.
+func createOCSPResponse(issuer, responderCrt *x509.Certificate, tmpl ocsp.Response, key crypto.Signer, exts []pkix.Extension) ([]byte, error) {
+	gm := sm2.IsSM2PublicKey(key.Public())
+	if !gm && len(exts) == 0 {
+		return ocsp.CreateResponse(issuer, responderCrt, tmpl, key)
+	}
+	signer := key
+	if gm {
+		// ocsp.CreateResponse knows no SM2, the response is signed
+		// afterwards
+		signer = unsignedOCSPSigner{}
+	}
+	der, err := ocsp.CreateResponse(issuer, responderCrt, tmpl, signer)
+	if err != nil {
+		return nil, err
+	}
+	return resignResponse(der, key, exts)
+}
+
+// unsignedOCSPSigner stands in for an SM2 key in ocsp.CreateResponse and
+// leaves the signature empty.
+type unsignedOCSPSigner struct{}
+
+func (unsignedOCSPSigner) Public() crypto.PublicKey {
+	return &ecdsa.PublicKey{Curve: elliptic.P256()}
+}
+
+func (unsignedOCSPSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, nil
+}
+
+// resignResponse adds exts to the responseExtensions of the DER
+// OCSPResponse der and signs it again with key. ocsp.CreateResponse only
+// writes singleExtensions, but RFC 8954 puts the nonce in the
+// responseExtensions.
+func resignResponse(der []byte, key crypto.Signer, exts []pkix.Extension) ([]byte, error) {
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, err
+	}
+	var basic basicOCSPResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, err
+	}
+	gm := sm2.IsSM2PublicKey(key.Public())
+	hash, ok := ocspSignatureHashes[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok && !gm {
+		return nil, fmt.Errorf("unsupported OCSP signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+	// TBSResponseData.Bytes aliases the rest of the response, so it must
+	// not be appended to in place
+	content := append([]byte(nil), basic.TBSResponseData.Bytes...)
+	if len(exts) > 0 {
+		extDER, err := asn1.MarshalWithParams(exts, "explicit,tag:1")
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, extDER...)
+	}
+	tbs, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var sig []byte
+	if gm {
+		basic.SignatureAlgorithm = pkix.AlgorithmIdentifier{Algorithm: oidSM2WithSM3}
+		sig, err = key.Sign(rand.Reader, tbs, sm2.DefaultSM2SignerOpts)
+	} else {
+		h := hash.New()
+		h.Write(tbs)
+		sig, err = key.Sign(rand.Reader, h.Sum(nil), hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	basic.TBSResponseData = asn1.RawValue{FullBytes: tbs}
+	basic.Signature = asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)}
+	if resp.Response.Response, err = asn1.Marshal(basic); err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(resp)
+}
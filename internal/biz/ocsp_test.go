@@ -0,0 +1,367 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kscep/internal/conf"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/go-kratos/kratos/v2/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+type memCARepo struct {
+	crt *x509.Certificate
+	key *rsa.PrivateKey
+}
+
+func (r *memCARepo) GetCert(t CaType) (*x509.Certificate, error) {
+	if t != RsaCa {
+		return nil, MissingCaCertErr
+	}
+	return r.crt, nil
+}
+
+func (r *memCARepo) GetKey(t CaType) (crypto.Signer, error) {
+	if t != RsaCa {
+		return nil, MissingCaCertErr
+	}
+	return r.key, nil
+}
+
+func (r *memCARepo) GetAddlCA() ([]*x509.Certificate, error) { return nil, nil }
+
+func (r *memCARepo) GetRA(t CaType) (*x509.Certificate, crypto.Signer, error) {
+	return nil, nil, MissingRaErr
+}
+
+func (r *memCARepo) GetNextCert(t CaType) (*x509.Certificate, error) {
+	return nil, MissingNextCaCertErr
+}
+
+func (r *memCARepo) SchedulePromotion(t CaType, at time.Time) error { return MissingNextCaCertErr }
+
+type memCertRepo map[string]*x509.Certificate
+
+func (r memCertRepo) GetCertificate(_ context.Context, serial *big.Int) (*x509.Certificate, error) {
+	crt, ok := r[serial.String()]
+	if !ok {
+		return nil, CertNotFoundErr
+	}
+	return crt, nil
+}
+
+func (r memCertRepo) ListCertificates(context.Context, *CertificateFilter) ([]*IssuedCertificate, error) {
+	return nil, nil
+}
+
+func (r memCertRepo) CountCertificates(context.Context, *CertificateFilter) (int, error) {
+	return 0, nil
+}
+
+type memRevocationRepo map[string]*Revocation
+
+func (r memRevocationRepo) Revoke(_ context.Context, serial *big.Int, reason int) error {
+	r[serial.String()] = &Revocation{Serial: serial, RevokedAt: time.Now().UTC().Truncate(time.Second), Reason: reason}
+	return nil
+}
+
+func (r memRevocationRepo) GetRevocation(_ context.Context, serial *big.Int) (*Revocation, error) {
+	return r[serial.String()], nil
+}
+
+func (r memRevocationRepo) ListRevoked(context.Context, *x509.Certificate) ([]*Revocation, error) {
+	revs := make([]*Revocation, 0, len(r))
+	for _, rev := range r {
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// testIssue signs a certificate for cn with the given extended key usages,
+// self-signed when parent is nil.
+func testIssue(t *testing.T, cn string, serial int64, parent *x509.Certificate, parentKey *rsa.PrivateKey, eku ...x509.ExtKeyUsage) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           eku,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
+
+// testOCSPRequest builds an OCSP request for crt, with a nonce extension if
+// nonce is set.
+func testOCSPRequest(t *testing.T, crt, issuer *x509.Certificate, nonce []byte) []byte {
+	t.Helper()
+	req, err := ocsp.CreateRequest(crt, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce == nil {
+		return req
+	}
+	var r struct {
+		TBSRequest struct {
+			RequestList []asn1.RawValue
+			Extensions  []pkix.Extension `asn1:"explicit,tag:2,optional"`
+		}
+	}
+	if _, err := asn1.Unmarshal(req, &r); err != nil {
+		t.Fatal(err)
+	}
+	value, err := asn1.Marshal(nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.TBSRequest.Extensions = []pkix.Extension{{Id: oidOCSPNonce, Value: value}}
+	req, err = asn1.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// responseNonce returns the nonce in the responseExtensions of resp.
+func responseNonce(t *testing.T, resp []byte) []byte {
+	t.Helper()
+	var r ocspResponse
+	if _, err := asn1.Unmarshal(resp, &r); err != nil {
+		t.Fatal(err)
+	}
+	var basic basicOCSPResponse
+	if _, err := asn1.Unmarshal(r.Response.Response, &basic); err != nil {
+		t.Fatal(err)
+	}
+	var tbs struct {
+		Version            int `asn1:"explicit,tag:0,default:0,optional"`
+		ResponderID        asn1.RawValue
+		ProducedAt         time.Time `asn1:"generalized"`
+		Responses          []asn1.RawValue
+		ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+	}
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &tbs); err != nil {
+		t.Fatal(err)
+	}
+	for _, ext := range tbs.ResponseExtensions {
+		if ext.Id.Equal(oidOCSPNonce) {
+			var nonce []byte
+			if _, err := asn1.Unmarshal(ext.Value, &nonce); err != nil {
+				t.Fatal(err)
+			}
+			return nonce
+		}
+	}
+	return nil
+}
+
+func TestOCSPUsecase_Respond(t *testing.T) {
+	caCrt, caKey := testIssue(t, "rsa ca", 1, nil, nil)
+	leaf, _ := testIssue(t, "device-1", 2, caCrt, caKey)
+	unknown, _ := testIssue(t, "device-2", 3, caCrt, caKey)
+	otherCA, otherKey := testIssue(t, "other ca", 4, nil, nil)
+	foreign, _ := testIssue(t, "device-3", 5, otherCA, otherKey)
+
+	ca := NewSCEPCAUsecase(&memCARepo{crt: caCrt, key: caKey}, log.DefaultLogger)
+	revocations := memRevocationRepo{}
+	uc, err := NewOCSPUsecase(&conf.Data{}, ca, memCertRepo{leaf.SerialNumber.String(): leaf}, revocations, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	der := uc.Respond(ctx, testOCSPRequest(t, leaf, caCrt, nil))
+	resp, err := ocsp.ParseResponseForCert(der, leaf, caCrt)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Status != ocsp.Good {
+		t.Errorf("status = %d, want good", resp.Status)
+	}
+	if d := resp.NextUpdate.Sub(resp.ThisUpdate); d != DefaultOCSPNextUpdate {
+		t.Errorf("nextUpdate - thisUpdate = %v, want %v", d, DefaultOCSPNextUpdate)
+	}
+	if cached := uc.Respond(ctx, testOCSPRequest(t, leaf, caCrt, nil)); !bytes.Equal(cached, der) {
+		t.Errorf("second response was signed again, want the cached one")
+	}
+
+	revocations.Revoke(ctx, leaf.SerialNumber, 1)
+	resp, err = ocsp.ParseResponseForCert(uc.Respond(ctx, testOCSPRequest(t, leaf, caCrt, nil)), leaf, caCrt)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Status != ocsp.Revoked || resp.RevocationReason != 1 {
+		t.Errorf("status after revocation = %d reason %d, want revoked reason 1", resp.Status, resp.RevocationReason)
+	}
+
+	resp, err = ocsp.ParseResponseForCert(uc.Respond(ctx, testOCSPRequest(t, unknown, caCrt, nil)), unknown, caCrt)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Status != ocsp.Unknown {
+		t.Errorf("status of an unknown serial = %d, want unknown", resp.Status)
+	}
+
+	if der := uc.Respond(ctx, testOCSPRequest(t, foreign, otherCA, nil)); !bytes.Equal(der, ocsp.UnauthorizedErrorResponse) {
+		t.Errorf("response for another issuer is not unauthorized")
+	}
+	if der := uc.Respond(ctx, []byte("garbage")); !bytes.Equal(der, ocsp.MalformedRequestErrorResponse) {
+		t.Errorf("response for garbage is not malformedRequest")
+	}
+}
+
+func TestOCSPUsecase_Nonce(t *testing.T) {
+	caCrt, caKey := testIssue(t, "rsa ca", 1, nil, nil)
+	leaf, _ := testIssue(t, "device-1", 2, caCrt, caKey)
+	ca := NewSCEPCAUsecase(&memCARepo{crt: caCrt, key: caKey}, log.DefaultLogger)
+	uc, err := NewOCSPUsecase(&conf.Data{}, ca, memCertRepo{leaf.SerialNumber.String(): leaf}, memRevocationRepo{}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, nonce := range [][]byte{[]byte("0123456789abcdef"), []byte("fedcba9876543210")} {
+		der := uc.Respond(ctx, testOCSPRequest(t, leaf, caCrt, nonce))
+		if _, err := ocsp.ParseResponseForCert(der, leaf, caCrt); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if got := responseNonce(t, der); !bytes.Equal(got, nonce) {
+			t.Errorf("response nonce = %q, want %q", got, nonce)
+		}
+	}
+	der := uc.Respond(ctx, testOCSPRequest(t, leaf, caCrt, bytes.Repeat([]byte{1}, maxOCSPNonceSize+1)))
+	if !bytes.Equal(der, ocsp.MalformedRequestErrorResponse) {
+		t.Errorf("response for an oversized nonce is not malformedRequest")
+	}
+}
+
+func TestCreateOCSPResponse_SM2(t *testing.T) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sm2 ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := smx509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCrt, err := smx509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := asn1.Marshal([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, exts := range [][]pkix.Extension{nil, {{Id: oidOCSPNonce, Value: nonce}}} {
+		der, err := createOCSPResponse(caCrt.ToX509(), caCrt.ToX509(), ocsp.Response{
+			Status:       ocsp.Revoked,
+			SerialNumber: big.NewInt(2),
+			RevokedAt:    time.Now(),
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}, key, exts)
+		if err != nil {
+			t.Fatalf("createOCSPResponse() error = %v", err)
+		}
+		resp, err := ocsp.ParseResponse(der, nil)
+		if err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if err := caCrt.CheckSignature(smx509.SM2WithSM3, resp.TBSResponseData, resp.Signature); err != nil {
+			t.Errorf("response not signed with SM2 and SM3: %v", err)
+		}
+		if resp.Status != ocsp.Revoked || resp.SerialNumber.Int64() != 2 {
+			t.Errorf("response status = %d for %v, want revoked for 2", resp.Status, resp.SerialNumber)
+		}
+		if got := responseNonce(t, der); exts != nil && string(got) != "0123456789abcdef" {
+			t.Errorf("response nonce = %q, want the request nonce", got)
+		}
+	}
+}
+
+func TestOCSPUsecase_DelegatedResponder(t *testing.T) {
+	caCrt, caKey := testIssue(t, "rsa ca", 1, nil, nil)
+	leaf, _ := testIssue(t, "device-1", 2, caCrt, caKey)
+	responder, responderKey := testIssue(t, "ocsp responder", 3, caCrt, caKey, x509.ExtKeyUsageOCSPSigning)
+	noEKU, noEKUKey := testIssue(t, "not a responder", 4, caCrt, caKey)
+
+	dir := t.TempDir()
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	cd := &conf.Data{Ocsp: &conf.Data_Ocsp{Responders: map[string]*conf.Data_Ocsp_Responder{
+		"RSA": {
+			Cert: writePEM("ocsp.pem", "CERTIFICATE", responder.Raw),
+			Key:  writePEM("ocsp.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(responderKey)),
+		},
+	}}}
+	ca := NewSCEPCAUsecase(&memCARepo{crt: caCrt, key: caKey}, log.DefaultLogger)
+	uc, err := NewOCSPUsecase(cd, ca, memCertRepo{leaf.SerialNumber.String(): leaf}, memRevocationRepo{}, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ocsp.ParseResponseForCert(uc.Respond(context.Background(), testOCSPRequest(t, leaf, caCrt, nil)), leaf, caCrt)
+	if err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Certificate == nil || !resp.Certificate.Equal(responder) {
+		t.Errorf("response is not signed by the delegated responder")
+	}
+
+	cd.Ocsp.Responders["RSA"] = &conf.Data_Ocsp_Responder{
+		Cert: writePEM("noeku.pem", "CERTIFICATE", noEKU.Raw),
+		Key:  writePEM("noeku.key", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(noEKUKey)),
+	}
+	if _, err := NewOCSPUsecase(cd, ca, memCertRepo{}, memRevocationRepo{}, log.DefaultLogger); err == nil {
+		t.Errorf("NewOCSPUsecase() accepted a responder without the ocsp_signing usage")
+	}
+}
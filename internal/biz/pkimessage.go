@@ -0,0 +1,279 @@
+package biz
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+
+	smpkcs7 "github.com/emmansun/gmsm/pkcs7"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/ploynomail/pkcs7"
+	"github.com/ploynomail/scep"
+	"github.com/ploynomail/scep/x509util"
+)
+
+// SCEP attribute OIDs, see RFC 8894 3.2.1.
+var (
+	oidSCEPmessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidSCEPpkiStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidSCEPfailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidSCEPsenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidSCEPrecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidSCEPtransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+)
+
+var MissingSenderNonceErr = errors.New("pkiMessage must include senderNonce attribute")
+
+// pkiRequest is a verified client pkiMessage. Unlike scep.ParsePKIMessage it
+// accepts every request message type, including CertPoll, GetCert and GetCRL
+// which the scep package does not implement.
+type pkiRequest struct {
+	TransactionID scep.TransactionID
+	MessageType   scep.MessageType
+	SenderNonce   scep.SenderNonce
+	// Signer is the certificate the client signed the message with.
+	Signer *x509.Certificate
+
+	// envelope is the pkcsPKIEnvelope, the signed content.
+	envelope []byte
+	// certs are the certificates included in the request, the reply is
+	// encrypted to them.
+	certs []*x509.Certificate
+	// gm is set for GM/T 0089 requests, signed with SM2 and answered in
+	// kind.
+	gm bool
+
+	// status and failInfo are those of the CertRep sent in reply.
+	status   scep.PKIStatus
+	failInfo scep.FailInfo
+}
+
+// parsePKIRequest verifies the signature of a client pkiMessage and reads
+// its SCEP attributes.
+func parsePKIRequest(data []byte) (*pkiRequest, error) {
+	if gmSignedData(data) {
+		return parseGMRequest(data)
+	}
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, err
+	}
+	req := &pkiRequest{envelope: p7.Content, certs: p7.Certificates}
+	if err := req.readAttributes(p7); err != nil {
+		return nil, err
+	}
+	req.Signer = p7.GetOnlySigner()
+	if req.Signer == nil {
+		return nil, errors.New("pkiMessage has no single signer certificate")
+	}
+	return req, nil
+}
+
+// signedAttributes is a parsed SignedData of either pkcs7 package.
+type signedAttributes interface {
+	UnmarshalSignedAttribute(attributeType asn1.ObjectIdentifier, out interface{}) error
+}
+
+// readAttributes reads the SCEP attributes of the request from p7.
+func (r *pkiRequest) readAttributes(p7 signedAttributes) error {
+	if err := p7.UnmarshalSignedAttribute(oidSCEPtransactionID, &r.TransactionID); err != nil {
+		return err
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPmessageType, &r.MessageType); err != nil {
+		return err
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPsenderNonce, &r.SenderNonce); err != nil {
+		return err
+	}
+	if len(r.SenderNonce) == 0 {
+		return MissingSenderNonceErr
+	}
+	return nil
+}
+
+// issuerAndSerial identifies the certificate a pkcsPKIEnvelope was
+// encrypted to.
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+func (ias issuerAndSerial) matches(crt *x509.Certificate) bool {
+	return ias.SerialNumber != nil && crt.SerialNumber.Cmp(ias.SerialNumber) == 0 &&
+		bytes.Equal(ias.IssuerName.FullBytes, crt.RawIssuer)
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []recipientInfo `asn1:"set"`
+	EncryptedContentInfo asn1.RawValue
+}
+
+type recipientInfo struct {
+	Version                int
+	IssuerAndSerialNumber  issuerAndSerial
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+// recipients returns the issuer and serial of every recipient of the
+// pkcsPKIEnvelope, i.e. the CA (or RA) certificates the client encrypted to.
+func (r *pkiRequest) recipients() ([]issuerAndSerial, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(r.envelope, &ci); err != nil {
+		return nil, err
+	}
+	if !ci.ContentType.Equal(pkcs7.OIDEnvelopedData) && !ci.ContentType.Equal(smpkcs7.SM2OIDEnvelopedData) {
+		return nil, errors.New("pkcsPKIEnvelope is not an envelopedData")
+	}
+	var ed envelopedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+		return nil, err
+	}
+	ids := make([]issuerAndSerial, 0, len(ed.RecipientInfos))
+	for _, ri := range ed.RecipientInfos {
+		ids = append(ids, ri.IssuerAndSerialNumber)
+	}
+	return ids, nil
+}
+
+// decrypt opens the pkcsPKIEnvelope of r with the key of the recipient CA.
+func (r *pkiRequest) decrypt(crt *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	if r.gm {
+		return gmDecrypt(r.envelope, crt, key)
+	}
+	p7, err := pkcs7.Parse(r.envelope)
+	if err != nil {
+		return nil, err
+	}
+	return p7.Decrypt(crt, key)
+}
+
+// csrReqMessage decrypts the pkcsPKIEnvelope of a PKCSReq, RenewalReq or
+// UpdateReq and reads the CSR in it, whose signature must be valid.
+func (r *pkiRequest) csrReqMessage(crt *x509.Certificate, key crypto.Signer) (*scep.CSRReqMessage, error) {
+	raw, err := r.decrypt(crt, key)
+	if err != nil {
+		return nil, err
+	}
+	// smx509 reads SM2 CSRs as well
+	csr, err := smx509.ParseCertificateRequest(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse CSR from pkiEnvelope: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR signature: %w", err)
+	}
+	challenge, err := x509util.ParseChallengePassword(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parse challenge password in pkiEnvelope: %w", err)
+	}
+	return &scep.CSRReqMessage{RawDecrypted: raw, CSR: csr.ToX509(), ChallengePassword: challenge}, nil
+}
+
+// certRep builds a CertRep answering r, signed by crtAuth. certs, if any,
+// are returned to the client in a degenerate PKCS#7 encrypted to the
+// certificates the client included in its request.
+func (r *pkiRequest) certRep(crtAuth *x509.Certificate, keyAuth crypto.Signer, status scep.PKIStatus, info scep.FailInfo, certs ...*x509.Certificate) ([]byte, error) {
+	var deg []byte
+	if status == scep.SUCCESS {
+		var err error
+		if deg, err = degenerateCertificates(certs); err != nil {
+			return nil, err
+		}
+	}
+	return r.reply(crtAuth, keyAuth, status, info, deg, certs)
+}
+
+// crlRep builds the SUCCESS CertRep answering a GetCRL with the DER crl.
+func (r *pkiRequest) crlRep(crtAuth *x509.Certificate, keyAuth crypto.Signer, crl []byte) ([]byte, error) {
+	deg, err := degenerateCRL(crl)
+	if err != nil {
+		return nil, err
+	}
+	return r.reply(crtAuth, keyAuth, scep.SUCCESS, "", deg, nil)
+}
+
+// reply signs a CertRep with the given status. deg, the degenerate PKCS#7
+// of a SUCCESS reply, is encrypted to the certificates of the request.
+func (r *pkiRequest) reply(crtAuth *x509.Certificate, keyAuth crypto.Signer, status scep.PKIStatus, info scep.FailInfo, deg []byte, certs []*x509.Certificate) ([]byte, error) {
+	// the senderNonce of the reply is a fresh one, RFC 8894 3.2.1.5
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	attrs := []pkcs7.Attribute{
+		{Type: oidSCEPtransactionID, Value: r.TransactionID},
+		{Type: oidSCEPpkiStatus, Value: status},
+		{Type: oidSCEPmessageType, Value: scep.CertRep},
+		{Type: oidSCEPsenderNonce, Value: scep.SenderNonce(nonce)},
+		{Type: oidSCEPrecipientNonce, Value: r.SenderNonce},
+	}
+	if status == scep.FAILURE {
+		attrs = append(attrs, pkcs7.Attribute{Type: oidSCEPfailInfo, Value: info})
+	}
+	r.status, r.failInfo = status, info
+	if r.gm {
+		return gmReply(crtAuth, keyAuth, attrs, deg, r.certs, certs)
+	}
+
+	var content []byte
+	if status == scep.SUCCESS {
+		var err error
+		content, err = pkcs7.Encrypt(deg, r.certs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	for _, crt := range certs {
+		sd.AddCertificate(crt)
+	}
+	if err := sd.AddSigner(crtAuth, keyAuth, pkcs7.SignerInfoConfig{ExtraSignedAttributes: attrs}); err != nil {
+		return nil, err
+	}
+	return sd.Finish()
+}
+
+// degenerateSignedData is a SignedData without signers carrying CRLs.
+type degenerateSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	CRLs             []asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+// degenerateCRL creates a CRL-only PKCS#7 SignedData, the form RFC 8894
+// 3.3.4 returns CRLs in. The pkcs7 package only builds certs-only ones.
+func degenerateCRL(crl []byte) ([]byte, error) {
+	sd, err := asn1.Marshal(degenerateSignedData{
+		Version:     1,
+		ContentInfo: contentInfo{ContentType: pkcs7.OIDData},
+		CRLs:        []asn1.RawValue{{FullBytes: crl}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: pkcs7.OIDSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
@@ -0,0 +1,116 @@
+package biz
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ploynomail/pkcs7"
+	"github.com/ploynomail/scep"
+)
+
+func testCACert(t *testing.T, cn string, serial int64) *x509.Certificate {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestPKIRequest_Recipients(t *testing.T) {
+	rsaCA := testCACert(t, "rsa ca", 1)
+	otherCA := testCACert(t, "other ca", 2)
+	sameNameCA := testCACert(t, "rsa ca", 3)
+
+	env, err := pkcs7.Encrypt([]byte("csr"), []*x509.Certificate{rsaCA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &pkiRequest{envelope: env}
+	rids, err := req.recipients()
+	if err != nil {
+		t.Fatalf("recipients() error = %v", err)
+	}
+	if len(rids) != 1 {
+		t.Fatalf("recipients() = %d recipients, want 1", len(rids))
+	}
+	if !rids[0].matches(rsaCA) {
+		t.Errorf("recipient does not match the certificate it was encrypted to")
+	}
+	if rids[0].matches(otherCA) {
+		t.Errorf("recipient matches a certificate of another issuer")
+	}
+	if rids[0].matches(sameNameCA) {
+		t.Errorf("recipient matches a certificate with another serial number")
+	}
+
+	req = &pkiRequest{envelope: []byte("not an envelope")}
+	if _, err := req.recipients(); err == nil {
+		t.Errorf("recipients() error = nil for garbage content")
+	}
+}
+
+func TestPKIRequest_ReplyNonces(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ca"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &pkiRequest{TransactionID: "tx", MessageType: scep.PKCSReq, SenderNonce: scep.SenderNonce("0123456789abcdef")}
+	data, err := req.certRep(crt, key, scep.FAILURE, scep.BadRequest)
+	if err != nil {
+		t.Fatalf("certRep() error = %v", err)
+	}
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sender, recipient scep.SenderNonce
+	if err := p7.UnmarshalSignedAttribute(oidSCEPsenderNonce, &sender); err != nil {
+		t.Fatal(err)
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPrecipientNonce, &recipient); err != nil {
+		t.Fatal(err)
+	}
+	if string(recipient) != string(req.SenderNonce) {
+		t.Errorf("recipientNonce = %x, want the senderNonce of the request %x", recipient, req.SenderNonce)
+	}
+	if len(sender) != 16 || string(sender) == string(req.SenderNonce) {
+		t.Errorf("senderNonce = %x, want 16 fresh bytes", sender)
+	}
+}
@@ -0,0 +1,250 @@
+package biz
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"kscep/internal/conf"
+	"regexp"
+
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/ploynomail/scep"
+)
+
+// PolicyViolation is a CSR rejected by the policy. FailInfo is returned to
+// the client, Reason is only logged.
+type PolicyViolation struct {
+	FailInfo scep.FailInfo
+	Reason   string
+}
+
+func (v *PolicyViolation) Error() string {
+	return "CSR policy violation: " + v.Reason
+}
+
+func badAlg(format string, a ...interface{}) *PolicyViolation {
+	return &PolicyViolation{FailInfo: scep.BadAlg, Reason: fmt.Sprintf(format, a...)}
+}
+
+func badRequest(format string, a ...interface{}) *PolicyViolation {
+	return &PolicyViolation{FailInfo: scep.BadRequest, Reason: fmt.Sprintf(format, a...)}
+}
+
+func badCertID(format string, a ...interface{}) *PolicyViolation {
+	return &PolicyViolation{FailInfo: scep.BadCertID, Reason: fmt.Sprintf(format, a...)}
+}
+
+func badTime(format string, a ...interface{}) *PolicyViolation {
+	return &PolicyViolation{FailInfo: scep.BadTime, Reason: fmt.Sprintf(format, a...)}
+}
+
+var subjectAttributes = map[string]func(*x509.CertificateRequest) bool{
+	"CN": func(csr *x509.CertificateRequest) bool { return csr.Subject.CommonName != "" },
+	"O":  func(csr *x509.CertificateRequest) bool { return len(csr.Subject.Organization) > 0 },
+	"OU": func(csr *x509.CertificateRequest) bool { return len(csr.Subject.OrganizationalUnit) > 0 },
+	"C":  func(csr *x509.CertificateRequest) bool { return len(csr.Subject.Country) > 0 },
+	"ST": func(csr *x509.CertificateRequest) bool { return len(csr.Subject.Province) > 0 },
+	"L":  func(csr *x509.CertificateRequest) bool { return len(csr.Subject.Locality) > 0 },
+}
+
+var curves = map[string]bool{"P-224": true, "P-256": true, "P-384": true, "P-521": true, "sm2p256v1": true}
+
+// CSRPolicy holds the rules a CSR has to satisfy before it is signed.
+type CSRPolicy struct {
+	minRSABits int
+	minECBits  int
+	// nil allows every curve or signature algorithm
+	curves  map[string]bool
+	sigAlgs map[x509.SignatureAlgorithm]bool
+
+	commonName []*regexp.Regexp
+	dnsNames   []*regexp.Regexp
+	emails     []*regexp.Regexp
+	uris       []*regexp.Regexp
+
+	requiredSubject []string
+	maxSANs         int
+	renewalSubject  string
+}
+
+// How the subject of a RenewalReq is compared to its signer certificate.
+const (
+	RenewalSubjectDN  = "dn"
+	RenewalSubjectCN  = "cn"
+	RenewalSubjectAny = "any"
+)
+
+// NewCSRPolicy compiles the policy section of the configuration. Without
+// one every CSR is accepted.
+func NewCSRPolicy(c *conf.Data) (*CSRPolicy, error) {
+	pc := c.GetPolicy()
+	p := &CSRPolicy{
+		minRSABits:      int(pc.GetMinRsaBits()),
+		minECBits:       int(pc.GetMinEcBits()),
+		requiredSubject: pc.GetRequiredSubject(),
+		maxSANs:         int(pc.GetMaxSans()),
+		renewalSubject:  pc.GetRenewalSubject(),
+	}
+	switch p.renewalSubject {
+	case "":
+		p.renewalSubject = RenewalSubjectDN
+	case RenewalSubjectDN, RenewalSubjectCN, RenewalSubjectAny:
+	default:
+		return nil, fmt.Errorf("policy: unknown renewal subject match %q", p.renewalSubject)
+	}
+	if names := pc.GetAllowedCurves(); len(names) > 0 {
+		p.curves = make(map[string]bool, len(names))
+		for _, name := range names {
+			if !curves[name] {
+				return nil, fmt.Errorf("policy: unknown curve %q", name)
+			}
+			p.curves[name] = true
+		}
+	}
+	if names := pc.GetSignatureAlgorithms(); len(names) > 0 {
+		known := make(map[string]x509.SignatureAlgorithm)
+		for alg := x509.MD2WithRSA; alg <= x509.PureEd25519; alg++ {
+			known[alg.String()] = alg
+		}
+		known[sigAlgName(smx509.SM2WithSM3)] = smx509.SM2WithSM3
+		p.sigAlgs = make(map[x509.SignatureAlgorithm]bool, len(names))
+		for _, name := range names {
+			alg, ok := known[name]
+			if !ok {
+				return nil, fmt.Errorf("policy: unknown signature algorithm %q", name)
+			}
+			p.sigAlgs[alg] = true
+		}
+	}
+	for _, attr := range p.requiredSubject {
+		if _, ok := subjectAttributes[attr]; !ok {
+			return nil, fmt.Errorf("policy: unknown subject attribute %q", attr)
+		}
+	}
+	var err error
+	if p.commonName, err = compileAllowlist(pc.GetCommonName()); err != nil {
+		return nil, err
+	}
+	if p.dnsNames, err = compileAllowlist(pc.GetDnsNames()); err != nil {
+		return nil, err
+	}
+	if p.emails, err = compileAllowlist(pc.GetEmails()); err != nil {
+		return nil, err
+	}
+	if p.uris, err = compileAllowlist(pc.GetUris()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compileAllowlist anchors the expressions so that they match whole values.
+func compileAllowlist(exprs []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("policy: %w", err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func allowed(allowlist []*regexp.Regexp, value string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	for _, re := range allowlist {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate returns a *PolicyViolation if csr breaks one of the rules.
+func (p *CSRPolicy) Evaluate(csr *x509.CertificateRequest) error {
+	if err := p.checkKey(csr); err != nil {
+		return err
+	}
+	if p.sigAlgs != nil && !p.sigAlgs[csr.SignatureAlgorithm] {
+		return badAlg("signature algorithm %s not allowed", sigAlgName(csr.SignatureAlgorithm))
+	}
+	for _, attr := range p.requiredSubject {
+		if !subjectAttributes[attr](csr) {
+			return badRequest("subject attribute %s missing", attr)
+		}
+	}
+	if cn := csr.Subject.CommonName; !allowed(p.commonName, cn) {
+		return badRequest("common name %q not allowed", cn)
+	}
+	for _, name := range csr.DNSNames {
+		if !allowed(p.dnsNames, name) {
+			return badRequest("DNS name %q not allowed", name)
+		}
+	}
+	for _, email := range csr.EmailAddresses {
+		if !allowed(p.emails, email) {
+			return badRequest("email address %q not allowed", email)
+		}
+	}
+	for _, uri := range csr.URIs {
+		if !allowed(p.uris, uri.String()) {
+			return badRequest("URI %q not allowed", uri)
+		}
+	}
+	sans := len(csr.DNSNames) + len(csr.EmailAddresses) + len(csr.IPAddresses) + len(csr.URIs)
+	if p.maxSANs > 0 && sans > p.maxSANs {
+		return badRequest("%d SANs requested, at most %d allowed", sans, p.maxSANs)
+	}
+	return nil
+}
+
+// CheckRenewal returns a *PolicyViolation if the subject of a renewal csr
+// does not match the certificate the RenewalReq was signed with.
+func (p *CSRPolicy) CheckRenewal(csr *x509.CertificateRequest, signer *x509.Certificate) error {
+	switch p.renewalSubject {
+	case RenewalSubjectAny:
+		return nil
+	case RenewalSubjectCN:
+		if csr.Subject.CommonName != signer.Subject.CommonName {
+			return badRequest("renewal common name %q does not match %q", csr.Subject.CommonName, signer.Subject.CommonName)
+		}
+	default:
+		if csr.Subject.String() != signer.Subject.String() {
+			return badRequest("renewal subject %q does not match %q", csr.Subject, signer.Subject)
+		}
+	}
+	return nil
+}
+
+// sigAlgName names alg, crypto/x509 does not know SM2-SM3.
+func sigAlgName(alg x509.SignatureAlgorithm) string {
+	if alg == smx509.SM2WithSM3 {
+		return "SM2-SM3"
+	}
+	return alg.String()
+}
+
+func (p *CSRPolicy) checkKey(csr *x509.CertificateRequest) error {
+	switch pub := csr.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := pub.N.BitLen(); bits < p.minRSABits {
+			return badAlg("RSA key of %d bits, at least %d required", bits, p.minRSABits)
+		}
+	case *ecdsa.PublicKey:
+		params := pub.Curve.Params()
+		if p.curves != nil && !p.curves[params.Name] {
+			return badAlg("curve %s not allowed", params.Name)
+		}
+		if params.BitSize < p.minECBits {
+			return badAlg("EC key of %d bits, at least %d required", params.BitSize, p.minECBits)
+		}
+	case ed25519.PublicKey:
+	default:
+		return badAlg("unsupported public key type %T", pub)
+	}
+	return nil
+}
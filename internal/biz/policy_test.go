@@ -0,0 +1,155 @@
+package biz
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"kscep/internal/conf"
+	"net/url"
+	"testing"
+
+	"github.com/ploynomail/scep"
+)
+
+func testCSR(t *testing.T, key crypto.Signer, tmpl *x509.CertificateRequest) *x509.CertificateRequest {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, tmpl, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csr
+}
+
+func TestCSRPolicy_Evaluate(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := NewCSRPolicy(&conf.Data{Policy: &conf.Data_Policy{
+		MinRsaBits:          2048,
+		AllowedCurves:       []string{"P-256", "P-384"},
+		SignatureAlgorithms: []string{"SHA256-RSA", "ECDSA-SHA256"},
+		CommonName:          []string{`device-\d+`},
+		DnsNames:            []string{`[a-z0-9-]+\.example\.com`},
+		Emails:              []string{`.*@example\.com`},
+		Uris:                []string{`spiffe://example\.com/.*`},
+		RequiredSubject:     []string{"CN", "O"},
+		MaxSans:             2,
+	}})
+	if err != nil {
+		t.Fatalf("NewCSRPolicy() error = %v", err)
+	}
+
+	subject := pkix.Name{CommonName: "device-1", Organization: []string{"example"}}
+	spiffe, _ := url.Parse("spiffe://example.com/device-1")
+	other, _ := url.Parse("https://example.com/device-1")
+	tests := []struct {
+		name     string
+		key      crypto.Signer
+		tmpl     *x509.CertificateRequest
+		failInfo scep.FailInfo
+	}{
+		{"valid RSA", rsa2048, &x509.CertificateRequest{Subject: subject, DNSNames: []string{"a.example.com"}}, ""},
+		{"valid EC", p256, &x509.CertificateRequest{Subject: subject, URIs: []*url.URL{spiffe}}, ""},
+		{"small RSA key", rsa1024, &x509.CertificateRequest{Subject: subject}, scep.BadAlg},
+		{"curve not allowed", p224, &x509.CertificateRequest{Subject: subject}, scep.BadAlg},
+		{"signature algorithm not allowed", rsa2048, &x509.CertificateRequest{Subject: subject, SignatureAlgorithm: x509.SHA512WithRSA}, scep.BadAlg},
+		{"missing subject attribute", rsa2048, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, scep.BadRequest},
+		{"common name not allowed", rsa2048, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1x", Organization: []string{"example"}}}, scep.BadRequest},
+		{"DNS name not allowed", rsa2048, &x509.CertificateRequest{Subject: subject, DNSNames: []string{"a.example.org"}}, scep.BadRequest},
+		{"email not allowed", rsa2048, &x509.CertificateRequest{Subject: subject, EmailAddresses: []string{"a@example.org"}}, scep.BadRequest},
+		{"URI not allowed", rsa2048, &x509.CertificateRequest{Subject: subject, URIs: []*url.URL{other}}, scep.BadRequest},
+		{"too many SANs", rsa2048, &x509.CertificateRequest{Subject: subject, DNSNames: []string{"a.example.com", "b.example.com", "c.example.com"}}, scep.BadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Evaluate(testCSR(t, tt.key, tt.tmpl))
+			if tt.failInfo == "" {
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+				return
+			}
+			var v *PolicyViolation
+			if !errors.As(err, &v) {
+				t.Fatalf("Evaluate() error = %v, want a PolicyViolation", err)
+			}
+			if v.FailInfo != tt.failInfo {
+				t.Fatalf("Evaluate() failInfo = %v, want %v (%s)", v.FailInfo, tt.failInfo, v.Reason)
+			}
+		})
+	}
+}
+
+func TestCSRPolicy_CheckRenewal(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &x509.Certificate{Subject: pkix.Name{CommonName: "device-1", Organization: []string{"example"}}}
+	same := testCSR(t, key, &x509.CertificateRequest{Subject: signer.Subject})
+	sameCN := testCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}})
+	otherCN := testCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}})
+	tests := []struct {
+		match string
+		csr   *x509.CertificateRequest
+		ok    bool
+	}{
+		{"", same, true},
+		{"", sameCN, false},
+		{RenewalSubjectDN, sameCN, false},
+		{RenewalSubjectCN, sameCN, true},
+		{RenewalSubjectCN, otherCN, false},
+		{RenewalSubjectAny, otherCN, true},
+	}
+	for _, tt := range tests {
+		policy, err := NewCSRPolicy(&conf.Data{Policy: &conf.Data_Policy{RenewalSubject: tt.match}})
+		if err != nil {
+			t.Fatalf("NewCSRPolicy() error = %v", err)
+		}
+		err = policy.CheckRenewal(tt.csr, signer)
+		var v *PolicyViolation
+		if tt.ok && err != nil || !tt.ok && !errors.As(err, &v) {
+			t.Errorf("CheckRenewal(%s) with match %q error = %v, want ok %v", tt.csr.Subject, tt.match, err, tt.ok)
+		}
+	}
+}
+
+func TestNewCSRPolicy(t *testing.T) {
+	if _, err := NewCSRPolicy(&conf.Data{}); err != nil {
+		t.Fatalf("NewCSRPolicy() error = %v without policy", err)
+	}
+	for _, pc := range []*conf.Data_Policy{
+		{AllowedCurves: []string{"secp256k1"}},
+		{SignatureAlgorithms: []string{"SHA256"}},
+		{RequiredSubject: []string{"SN"}},
+		{CommonName: []string{"("}},
+		{RenewalSubject: "ou"},
+	} {
+		if _, err := NewCSRPolicy(&conf.Data{Policy: pc}); err == nil {
+			t.Errorf("NewCSRPolicy(%v) error = nil", pc)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package biz
+
+import (
+	"crypto/x509"
+	"fmt"
+	"kscep/internal/conf"
+	"sort"
+)
+
+// SAN types a certificate profile can allow.
+const (
+	SANDNS   = "dns"
+	SANEmail = "email"
+	SANIP    = "ip"
+	SANURI   = "uri"
+)
+
+var keyUsages = map[string]x509.KeyUsage{
+	"digital_signature":  x509.KeyUsageDigitalSignature,
+	"content_commitment": x509.KeyUsageContentCommitment,
+	"key_encipherment":   x509.KeyUsageKeyEncipherment,
+	"data_encipherment":  x509.KeyUsageDataEncipherment,
+	"key_agreement":      x509.KeyUsageKeyAgreement,
+	"cert_sign":          x509.KeyUsageCertSign,
+	"crl_sign":           x509.KeyUsageCRLSign,
+}
+
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"client_auth":      x509.ExtKeyUsageClientAuth,
+	"server_auth":      x509.ExtKeyUsageServerAuth,
+	"code_signing":     x509.ExtKeyUsageCodeSigning,
+	"email_protection": x509.ExtKeyUsageEmailProtection,
+	"time_stamping":    x509.ExtKeyUsageTimeStamping,
+	"ocsp_signing":     x509.ExtKeyUsageOCSPSigning,
+	"ipsec_end_system": x509.ExtKeyUsageIPSECEndSystem,
+	"ipsec_tunnel":     x509.ExtKeyUsageIPSECTunnel,
+	"ipsec_user":       x509.ExtKeyUsageIPSECUser,
+}
+
+// CertProfile is the template certificates are issued from on one SCEP
+// endpoint.
+type CertProfile struct {
+	Name        string
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	// ValidityDays overrides the validity configured for the signer if set.
+	ValidityDays int
+	// AllowedSANs is the set of SAN types a CSR may request; nil allows all.
+	AllowedSANs map[string]bool
+	// Subject attributes replacing those of the CSR, if set.
+	Organization       string
+	OrganizationalUnit string
+	Country            string
+	Province           string
+	Locality           string
+}
+
+// DefaultCertProfile is the profile of the /api/v1/scep endpoint: client
+// authentication certificates carrying the SANs of the CSR.
+func DefaultCertProfile() *CertProfile {
+	return &CertProfile{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+// NewCertProfile builds the named profile from its configuration.
+func NewCertProfile(name string, c *conf.Data_Profile) (*CertProfile, error) {
+	p := &CertProfile{
+		Name:         name,
+		ValidityDays: int(c.GetValidityDay()),
+	}
+	for _, ku := range c.GetKeyUsage() {
+		u, ok := keyUsages[ku]
+		if !ok {
+			return nil, fmt.Errorf("profile %s: unknown key usage %q", name, ku)
+		}
+		p.KeyUsage |= u
+	}
+	for _, eku := range c.GetExtKeyUsage() {
+		u, ok := extKeyUsages[eku]
+		if !ok {
+			return nil, fmt.Errorf("profile %s: unknown extended key usage %q", name, eku)
+		}
+		p.ExtKeyUsage = append(p.ExtKeyUsage, u)
+	}
+	if sans := c.GetAllowedSan(); len(sans) > 0 {
+		p.AllowedSANs = make(map[string]bool, len(sans))
+		for _, san := range sans {
+			switch san {
+			case SANDNS, SANEmail, SANIP, SANURI:
+				p.AllowedSANs[san] = true
+			default:
+				return nil, fmt.Errorf("profile %s: unknown SAN type %q", name, san)
+			}
+		}
+	}
+	if s := c.GetSubject(); s != nil {
+		p.Organization = s.GetOrganization()
+		p.OrganizationalUnit = s.GetOrganizationalUnit()
+		p.Country = s.GetCountry()
+		p.Province = s.GetProvince()
+		p.Locality = s.GetLocality()
+	}
+	return p, nil
+}
+
+// CheckSANs fails if csr requests a SAN type the profile does not allow.
+func (p *CertProfile) CheckSANs(csr *x509.CertificateRequest) error {
+	if p.AllowedSANs == nil {
+		return nil
+	}
+	requested := map[string]bool{
+		SANDNS:   len(csr.DNSNames) > 0,
+		SANEmail: len(csr.EmailAddresses) > 0,
+		SANIP:    len(csr.IPAddresses) > 0,
+		SANURI:   len(csr.URIs) > 0,
+	}
+	var denied []string
+	for san, ok := range requested {
+		if ok && !p.AllowedSANs[san] {
+			denied = append(denied, san)
+		}
+	}
+	if len(denied) > 0 {
+		sort.Strings(denied)
+		return fmt.Errorf("%w: %v", SANNotAllowedErr, denied)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package biz
+
+import (
+	"crypto/x509"
+	"errors"
+	"kscep/internal/conf"
+	"net"
+	"testing"
+)
+
+func TestNewCertProfile(t *testing.T) {
+	p, err := NewCertProfile("vpn", &conf.Data_Profile{
+		KeyUsage:    []string{"digital_signature", "key_agreement"},
+		ExtKeyUsage: []string{"client_auth", "ipsec_user"},
+		ValidityDay: 30,
+		AllowedSan:  []string{"dns", "ip"},
+		Subject:     &conf.Data_Profile_Subject{OrganizationalUnit: "vpn"},
+	})
+	if err != nil {
+		t.Fatalf("NewCertProfile() error = %v", err)
+	}
+	if p.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyAgreement {
+		t.Errorf("KeyUsage = %v", p.KeyUsage)
+	}
+	if len(p.ExtKeyUsage) != 2 || p.ExtKeyUsage[1] != x509.ExtKeyUsageIPSECUser {
+		t.Errorf("ExtKeyUsage = %v", p.ExtKeyUsage)
+	}
+	if p.ValidityDays != 30 || p.OrganizationalUnit != "vpn" {
+		t.Errorf("ValidityDays = %d, OrganizationalUnit = %q", p.ValidityDays, p.OrganizationalUnit)
+	}
+
+	for _, c := range []*conf.Data_Profile{
+		{KeyUsage: []string{"signing"}},
+		{ExtKeyUsage: []string{"any"}},
+		{AllowedSan: []string{"upn"}},
+	} {
+		if _, err := NewCertProfile("bad", c); err == nil {
+			t.Errorf("NewCertProfile(%v) error = nil", c)
+		}
+	}
+}
+
+func TestCertProfile_CheckSANs(t *testing.T) {
+	p, err := NewCertProfile("server", &conf.Data_Profile{AllowedSan: []string{"dns"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.CheckSANs(&x509.CertificateRequest{DNSNames: []string{"a.example.com"}}); err != nil {
+		t.Errorf("CheckSANs() error = %v for an allowed SAN", err)
+	}
+	err = p.CheckSANs(&x509.CertificateRequest{
+		DNSNames:    []string{"a.example.com"},
+		IPAddresses: []net.IP{net.IPv4(10, 0, 0, 1)},
+	})
+	if !errors.Is(err, SANNotAllowedErr) {
+		t.Errorf("CheckSANs() error = %v, want %v", err, SANNotAllowedErr)
+	}
+	if err := DefaultCertProfile().CheckSANs(&x509.CertificateRequest{
+		EmailAddresses: []string{"a@example.com"},
+	}); err != nil {
+		t.Errorf("default profile CheckSANs() error = %v", err)
+	}
+}
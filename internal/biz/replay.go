@@ -0,0 +1,75 @@
+package biz
+
+import (
+	"context"
+	"kscep/internal/conf"
+	"math/big"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+)
+
+// DefaultReplayWindow is how long senderNonces and transactions are
+// remembered when replay.window is not configured.
+const DefaultReplayWindow = 24 * time.Hour
+
+// DefaultReplayEntries bounds the in-memory replay cache when
+// replay.max_entries is not configured.
+const DefaultReplayEntries = 100000
+
+type ReplayRepo interface {
+	// PutNonce records a senderNonce until expiresAt. It fails with
+	// NonceReusedErr if the nonce is recorded and has not expired.
+	PutNonce(ctx context.Context, nonce []byte, expiresAt time.Time) error
+	// PutTransaction records serial as the certificate issued for the
+	// transaction until expiresAt.
+	PutTransaction(ctx context.Context, id string, serial *big.Int, expiresAt time.Time) error
+	// GetTransaction returns the serial number of the certificate issued
+	// for the transaction, or TransactionNotFoundErr.
+	GetTransaction(ctx context.Context, id string) (*big.Int, error)
+}
+
+// ReplayUsecase rejects reused senderNonces and remembers the certificate
+// issued for each transaction, so that a repeated transaction is answered
+// with it instead of a second certificate.
+type ReplayUsecase struct {
+	repo   ReplayRepo
+	window time.Duration
+	log    *log.Helper
+}
+
+func NewReplayUsecase(c *conf.Data, repo ReplayRepo, logger log.Logger) *ReplayUsecase {
+	window := c.GetReplay().GetWindow().AsDuration()
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+	return &ReplayUsecase{
+		repo:   repo,
+		window: window,
+		log:    log.NewHelper(log.With(logger, "module", "usecase/scep/replay")),
+	}
+}
+
+// CheckNonce fails with NonceReusedErr if nonce was seen within the window.
+func (uc *ReplayUsecase) CheckNonce(ctx context.Context, nonce scep.SenderNonce) error {
+	return uc.repo.PutNonce(ctx, nonce, time.Now().Add(uc.window))
+}
+
+// Issued returns the serial number of the certificate issued for the
+// transaction, or TransactionNotFoundErr.
+func (uc *ReplayUsecase) Issued(ctx context.Context, t scep.MessageType, id scep.TransactionID) (*big.Int, error) {
+	return uc.repo.GetTransaction(ctx, transactionKey(t, id))
+}
+
+// RecordIssued remembers serial as the certificate issued for the transaction.
+func (uc *ReplayUsecase) RecordIssued(ctx context.Context, t scep.MessageType, id scep.TransactionID, serial *big.Int) error {
+	return uc.repo.PutTransaction(ctx, transactionKey(t, id), serial, time.Now().Add(uc.window))
+}
+
+// transactionKey tells transactions of different message types apart. Most
+// clients derive the transactionID from the public key, so a renewal that
+// keeps the key shares it with the initial enrollment.
+func transactionKey(t scep.MessageType, id scep.TransactionID) string {
+	return string(t) + "/" + string(id)
+}
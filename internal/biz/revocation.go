@@ -0,0 +1,205 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"kscep/internal/conf"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// DefaultCRLNextUpdate is the CRL lifetime used when crl.next_update is not
+// configured.
+const DefaultCRLNextUpdate = 24 * time.Hour
+
+// RevocationReasons maps the reason names of the admin API to their RFC 5280
+// CRLReason codes. removeFromCRL only exists in delta CRLs and is left out.
+var RevocationReasons = map[string]int{
+	"unspecified":            0,
+	"key_compromise":         1,
+	"ca_compromise":          2,
+	"affiliation_changed":    3,
+	"superseded":             4,
+	"cessation_of_operation": 5,
+	"certificate_hold":       6,
+	"privilege_withdrawn":    9,
+	"aa_compromise":          10,
+}
+
+var oidExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+
+// Revocation is a revoked certificate.
+type Revocation struct {
+	Serial    *big.Int
+	RevokedAt time.Time
+	// Reason is the RFC 5280 CRLReason code.
+	Reason int
+}
+
+type RevocationRepo interface {
+	Revoke(ctx context.Context, serial *big.Int, reason int) error
+	// GetRevocation returns nil if the certificate is not revoked.
+	GetRevocation(ctx context.Context, serial *big.Int) (*Revocation, error)
+	// ListRevoked returns the revoked certificates issued by issuer.
+	ListRevoked(ctx context.Context, issuer *x509.Certificate) ([]*Revocation, error)
+}
+
+type RevocationUsecase struct {
+	repo       RevocationRepo
+	ca         *SCEPCAUsecase
+	nextUpdate time.Duration
+
+	// signMu serializes the signing of CRLs, so that concurrent requests
+	// for a CRL that is not cached sign it once.
+	signMu sync.Mutex
+	mu     sync.Mutex
+	cache  map[CaType]*crlCacheEntry
+
+	log *log.Helper
+}
+
+// crlCacheEntry is a signed CRL and what it was signed from.
+type crlCacheEntry struct {
+	der []byte
+	// ca is the raw certificate of the CA that signed it.
+	ca []byte
+	// revoked is the digest of the revocations it lists.
+	revoked    [sha256.Size]byte
+	thisUpdate time.Time
+}
+
+func NewRevocationUsecase(c *conf.Data, repo RevocationRepo, ca *SCEPCAUsecase, logger log.Logger) *RevocationUsecase {
+	nextUpdate := c.GetCrl().GetNextUpdate().AsDuration()
+	if nextUpdate <= 0 {
+		nextUpdate = DefaultCRLNextUpdate
+	}
+	return &RevocationUsecase{
+		repo:       repo,
+		ca:         ca,
+		nextUpdate: nextUpdate,
+		cache:      map[CaType]*crlCacheEntry{},
+		log:        log.NewHelper(log.With(logger, "module", "usecase/scep/revocation")),
+	}
+}
+
+// Revoke revokes the certificate with the given serial number. The next
+// CRL of its CA lists it.
+func (uc *RevocationUsecase) Revoke(ctx context.Context, serial *big.Int, reason string) error {
+	code, ok := RevocationReasons[reason]
+	if !ok {
+		return InvalidRevocationReasonErr
+	}
+	if err := uc.repo.Revoke(ctx, serial, code); err != nil {
+		return err
+	}
+	uc.mu.Lock()
+	uc.cache = map[CaType]*crlCacheEntry{}
+	uc.mu.Unlock()
+	uc.log.Infof("revoked certificate %s, reason %s", serial.Text(16), reason)
+	return nil
+}
+
+// Get returns the revocation of the certificate with the given serial
+// number, or nil if it is not revoked.
+func (uc *RevocationUsecase) Get(ctx context.Context, serial *big.Int) (*Revocation, error) {
+	return uc.repo.GetRevocation(ctx, serial)
+}
+
+// CRL returns a DER encoded CRL of the certificates revoked by the CA of
+// type t. Signed CRLs are cached until a certificate is revoked, also by
+// another replica, the CA changes or half of their lifetime has passed.
+func (uc *RevocationUsecase) CRL(ctx context.Context, t CaType) ([]byte, error) {
+	crt, err := uc.ca.GetCACert(t.String())
+	if err != nil {
+		return nil, err
+	}
+	revs, err := uc.repo.ListRevoked(ctx, crt)
+	if err != nil {
+		return nil, err
+	}
+	revoked := revocationsDigest(revs)
+	if der := uc.cachedCRL(t, crt, revoked); der != nil {
+		return der, nil
+	}
+	uc.signMu.Lock()
+	defer uc.signMu.Unlock()
+	if der := uc.cachedCRL(t, crt, revoked); der != nil {
+		return der, nil
+	}
+	signer, err := uc.ca.GetCAKey(t.String())
+	if err != nil {
+		return nil, err
+	}
+	// smx509 signs with SM2 and SM3 for the SM2 CA, but only reads the
+	// deprecated RevokedCertificates, so the reason extension is added here
+	entries := make([]pkix.RevokedCertificate, 0, len(revs))
+	for _, rev := range revs {
+		entry := pkix.RevokedCertificate{
+			SerialNumber:   rev.Serial,
+			RevocationTime: rev.RevokedAt.UTC(),
+		}
+		// unspecified is left out, RFC 5280 5.3.1
+		if rev.Reason != 0 {
+			reason, err := asn1.Marshal(asn1.Enumerated(rev.Reason))
+			if err != nil {
+				return nil, err
+			}
+			entry.Extensions = []pkix.Extension{{Id: oidExtensionReasonCode, Value: reason}}
+		}
+		entries = append(entries, entry)
+	}
+	now := time.Now()
+	der, err := smx509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: entries,
+		// CRLs are signed on demand, so the time keeps the CRL number
+		// increasing without a counter shared between replicas.
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(uc.nextUpdate),
+	}, (*smx509.Certificate)(crt), signer)
+	if err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	uc.cache[t] = &crlCacheEntry{der: der, ca: crt.Raw, revoked: revoked, thisUpdate: now}
+	uc.mu.Unlock()
+	return der, nil
+}
+
+// cachedCRL returns the cached CRL of t if it was signed by crt, lists the
+// revocations of digest revoked and is still fresh.
+func (uc *RevocationUsecase) cachedCRL(t CaType, crt *x509.Certificate, revoked [sha256.Size]byte) []byte {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	e, ok := uc.cache[t]
+	if !ok {
+		return nil
+	}
+	if !bytes.Equal(e.ca, crt.Raw) || e.revoked != revoked || time.Now().After(e.thisUpdate.Add(uc.nextUpdate/2)) {
+		delete(uc.cache, t)
+		return nil
+	}
+	return e.der
+}
+
+// revocationsDigest hashes revs independently of their order.
+func revocationsDigest(revs []*Revocation) [sha256.Size]byte {
+	lines := make([]string, 0, len(revs))
+	for _, rev := range revs {
+		lines = append(lines, fmt.Sprintf("%s %d %d", rev.Serial.Text(16), rev.RevokedAt.UnixNano(), rev.Reason))
+	}
+	sort.Strings(lines)
+	return sha256.Sum256([]byte(strings.Join(lines, "\n")))
+}
@@ -0,0 +1,62 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"kscep/internal/conf"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+func TestRevocationUsecase_CRL(t *testing.T) {
+	caCrt, caKey := testIssue(t, "rsa ca", 1, nil, nil)
+	revocations := memRevocationRepo{}
+	uc := NewRevocationUsecase(&conf.Data{}, revocations, NewSCEPCAUsecase(&memCARepo{crt: caCrt, key: caKey}, log.DefaultLogger), log.DefaultLogger)
+	ctx := context.Background()
+	crl := func() []byte {
+		t.Helper()
+		der, err := uc.CRL(ctx, RsaCa)
+		if err != nil {
+			t.Fatalf("CRL() error = %v", err)
+		}
+		return der
+	}
+
+	first := crl()
+	if !bytes.Equal(crl(), first) {
+		t.Errorf("second CRL was signed again, want the cached one")
+	}
+
+	// Test a revocation through the usecase signs a new CRL
+	if err := uc.Revoke(ctx, big.NewInt(2), "key_compromise"); err != nil {
+		t.Fatal(err)
+	}
+	second := crl()
+	if bytes.Equal(second, first) {
+		t.Errorf("CRL after a revocation is the cached one")
+	}
+
+	// Test a revocation by another replica signs a new CRL
+	revocations.Revoke(ctx, big.NewInt(3), 1)
+	third := crl()
+	if bytes.Equal(third, second) {
+		t.Errorf("CRL after a revocation by another replica is the cached one")
+	}
+	list, err := x509.ParseRevocationList(third)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.RevokedCertificateEntries) != 2 {
+		t.Errorf("CRL lists %d certificates, want 2", len(list.RevokedCertificateEntries))
+	}
+
+	// Test a CRL past half of its lifetime is signed again
+	uc.cache[RsaCa].thisUpdate = time.Now().Add(-DefaultCRLNextUpdate)
+	if bytes.Equal(crl(), third) {
+		t.Errorf("CRL past half of its lifetime is the cached one")
+	}
+}
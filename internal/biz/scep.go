@@ -3,10 +3,14 @@ package biz
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"kscep/internal/utils"
+	"math/big"
 	"strings"
+	"time"
 
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/ploynomail/pkcs7"
@@ -15,26 +19,56 @@ import (
 
 type SCEPUsecase struct {
 	caUsecase *SCEPCAUsecase
+	// challenge verifies the challengePassword of initial enrollments.
+	challenge *ChallengeUsecase
+	// approval parks enrollments when manual approval is enabled.
+	approval *ApprovalUsecase
+	// policy decides which CSRs may be signed at all.
+	policy *CSRPolicy
+	// certs answers GetCert, revocation GetCRL.
+	certs      *CertificateUsecase
+	revocation *RevocationUsecase
+	// replay rejects replayed pkiMessages.
+	replay *ReplayUsecase
+	// caps are advertised by GetCACaps.
+	caps *CACaps
 	// The (chainable) CSR signing function. Intended to handle all
 	// SCEP request functionality such as CSR & challenge checking, CA
 	// issuance, RA proxying, etc.
 	signer *CSRSignerUsecase
+	// metrics count the outcome of PKIOperations.
+	metrics *Metrics
 
 	/// info logging is implemented in the service middleware layer.
 	log *log.Helper
 }
 
 // NewSCEPRepo returns a new SCEPRepo instance.
-func NewSCEPUsecase(cu *SCEPCAUsecase, singer *CSRSignerUsecase, logger log.Logger) *SCEPUsecase {
+func NewSCEPUsecase(cu *SCEPCAUsecase, singer *CSRSignerUsecase, challenge *ChallengeUsecase, approval *ApprovalUsecase, policy *CSRPolicy, certs *CertificateUsecase, revocation *RevocationUsecase, replay *ReplayUsecase, caps *CACaps, metrics *Metrics, logger log.Logger) *SCEPUsecase {
 	return &SCEPUsecase{
-		caUsecase: cu,
-		signer:    singer,
-		log:       log.NewHelper(log.With(logger, "module", "usecase/scep")),
+		caUsecase:  cu,
+		challenge:  challenge,
+		approval:   approval,
+		policy:     policy,
+		certs:      certs,
+		revocation: revocation,
+		replay:     replay,
+		caps:       caps,
+		signer:     singer,
+		metrics:    metrics,
+		log:        log.NewHelper(log.With(logger, "module", "usecase/scep")),
 	}
 }
 
-func (svc *SCEPUsecase) GetCACaps(ctx context.Context) ([]byte, error) {
-	return GBT0089Caps, nil
+// GetCACaps returns the capabilities of the CA of the requested type.
+// GetNextCACert is only advertised while a next CA is staged.
+func (svc *SCEPUsecase) GetCACaps(ctx context.Context, msg string) ([]byte, error) {
+	caType := strings.ToUpper(strings.Trim(msg, "\n"))
+	if !utils.IsInArray(SupportedCaTypes, caType) {
+		return nil, UnsupportedCaTypeErr
+	}
+	_, err := svc.caUsecase.GetNextCACert(caType)
+	return svc.caps.Get(GetCaType(caType), err == nil), nil
 }
 
 func (svc *SCEPUsecase) GetCACert(ctx context.Context, msg string) ([]byte, int, error) {
@@ -47,57 +81,451 @@ func (svc *SCEPUsecase) GetCACert(ctx context.Context, msg string) ([]byte, int,
 		svc.log.Errorf("failed to get CA cert: %v", err)
 		return nil, 0, MissingCaCertErr
 	}
-	addlCA, err := svc.caUsecase.GetAddlCA()
+	chain, err := svc.caUsecase.GetCAChain(cer)
 	if err != nil {
 		return nil, 0, err
 	}
-	if len(addlCA) < 1 {
+	certs := []*x509.Certificate{cer}
+	// in RA mode clients find the RA certificate to encrypt to by its key
+	// usage in the RA+CA chain
+	if ra, _ := svc.ra(GetCaType(caType), cer); ra != nil {
+		certs = append(certs, ra)
+	}
+	certs = append(certs, chain...)
+	if len(certs) == 1 {
 		return cer.Raw, 1, nil
 	}
-	certs := []*x509.Certificate{cer}
-	certs = append(certs, addlCA...)
 	data, err := svc.DegenerateCertificates(certs)
-	return data, len(addlCA) + 1, err
+	return data, len(certs), err
 }
 
-// TODO
-func (svc *SCEPUsecase) PKIOperation(ctx context.Context, data []byte) ([]byte, error) {
-	caCrt, err := svc.caUsecase.GetCACert("RSA")
+// ra returns the RA answering for the CA of type t, or nil if it has none.
+// An RA that was not issued by caCrt, the current CA, is ignored.
+func (svc *SCEPUsecase) ra(t CaType, caCrt *x509.Certificate) (*x509.Certificate, crypto.Signer) {
+	crt, key, err := svc.caUsecase.GetRA(t.String())
+	if err != nil {
+		if !errors.Is(err, MissingRaErr) {
+			svc.log.Errorf("failed to get %s RA: %v", t, err)
+		}
+		return nil, nil
+	}
+	if err := checkSignatureFrom(crt, caCrt); err != nil {
+		svc.log.Warnf("ignoring %s RA not issued by the current CA: %v", t, err)
+		return nil, nil
+	}
+	return crt, key
+}
+
+// CheckProfile fails with UnknownProfileErr unless profile names a
+// configured certificate profile or is empty.
+func (svc *SCEPUsecase) CheckProfile(profile string) error {
+	_, err := svc.signer.Profile(profile)
+	return err
+}
+
+// PKIOperation handles a pkiMessage sent to the given SCEP profile.
+func (svc *SCEPUsecase) PKIOperation(ctx context.Context, profile string, data []byte) (rep []byte, err error) {
+	if err := svc.CheckProfile(profile); err != nil {
+		return nil, err
+	}
+	var req *pkiRequest
+	defer func() { svc.metrics.observePKIOperation(req, err) }()
+	req, err = parsePKIRequest(data)
 	if err != nil {
 		return nil, err
 	}
-	caKey, err := svc.caUsecase.GetCAKey("RSA")
+	ca, err := svc.recipientCA(req)
 	if err != nil {
 		return nil, err
 	}
+	caCrt, caKey := ca.Cert, ca.Key
+	// RFC 8894 3.2.1.5: a senderNonce seen before is a replayed message
+	if err := svc.replay.CheckNonce(ctx, req.SenderNonce); err != nil {
+		if !errors.Is(err, NonceReusedErr) {
+			return nil, err
+		}
+		svc.log.Warnf("replayed senderNonce in transaction %s", req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	switch req.MessageType {
+	case scep.PKCSReq, scep.RenewalReq, scep.UpdateReq:
+		return svc.enroll(ctx, profile, req, ca)
+	case scep.CertPoll:
+		return svc.certPoll(ctx, req, caCrt, caKey)
+	case scep.GetCert:
+		return svc.getCert(ctx, req, caCrt, caKey)
+	case scep.GetCRL:
+		return svc.getCRL(ctx, req, caCrt, caKey)
+	default:
+		svc.log.Warnf("unsupported message type %s in transaction %s", req.MessageType, req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+}
+
+// recipientCA is the CA a pkiMessage was encrypted to. Cert and Key decrypt
+// the request and sign the reply; they are the RA's when the message was
+// encrypted to the RA of the CA. CA issues the certificate.
+type recipientCA struct {
+	Type CaType
+	CA   *x509.Certificate
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
 
-	msg, err := scep.ParsePKIMessage(data, scep.WithLogger(utils.LoggerWapper(svc.log)))
+// recipientCA selects the CA by the issuer and serial number in the
+// RecipientInfo of the pkcsPKIEnvelope. Clients encrypt to the certificate
+// they fetched with GetCACert, so this is the CA, or its RA, they expect to
+// answer.
+func (svc *SCEPUsecase) recipientCA(req *pkiRequest) (*recipientCA, error) {
+	rids, err := req.recipients()
+	if err != nil {
+		// an envelope we cannot read the recipients of is left to the
+		// default CA to decrypt
+		svc.log.Warnf("failed to read recipients of transaction %s: %v", req.TransactionID, err)
+		return svc.loadCA(RsaCa)
+	}
+	for _, t := range []CaType{RsaCa, EccCa, SM2Ca} {
+		crt, err := svc.caUsecase.GetCACert(t.String())
+		if err != nil || crt == nil {
+			continue
+		}
+		if raCrt, raKey := svc.ra(t, crt); raCrt != nil {
+			for _, rid := range rids {
+				if rid.matches(raCrt) {
+					ca, err := svc.loadCA(t)
+					if err != nil {
+						return nil, err
+					}
+					ca.Cert, ca.Key = raCrt, raKey
+					return ca, nil
+				}
+			}
+		}
+		for _, rid := range rids {
+			if rid.matches(crt) {
+				return svc.loadCA(t)
+			}
+		}
+	}
+	return nil, UnknownRecipientErr
+}
+
+func (svc *SCEPUsecase) loadCA(t CaType) (*recipientCA, error) {
+	crt, err := svc.caUsecase.GetCACert(t.String())
 	if err != nil {
 		return nil, err
 	}
-	if err := msg.DecryptPKIEnvelope(caCrt, caKey); err != nil {
+	key, err := svc.caUsecase.GetCAKey(t.String())
+	if err != nil {
 		return nil, err
 	}
+	return &recipientCA{Type: t, CA: crt, Cert: crt, Key: key}, nil
+}
 
-	crt, err := svc.signer.SignCSR(ctx, msg.CSRReqMessage)
+// enroll handles PKCSReq, RenewalReq and UpdateReq messages.
+func (svc *SCEPUsecase) enroll(ctx context.Context, profile string, req *pkiRequest, ca *recipientCA) ([]byte, error) {
+	caCrt, caKey := ca.Cert, ca.Key
+	start := time.Now()
+	csrMsg, err := req.csrReqMessage(caCrt, caKey)
+	svc.metrics.ObservePhase(PhaseDecrypt, start)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client repeats its PKCSReq while it is told PENDING, answer it
+	// with the current state of the queued request
+	parking := svc.approval.Manual() && req.MessageType == scep.PKCSReq
+	if parking {
+		pr, err := svc.approval.Lookup(ctx, string(req.TransactionID))
+		if err == nil {
+			return svc.pendingReply(req, pr, caCrt, caKey)
+		}
+		if !errors.Is(err, PendingNotFoundErr) {
+			return nil, err
+		}
+	}
+
+	// a repeated transaction is answered with the certificate issued for it
+	// rather than a second one
+	serial, err := svc.replay.Issued(ctx, req.MessageType, req.TransactionID)
+	if err == nil {
+		return svc.reissue(ctx, req, csrMsg.CSR, serial, caCrt, caKey)
+	}
+	if !errors.Is(err, TransactionNotFoundErr) {
+		return nil, err
+	}
+
+	// RFC 8894 3.3.1.2: a renewal is authenticated by the certificate it
+	// is signed with instead of a challenge password.
+	challenge := ""
+	renewal := req.MessageType == scep.RenewalReq
+	if renewal {
+		if err := svc.checkRenewal(ctx, req.Signer, csrMsg.CSR, ca.CA); err != nil {
+			var v *PolicyViolation
+			if !errors.As(err, &v) {
+				return nil, err
+			}
+			svc.log.Warnf("rejected renewal in transaction %s: %s", req.TransactionID, v.Reason)
+			return req.certRep(caCrt, caKey, scep.FAILURE, v.FailInfo)
+		}
+	} else {
+		challenge = csrMsg.ChallengePassword
+		if err := svc.challenge.Verify(ctx, &ChallengeRequest{
+			Profile:   profile,
+			Challenge: challenge,
+			CSR:       csrMsg.CSR,
+		}); err != nil {
+			svc.log.Warnf("challenge verification failed for transaction %s: %v", req.TransactionID, err)
+			return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+		}
+	}
+
+	p, err := svc.signer.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.CheckSANs(csrMsg.CSR); err != nil {
+		svc.log.Warnf("rejected transaction %s: %v", req.TransactionID, err)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	if err := svc.policy.Evaluate(csrMsg.CSR); err != nil {
+		var v *PolicyViolation
+		if !errors.As(err, &v) {
+			return nil, err
+		}
+		svc.log.Warnf("rejected transaction %s: %s", req.TransactionID, v.Reason)
+		return req.certRep(caCrt, caKey, scep.FAILURE, v.FailInfo)
+	}
+
+	// a one-time challenge is used up before signing, so that concurrent
+	// requests cannot share it, and given back if no certificate results
+	if err := svc.challenge.Consume(ctx, challenge); err != nil {
+		if !errors.Is(err, ChallengeConsumedErr) {
+			return nil, err
+		}
+		svc.log.Warnf("challenge of transaction %s was consumed concurrently", req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+
+	if parking {
+		pr, err := svc.approval.Park(ctx, string(req.TransactionID), profile, ca.Type, csrMsg.CSR)
+		if err != nil {
+			svc.challenge.Release(ctx, challenge)
+			return nil, err
+		}
+		return svc.pendingReply(req, pr, caCrt, caKey)
+	}
+
+	crt, err := svc.signer.SignCSR(ctx, ca.Type, profile, csrMsg)
 	if err == nil && crt == nil {
 		err = errors.New("no signed certificate")
 	}
 	if err != nil {
+		svc.challenge.Release(ctx, challenge)
 		svc.log.Errorf("failed to sign CSR: %v", err)
-		certRep, err := msg.Fail(caCrt, caKey, scep.BadRequest)
-		return certRep.Raw, err
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	if err := svc.replay.RecordIssued(ctx, req.MessageType, req.TransactionID, crt.SerialNumber); err != nil {
+		svc.log.Errorf("failed to record transaction %s: %v", req.TransactionID, err)
+	}
+	// the depot revokes certificates with the same subject on its own, a
+	// renewal under another subject is superseded here
+	if renewal {
+		err := svc.revocation.Revoke(ctx, req.Signer.SerialNumber, "superseded")
+		if err != nil && !errors.Is(err, AlreadyRevokedErr) {
+			svc.log.Errorf("failed to revoke renewed certificate %s: %v", req.Signer.SerialNumber.Text(16), err)
+		}
+	}
+
+	return req.certRep(caCrt, caKey, scep.SUCCESS, "", crt)
+}
+
+// reissue answers a repeated transaction with the certificate issued for
+// it, provided the CSR asks for the same public key.
+func (svc *SCEPUsecase) reissue(ctx context.Context, req *pkiRequest, csr *x509.CertificateRequest, serial *big.Int, caCrt *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	crt, err := svc.certs.Get(ctx, serial)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := crt.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(csr.PublicKey) {
+		svc.log.Warnf("transaction %s repeated with another public key", req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	svc.log.Infof("transaction %s repeated, returning certificate %s", req.TransactionID, serial.Text(16))
+	return req.certRep(caCrt, caKey, scep.SUCCESS, "", crt)
+}
+
+// certPoll answers a CertPoll (GetCertInitial) with the outcome of the
+// manual approval of the polled transaction.
+func (svc *SCEPUsecase) certPoll(ctx context.Context, req *pkiRequest, caCrt *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	pr, err := svc.approval.Lookup(ctx, string(req.TransactionID))
+	if errors.Is(err, PendingNotFoundErr) {
+		svc.log.Warnf("CertPoll for unknown transaction %s", req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadCertID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return svc.pendingReply(req, pr, caCrt, caKey)
+}
+
+// issuerAndSerial decrypts the IssuerAndSerialNumber a GetCert or GetCRL
+// asks for.
+func (svc *SCEPUsecase) issuerAndSerial(req *pkiRequest, caCrt *x509.Certificate, caKey crypto.Signer) (*issuerAndSerial, error) {
+	start := time.Now()
+	content, err := req.decrypt(caCrt, caKey)
+	svc.metrics.ObservePhase(PhaseDecrypt, start)
+	if err != nil {
+		return nil, err
+	}
+	var ias issuerAndSerial
+	rest, err := asn1.Unmarshal(content, &ias)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 || ias.SerialNumber == nil {
+		return nil, errors.New("malformed IssuerAndSerialNumber")
+	}
+	return &ias, nil
+}
+
+// getCert answers a GetCert with the issued certificate it names, see
+// RFC 8894 3.3.3.
+func (svc *SCEPUsecase) getCert(ctx context.Context, req *pkiRequest, caCrt *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	ias, err := svc.issuerAndSerial(req, caCrt, caKey)
+	if err != nil {
+		svc.log.Warnf("bad GetCert in transaction %s: %v", req.TransactionID, err)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	crt, err := svc.certs.Get(ctx, ias.SerialNumber)
+	if errors.Is(err, CertNotFoundErr) || (err == nil && !ias.matches(crt)) {
+		svc.log.Warnf("GetCert for unknown certificate %s in transaction %s", ias.SerialNumber.Text(16), req.TransactionID)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadCertID)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return req.certRep(caCrt, caKey, scep.SUCCESS, "", crt)
+}
 
-	certRep, err := msg.Success(caCrt, caKey, crt)
-	return certRep.Raw, err
+// getCRL answers a GetCRL with the current CRL of the CA that issued the
+// certificate it names, see RFC 8894 3.3.4.
+func (svc *SCEPUsecase) getCRL(ctx context.Context, req *pkiRequest, caCrt *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	ias, err := svc.issuerAndSerial(req, caCrt, caKey)
+	if err != nil {
+		svc.log.Warnf("bad GetCRL in transaction %s: %v", req.TransactionID, err)
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	}
+	for _, t := range []CaType{RsaCa, EccCa, SM2Ca} {
+		crt, err := svc.caUsecase.GetCACert(t.String())
+		if err != nil || crt == nil || !bytes.Equal(crt.RawSubject, ias.IssuerName.FullBytes) {
+			continue
+		}
+		crl, err := svc.revocation.CRL(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		return req.crlRep(caCrt, caKey, crl)
+	}
+	svc.log.Warnf("GetCRL for unknown issuer in transaction %s", req.TransactionID)
+	return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadCertID)
 }
 
-func (svc *SCEPUsecase) GetNextCACert(ctx context.Context) ([]byte, error) {
-	return nil, errors.New("not yet implemented")
+// pendingReply builds the CertRep for a parked request: SUCCESS once it is
+// approved, FAILURE once rejected and PENDING until then.
+func (svc *SCEPUsecase) pendingReply(req *pkiRequest, pr *PendingRequest, caCrt *x509.Certificate, caKey crypto.Signer) ([]byte, error) {
+	switch {
+	// a request signed but not yet marked issued has its certificate too
+	case pr.Status == StatusIssued, pr.Status == StatusApproving && pr.Certificate != nil:
+		return req.certRep(caCrt, caKey, scep.SUCCESS, "", pr.Certificate)
+	case pr.Status == StatusRejected:
+		return req.certRep(caCrt, caKey, scep.FAILURE, scep.BadRequest)
+	default:
+		return req.certRep(caCrt, caKey, scep.PENDING, "")
+	}
+}
+
+// checkRenewal returns a *PolicyViolation unless signer, the certificate a
+// renewal is authenticated with, passes checkIssued and is within its
+// renewal window, and csr matches its subject.
+func (svc *SCEPUsecase) checkRenewal(ctx context.Context, signer *x509.Certificate, csr *x509.CertificateRequest, caCrt *x509.Certificate) error {
+	if err := svc.checkIssued(ctx, signer, caCrt); err != nil {
+		return err
+	}
+	if days := svc.signer.AllowRenewalDays(); days > 0 && time.Now().AddDate(0, 0, days).Before(signer.NotAfter) {
+		return badTime("signer %s expires at %s, renewal is allowed %d days before", signer.SerialNumber.Text(16), signer.NotAfter, days)
+	}
+	return svc.policy.CheckRenewal(csr, signer)
+}
+
+// checkIssued returns a *PolicyViolation unless crt was issued by caCrt, is
+// known to the depot, not revoked and valid.
+func (svc *SCEPUsecase) checkIssued(ctx context.Context, crt *x509.Certificate, caCrt *x509.Certificate) error {
+	serial := crt.SerialNumber.Text(16)
+	if err := checkSignatureFrom(crt, caCrt); err != nil {
+		return badCertID("signer %s was not issued by the CA: %v", serial, err)
+	}
+	issued, err := svc.certs.Get(ctx, crt.SerialNumber)
+	if errors.Is(err, CertNotFoundErr) || (err == nil && !bytes.Equal(issued.Raw, crt.Raw)) {
+		return badCertID("signer %s is not an issued certificate", serial)
+	}
+	if err != nil {
+		return err
+	}
+	rev, err := svc.revocation.Get(ctx, crt.SerialNumber)
+	if err != nil {
+		return err
+	}
+	if rev != nil {
+		return badCertID("signer %s was revoked at %s", serial, rev.RevokedAt)
+	}
+	now := time.Now()
+	if now.Before(crt.NotBefore) || now.After(crt.NotAfter) {
+		return badTime("signer %s is only valid from %s to %s", serial, crt.NotBefore, crt.NotAfter)
+	}
+	return nil
+}
+
+// GetNextCACert returns the CA certificate staged to replace the current CA
+// of the requested type. As required by RFC 8894 3.5.2 it is wrapped in a
+// certificates-only SignedData signed by the current CA, so clients can
+// trust it on the strength of the CA they already know.
+func (svc *SCEPUsecase) GetNextCACert(ctx context.Context, msg string) ([]byte, error) {
+	caType := strings.ToUpper(strings.Trim(msg, "\n"))
+	if !utils.IsInArray(SupportedCaTypes, caType) {
+		return nil, UnsupportedCaTypeErr
+	}
+	next, err := svc.caUsecase.GetNextCACert(caType)
+	if err != nil {
+		return nil, err
+	}
+	ca, err := svc.loadCA(GetCaType(caType))
+	if err != nil {
+		svc.log.Errorf("failed to get CA: %v", err)
+		return nil, MissingCaCertErr
+	}
+	if ca.Type == SM2Ca {
+		return gmSignCertificates(ca.Cert, ca.Key, next)
+	}
+	sd, err := pkcs7.NewSignedData(nil)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	sd.AddCertificate(next)
+	if err := sd.AddSigner(ca.Cert, ca.Key, pkcs7.SignerInfoConfig{}); err != nil {
+		return nil, err
+	}
+	return sd.Finish()
 }
 
 func (svc *SCEPUsecase) DegenerateCertificates(certs []*x509.Certificate) ([]byte, error) {
+	return degenerateCertificates(certs)
+}
+
+// degenerateCertificates creates a certs-only PKCS#7 SignedData.
+func degenerateCertificates(certs []*x509.Certificate) ([]byte, error) {
 	var buf bytes.Buffer
 	for _, cert := range certs {
 		buf.Write(cert.Raw)
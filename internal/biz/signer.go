@@ -3,6 +3,7 @@ package biz
 import (
 	"context"
 	"crypto/x509"
+	"fmt"
 	"kscep/internal/conf"
 
 	"github.com/go-kratos/kratos/v2/log"
@@ -21,27 +22,123 @@ type CSRReqMessage struct {
 	ChallengePassword string
 }
 
+// DefaultValidityDays is the validity of issued certificates when
+// RSAsigerconfig.validityDay is not set.
+const DefaultValidityDays = 365
+
+// SignRequest is a CSR to be issued by one of the CAs.
+type SignRequest struct {
+	CaType  CaType
+	Profile *CertProfile
+	CSR     *scep.CSRReqMessage
+	// Serving is set for the serving certificate of kscep, which is renewed
+	// before the renewal window of enrolled certificates and so is not
+	// checked for an existing certificate of its subject.
+	Serving bool
+}
+
 type CSRSignerRepo interface {
-	SignCSRContext(context.Context, *scep.CSRReqMessage) (*x509.Certificate, error)
-	WithCAPass(pass string)
+	SignCSRContext(context.Context, *SignRequest) (*x509.Certificate, error)
 	WithAllowRenewalDays(r int)
 	WithValidityDays(v int)
 	WithSeverAttrs()
 }
 
 type CSRSignerUsecase struct {
-	repo CSRSignerRepo
-	conf *conf.Data
-	log  *log.Helper
+	repo     CSRSignerRepo
+	conf     *conf.Data
+	profiles map[string]*CertProfile
+	// allowRenewalDays is how many days before it expires a certificate
+	// may be renewed, 0 for any time.
+	allowRenewalDays int
+	log              *log.Helper
 }
 
-func NewCSRSignerUsecase(conf *conf.Data, logger log.Logger) *CSRSignerUsecase {
+// NewCSRSignerUsecase configures repo from the RSAsigerconfig section and
+// loads the certificate profiles.
+func NewCSRSignerUsecase(conf *conf.Data, repo CSRSignerRepo, logger log.Logger) (*CSRSignerUsecase, error) {
+	profiles := map[string]*CertProfile{"": DefaultCertProfile()}
+	for name, pc := range conf.GetProfiles() {
+		if name == "" {
+			return nil, fmt.Errorf("profile with an empty name")
+		}
+		p, err := NewCertProfile(name, pc)
+		if err != nil {
+			return nil, err
+		}
+		profiles[name] = p
+	}
+
+	c := conf.GetRSAsigerconfig()
+	validityDays := int(c.GetValidityDay())
+	if validityDays <= 0 {
+		validityDays = DefaultValidityDays
+	}
+	allowRenewalDays := int(c.GetAllowRenewal())
+	repo.WithAllowRenewalDays(allowRenewalDays)
+	repo.WithValidityDays(validityDays)
 	return &CSRSignerUsecase{
-		conf: conf,
-		log:  log.NewHelper(log.With(logger, "module", "usecase/scep/signer")),
+		repo:             repo,
+		conf:             conf,
+		profiles:         profiles,
+		allowRenewalDays: allowRenewalDays,
+		log:              log.NewHelper(log.With(logger, "module", "usecase/scep/signer")),
+	}, nil
+}
+
+// AllowRenewalDays is how many days before it expires a certificate may be
+// renewed, 0 for any time.
+func (uc *CSRSignerUsecase) AllowRenewalDays() int {
+	return uc.allowRenewalDays
+}
+
+// Profile returns the named certificate profile. The empty name is the
+// default profile.
+func (uc *CSRSignerUsecase) Profile(name string) (*CertProfile, error) {
+	p, ok := uc.profiles[name]
+	if !ok {
+		return nil, UnknownProfileErr
 	}
+	return p, nil
 }
 
-func (uc *CSRSignerUsecase) SignCSR(ctx context.Context, csr *scep.CSRReqMessage) (*x509.Certificate, error) {
-	return uc.repo.SignCSRContext(ctx, csr)
+// SignCSR issues a certificate for csr from the named profile, signed by
+// the CA of type t.
+func (uc *CSRSignerUsecase) SignCSR(ctx context.Context, t CaType, profile string, csr *scep.CSRReqMessage) (*x509.Certificate, error) {
+	p, err := uc.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	return uc.sign(ctx, t, p, csr)
+}
+
+// SignServingCSR issues the TLS serving certificate of kscep for csr from
+// the "tls" profile if one is configured, and from a server authentication
+// profile otherwise. validityDays overrides that of the profile if set.
+func (uc *CSRSignerUsecase) SignServingCSR(ctx context.Context, t CaType, validityDays int, csr *scep.CSRReqMessage) (*x509.Certificate, error) {
+	p, ok := uc.profiles[ServingCertProfileName]
+	if !ok {
+		p = &CertProfile{
+			Name:         ServingCertProfileName,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			ValidityDays: DefaultServingCertValidityDays,
+		}
+	}
+	if validityDays > 0 {
+		cp := *p
+		cp.ValidityDays = validityDays
+		p = &cp
+	}
+	if err := p.CheckSANs(csr.CSR); err != nil {
+		return nil, err
+	}
+	return uc.repo.SignCSRContext(ctx, &SignRequest{CaType: t, Profile: p, CSR: csr, Serving: true})
+}
+
+func (uc *CSRSignerUsecase) sign(ctx context.Context, t CaType, p *CertProfile, csr *scep.CSRReqMessage) (*x509.Certificate, error) {
+	if err := p.CheckSANs(csr.CSR); err != nil {
+		return nil, err
+	}
+	return uc.repo.SignCSRContext(ctx, &SignRequest{CaType: t, Profile: p, CSR: csr})
 }
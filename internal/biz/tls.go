@@ -0,0 +1,150 @@
+package biz
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"kscep/internal/utils"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+)
+
+// DefaultServingCertValidityDays is the validity of the serving
+// certificate kscep issues itself when none is configured.
+const DefaultServingCertValidityDays = 90
+
+// ServingCertProfileName is the profile the serving certificate is issued
+// from when it is configured.
+const ServingCertProfileName = "tls"
+
+// ServingCertRequest describes the serving certificate of kscep.
+type ServingCertRequest struct {
+	CaType       CaType
+	CommonName   string
+	DNSNames     []string
+	IPAddresses  []net.IP
+	ValidityDays int
+}
+
+// ServerTLSUsecase issues the TLS serving certificate of kscep from its CA
+// and verifies TLS client certificates against it.
+type ServerTLSUsecase struct {
+	scep *SCEPUsecase
+	log  *log.Helper
+}
+
+func NewServerTLSUsecase(scep *SCEPUsecase, logger log.Logger) *ServerTLSUsecase {
+	return &ServerTLSUsecase{
+		scep: scep,
+		log:  log.NewHelper(log.With(logger, "module", "usecase/tls")),
+	}
+}
+
+// ServingCertCaType returns the CA type that issues the serving
+// certificate, RSA by default. SM2 certificates cannot be used by
+// crypto/tls.
+func ServingCertCaType(t string) (CaType, error) {
+	t = strings.ToUpper(t)
+	if t == "SM2" || !utils.IsInArray(SupportedCaTypes, t) {
+		return RsaCa, fmt.Errorf("tls issue: %w: %q", UnsupportedCaTypeErr, t)
+	}
+	return GetCaType(t), nil
+}
+
+// ServingCertCurrent reports whether crt was issued by the current CA of
+// type t and is not past two thirds of its validity.
+func (uc *ServerTLSUsecase) ServingCertCurrent(t CaType, crt *x509.Certificate) bool {
+	caCrt, err := uc.scep.caUsecase.GetCACert(t.String())
+	if err != nil || caCrt == nil || checkSignatureFrom(crt, caCrt) != nil {
+		return false
+	}
+	renewAt := crt.NotBefore.Add(crt.NotAfter.Sub(crt.NotBefore) * 2 / 3)
+	return time.Now().Before(renewAt)
+}
+
+// IssueServingCert issues a server authentication certificate for a new
+// ECDSA P-256 key. It returns the certificate followed by the CA
+// certificate, and the key.
+func (uc *ServerTLSUsecase) IssueServingCert(ctx context.Context, req *ServingCertRequest) ([]*x509.Certificate, *ecdsa.PrivateKey, error) {
+	caCrt, err := uc.scep.caUsecase.GetCACert(req.CaType.String())
+	if err != nil || caCrt == nil {
+		return nil, nil, MissingCaCertErr
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	dnsNames := req.DNSNames
+	if len(dnsNames) == 0 && len(req.IPAddresses) == 0 && req.CommonName != "" {
+		dnsNames = []string{req.CommonName}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: req.CommonName},
+		DNSNames:    dnsNames,
+		IPAddresses: req.IPAddresses,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := uc.scep.policy.Evaluate(csr); err != nil {
+		return nil, nil, err
+	}
+	crt, err := uc.scep.signer.SignServingCSR(ctx, req.CaType, req.ValidityDays, &scep.CSRReqMessage{RawDecrypted: der, CSR: csr})
+	if err != nil {
+		return nil, nil, err
+	}
+	uc.log.Infof("issued serving certificate %s for %q", crt.SerialNumber.Text(16), req.CommonName)
+	return []*x509.Certificate{crt, caCrt}, key, nil
+}
+
+// VerifyClient returns an error unless chain starts with a certificate
+// issued by one of the CAs that is known to the depot, not revoked, valid
+// and for client authentication.
+func (uc *ServerTLSUsecase) VerifyClient(ctx context.Context, chain []*x509.Certificate) error {
+	if len(chain) == 0 {
+		return errors.New("no client certificate")
+	}
+	crt := chain[0]
+	if !clientAuth(crt) {
+		return fmt.Errorf("client certificate %s is not for client authentication", crt.SerialNumber.Text(16))
+	}
+	var err error
+	for _, t := range []CaType{RsaCa, EccCa, SM2Ca} {
+		caCrt, caErr := uc.scep.caUsecase.GetCACert(t.String())
+		if caErr != nil || caCrt == nil {
+			continue
+		}
+		if err = uc.scep.checkIssued(ctx, crt, caCrt); err == nil {
+			return nil
+		}
+	}
+	if err == nil {
+		err = MissingCaCertErr
+	}
+	return err
+}
+
+func clientAuth(crt *x509.Certificate) bool {
+	if len(crt.ExtKeyUsage) == 0 {
+		return true
+	}
+	for _, u := range crt.ExtKeyUsage {
+		if u == x509.ExtKeyUsageClientAuth || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
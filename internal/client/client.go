@@ -59,7 +59,7 @@ func (e *Endpoints) Supports(cap string) bool {
 }
 
 func (e *Endpoints) GetCACert(ctx context.Context, message string) ([]byte, int, error) {
-	request := biz.SCEPRequest{Operation: biz.GetCACaps, Message: []byte(message)}
+	request := biz.SCEPRequest{Operation: biz.GetCACert, Message: []byte(message)}
 	response, err := e.GetEndpoint(ctx, request)
 	if err != nil {
 		return nil, 0, err
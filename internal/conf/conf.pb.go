@@ -81,8 +81,10 @@ type Server struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Http   *Server_HTTP   `protobuf:"bytes,1,opt,name=http,proto3" json:"http,omitempty"`
-	Logger *Server_Logger `protobuf:"bytes,2,opt,name=logger,proto3" json:"logger,omitempty"`
+	Http    *Server_HTTP    `protobuf:"bytes,1,opt,name=http,proto3" json:"http,omitempty"`
+	Logger  *Server_Logger  `protobuf:"bytes,2,opt,name=logger,proto3" json:"logger,omitempty"`
+	Admin   *Server_Admin   `protobuf:"bytes,3,opt,name=admin,proto3" json:"admin,omitempty"`
+	Metrics *Server_Metrics `protobuf:"bytes,4,opt,name=metrics,proto3" json:"metrics,omitempty"`
 }
 
 func (x *Server) Reset() {
@@ -131,6 +133,20 @@ func (x *Server) GetLogger() *Server_Logger {
 	return nil
 }
 
+func (x *Server) GetAdmin() *Server_Admin {
+	if x != nil {
+		return x.Admin
+	}
+	return nil
+}
+
+func (x *Server) GetMetrics() *Server_Metrics {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
 type Data struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -140,6 +156,27 @@ type Data struct {
 	DepotType      string               `protobuf:"bytes,2,opt,name=depot_type,json=depotType,proto3" json:"depot_type,omitempty"`
 	Filedepot      *Data_Filedepot      `protobuf:"bytes,3,opt,name=filedepot,proto3" json:"filedepot,omitempty"`
 	RSAsigerconfig *Data_RSASigerConfig `protobuf:"bytes,4,opt,name=RSAsigerconfig,proto3" json:"RSAsigerconfig,omitempty"`
+	Challenge      *Data_Challenge      `protobuf:"bytes,5,opt,name=challenge,proto3" json:"challenge,omitempty"`
+	// park PKCSReq enrollments until an operator approves them
+	ManualApproval bool `protobuf:"varint,6,opt,name=manual_approval,json=manualApproval,proto3" json:"manual_approval,omitempty"`
+	// certificate profiles by name
+	Profiles  map[string]*Data_Profile `protobuf:"bytes,7,rep,name=profiles,proto3" json:"profiles,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Policy    *Data_Policy             `protobuf:"bytes,8,opt,name=policy,proto3" json:"policy,omitempty"`
+	Boltdepot *Data_Boltdepot          `protobuf:"bytes,9,opt,name=boltdepot,proto3" json:"boltdepot,omitempty"`
+	Crl       *Data_Crl                `protobuf:"bytes,10,opt,name=crl,proto3" json:"crl,omitempty"`
+	Ocsp      *Data_Ocsp               `protobuf:"bytes,11,opt,name=ocsp,proto3" json:"ocsp,omitempty"`
+	Replay    *Data_Replay             `protobuf:"bytes,12,opt,name=replay,proto3" json:"replay,omitempty"`
+	// RA certificates by CA type (RSA, ECC, SM2). Clients encrypt their
+	// requests to the RA, which signs the CertReps, while certificates are
+	// still issued by the CA.
+	Ra map[string]*Data_Ra `protobuf:"bytes,13,rep,name=ra,proto3" json:"ra,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// GetCACaps capabilities by CA type (RSA, ECC, SM2), replacing the
+	// defaults of the type
+	Caps map[string]*Data_Caps `protobuf:"bytes,14,rep,name=caps,proto3" json:"caps,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// passphrases of the encrypted CA keys by CA type (RSA, ECC, SM2).
+	// RSAsigerconfig.capass is used for RSA if it is not listed.
+	Capass      map[string]string `protobuf:"bytes,15,rep,name=capass,proto3" json:"capass,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	KeyProvider *Data_KeyProvider `protobuf:"bytes,16,opt,name=key_provider,json=keyProvider,proto3" json:"key_provider,omitempty"`
 }
 
 func (x *Data) Reset() {
@@ -202,6 +239,90 @@ func (x *Data) GetRSAsigerconfig() *Data_RSASigerConfig {
 	return nil
 }
 
+func (x *Data) GetChallenge() *Data_Challenge {
+	if x != nil {
+		return x.Challenge
+	}
+	return nil
+}
+
+func (x *Data) GetManualApproval() bool {
+	if x != nil {
+		return x.ManualApproval
+	}
+	return false
+}
+
+func (x *Data) GetProfiles() map[string]*Data_Profile {
+	if x != nil {
+		return x.Profiles
+	}
+	return nil
+}
+
+func (x *Data) GetPolicy() *Data_Policy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *Data) GetBoltdepot() *Data_Boltdepot {
+	if x != nil {
+		return x.Boltdepot
+	}
+	return nil
+}
+
+func (x *Data) GetCrl() *Data_Crl {
+	if x != nil {
+		return x.Crl
+	}
+	return nil
+}
+
+func (x *Data) GetOcsp() *Data_Ocsp {
+	if x != nil {
+		return x.Ocsp
+	}
+	return nil
+}
+
+func (x *Data) GetReplay() *Data_Replay {
+	if x != nil {
+		return x.Replay
+	}
+	return nil
+}
+
+func (x *Data) GetRa() map[string]*Data_Ra {
+	if x != nil {
+		return x.Ra
+	}
+	return nil
+}
+
+func (x *Data) GetCaps() map[string]*Data_Caps {
+	if x != nil {
+		return x.Caps
+	}
+	return nil
+}
+
+func (x *Data) GetCapass() map[string]string {
+	if x != nil {
+		return x.Capass
+	}
+	return nil
+}
+
+func (x *Data) GetKeyProvider() *Data_KeyProvider {
+	if x != nil {
+		return x.KeyProvider
+	}
+	return nil
+}
+
 type Server_Logger struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -289,6 +410,7 @@ type Server_HTTP struct {
 	Network string               `protobuf:"bytes,1,opt,name=network,proto3" json:"network,omitempty"`
 	Addr    string               `protobuf:"bytes,2,opt,name=addr,proto3" json:"addr,omitempty"`
 	Timeout *durationpb.Duration `protobuf:"bytes,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+	Tls     *Server_HTTP_TLS     `protobuf:"bytes,4,opt,name=tls,proto3" json:"tls,omitempty"`
 }
 
 func (x *Server_HTTP) Reset() {
@@ -344,17 +466,111 @@ func (x *Server_HTTP) GetTimeout() *durationpb.Duration {
 	return nil
 }
 
-type Data_Database struct {
+func (x *Server_HTTP) GetTls() *Server_HTTP_TLS {
+	if x != nil {
+		return x.Tls
+	}
+	return nil
+}
+
+type Server_Admin struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Driver string `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
-	Source string `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// bearer tokens accepted by the admin API
+	Tokens []string `protobuf:"bytes,1,rep,name=tokens,proto3" json:"tokens,omitempty"`
+	// also accept TLS client certificates issued by the CA, valid, not
+	// revoked, with the client_auth extended key usage and listed in
+	// client_cert_subjects or client_cert_fingerprints
+	ClientCert bool `protobuf:"varint,2,opt,name=client_cert,json=clientCert,proto3" json:"client_cert,omitempty"`
+	// subjects of the admin client certificates, eg: "CN=operator,O=example"
+	ClientCertSubjects []string `protobuf:"bytes,3,rep,name=client_cert_subjects,json=clientCertSubjects,proto3" json:"client_cert_subjects,omitempty"`
+	// SHA-256 fingerprints of the admin client certificates in hex, colons
+	// allowed
+	ClientCertFingerprints []string `protobuf:"bytes,4,rep,name=client_cert_fingerprints,json=clientCertFingerprints,proto3" json:"client_cert_fingerprints,omitempty"`
+}
+
+func (x *Server_Admin) Reset() {
+	*x = Server_Admin{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
 }
 
-func (x *Data_Database) Reset() {
-	*x = Data_Database{}
+func (x *Server_Admin) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Server_Admin) ProtoMessage() {}
+
+func (x *Server_Admin) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Server_Admin.ProtoReflect.Descriptor instead.
+func (*Server_Admin) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{1, 2}
+}
+
+func (x *Server_Admin) GetTokens() []string {
+	if x != nil {
+		return x.Tokens
+	}
+	return nil
+}
+
+func (x *Server_Admin) GetClientCert() bool {
+	if x != nil {
+		return x.ClientCert
+	}
+	return false
+}
+
+func (x *Server_Admin) GetClientCertSubjects() []string {
+	if x != nil {
+		return x.ClientCertSubjects
+	}
+	return nil
+}
+
+func (x *Server_Admin) GetClientCertFingerprints() []string {
+	if x != nil {
+		return x.ClientCertFingerprints
+	}
+	return nil
+}
+
+// Prometheus metrics, served on the HTTP listener when enabled
+type Server_Metrics struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// path of the endpoint, default /metrics
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// valid certificates expiring within this many days are counted by
+	// kscep_certificates_expiring, default 30
+	ExpiringDays int32 `protobuf:"varint,2,opt,name=expiring_days,json=expiringDays,proto3" json:"expiring_days,omitempty"`
+	// serve the endpoint
+	Enabled bool `protobuf:"varint,3,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	// require an admin token or client certificate, as the admin API does;
+	// without it restrict the path on a proxy or firewall
+	AdminAuth bool `protobuf:"varint,4,opt,name=admin_auth,json=adminAuth,proto3" json:"admin_auth,omitempty"`
+}
+
+func (x *Server_Metrics) Reset() {
+	*x = Server_Metrics{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_conf_conf_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -362,13 +578,13 @@ func (x *Data_Database) Reset() {
 	}
 }
 
-func (x *Data_Database) String() string {
+func (x *Server_Metrics) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Data_Database) ProtoMessage() {}
+func (*Server_Metrics) ProtoMessage() {}
 
-func (x *Data_Database) ProtoReflect() protoreflect.Message {
+func (x *Server_Metrics) ProtoReflect() protoreflect.Message {
 	mi := &file_conf_conf_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -380,51 +596,79 @@ func (x *Data_Database) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Data_Database.ProtoReflect.Descriptor instead.
-func (*Data_Database) Descriptor() ([]byte, []int) {
-	return file_conf_conf_proto_rawDescGZIP(), []int{2, 0}
+// Deprecated: Use Server_Metrics.ProtoReflect.Descriptor instead.
+func (*Server_Metrics) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{1, 3}
 }
 
-func (x *Data_Database) GetDriver() string {
+func (x *Server_Metrics) GetPath() string {
 	if x != nil {
-		return x.Driver
+		return x.Path
 	}
 	return ""
 }
 
-func (x *Data_Database) GetSource() string {
+func (x *Server_Metrics) GetExpiringDays() int32 {
 	if x != nil {
-		return x.Source
+		return x.ExpiringDays
 	}
-	return ""
+	return 0
 }
 
-type Data_Filedepot struct {
+func (x *Server_Metrics) GetEnabled() bool {
+	if x != nil {
+		return x.Enabled
+	}
+	return false
+}
+
+func (x *Server_Metrics) GetAdminAuth() bool {
+	if x != nil {
+		return x.AdminAuth
+	}
+	return false
+}
+
+// TLS serves HTTPS. Client certificates are requested, not required:
+// EST reenrollment and the admin API check them themselves.
+type Server_HTTP_TLS struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Capath     string `protobuf:"bytes,1,opt,name=capath,proto3" json:"capath,omitempty"`
-	Addlcapath string `protobuf:"bytes,2,opt,name=addlcapath,proto3" json:"addlcapath,omitempty"`
-}
-
-func (x *Data_Filedepot) Reset() {
-	*x = Data_Filedepot{}
+	// PEM files of the serving certificate, followed by its chain, and
+	// of its key. They are reloaded when they change on disk.
+	Cert string `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Key  string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	// lowest protocol version accepted: 1.0, 1.1, 1.2 (default), 1.3
+	MinVersion string `protobuf:"bytes,3,opt,name=min_version,json=minVersion,proto3" json:"min_version,omitempty"`
+	// TLS 1.0-1.2 cipher suites by their Go name, eg:
+	// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Empty uses the Go
+	// defaults, TLS 1.3 suites are not configurable.
+	CipherSuites []string `protobuf:"bytes,4,rep,name=cipher_suites,json=cipherSuites,proto3" json:"cipher_suites,omitempty"`
+	// issue cert and key at startup, and renew them while serving, when
+	// they are missing, were not issued by the current CA or are past two
+	// thirds of their validity
+	Issue *Server_HTTP_TLS_Issue `protobuf:"bytes,5,opt,name=issue,proto3" json:"issue,omitempty"`
+}
+
+func (x *Server_HTTP_TLS) Reset() {
+	*x = Server_HTTP_TLS{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_conf_conf_proto_msgTypes[7]
+		mi := &file_conf_conf_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Data_Filedepot) String() string {
+func (x *Server_HTTP_TLS) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Data_Filedepot) ProtoMessage() {}
+func (*Server_HTTP_TLS) ProtoMessage() {}
 
-func (x *Data_Filedepot) ProtoReflect() protoreflect.Message {
-	mi := &file_conf_conf_proto_msgTypes[7]
+func (x *Server_HTTP_TLS) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -435,52 +679,80 @@ func (x *Data_Filedepot) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Data_Filedepot.ProtoReflect.Descriptor instead.
-func (*Data_Filedepot) Descriptor() ([]byte, []int) {
-	return file_conf_conf_proto_rawDescGZIP(), []int{2, 1}
+// Deprecated: Use Server_HTTP_TLS.ProtoReflect.Descriptor instead.
+func (*Server_HTTP_TLS) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{1, 1, 0}
 }
 
-func (x *Data_Filedepot) GetCapath() string {
+func (x *Server_HTTP_TLS) GetCert() string {
 	if x != nil {
-		return x.Capath
+		return x.Cert
 	}
 	return ""
 }
 
-func (x *Data_Filedepot) GetAddlcapath() string {
+func (x *Server_HTTP_TLS) GetKey() string {
 	if x != nil {
-		return x.Addlcapath
+		return x.Key
 	}
 	return ""
 }
 
-type Data_RSASigerConfig struct {
+func (x *Server_HTTP_TLS) GetMinVersion() string {
+	if x != nil {
+		return x.MinVersion
+	}
+	return ""
+}
+
+func (x *Server_HTTP_TLS) GetCipherSuites() []string {
+	if x != nil {
+		return x.CipherSuites
+	}
+	return nil
+}
+
+func (x *Server_HTTP_TLS) GetIssue() *Server_HTTP_TLS_Issue {
+	if x != nil {
+		return x.Issue
+	}
+	return nil
+}
+
+// Issue is a serving certificate kscep issues itself from its CA
+type Server_HTTP_TLS_Issue struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Capass       string `protobuf:"bytes,1,opt,name=capass,proto3" json:"capass,omitempty"`
-	AllowRenewal int32  `protobuf:"varint,2,opt,name=allowRenewal,proto3" json:"allowRenewal,omitempty"`
-	ValidityDay  int32  `protobuf:"varint,3,opt,name=validityDay,proto3" json:"validityDay,omitempty"`
+	// CA type (RSA, ECC), default RSA
+	Ca         string `protobuf:"bytes,1,opt,name=ca,proto3" json:"ca,omitempty"`
+	CommonName string `protobuf:"bytes,2,opt,name=common_name,json=commonName,proto3" json:"common_name,omitempty"`
+	// SANs of the certificate, the common name if none
+	DnsNames    []string `protobuf:"bytes,3,rep,name=dns_names,json=dnsNames,proto3" json:"dns_names,omitempty"`
+	IpAddresses []string `protobuf:"bytes,4,rep,name=ip_addresses,json=ipAddresses,proto3" json:"ip_addresses,omitempty"`
+	// validity of the certificate, default that of the "tls" profile
+	// or 90
+	ValidityDay int32 `protobuf:"varint,5,opt,name=validity_day,json=validityDay,proto3" json:"validity_day,omitempty"`
 }
 
-func (x *Data_RSASigerConfig) Reset() {
-	*x = Data_RSASigerConfig{}
+func (x *Server_HTTP_TLS_Issue) Reset() {
+	*x = Server_HTTP_TLS_Issue{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_conf_conf_proto_msgTypes[8]
+		mi := &file_conf_conf_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Data_RSASigerConfig) String() string {
+func (x *Server_HTTP_TLS_Issue) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Data_RSASigerConfig) ProtoMessage() {}
+func (*Server_HTTP_TLS_Issue) ProtoMessage() {}
 
-func (x *Data_RSASigerConfig) ProtoReflect() protoreflect.Message {
-	mi := &file_conf_conf_proto_msgTypes[8]
+func (x *Server_HTTP_TLS_Issue) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -491,148 +763,1521 @@ func (x *Data_RSASigerConfig) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Data_RSASigerConfig.ProtoReflect.Descriptor instead.
-func (*Data_RSASigerConfig) Descriptor() ([]byte, []int) {
-	return file_conf_conf_proto_rawDescGZIP(), []int{2, 2}
+// Deprecated: Use Server_HTTP_TLS_Issue.ProtoReflect.Descriptor instead.
+func (*Server_HTTP_TLS_Issue) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{1, 1, 0, 0}
 }
 
-func (x *Data_RSASigerConfig) GetCapass() string {
+func (x *Server_HTTP_TLS_Issue) GetCa() string {
 	if x != nil {
-		return x.Capass
+		return x.Ca
 	}
 	return ""
 }
 
-func (x *Data_RSASigerConfig) GetAllowRenewal() int32 {
+func (x *Server_HTTP_TLS_Issue) GetCommonName() string {
 	if x != nil {
-		return x.AllowRenewal
+		return x.CommonName
 	}
-	return 0
+	return ""
 }
 
-func (x *Data_RSASigerConfig) GetValidityDay() int32 {
+func (x *Server_HTTP_TLS_Issue) GetDnsNames() []string {
 	if x != nil {
-		return x.ValidityDay
+		return x.DnsNames
 	}
-	return 0
+	return nil
 }
 
-var File_conf_conf_proto protoreflect.FileDescriptor
+func (x *Server_HTTP_TLS_Issue) GetIpAddresses() []string {
+	if x != nil {
+		return x.IpAddresses
+	}
+	return nil
+}
 
-var file_conf_conf_proto_rawDesc = []byte{
-	0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x66, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x12, 0x0a, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x1a, 0x1e, 0x67,
-	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64,
-	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5d, 0x0a,
-	0x09, 0x42, 0x6f, 0x6f, 0x74, 0x73, 0x74, 0x72, 0x61, 0x70, 0x12, 0x2a, 0x0a, 0x06, 0x73, 0x65,
-	0x72, 0x76, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6b, 0x72, 0x61,
-	0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x06,
-	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xda, 0x03, 0x0a,
-	0x06, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x2b, 0x0a, 0x04, 0x68, 0x74, 0x74, 0x70, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61,
-	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x52, 0x04,
-	0x68, 0x74, 0x74, 0x70, 0x12, 0x31, 0x0a, 0x06, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x52,
-	0x06, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x1a, 0x84, 0x02, 0x0a, 0x06, 0x4c, 0x6f, 0x67, 0x67,
-	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68,
-	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1d, 0x0a, 0x0a,
-	0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09,
-	0x52, 0x09, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x50, 0x61, 0x74, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x65,
-	0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65,
-	0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x53, 0x0a, 0x0e, 0x69, 0x6e, 0x69, 0x74, 0x69,
-	0x61, 0x6c, 0x5f, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32,
-	0x2c, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72,
-	0x76, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69,
-	0x61, 0x6c, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0d, 0x69,
-	0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x1a, 0x40, 0x0a, 0x12,
-	0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x45, 0x6e, 0x74,
-	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x69,
-	0x0a, 0x04, 0x48, 0x54, 0x54, 0x50, 0x12, 0x18, 0x0a, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72,
-	0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b,
-	0x12, 0x12, 0x0a, 0x04, 0x61, 0x64, 0x64, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x61, 0x64, 0x64, 0x72, 0x12, 0x33, 0x0a, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
-	0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22, 0xd0, 0x03, 0x0a, 0x04, 0x44, 0x61,
-	0x74, 0x61, 0x12, 0x35, 0x0a, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70,
-	0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x52,
-	0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x65, 0x70,
-	0x6f, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64,
-	0x65, 0x70, 0x6f, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x38, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65,
-	0x64, 0x65, 0x70, 0x6f, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6b, 0x72,
-	0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x46, 0x69,
-	0x6c, 0x65, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x52, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x64, 0x65, 0x70,
-	0x6f, 0x74, 0x12, 0x47, 0x0a, 0x0e, 0x52, 0x53, 0x41, 0x73, 0x69, 0x67, 0x65, 0x72, 0x63, 0x6f,
-	0x6e, 0x66, 0x69, 0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6b, 0x72, 0x61,
-	0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x52, 0x53, 0x41,
-	0x53, 0x69, 0x67, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x0e, 0x52, 0x53, 0x41,
-	0x73, 0x69, 0x67, 0x65, 0x72, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x1a, 0x3a, 0x0a, 0x08, 0x44,
-	0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x72, 0x69, 0x76, 0x65,
-	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12,
-	0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x1a, 0x43, 0x0a, 0x09, 0x46, 0x69, 0x6c, 0x65, 0x64,
-	0x65, 0x70, 0x6f, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1e, 0x0a, 0x0a,
-	0x61, 0x64, 0x64, 0x6c, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x0a, 0x61, 0x64, 0x64, 0x6c, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x1a, 0x6e, 0x0a, 0x0e,
-	0x52, 0x53, 0x41, 0x53, 0x69, 0x67, 0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x16,
-	0x0a, 0x06, 0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
-	0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x52,
-	0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x61, 0x6c,
-	0x6c, 0x6f, 0x77, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x12, 0x20, 0x0a, 0x0b, 0x76, 0x61,
-	0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52,
-	0x0b, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61, 0x79, 0x42, 0x1a, 0x5a, 0x18,
-	0x6b, 0x73, 0x63, 0x65, 0x70, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x63,
-	0x6f, 0x6e, 0x66, 0x3b, 0x63, 0x6f, 0x6e, 0x66, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *Server_HTTP_TLS_Issue) GetValidityDay() int32 {
+	if x != nil {
+		return x.ValidityDay
+	}
+	return 0
 }
 
-var (
-	file_conf_conf_proto_rawDescOnce sync.Once
-	file_conf_conf_proto_rawDescData = file_conf_conf_proto_rawDesc
-)
+type Data_Database struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-func file_conf_conf_proto_rawDescGZIP() []byte {
-	file_conf_conf_proto_rawDescOnce.Do(func() {
-		file_conf_conf_proto_rawDescData = protoimpl.X.CompressGZIP(file_conf_conf_proto_rawDescData)
-	})
-	return file_conf_conf_proto_rawDescData
+	// database/sql driver name and data source, used by depot_type "sql"
+	Driver string `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	Source string `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// subject and lifetime of the RSA CA created on first start
+	CaYears        int32  `protobuf:"varint,3,opt,name=ca_years,json=caYears,proto3" json:"ca_years,omitempty"`
+	CaOrganization string `protobuf:"bytes,4,opt,name=ca_organization,json=caOrganization,proto3" json:"ca_organization,omitempty"`
+	CaCountry      string `protobuf:"bytes,5,opt,name=ca_country,json=caCountry,proto3" json:"ca_country,omitempty"`
+	CaKeySize      int32  `protobuf:"varint,6,opt,name=ca_key_size,json=caKeySize,proto3" json:"ca_key_size,omitempty"`
 }
 
-var file_conf_conf_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
-var file_conf_conf_proto_goTypes = []interface{}{
-	(*Bootstrap)(nil),           // 0: kratos.api.Bootstrap
-	(*Server)(nil),              // 1: kratos.api.Server
-	(*Data)(nil),                // 2: kratos.api.Data
-	(*Server_Logger)(nil),       // 3: kratos.api.Server.Logger
-	(*Server_HTTP)(nil),         // 4: kratos.api.Server.HTTP
-	nil,                         // 5: kratos.api.Server.Logger.InitialFieldsEntry
-	(*Data_Database)(nil),       // 6: kratos.api.Data.Database
-	(*Data_Filedepot)(nil),      // 7: kratos.api.Data.Filedepot
-	(*Data_RSASigerConfig)(nil), // 8: kratos.api.Data.RSASigerConfig
-	(*durationpb.Duration)(nil), // 9: google.protobuf.Duration
-}
-var file_conf_conf_proto_depIdxs = []int32{
-	1, // 0: kratos.api.Bootstrap.server:type_name -> kratos.api.Server
-	2, // 1: kratos.api.Bootstrap.data:type_name -> kratos.api.Data
-	4, // 2: kratos.api.Server.http:type_name -> kratos.api.Server.HTTP
-	3, // 3: kratos.api.Server.logger:type_name -> kratos.api.Server.Logger
-	6, // 4: kratos.api.Data.database:type_name -> kratos.api.Data.Database
-	7, // 5: kratos.api.Data.filedepot:type_name -> kratos.api.Data.Filedepot
-	8, // 6: kratos.api.Data.RSAsigerconfig:type_name -> kratos.api.Data.RSASigerConfig
-	5, // 7: kratos.api.Server.Logger.initial_fields:type_name -> kratos.api.Server.Logger.InitialFieldsEntry
-	9, // 8: kratos.api.Server.HTTP.timeout:type_name -> google.protobuf.Duration
-	9, // [9:9] is the sub-list for method output_type
-	9, // [9:9] is the sub-list for method input_type
-	9, // [9:9] is the sub-list for extension type_name
-	9, // [9:9] is the sub-list for extension extendee
-	0, // [0:9] is the sub-list for field type_name
+func (x *Data_Database) Reset() {
+	*x = Data_Database{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Database) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Database) ProtoMessage() {}
+
+func (x *Data_Database) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Database.ProtoReflect.Descriptor instead.
+func (*Data_Database) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 0}
+}
+
+func (x *Data_Database) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *Data_Database) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Data_Database) GetCaYears() int32 {
+	if x != nil {
+		return x.CaYears
+	}
+	return 0
+}
+
+func (x *Data_Database) GetCaOrganization() string {
+	if x != nil {
+		return x.CaOrganization
+	}
+	return ""
+}
+
+func (x *Data_Database) GetCaCountry() string {
+	if x != nil {
+		return x.CaCountry
+	}
+	return ""
+}
+
+func (x *Data_Database) GetCaKeySize() int32 {
+	if x != nil {
+		return x.CaKeySize
+	}
+	return 0
+}
+
+type Data_Filedepot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Capath string `protobuf:"bytes,1,opt,name=capath,proto3" json:"capath,omitempty"`
+	// directory of intermediate and root CA certificates, PEM bundles or
+	// DER files, returned by GetCACert after the CAs they issued
+	Addlcapath string `protobuf:"bytes,2,opt,name=addlcapath,proto3" json:"addlcapath,omitempty"`
+}
+
+func (x *Data_Filedepot) Reset() {
+	*x = Data_Filedepot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Filedepot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Filedepot) ProtoMessage() {}
+
+func (x *Data_Filedepot) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Filedepot.ProtoReflect.Descriptor instead.
+func (*Data_Filedepot) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 1}
+}
+
+func (x *Data_Filedepot) GetCapath() string {
+	if x != nil {
+		return x.Capath
+	}
+	return ""
+}
+
+func (x *Data_Filedepot) GetAddlcapath() string {
+	if x != nil {
+		return x.Addlcapath
+	}
+	return ""
+}
+
+type Data_Boltdepot struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// path of the BoltDB database file
+	Path string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	// subject and lifetime of the RSA CA created on first start
+	CaYears        int32  `protobuf:"varint,2,opt,name=ca_years,json=caYears,proto3" json:"ca_years,omitempty"`
+	CaOrganization string `protobuf:"bytes,3,opt,name=ca_organization,json=caOrganization,proto3" json:"ca_organization,omitempty"`
+	CaCountry      string `protobuf:"bytes,4,opt,name=ca_country,json=caCountry,proto3" json:"ca_country,omitempty"`
+	CaKeySize      int32  `protobuf:"varint,5,opt,name=ca_key_size,json=caKeySize,proto3" json:"ca_key_size,omitempty"`
+}
+
+func (x *Data_Boltdepot) Reset() {
+	*x = Data_Boltdepot{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Boltdepot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Boltdepot) ProtoMessage() {}
+
+func (x *Data_Boltdepot) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Boltdepot.ProtoReflect.Descriptor instead.
+func (*Data_Boltdepot) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 2}
+}
+
+func (x *Data_Boltdepot) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Data_Boltdepot) GetCaYears() int32 {
+	if x != nil {
+		return x.CaYears
+	}
+	return 0
+}
+
+func (x *Data_Boltdepot) GetCaOrganization() string {
+	if x != nil {
+		return x.CaOrganization
+	}
+	return ""
+}
+
+func (x *Data_Boltdepot) GetCaCountry() string {
+	if x != nil {
+		return x.CaCountry
+	}
+	return ""
+}
+
+func (x *Data_Boltdepot) GetCaKeySize() int32 {
+	if x != nil {
+		return x.CaKeySize
+	}
+	return 0
+}
+
+type Data_RSASigerConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Capass       string `protobuf:"bytes,1,opt,name=capass,proto3" json:"capass,omitempty"`
+	AllowRenewal int32  `protobuf:"varint,2,opt,name=allowRenewal,proto3" json:"allowRenewal,omitempty"`
+	ValidityDay  int32  `protobuf:"varint,3,opt,name=validityDay,proto3" json:"validityDay,omitempty"`
+}
+
+func (x *Data_RSASigerConfig) Reset() {
+	*x = Data_RSASigerConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_RSASigerConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_RSASigerConfig) ProtoMessage() {}
+
+func (x *Data_RSASigerConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_RSASigerConfig.ProtoReflect.Descriptor instead.
+func (*Data_RSASigerConfig) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 3}
+}
+
+func (x *Data_RSASigerConfig) GetCapass() string {
+	if x != nil {
+		return x.Capass
+	}
+	return ""
+}
+
+func (x *Data_RSASigerConfig) GetAllowRenewal() int32 {
+	if x != nil {
+		return x.AllowRenewal
+	}
+	return 0
+}
+
+func (x *Data_RSASigerConfig) GetValidityDay() int32 {
+	if x != nil {
+		return x.ValidityDay
+	}
+	return 0
+}
+
+type Data_Challenge struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// shared secret accepted on every SCEP endpoint, and as the basic auth
+	// password of EST enrollments
+	Static string `protobuf:"bytes,1,opt,name=static,proto3" json:"static,omitempty"`
+	// shared secrets per SCEP profile, overriding static
+	Profiles map[string]string `protobuf:"bytes,2,rep,name=profiles,proto3" json:"profiles,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// accept one-time challenges stored in the depot
+	Dynamic bool `protobuf:"varint,3,opt,name=dynamic,proto3" json:"dynamic,omitempty"`
+	// default lifetime of issued one-time challenges
+	Ttl *durationpb.Duration `protobuf:"bytes,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+}
+
+func (x *Data_Challenge) Reset() {
+	*x = Data_Challenge{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Challenge) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Challenge) ProtoMessage() {}
+
+func (x *Data_Challenge) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Challenge.ProtoReflect.Descriptor instead.
+func (*Data_Challenge) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 4}
+}
+
+func (x *Data_Challenge) GetStatic() string {
+	if x != nil {
+		return x.Static
+	}
+	return ""
+}
+
+func (x *Data_Challenge) GetProfiles() map[string]string {
+	if x != nil {
+		return x.Profiles
+	}
+	return nil
+}
+
+func (x *Data_Challenge) GetDynamic() bool {
+	if x != nil {
+		return x.Dynamic
+	}
+	return false
+}
+
+func (x *Data_Challenge) GetTtl() *durationpb.Duration {
+	if x != nil {
+		return x.Ttl
+	}
+	return nil
+}
+
+// Profile is a certificate template served at /api/v1/scep/{name}
+type Data_Profile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// digital_signature, content_commitment, key_encipherment,
+	// data_encipherment, key_agreement, cert_sign, crl_sign
+	KeyUsage []string `protobuf:"bytes,1,rep,name=key_usage,json=keyUsage,proto3" json:"key_usage,omitempty"`
+	// client_auth, server_auth, code_signing, email_protection,
+	// time_stamping, ocsp_signing, ipsec_end_system, ipsec_tunnel, ipsec_user
+	ExtKeyUsage []string `protobuf:"bytes,2,rep,name=ext_key_usage,json=extKeyUsage,proto3" json:"ext_key_usage,omitempty"`
+	// validity of issued certificates, defaults to RSAsigerconfig.validityDay
+	ValidityDay int32 `protobuf:"varint,3,opt,name=validity_day,json=validityDay,proto3" json:"validity_day,omitempty"`
+	// SAN types a CSR may request: dns, email, ip, uri. Empty allows all.
+	AllowedSan []string `protobuf:"bytes,4,rep,name=allowed_san,json=allowedSan,proto3" json:"allowed_san,omitempty"`
+	// subject attributes replacing those of the CSR
+	Subject *Data_Profile_Subject `protobuf:"bytes,5,opt,name=subject,proto3" json:"subject,omitempty"`
+}
+
+func (x *Data_Profile) Reset() {
+	*x = Data_Profile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Profile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Profile) ProtoMessage() {}
+
+func (x *Data_Profile) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Profile.ProtoReflect.Descriptor instead.
+func (*Data_Profile) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 5}
+}
+
+func (x *Data_Profile) GetKeyUsage() []string {
+	if x != nil {
+		return x.KeyUsage
+	}
+	return nil
+}
+
+func (x *Data_Profile) GetExtKeyUsage() []string {
+	if x != nil {
+		return x.ExtKeyUsage
+	}
+	return nil
+}
+
+func (x *Data_Profile) GetValidityDay() int32 {
+	if x != nil {
+		return x.ValidityDay
+	}
+	return 0
+}
+
+func (x *Data_Profile) GetAllowedSan() []string {
+	if x != nil {
+		return x.AllowedSan
+	}
+	return nil
+}
+
+func (x *Data_Profile) GetSubject() *Data_Profile_Subject {
+	if x != nil {
+		return x.Subject
+	}
+	return nil
+}
+
+// Policy restricts the CSRs accepted for signing
+type Data_Policy struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// minimum RSA modulus size in bits
+	MinRsaBits int32 `protobuf:"varint,1,opt,name=min_rsa_bits,json=minRsaBits,proto3" json:"min_rsa_bits,omitempty"`
+	// minimum EC key size in bits
+	MinEcBits int32 `protobuf:"varint,2,opt,name=min_ec_bits,json=minEcBits,proto3" json:"min_ec_bits,omitempty"`
+	// allowed EC curves: P-224, P-256, P-384, P-521, sm2p256v1. Empty allows all.
+	AllowedCurves []string `protobuf:"bytes,3,rep,name=allowed_curves,json=allowedCurves,proto3" json:"allowed_curves,omitempty"`
+	// allowed CSR signature algorithms, eg: SHA256-RSA, ECDSA-SHA256, SM2-SM3.
+	// Empty allows all.
+	SignatureAlgorithms []string `protobuf:"bytes,4,rep,name=signature_algorithms,json=signatureAlgorithms,proto3" json:"signature_algorithms,omitempty"`
+	// regular expressions the subject CN and SANs must match in full, one
+	// of the list each. Empty allows all.
+	CommonName []string `protobuf:"bytes,5,rep,name=common_name,json=commonName,proto3" json:"common_name,omitempty"`
+	DnsNames   []string `protobuf:"bytes,6,rep,name=dns_names,json=dnsNames,proto3" json:"dns_names,omitempty"`
+	Emails     []string `protobuf:"bytes,7,rep,name=emails,proto3" json:"emails,omitempty"`
+	Uris       []string `protobuf:"bytes,8,rep,name=uris,proto3" json:"uris,omitempty"`
+	// subject attributes the CSR must carry: CN, O, OU, C, ST, L
+	RequiredSubject []string `protobuf:"bytes,9,rep,name=required_subject,json=requiredSubject,proto3" json:"required_subject,omitempty"`
+	// maximum number of SANs, 0 for no limit
+	MaxSans int32 `protobuf:"varint,10,opt,name=max_sans,json=maxSans,proto3" json:"max_sans,omitempty"`
+	// how the subject of a RenewalReq has to match the certificate it is
+	// signed with: dn (default) the whole subject, cn the common name,
+	// any not at all
+	RenewalSubject string `protobuf:"bytes,11,opt,name=renewal_subject,json=renewalSubject,proto3" json:"renewal_subject,omitempty"`
+}
+
+func (x *Data_Policy) Reset() {
+	*x = Data_Policy{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Policy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Policy) ProtoMessage() {}
+
+func (x *Data_Policy) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Policy.ProtoReflect.Descriptor instead.
+func (*Data_Policy) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 6}
+}
+
+func (x *Data_Policy) GetMinRsaBits() int32 {
+	if x != nil {
+		return x.MinRsaBits
+	}
+	return 0
+}
+
+func (x *Data_Policy) GetMinEcBits() int32 {
+	if x != nil {
+		return x.MinEcBits
+	}
+	return 0
+}
+
+func (x *Data_Policy) GetAllowedCurves() []string {
+	if x != nil {
+		return x.AllowedCurves
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetSignatureAlgorithms() []string {
+	if x != nil {
+		return x.SignatureAlgorithms
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetCommonName() []string {
+	if x != nil {
+		return x.CommonName
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetDnsNames() []string {
+	if x != nil {
+		return x.DnsNames
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetEmails() []string {
+	if x != nil {
+		return x.Emails
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetUris() []string {
+	if x != nil {
+		return x.Uris
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetRequiredSubject() []string {
+	if x != nil {
+		return x.RequiredSubject
+	}
+	return nil
+}
+
+func (x *Data_Policy) GetMaxSans() int32 {
+	if x != nil {
+		return x.MaxSans
+	}
+	return 0
+}
+
+func (x *Data_Policy) GetRenewalSubject() string {
+	if x != nil {
+		return x.RenewalSubject
+	}
+	return ""
+}
+
+type Data_Crl struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// time until the nextUpdate of a CRL, default 24h
+	NextUpdate *durationpb.Duration `protobuf:"bytes,1,opt,name=next_update,json=nextUpdate,proto3" json:"next_update,omitempty"`
+}
+
+func (x *Data_Crl) Reset() {
+	*x = Data_Crl{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Crl) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Crl) ProtoMessage() {}
+
+func (x *Data_Crl) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Crl.ProtoReflect.Descriptor instead.
+func (*Data_Crl) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 7}
+}
+
+func (x *Data_Crl) GetNextUpdate() *durationpb.Duration {
+	if x != nil {
+		return x.NextUpdate
+	}
+	return nil
+}
+
+type Data_Ocsp struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// delegated responders by CA type (RSA, ECC, SM2). CA types without one
+	// are answered with the CA key.
+	Responders map[string]*Data_Ocsp_Responder `protobuf:"bytes,1,rep,name=responders,proto3" json:"responders,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// time until the nextUpdate of a response, default 1h
+	NextUpdate *durationpb.Duration `protobuf:"bytes,2,opt,name=next_update,json=nextUpdate,proto3" json:"next_update,omitempty"`
+}
+
+func (x *Data_Ocsp) Reset() {
+	*x = Data_Ocsp{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Ocsp) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Ocsp) ProtoMessage() {}
+
+func (x *Data_Ocsp) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Ocsp.ProtoReflect.Descriptor instead.
+func (*Data_Ocsp) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 8}
+}
+
+func (x *Data_Ocsp) GetResponders() map[string]*Data_Ocsp_Responder {
+	if x != nil {
+		return x.Responders
+	}
+	return nil
+}
+
+func (x *Data_Ocsp) GetNextUpdate() *durationpb.Duration {
+	if x != nil {
+		return x.NextUpdate
+	}
+	return nil
+}
+
+// Replay guards PKIOperation against replayed pkiMessages
+type Data_Replay struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// how long senderNonces and transactionIDs are remembered, default 24h
+	Window *durationpb.Duration `protobuf:"bytes,1,opt,name=window,proto3" json:"window,omitempty"`
+	// most nonces and transactions each kept in memory, default 100000
+	MaxEntries int32 `protobuf:"varint,2,opt,name=max_entries,json=maxEntries,proto3" json:"max_entries,omitempty"`
+	// remember them in the depot instead of in memory, so that replicas
+	// sharing a bolt or sql depot see each other's messages
+	Depot bool `protobuf:"varint,3,opt,name=depot,proto3" json:"depot,omitempty"`
+}
+
+func (x *Data_Replay) Reset() {
+	*x = Data_Replay{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Replay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Replay) ProtoMessage() {}
+
+func (x *Data_Replay) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Replay.ProtoReflect.Descriptor instead.
+func (*Data_Replay) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 9}
+}
+
+func (x *Data_Replay) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *Data_Replay) GetMaxEntries() int32 {
+	if x != nil {
+		return x.MaxEntries
+	}
+	return 0
+}
+
+func (x *Data_Replay) GetDepot() bool {
+	if x != nil {
+		return x.Depot
+	}
+	return false
+}
+
+// Ra is a registration authority answering for a CA
+type Data_Ra struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// PEM files of the RA certificate, issued by the CA with the
+	// digital_signature and key_encipherment key usages, and of its key
+	Cert string `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Key  string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (x *Data_Ra) Reset() {
+	*x = Data_Ra{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Ra) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Ra) ProtoMessage() {}
+
+func (x *Data_Ra) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Ra.ProtoReflect.Descriptor instead.
+func (*Data_Ra) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 10}
+}
+
+func (x *Data_Ra) GetCert() string {
+	if x != nil {
+		return x.Cert
+	}
+	return ""
+}
+
+func (x *Data_Ra) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+// Caps are the capabilities GetCACaps advertises for a CA type
+type Data_Caps struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// AES, DES3, POSTPKIOperation, Renewal, SCEPStandard, SHA-1, SHA-256,
+	// SHA-512, SM3, SM4, Update. GetNextCACert is added while a next CA is
+	// staged and left out otherwise.
+	Caps []string `protobuf:"bytes,1,rep,name=caps,proto3" json:"caps,omitempty"`
+}
+
+func (x *Data_Caps) Reset() {
+	*x = Data_Caps{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Caps) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Caps) ProtoMessage() {}
+
+func (x *Data_Caps) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Caps.ProtoReflect.Descriptor instead.
+func (*Data_Caps) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 11}
+}
+
+func (x *Data_Caps) GetCaps() []string {
+	if x != nil {
+		return x.Caps
+	}
+	return nil
+}
+
+// KeyProvider is where the CA keys are kept
+type Data_KeyProvider struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// depot (default) reads them with the CA certificates, remote asks the
+	// signing daemon of cmd/signer, which keeps them out of the server
+	Type string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	// Unix socket of the signing daemon
+	Socket string `protobuf:"bytes,2,opt,name=socket,proto3" json:"socket,omitempty"`
+	// bound of each remote key operation, default 5s
+	Timeout *durationpb.Duration `protobuf:"bytes,3,opt,name=timeout,proto3" json:"timeout,omitempty"`
+}
+
+func (x *Data_KeyProvider) Reset() {
+	*x = Data_KeyProvider{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_KeyProvider) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_KeyProvider) ProtoMessage() {}
+
+func (x *Data_KeyProvider) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_KeyProvider.ProtoReflect.Descriptor instead.
+func (*Data_KeyProvider) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 12}
+}
+
+func (x *Data_KeyProvider) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Data_KeyProvider) GetSocket() string {
+	if x != nil {
+		return x.Socket
+	}
+	return ""
+}
+
+func (x *Data_KeyProvider) GetTimeout() *durationpb.Duration {
+	if x != nil {
+		return x.Timeout
+	}
+	return nil
+}
+
+type Data_Profile_Subject struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Organization       string `protobuf:"bytes,1,opt,name=organization,proto3" json:"organization,omitempty"`
+	OrganizationalUnit string `protobuf:"bytes,2,opt,name=organizational_unit,json=organizationalUnit,proto3" json:"organizational_unit,omitempty"`
+	Country            string `protobuf:"bytes,3,opt,name=country,proto3" json:"country,omitempty"`
+	Province           string `protobuf:"bytes,4,opt,name=province,proto3" json:"province,omitempty"`
+	Locality           string `protobuf:"bytes,5,opt,name=locality,proto3" json:"locality,omitempty"`
+}
+
+func (x *Data_Profile_Subject) Reset() {
+	*x = Data_Profile_Subject{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Profile_Subject) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Profile_Subject) ProtoMessage() {}
+
+func (x *Data_Profile_Subject) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Profile_Subject.ProtoReflect.Descriptor instead.
+func (*Data_Profile_Subject) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 5, 0}
+}
+
+func (x *Data_Profile_Subject) GetOrganization() string {
+	if x != nil {
+		return x.Organization
+	}
+	return ""
+}
+
+func (x *Data_Profile_Subject) GetOrganizationalUnit() string {
+	if x != nil {
+		return x.OrganizationalUnit
+	}
+	return ""
+}
+
+func (x *Data_Profile_Subject) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *Data_Profile_Subject) GetProvince() string {
+	if x != nil {
+		return x.Province
+	}
+	return ""
+}
+
+func (x *Data_Profile_Subject) GetLocality() string {
+	if x != nil {
+		return x.Locality
+	}
+	return ""
+}
+
+// Responder is a delegated OCSP signing certificate, issued by the CA
+// it answers for with the ocsp_signing extended key usage
+type Data_Ocsp_Responder struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// PEM files of the certificate and its key
+	Cert string `protobuf:"bytes,1,opt,name=cert,proto3" json:"cert,omitempty"`
+	Key  string `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (x *Data_Ocsp_Responder) Reset() {
+	*x = Data_Ocsp_Responder{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_conf_conf_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Data_Ocsp_Responder) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Data_Ocsp_Responder) ProtoMessage() {}
+
+func (x *Data_Ocsp_Responder) ProtoReflect() protoreflect.Message {
+	mi := &file_conf_conf_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Data_Ocsp_Responder.ProtoReflect.Descriptor instead.
+func (*Data_Ocsp_Responder) Descriptor() ([]byte, []int) {
+	return file_conf_conf_proto_rawDescGZIP(), []int{2, 8, 0}
+}
+
+func (x *Data_Ocsp_Responder) GetCert() string {
+	if x != nil {
+		return x.Cert
+	}
+	return ""
+}
+
+func (x *Data_Ocsp_Responder) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+var File_conf_conf_proto protoreflect.FileDescriptor
+
+var file_conf_conf_proto_rawDesc = []byte{
+	0x0a, 0x0f, 0x63, 0x6f, 0x6e, 0x66, 0x2f, 0x63, 0x6f, 0x6e, 0x66, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x12, 0x0a, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x1a, 0x1e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x5d, 0x0a,
+	0x09, 0x42, 0x6f, 0x6f, 0x74, 0x73, 0x74, 0x72, 0x61, 0x70, 0x12, 0x2a, 0x0a, 0x06, 0x73, 0x65,
+	0x72, 0x76, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x6b, 0x72, 0x61,
+	0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x06,
+	0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0xe7, 0x09, 0x0a,
+	0x06, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x12, 0x2b, 0x0a, 0x04, 0x68, 0x74, 0x74, 0x70, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x52, 0x04,
+	0x68, 0x74, 0x74, 0x70, 0x12, 0x31, 0x0a, 0x06, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70,
+	0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x52,
+	0x06, 0x6c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x12, 0x2e, 0x0a, 0x05, 0x61, 0x64, 0x6d, 0x69, 0x6e,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e,
+	0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x41, 0x64, 0x6d, 0x69, 0x6e,
+	0x52, 0x05, 0x61, 0x64, 0x6d, 0x69, 0x6e, 0x12, 0x34, 0x0a, 0x07, 0x6d, 0x65, 0x74, 0x72, 0x69,
+	0x63, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x4d, 0x65, 0x74,
+	0x72, 0x69, 0x63, 0x73, 0x52, 0x07, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x1a, 0x84, 0x02,
+	0x0a, 0x06, 0x4c, 0x6f, 0x67, 0x67, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x65, 0x76, 0x65,
+	0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6c, 0x65, 0x76, 0x65, 0x6c, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x5f, 0x70, 0x61, 0x74, 0x68,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x50, 0x61, 0x74,
+	0x68, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x53, 0x0a,
+	0x0e, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x18,
+	0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x2c, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x4c, 0x6f, 0x67, 0x67, 0x65, 0x72,
+	0x2e, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x46, 0x69, 0x65, 0x6c,
+	0x64, 0x73, 0x1a, 0x40, 0x0a, 0x12, 0x49, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x46, 0x69, 0x65,
+	0x6c, 0x64, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x1a, 0xe3, 0x03, 0x0a, 0x04, 0x48, 0x54, 0x54, 0x50, 0x12, 0x18, 0x0a,
+	0x07, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07,
+	0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x64, 0x64, 0x72, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x64, 0x64, 0x72, 0x12, 0x33, 0x0a, 0x07, 0x74,
+	0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74,
+	0x12, 0x2d, 0x0a, 0x03, 0x74, 0x6c, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1b, 0x2e,
+	0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x2e, 0x54, 0x4c, 0x53, 0x52, 0x03, 0x74, 0x6c, 0x73, 0x1a,
+	0xc8, 0x02, 0x0a, 0x03, 0x54, 0x4c, 0x53, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x65, 0x72, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x65, 0x72, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b,
+	0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1f, 0x0a,
+	0x0b, 0x6d, 0x69, 0x6e, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x6d, 0x69, 0x6e, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x23,
+	0x0a, 0x0d, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x5f, 0x73, 0x75, 0x69, 0x74, 0x65, 0x73, 0x18,
+	0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x53, 0x75, 0x69,
+	0x74, 0x65, 0x73, 0x12, 0x37, 0x0a, 0x05, 0x69, 0x73, 0x73, 0x75, 0x65, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x21, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x2e, 0x48, 0x54, 0x54, 0x50, 0x2e, 0x54, 0x4c, 0x53, 0x2e,
+	0x49, 0x73, 0x73, 0x75, 0x65, 0x52, 0x05, 0x69, 0x73, 0x73, 0x75, 0x65, 0x1a, 0x9b, 0x01, 0x0a,
+	0x05, 0x49, 0x73, 0x73, 0x75, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x63, 0x61, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x02, 0x63, 0x61, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e,
+	0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6d,
+	0x6d, 0x6f, 0x6e, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x6e, 0x73, 0x5f, 0x6e,
+	0x61, 0x6d, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x64, 0x6e, 0x73, 0x4e,
+	0x61, 0x6d, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x69, 0x70, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x65, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0b, 0x69, 0x70, 0x41, 0x64,
+	0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x76, 0x61, 0x6c, 0x69, 0x64,
+	0x69, 0x74, 0x79, 0x5f, 0x64, 0x61, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x76,
+	0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61, 0x79, 0x1a, 0xac, 0x01, 0x0a, 0x05, 0x41,
+	0x64, 0x6d, 0x69, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x73, 0x12, 0x1f, 0x0a, 0x0b,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x0a, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x12, 0x30, 0x0a,
+	0x14, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x73, 0x75, 0x62,
+	0x6a, 0x65, 0x63, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x12, 0x63, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x12,
+	0x38, 0x0a, 0x18, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x5f, 0x66,
+	0x69, 0x6e, 0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x16, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x65, 0x72, 0x74, 0x46, 0x69, 0x6e,
+	0x67, 0x65, 0x72, 0x70, 0x72, 0x69, 0x6e, 0x74, 0x73, 0x1a, 0x7b, 0x0a, 0x07, 0x4d, 0x65, 0x74,
+	0x72, 0x69, 0x63, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x23, 0x0a, 0x0d, 0x65, 0x78, 0x70, 0x69,
+	0x72, 0x69, 0x6e, 0x67, 0x5f, 0x64, 0x61, 0x79, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x0c, 0x65, 0x78, 0x70, 0x69, 0x72, 0x69, 0x6e, 0x67, 0x44, 0x61, 0x79, 0x73, 0x12, 0x18, 0x0a,
+	0x07, 0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x65, 0x6e, 0x61, 0x62, 0x6c, 0x65, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x61, 0x64, 0x6d, 0x69, 0x6e,
+	0x5f, 0x61, 0x75, 0x74, 0x68, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x61, 0x64, 0x6d,
+	0x69, 0x6e, 0x41, 0x75, 0x74, 0x68, 0x22, 0x84, 0x1a, 0x0a, 0x04, 0x44, 0x61, 0x74, 0x61, 0x12,
+	0x35, 0x0a, 0x08, 0x64, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44,
+	0x61, 0x74, 0x61, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x52, 0x08, 0x64, 0x61,
+	0x74, 0x61, 0x62, 0x61, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x5f,
+	0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x64, 0x65, 0x70, 0x6f,
+	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x38, 0x0a, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x64, 0x65, 0x70,
+	0x6f, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x46, 0x69, 0x6c, 0x65, 0x64,
+	0x65, 0x70, 0x6f, 0x74, 0x52, 0x09, 0x66, 0x69, 0x6c, 0x65, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x12,
+	0x47, 0x0a, 0x0e, 0x52, 0x53, 0x41, 0x73, 0x69, 0x67, 0x65, 0x72, 0x63, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x52, 0x53, 0x41, 0x53, 0x69, 0x67,
+	0x65, 0x72, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x0e, 0x52, 0x53, 0x41, 0x73, 0x69, 0x67,
+	0x65, 0x72, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x38, 0x0a, 0x09, 0x63, 0x68, 0x61, 0x6c,
+	0x6c, 0x65, 0x6e, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6b, 0x72,
+	0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x43, 0x68,
+	0x61, 0x6c, 0x6c, 0x65, 0x6e, 0x67, 0x65, 0x52, 0x09, 0x63, 0x68, 0x61, 0x6c, 0x6c, 0x65, 0x6e,
+	0x67, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x5f, 0x61, 0x70, 0x70,
+	0x72, 0x6f, 0x76, 0x61, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e, 0x6d, 0x61, 0x6e,
+	0x75, 0x61, 0x6c, 0x41, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x61, 0x6c, 0x12, 0x3a, 0x0a, 0x08, 0x70,
+	0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
+	0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e,
+	0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x70,
+	0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x2f, 0x0a, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63,
+	0x79, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79,
+	0x52, 0x06, 0x70, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x38, 0x0a, 0x09, 0x62, 0x6f, 0x6c, 0x74,
+	0x64, 0x65, 0x70, 0x6f, 0x74, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x6b, 0x72,
+	0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x42, 0x6f,
+	0x6c, 0x74, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x52, 0x09, 0x62, 0x6f, 0x6c, 0x74, 0x64, 0x65, 0x70,
+	0x6f, 0x74, 0x12, 0x26, 0x0a, 0x03, 0x63, 0x72, 0x6c, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x14, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74,
+	0x61, 0x2e, 0x43, 0x72, 0x6c, 0x52, 0x03, 0x63, 0x72, 0x6c, 0x12, 0x29, 0x0a, 0x04, 0x6f, 0x63,
+	0x73, 0x70, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f,
+	0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x4f, 0x63, 0x73, 0x70, 0x52,
+	0x04, 0x6f, 0x63, 0x73, 0x70, 0x12, 0x2f, 0x0a, 0x06, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x79, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61,
+	0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x79, 0x52, 0x06,
+	0x72, 0x65, 0x70, 0x6c, 0x61, 0x79, 0x12, 0x28, 0x0a, 0x02, 0x72, 0x61, 0x18, 0x0d, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x44, 0x61, 0x74, 0x61, 0x2e, 0x52, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x02, 0x72, 0x61,
+	0x12, 0x2e, 0x0a, 0x04, 0x63, 0x61, 0x70, 0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61,
+	0x2e, 0x43, 0x61, 0x70, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x63, 0x61, 0x70, 0x73,
+	0x12, 0x34, 0x0a, 0x06, 0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1c, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61,
+	0x74, 0x61, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x73, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x06,
+	0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x12, 0x3f, 0x0a, 0x0c, 0x6b, 0x65, 0x79, 0x5f, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x6b,
+	0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x4b,
+	0x65, 0x79, 0x50, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x52, 0x0b, 0x6b, 0x65, 0x79, 0x50,
+	0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x1a, 0xbd, 0x01, 0x0a, 0x08, 0x44, 0x61, 0x74, 0x61,
+	0x62, 0x61, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x63, 0x61, 0x5f, 0x79, 0x65, 0x61, 0x72, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x63, 0x61, 0x59, 0x65, 0x61, 0x72, 0x73, 0x12,
+	0x27, 0x0a, 0x0f, 0x63, 0x61, 0x5f, 0x6f, 0x72, 0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x63, 0x61, 0x4f, 0x72, 0x67, 0x61,
+	0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x61, 0x5f, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x61,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1e, 0x0a, 0x0b, 0x63, 0x61, 0x5f, 0x6b, 0x65,
+	0x79, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x63, 0x61,
+	0x4b, 0x65, 0x79, 0x53, 0x69, 0x7a, 0x65, 0x1a, 0x43, 0x0a, 0x09, 0x46, 0x69, 0x6c, 0x65, 0x64,
+	0x65, 0x70, 0x6f, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1e, 0x0a, 0x0a,
+	0x61, 0x64, 0x64, 0x6c, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x61, 0x64, 0x64, 0x6c, 0x63, 0x61, 0x70, 0x61, 0x74, 0x68, 0x1a, 0xa2, 0x01, 0x0a,
+	0x09, 0x42, 0x6f, 0x6c, 0x74, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x19,
+	0x0a, 0x08, 0x63, 0x61, 0x5f, 0x79, 0x65, 0x61, 0x72, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x07, 0x63, 0x61, 0x59, 0x65, 0x61, 0x72, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x63, 0x61, 0x5f,
+	0x6f, 0x72, 0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x63, 0x61, 0x4f, 0x72, 0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x63, 0x61, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x63, 0x61, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x1e, 0x0a, 0x0b, 0x63, 0x61, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x73, 0x69, 0x7a, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x63, 0x61, 0x4b, 0x65, 0x79, 0x53, 0x69, 0x7a,
+	0x65, 0x1a, 0x6e, 0x0a, 0x0e, 0x52, 0x53, 0x41, 0x53, 0x69, 0x67, 0x65, 0x72, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x63, 0x61, 0x70, 0x61, 0x73, 0x73, 0x12, 0x22, 0x0a, 0x0c, 0x61,
+	0x6c, 0x6c, 0x6f, 0x77, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x0c, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x52, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x12,
+	0x20, 0x0a, 0x0b, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61,
+	0x79, 0x1a, 0xed, 0x01, 0x0a, 0x09, 0x43, 0x68, 0x61, 0x6c, 0x6c, 0x65, 0x6e, 0x67, 0x65, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x69, 0x63, 0x12, 0x44, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x6b, 0x72, 0x61, 0x74,
+	0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x43, 0x68, 0x61, 0x6c,
+	0x6c, 0x65, 0x6e, 0x67, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x18, 0x0a,
+	0x07, 0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69, 0x63, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x64, 0x79, 0x6e, 0x61, 0x6d, 0x69, 0x63, 0x12, 0x2b, 0x0a, 0x03, 0x74, 0x74, 0x6c, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x03, 0x74, 0x74, 0x6c, 0x1a, 0x3b, 0x0a, 0x0d, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x1a, 0xfd, 0x02, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x1b, 0x0a,
+	0x09, 0x6b, 0x65, 0x79, 0x5f, 0x75, 0x73, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x08, 0x6b, 0x65, 0x79, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x22, 0x0a, 0x0d, 0x65, 0x78,
+	0x74, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x75, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x0b, 0x65, 0x78, 0x74, 0x4b, 0x65, 0x79, 0x55, 0x73, 0x61, 0x67, 0x65, 0x12, 0x21,
+	0x0a, 0x0c, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x5f, 0x64, 0x61, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x69, 0x74, 0x79, 0x44, 0x61,
+	0x79, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x5f, 0x73, 0x61, 0x6e,
+	0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x53,
+	0x61, 0x6e, 0x12, 0x3a, 0x0a, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69,
+	0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x2e, 0x53, 0x75,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x52, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x1a, 0xb0,
+	0x01, 0x0a, 0x07, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x22, 0x0a, 0x0c, 0x6f, 0x72,
+	0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0c, 0x6f, 0x72, 0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2f,
+	0x0a, 0x13, 0x6f, 0x72, 0x67, 0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c,
+	0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x6f, 0x72, 0x67,
+	0x61, 0x6e, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x55, 0x6e, 0x69, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f,
+	0x76, 0x69, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72, 0x6f,
+	0x76, 0x69, 0x6e, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x69, 0x74,
+	0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x69, 0x74,
+	0x79, 0x1a, 0xfd, 0x02, 0x0a, 0x06, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x20, 0x0a, 0x0c,
+	0x6d, 0x69, 0x6e, 0x5f, 0x72, 0x73, 0x61, 0x5f, 0x62, 0x69, 0x74, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x0a, 0x6d, 0x69, 0x6e, 0x52, 0x73, 0x61, 0x42, 0x69, 0x74, 0x73, 0x12, 0x1e,
+	0x0a, 0x0b, 0x6d, 0x69, 0x6e, 0x5f, 0x65, 0x63, 0x5f, 0x62, 0x69, 0x74, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x09, 0x6d, 0x69, 0x6e, 0x45, 0x63, 0x42, 0x69, 0x74, 0x73, 0x12, 0x25,
+	0x0a, 0x0e, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x5f, 0x63, 0x75, 0x72, 0x76, 0x65, 0x73,
+	0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x65, 0x64, 0x43,
+	0x75, 0x72, 0x76, 0x65, 0x73, 0x12, 0x31, 0x0a, 0x14, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x5f, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x13, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x41, 0x6c,
+	0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d,
+	0x6f, 0x6e, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x63,
+	0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x64, 0x6e, 0x73,
+	0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x64, 0x6e,
+	0x73, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x73,
+	0x18, 0x07, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x75, 0x72, 0x69, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x75, 0x72,
+	0x69, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x5f, 0x73,
+	0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x72, 0x65,
+	0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x19, 0x0a,
+	0x08, 0x6d, 0x61, 0x78, 0x5f, 0x73, 0x61, 0x6e, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x07, 0x6d, 0x61, 0x78, 0x53, 0x61, 0x6e, 0x73, 0x12, 0x27, 0x0a, 0x0f, 0x72, 0x65, 0x6e, 0x65,
+	0x77, 0x61, 0x6c, 0x5f, 0x73, 0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x0b, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0e, 0x72, 0x65, 0x6e, 0x65, 0x77, 0x61, 0x6c, 0x53, 0x75, 0x62, 0x6a, 0x65, 0x63,
+	0x74, 0x1a, 0x41, 0x0a, 0x03, 0x43, 0x72, 0x6c, 0x12, 0x3a, 0x0a, 0x0b, 0x6e, 0x65, 0x78, 0x74,
+	0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x6e, 0x65, 0x78, 0x74, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x1a, 0x9c, 0x02, 0x0a, 0x04, 0x4f, 0x63, 0x73, 0x70, 0x12, 0x45, 0x0a,
+	0x0a, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x64, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x25, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44,
+	0x61, 0x74, 0x61, 0x2e, 0x4f, 0x63, 0x73, 0x70, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x64,
+	0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x64, 0x65, 0x72, 0x73, 0x12, 0x3a, 0x0a, 0x0b, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x6e, 0x65, 0x78, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x1a, 0x31, 0x0a, 0x09, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a,
+	0x04, 0x63, 0x65, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x65, 0x72,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x1a, 0x5e, 0x0a, 0x0f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x64, 0x65, 0x72,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x35, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x4f, 0x63, 0x73, 0x70, 0x2e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x64, 0x65, 0x72, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x1a, 0x72, 0x0a, 0x06, 0x52, 0x65, 0x70, 0x6c, 0x61, 0x79, 0x12, 0x31, 0x0a,
+	0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+	0x12, 0x1f, 0x0a, 0x0b, 0x6d, 0x61, 0x78, 0x5f, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x6d, 0x61, 0x78, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x05, 0x64, 0x65, 0x70, 0x6f, 0x74, 0x1a, 0x2a, 0x0a, 0x02, 0x52, 0x61, 0x12, 0x12, 0x0a,
+	0x04, 0x63, 0x65, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x65, 0x72,
+	0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x1a, 0x1a, 0x0a, 0x04, 0x43, 0x61, 0x70, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x63,
+	0x61, 0x70, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x63, 0x61, 0x70, 0x73, 0x1a,
+	0x6e, 0x0a, 0x0b, 0x4b, 0x65, 0x79, 0x50, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12, 0x12,
+	0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x33, 0x0a, 0x07, 0x74, 0x69,
+	0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x1a,
+	0x55, 0x0a, 0x0d, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x2e, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44,
+	0x61, 0x74, 0x61, 0x2e, 0x50, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x4a, 0x0a, 0x07, 0x52, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x29, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e,
+	0x44, 0x61, 0x74, 0x61, 0x2e, 0x52, 0x61, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x1a, 0x4e, 0x0a, 0x09, 0x43, 0x61, 0x70, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
+	0x79, 0x12, 0x2b, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x15, 0x2e, 0x6b, 0x72, 0x61, 0x74, 0x6f, 0x73, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x44, 0x61,
+	0x74, 0x61, 0x2e, 0x43, 0x61, 0x70, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x1a, 0x39, 0x0a, 0x0b, 0x43, 0x61, 0x70, 0x61, 0x73, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x1a, 0x5a,
+	0x18, 0x6b, 0x73, 0x63, 0x65, 0x70, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
+	0x63, 0x6f, 0x6e, 0x66, 0x3b, 0x63, 0x6f, 0x6e, 0x66, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+}
+
+var (
+	file_conf_conf_proto_rawDescOnce sync.Once
+	file_conf_conf_proto_rawDescData = file_conf_conf_proto_rawDesc
+)
+
+func file_conf_conf_proto_rawDescGZIP() []byte {
+	file_conf_conf_proto_rawDescOnce.Do(func() {
+		file_conf_conf_proto_rawDescData = protoimpl.X.CompressGZIP(file_conf_conf_proto_rawDescData)
+	})
+	return file_conf_conf_proto_rawDescData
+}
+
+var file_conf_conf_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
+var file_conf_conf_proto_goTypes = []interface{}{
+	(*Bootstrap)(nil),             // 0: kratos.api.Bootstrap
+	(*Server)(nil),                // 1: kratos.api.Server
+	(*Data)(nil),                  // 2: kratos.api.Data
+	(*Server_Logger)(nil),         // 3: kratos.api.Server.Logger
+	(*Server_HTTP)(nil),           // 4: kratos.api.Server.HTTP
+	(*Server_Admin)(nil),          // 5: kratos.api.Server.Admin
+	(*Server_Metrics)(nil),        // 6: kratos.api.Server.Metrics
+	nil,                           // 7: kratos.api.Server.Logger.InitialFieldsEntry
+	(*Server_HTTP_TLS)(nil),       // 8: kratos.api.Server.HTTP.TLS
+	(*Server_HTTP_TLS_Issue)(nil), // 9: kratos.api.Server.HTTP.TLS.Issue
+	(*Data_Database)(nil),         // 10: kratos.api.Data.Database
+	(*Data_Filedepot)(nil),        // 11: kratos.api.Data.Filedepot
+	(*Data_Boltdepot)(nil),        // 12: kratos.api.Data.Boltdepot
+	(*Data_RSASigerConfig)(nil),   // 13: kratos.api.Data.RSASigerConfig
+	(*Data_Challenge)(nil),        // 14: kratos.api.Data.Challenge
+	(*Data_Profile)(nil),          // 15: kratos.api.Data.Profile
+	(*Data_Policy)(nil),           // 16: kratos.api.Data.Policy
+	(*Data_Crl)(nil),              // 17: kratos.api.Data.Crl
+	(*Data_Ocsp)(nil),             // 18: kratos.api.Data.Ocsp
+	(*Data_Replay)(nil),           // 19: kratos.api.Data.Replay
+	(*Data_Ra)(nil),               // 20: kratos.api.Data.Ra
+	(*Data_Caps)(nil),             // 21: kratos.api.Data.Caps
+	(*Data_KeyProvider)(nil),      // 22: kratos.api.Data.KeyProvider
+	nil,                           // 23: kratos.api.Data.ProfilesEntry
+	nil,                           // 24: kratos.api.Data.RaEntry
+	nil,                           // 25: kratos.api.Data.CapsEntry
+	nil,                           // 26: kratos.api.Data.CapassEntry
+	nil,                           // 27: kratos.api.Data.Challenge.ProfilesEntry
+	(*Data_Profile_Subject)(nil),  // 28: kratos.api.Data.Profile.Subject
+	(*Data_Ocsp_Responder)(nil),   // 29: kratos.api.Data.Ocsp.Responder
+	nil,                           // 30: kratos.api.Data.Ocsp.RespondersEntry
+	(*durationpb.Duration)(nil),   // 31: google.protobuf.Duration
+}
+var file_conf_conf_proto_depIdxs = []int32{
+	1,  // 0: kratos.api.Bootstrap.server:type_name -> kratos.api.Server
+	2,  // 1: kratos.api.Bootstrap.data:type_name -> kratos.api.Data
+	4,  // 2: kratos.api.Server.http:type_name -> kratos.api.Server.HTTP
+	3,  // 3: kratos.api.Server.logger:type_name -> kratos.api.Server.Logger
+	5,  // 4: kratos.api.Server.admin:type_name -> kratos.api.Server.Admin
+	6,  // 5: kratos.api.Server.metrics:type_name -> kratos.api.Server.Metrics
+	10, // 6: kratos.api.Data.database:type_name -> kratos.api.Data.Database
+	11, // 7: kratos.api.Data.filedepot:type_name -> kratos.api.Data.Filedepot
+	13, // 8: kratos.api.Data.RSAsigerconfig:type_name -> kratos.api.Data.RSASigerConfig
+	14, // 9: kratos.api.Data.challenge:type_name -> kratos.api.Data.Challenge
+	23, // 10: kratos.api.Data.profiles:type_name -> kratos.api.Data.ProfilesEntry
+	16, // 11: kratos.api.Data.policy:type_name -> kratos.api.Data.Policy
+	12, // 12: kratos.api.Data.boltdepot:type_name -> kratos.api.Data.Boltdepot
+	17, // 13: kratos.api.Data.crl:type_name -> kratos.api.Data.Crl
+	18, // 14: kratos.api.Data.ocsp:type_name -> kratos.api.Data.Ocsp
+	19, // 15: kratos.api.Data.replay:type_name -> kratos.api.Data.Replay
+	24, // 16: kratos.api.Data.ra:type_name -> kratos.api.Data.RaEntry
+	25, // 17: kratos.api.Data.caps:type_name -> kratos.api.Data.CapsEntry
+	26, // 18: kratos.api.Data.capass:type_name -> kratos.api.Data.CapassEntry
+	22, // 19: kratos.api.Data.key_provider:type_name -> kratos.api.Data.KeyProvider
+	7,  // 20: kratos.api.Server.Logger.initial_fields:type_name -> kratos.api.Server.Logger.InitialFieldsEntry
+	31, // 21: kratos.api.Server.HTTP.timeout:type_name -> google.protobuf.Duration
+	8,  // 22: kratos.api.Server.HTTP.tls:type_name -> kratos.api.Server.HTTP.TLS
+	9,  // 23: kratos.api.Server.HTTP.TLS.issue:type_name -> kratos.api.Server.HTTP.TLS.Issue
+	27, // 24: kratos.api.Data.Challenge.profiles:type_name -> kratos.api.Data.Challenge.ProfilesEntry
+	31, // 25: kratos.api.Data.Challenge.ttl:type_name -> google.protobuf.Duration
+	28, // 26: kratos.api.Data.Profile.subject:type_name -> kratos.api.Data.Profile.Subject
+	31, // 27: kratos.api.Data.Crl.next_update:type_name -> google.protobuf.Duration
+	30, // 28: kratos.api.Data.Ocsp.responders:type_name -> kratos.api.Data.Ocsp.RespondersEntry
+	31, // 29: kratos.api.Data.Ocsp.next_update:type_name -> google.protobuf.Duration
+	31, // 30: kratos.api.Data.Replay.window:type_name -> google.protobuf.Duration
+	31, // 31: kratos.api.Data.KeyProvider.timeout:type_name -> google.protobuf.Duration
+	15, // 32: kratos.api.Data.ProfilesEntry.value:type_name -> kratos.api.Data.Profile
+	20, // 33: kratos.api.Data.RaEntry.value:type_name -> kratos.api.Data.Ra
+	21, // 34: kratos.api.Data.CapsEntry.value:type_name -> kratos.api.Data.Caps
+	29, // 35: kratos.api.Data.Ocsp.RespondersEntry.value:type_name -> kratos.api.Data.Ocsp.Responder
+	36, // [36:36] is the sub-list for method output_type
+	36, // [36:36] is the sub-list for method input_type
+	36, // [36:36] is the sub-list for extension type_name
+	36, // [36:36] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
 }
 
 func init() { file_conf_conf_proto_init() }
@@ -701,7 +2346,55 @@ func file_conf_conf_proto_init() {
 				return nil
 			}
 		}
+		file_conf_conf_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Server_Admin); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 		file_conf_conf_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Server_Metrics); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Server_HTTP_TLS); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Server_HTTP_TLS_Issue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Data_Database); i {
 			case 0:
 				return &v.state
@@ -713,7 +2406,7 @@ func file_conf_conf_proto_init() {
 				return nil
 			}
 		}
-		file_conf_conf_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+		file_conf_conf_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Data_Filedepot); i {
 			case 0:
 				return &v.state
@@ -725,7 +2418,19 @@ func file_conf_conf_proto_init() {
 				return nil
 			}
 		}
-		file_conf_conf_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+		file_conf_conf_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Boltdepot); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Data_RSASigerConfig); i {
 			case 0:
 				return &v.state
@@ -737,6 +2442,138 @@ func file_conf_conf_proto_init() {
 				return nil
 			}
 		}
+		file_conf_conf_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Challenge); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Profile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Policy); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Crl); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Ocsp); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Replay); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Ra); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Caps); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_KeyProvider); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Profile_Subject); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_conf_conf_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Data_Ocsp_Responder); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -744,7 +2581,7 @@ func file_conf_conf_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_conf_conf_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   31,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1,50 +1,176 @@
 package data
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/x509"
+	"encoding/pem"
+	"fmt"
 	"kscep/internal/biz"
 	"kscep/internal/conf"
+	"kscep/internal/depots"
+	"kscep/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/emmansun/gmsm/smx509"
 	"github.com/go-kratos/kratos/v2/log"
 )
 
 type SCEPCARepo struct {
-	data       *Data
-	dataConfig *conf.Data
-	log        *log.Helper
+	data *Data
+	ras  map[biz.CaType]*registrationAuthority
+	// addlCA are the CA certificates found in filedepot.addlcapath.
+	addlCA []*x509.Certificate
+	log    *log.Helper
 }
 
-func NewSCEPCARepo(c *conf.Data, data *Data, logger log.Logger) biz.SCEPCARepo {
+type registrationAuthority struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// NewSCEPCARepo loads the RA certificates and keys configured in ra and the
+// additional CA certificates in filedepot.addlcapath.
+func NewSCEPCARepo(c *conf.Data, data *Data, logger log.Logger) (biz.SCEPCARepo, error) {
+	helper := log.NewHelper(log.With(logger, "module", "data/scep/ca"))
+	ras := map[biz.CaType]*registrationAuthority{}
+	for name, rc := range c.GetRa() {
+		t := strings.ToUpper(name)
+		if t == "" || !utils.IsInArray(biz.SupportedCaTypes, t) {
+			return nil, fmt.Errorf("ra: %w: %q", biz.UnsupportedCaTypeErr, name)
+		}
+		crt, err := utils.LoadPEMCertFromFile(rc.GetCert())
+		if err != nil {
+			return nil, fmt.Errorf("ra %s certificate: %w", t, err)
+		}
+		key, err := utils.LoadPEMKeyFromFile(rc.GetKey())
+		if err != nil {
+			return nil, fmt.Errorf("ra %s key: %w", t, err)
+		}
+		if !keyMatches(key, crt) {
+			return nil, fmt.Errorf("ra %s key does not match its certificate", t)
+		}
+		ras[biz.GetCaType(t)] = &registrationAuthority{cert: crt, key: key}
+	}
+	var addlCA []*x509.Certificate
+	if dir := c.GetFiledepot().GetAddlcapath(); dir != "" {
+		var err error
+		if addlCA, err = loadAddlCA(dir, helper); err != nil {
+			return nil, fmt.Errorf("addlcapath: %w", err)
+		}
+	}
 	return &SCEPCARepo{
-		data:       data,
-		dataConfig: c,
-		log:        log.NewHelper(log.With(logger, "module", "data/scep/ca")),
+		data:   data,
+		ras:    ras,
+		addlCA: addlCA,
+		log:    helper,
+	}, nil
+}
+
+// loadAddlCA reads the CA certificates in the files of dir, which may be
+// PEM bundles or DER encoded. Other files, and certificates that are not CA
+// certificates, such as the keys and issued certificates of a file depot
+// sharing the directory, are skipped.
+func loadAddlCA(dir string, l *log.Helper) ([]*x509.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cas []*x509.Certificate
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, crt := range parseCertificates(data) {
+			if crt.BasicConstraintsValid && crt.IsCA {
+				cas = append(cas, crt)
+			}
+		}
 	}
+	l.Infof("loaded %d additional CA certificates from %s", len(cas), dir)
+	return cas, nil
 }
 
-func (c *SCEPCARepo) GetCert(t biz.CaType) (*x509.Certificate, error) {
-	var pass string = ""
-	if t == biz.RsaCa {
-		pass = c.dataConfig.RSAsigerconfig.Capass
+// parseCertificates returns the certificates of a PEM bundle or of
+// concatenated DER certificates, or none if data is neither.
+func parseCertificates(data []byte) []*x509.Certificate {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		smCrts, _ := smx509.ParseCertificates(data)
+		crts := make([]*x509.Certificate, 0, len(smCrts))
+		for _, crt := range smCrts {
+			crts = append(crts, crt.ToX509())
+		}
+		return crts
+	}
+	var crts []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return crts
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if crt, err := smx509.ParseCertificate(block.Bytes); err == nil {
+			crts = append(crts, crt.ToX509())
+		}
 	}
-	pub, _, err := c.data.Depot.CA([]byte(pass), t.String())
+}
+
+func (c *SCEPCARepo) GetCert(t biz.CaType) (*x509.Certificate, error) {
+	pub, err := c.data.CACerts(t)
 	if err != nil {
 		return nil, err
 	}
 	return pub[0], nil
 }
-func (c *SCEPCARepo) GetKey(t biz.CaType) (interface{}, error) {
-	var pass string = ""
-	if t == biz.RsaCa {
-		pass = c.dataConfig.RSAsigerconfig.Capass
-	}
-	_, key, err := c.data.Depot.CA([]byte(pass), t.String())
+
+func (c *SCEPCARepo) GetKey(t biz.CaType) (crypto.Signer, error) {
+	crt, err := c.GetCert(t)
 	if err != nil {
 		return nil, err
 	}
-	return key, nil
+	return c.data.CAKey(t, crt)
+}
+
+func (c *SCEPCARepo) GetNextCert(t biz.CaType) (*x509.Certificate, error) {
+	if c.data.Rollover == nil {
+		return nil, biz.MissingNextCaCertErr
+	}
+	crt, err := c.data.Rollover.NextCA(t.String())
+	if err == depots.NextCANotFoundErr {
+		return nil, biz.MissingNextCaCertErr
+	}
+	return crt, err
+}
+
+func (c *SCEPCARepo) SchedulePromotion(t biz.CaType, at time.Time) error {
+	if c.data.Rollover == nil {
+		return biz.DepotConfigErr
+	}
+	err := c.data.Rollover.ScheduleCAPromotion(t.String(), at)
+	if err == depots.NextCANotFoundErr {
+		return biz.MissingNextCaCertErr
+	}
+	return err
 }
 
 func (c *SCEPCARepo) GetAddlCA() ([]*x509.Certificate, error) {
-	return nil, nil
+	return c.addlCA, nil
+}
+
+func (c *SCEPCARepo) GetRA(t biz.CaType) (*x509.Certificate, crypto.Signer, error) {
+	ra, ok := c.ras[t]
+	if !ok {
+		return nil, nil, biz.MissingRaErr
+	}
+	return ra.cert, ra.key, nil
 }
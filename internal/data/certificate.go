@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"crypto/x509"
+	"kscep/internal/biz"
+	"kscep/internal/depots"
+	"math/big"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type CertificateRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+func NewCertificateRepo(data *Data, logger log.Logger) biz.CertificateRepo {
+	return &CertificateRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "data/scep/certificate")),
+	}
+}
+
+func (r *CertificateRepo) GetCertificate(ctx context.Context, serial *big.Int) (*x509.Certificate, error) {
+	if r.data.Certificates == nil {
+		return nil, biz.CertNotFoundErr
+	}
+	crt, err := r.data.Certificates.Certificate(serial)
+	if err == depots.CertNotFoundErr {
+		return nil, biz.CertNotFoundErr
+	}
+	return crt, err
+}
+
+func certificateQuery(f *biz.CertificateFilter) *depots.CertificateQuery {
+	return &depots.CertificateQuery{
+		CommonName:    f.CommonName,
+		Serial:        f.Serial,
+		Status:        string(f.Status),
+		ExpiresBefore: f.ExpiresBefore,
+		Issuer:        f.Issuer,
+	}
+}
+
+func (r *CertificateRepo) ListCertificates(ctx context.Context, f *biz.CertificateFilter) ([]*biz.IssuedCertificate, error) {
+	recs, err := r.data.Depot.QueryCertificates(certificateQuery(f))
+	if err != nil {
+		return nil, err
+	}
+	certs := make([]*biz.IssuedCertificate, 0, len(recs))
+	for _, rec := range recs {
+		certs = append(certs, &biz.IssuedCertificate{
+			Certificate: rec.Certificate,
+			Status:      biz.CertificateStatus(rec.Status),
+			RevokedAt:   rec.RevokedAt,
+			Reason:      rec.Reason,
+		})
+	}
+	return certs, nil
+}
+
+func (r *CertificateRepo) CountCertificates(ctx context.Context, f *biz.CertificateFilter) (int, error) {
+	if r.data.Counter != nil {
+		return r.data.Counter.CountCertificates(certificateQuery(f))
+	}
+	recs, err := r.data.Depot.QueryCertificates(certificateQuery(f))
+	return len(recs), err
+}
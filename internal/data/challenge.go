@@ -0,0 +1,85 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"kscep/internal/biz"
+	"kscep/internal/depots"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type ChallengeRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+func NewChallengeRepo(data *Data, logger log.Logger) biz.ChallengeRepo {
+	return &ChallengeRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "data/scep/challenge")),
+	}
+}
+
+func (r *ChallengeRepo) CreateChallenge(ctx context.Context, ch *biz.Challenge) error {
+	if r.data.Challenges == nil {
+		return biz.DepotConfigErr
+	}
+	return r.data.Challenges.PutChallenge(&depots.Challenge{
+		Digest:     challengeDigest(ch.Value),
+		CommonName: ch.CommonName,
+		CreatedAt:  ch.CreatedAt,
+		ExpiresAt:  ch.ExpiresAt,
+	})
+}
+
+func (r *ChallengeRepo) GetChallenge(ctx context.Context, challenge string) (*biz.Challenge, error) {
+	if r.data.Challenges == nil {
+		return nil, biz.ChallengeNotFoundErr
+	}
+	ch, err := r.data.Challenges.GetChallenge(challengeDigest(challenge))
+	if err != nil {
+		return nil, challengeErr(err)
+	}
+	return &biz.Challenge{
+		Value:      challenge,
+		CommonName: ch.CommonName,
+		CreatedAt:  ch.CreatedAt,
+		ExpiresAt:  ch.ExpiresAt,
+		Consumed:   ch.Consumed(),
+	}, nil
+}
+
+func (r *ChallengeRepo) ConsumeChallenge(ctx context.Context, challenge string) error {
+	if r.data.Challenges == nil {
+		return biz.ChallengeNotFoundErr
+	}
+	return challengeErr(r.data.Challenges.ConsumeChallenge(challengeDigest(challenge)))
+}
+
+func (r *ChallengeRepo) ReleaseChallenge(ctx context.Context, challenge string) error {
+	if r.data.Challenges == nil {
+		return biz.ChallengeNotFoundErr
+	}
+	return challengeErr(r.data.Challenges.ReleaseChallenge(challengeDigest(challenge)))
+}
+
+// challengeDigest is the key a challenge is stored under, so the depot
+// never holds usable challenge passwords.
+func challengeDigest(challenge string) string {
+	sum := sha256.Sum256([]byte(challenge))
+	return hex.EncodeToString(sum[:])
+}
+
+// challengeErr maps depot errors to their biz counterparts.
+func challengeErr(err error) error {
+	switch err {
+	case depots.ChallengeNotFoundErr:
+		return biz.ChallengeNotFoundErr
+	case depots.ChallengeConsumedErr:
+		return biz.ChallengeConsumedErr
+	default:
+		return err
+	}
+}
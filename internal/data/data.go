@@ -1,10 +1,17 @@
 package data
 
 import (
+	"database/sql"
+	"time"
+
 	"kscep/internal/biz"
 	"kscep/internal/conf"
+	boltdepot "kscep/internal/depots/bolt"
 	"kscep/internal/depots/filedepot"
+	"kscep/internal/depots/sqldepot"
+	"kscep/internal/keyprovider"
 
+	"github.com/boltdb/bolt"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/google/wire"
 )
@@ -14,31 +21,146 @@ var ProviderSet = wire.NewSet(
 	NewData,
 	NewSCEPCARepo,
 	NewSigner,
+	NewChallengeRepo,
+	NewPendingRepo,
+	NewRevocationRepo,
+	NewCertificateRepo,
+	NewReplayRepo,
 )
 
 // Data .
 type Data struct {
-	Depot Depot
+	Depot        Depot
+	Certificates CertificateDepot
+	Challenges   ChallengeDepot
+	Pending      PendingDepot
+	Rollover     RolloverDepot
+	// Revocations is nil for depots that cannot revoke certificates.
+	Revocations RevocationDepot
+	// Replay is nil for depots that cannot share the replay cache.
+	Replay ReplayDepot
+	// Counter is nil for depots that cannot count certificates without
+	// reading them.
+	Counter CertificateCounter
+	// Keys provides the CA keys, which may be kept out of the depot.
+	Keys keyprovider.Provider
+	// caPass are the passphrases of the CA keys by CA type.
+	caPass map[biz.CaType]string
 }
 
 // NewData .
 func NewData(c *conf.Data, logger log.Logger) (*Data, func(), error) {
 	var depot Depot
-	var err error
+	var certificates CertificateDepot
+	var challenges ChallengeDepot
+	var pending PendingDepot
+	var rollover RolloverDepot
+	var revocations RevocationDepot
+	var replay ReplayDepot
+	var counter CertificateCounter
+	var closers []func() error
+	caPass, err := biz.CAPassphrases(c)
+	if err != nil {
+		return nil, nil, err
+	}
 	switch c.DepotType {
 	case "file":
 		if c.Filedepot.Capath == "" || c.Filedepot.Addlcapath == "" {
 			return nil, nil, biz.DepotConfigErr
 		}
-		depot, err = filedepot.NewFileDepot(c.Filedepot.Capath)
+		fd, err := filedepot.NewFileDepot(c.Filedepot.Capath)
 		if err != nil {
 			panic(err)
 		}
+		depot, certificates, challenges, pending, rollover, revocations = fd, fd, fd, fd, fd, fd
+	case "bolt":
+		if c.Boltdepot.GetPath() == "" {
+			return nil, nil, biz.DepotConfigErr
+		}
+		db, err := bolt.Open(c.Boltdepot.GetPath(), 0600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, nil, err
+		}
+		bd, err := boltdepot.NewBoltDepot(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		// create the RSA CA on first use
+		keySize, years := int(c.Boltdepot.GetCaKeySize()), int(c.Boltdepot.GetCaYears())
+		if keySize == 0 {
+			keySize = 2048
+		}
+		if years == 0 {
+			years = 10
+		}
+		key, err := bd.CreateOrLoadKey(biz.RsaCa.String(), keySize)
+		if err == nil {
+			_, err = bd.CreateOrLoadCA(biz.RsaCa.String(), key, years, c.Boltdepot.GetCaOrganization(), c.Boltdepot.GetCaCountry())
+		}
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		closers = append(closers, db.Close)
+		depot, certificates, challenges, pending, rollover, revocations = bd, bd, bd, bd, bd, bd
+		replay = bd
+	case "sql":
+		if c.Database.GetDriver() == "" || c.Database.GetSource() == "" {
+			return nil, nil, biz.DepotConfigErr
+		}
+		db, err := sql.Open(c.Database.GetDriver(), c.Database.GetSource())
+		if err != nil {
+			return nil, nil, err
+		}
+		sd, err := sqldepot.NewSQLDepot(db, c.Database.GetDriver())
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		// create the RSA CA on first use
+		keySize, years := int(c.Database.GetCaKeySize()), int(c.Database.GetCaYears())
+		if keySize == 0 {
+			keySize = 2048
+		}
+		if years == 0 {
+			years = 10
+		}
+		_, err = sd.CreateOrLoadCA(biz.RsaCa.String(), []byte(caPass[biz.RsaCa]), keySize, years, c.Database.GetCaOrganization(), c.Database.GetCaCountry())
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		closers = append(closers, db.Close)
+		depot, certificates, challenges, pending, rollover, revocations = sd, sd, sd, sd, sd, sd
+		replay, counter = sd, sd
+	}
+	keys, err := newKeyProvider(c, depot, caPass)
+	if err != nil {
+		for _, c := range closers {
+			c()
+		}
+		return nil, nil, err
 	}
 	cleanup := func() {
-		log.NewHelper(logger).Info("closing the data resources")
+		l := log.NewHelper(logger)
+		l.Info("closing the data resources")
+		for _, c := range closers {
+			if err := c(); err != nil {
+				l.Error(err)
+			}
+		}
 	}
 	return &Data{
-		Depot: depot,
+		Depot:        depot,
+		Certificates: certificates,
+		Challenges:   challenges,
+		Pending:      pending,
+		Rollover:     rollover,
+		Revocations:  revocations,
+		Replay:       replay,
+		Counter:      counter,
+		Keys:         keys,
+		caPass:       caPass,
 	}, cleanup, nil
 }
@@ -2,13 +2,73 @@ package data
 
 import (
 	"crypto/x509"
+	"kscep/internal/depots"
 	"math/big"
+	"time"
 )
 
 // Depot is a repository for managing certificates
 type Depot interface {
 	CA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error)
+	// CACerts returns the certificates of CA like CA, without its key.
+	CACerts(namePrefix string) ([]*x509.Certificate, error)
 	Put(name string, crt *x509.Certificate) error
 	Serial() (*big.Int, error)
 	HasCN(cn string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error)
+	// QueryCertificates lists the issued certificates that match q.
+	QueryCertificates(q *depots.CertificateQuery) ([]*depots.CertificateRecord, error)
+}
+
+// CertificateCounter counts the issued certificates that match a query
+type CertificateCounter interface {
+	CountCertificates(q *depots.CertificateQuery) (int, error)
+}
+
+// CertificateDepot looks up issued certificates by serial number
+type CertificateDepot interface {
+	Certificate(serial *big.Int) (*x509.Certificate, error)
+}
+
+// ChallengeDepot persists one-time enrollment challenges
+type ChallengeDepot interface {
+	PutChallenge(ch *depots.Challenge) error
+	GetChallenge(digest string) (*depots.Challenge, error)
+	ConsumeChallenge(digest string) error
+	ReleaseChallenge(digest string) error
+}
+
+// PendingDepot persists enrollments waiting for manual approval
+type PendingDepot interface {
+	PutPending(pr *depots.PendingRequest) error
+	GetPending(id string) (*depots.PendingRequest, error)
+	ListPending(status string) ([]*depots.PendingRequest, error)
+	UpdatePending(pr *depots.PendingRequest, status string) error
+}
+
+// RolloverDepot stages the CA that replaces the current one of a CA type
+type RolloverDepot interface {
+	NextCA(namePrefix string) (*x509.Certificate, error)
+	ScheduleCAPromotion(namePrefix string, at time.Time) error
+}
+
+// RevocationDepot revokes issued certificates and lists the revoked ones
+type RevocationDepot interface {
+	Revoke(serial *big.Int, reason int) error
+	// Revocation returns nil if the certificate is not revoked.
+	Revocation(serial *big.Int) (*depots.Revocation, error)
+	Revocations() ([]*depots.Revocation, error)
+}
+
+// ReplayDepot remembers the senderNonces and transactions of pkiMessages, so
+// that replicas sharing the depot detect replays sent to one another
+type ReplayDepot interface {
+	// PutNonce records nonce until expiresAt. It fails with
+	// depots.NonceReusedErr if the nonce is recorded and has not expired.
+	PutNonce(nonce string, expiresAt time.Time) error
+	PutTransaction(tx *depots.Transaction) error
+	// GetTransaction fails with depots.TransactionNotFoundErr for unknown
+	// and expired transactions.
+	GetTransaction(id string) (*depots.Transaction, error)
+	// PurgeReplay forgets the nonces and transactions expired at t.
+	PurgeReplay(t time.Time) error
 }
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"sync"
+
+	"kscep/internal/biz"
+	"kscep/internal/conf"
+	"kscep/internal/keyprovider"
+)
+
+// key provider types of conf.Data.KeyProvider
+const (
+	keyProviderDepot  = "depot"
+	keyProviderRemote = "remote"
+)
+
+// newKeyProvider returns the provider of the CA keys configured in c,
+// reading them from depot by default.
+func newKeyProvider(c *conf.Data, depot Depot, caPass map[biz.CaType]string) (keyprovider.Provider, error) {
+	kp := c.GetKeyProvider()
+	switch kp.GetType() {
+	case "", keyProviderDepot:
+		return &depotKeys{depot: depot, caPass: caPass, signers: map[string]crypto.Signer{}}, nil
+	case keyProviderRemote:
+		if kp.GetSocket() == "" {
+			return nil, fmt.Errorf("key_provider: %w: no socket", biz.DepotConfigErr)
+		}
+		return keyprovider.NewRemoteProvider(kp.GetSocket(), kp.GetTimeout().AsDuration()), nil
+	default:
+		return nil, fmt.Errorf("key_provider: %w: unknown type %q", biz.DepotConfigErr, kp.GetType())
+	}
+}
+
+// depotKeys provides the CA keys stored in the depot with the CA
+// certificates.
+type depotKeys struct {
+	depot  Depot
+	caPass map[biz.CaType]string
+
+	mu      sync.Mutex
+	signers map[string]crypto.Signer
+}
+
+// Signer returns the key of CA name. Parsed keys are kept while they match
+// the certificate of the CA, which is read without decrypting the key, so
+// a rolled over or replaced CA has its key read again.
+func (k *depotKeys) Signer(name string) (crypto.Signer, error) {
+	certs, err := k.depot.CACerts(name)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	signer, ok := k.signers[name]
+	k.mu.Unlock()
+	if ok && keyMatches(signer, certs[0]) {
+		return signer, nil
+	}
+	_, key, err := k.depot.CA([]byte(k.caPass[biz.GetCaType(name)]), name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, keyprovider.KeyNotFoundErr
+	}
+	if signer, err = keyprovider.ToSigner(key); err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.signers[name] = signer
+	k.mu.Unlock()
+	return signer, nil
+}
+
+// CAKey returns the key of crt, the current CA of type t. A key provider
+// other than the depot is not rolled over with it: after a promotion its key
+// for t still belongs to the previous CA, and the successor is looked up as
+// <TYPE>.next, where it is staged next to the key. A key matching neither
+// is refused rather than signed with.
+func (d *Data) CAKey(t biz.CaType, crt *x509.Certificate) (crypto.Signer, error) {
+	key, err := d.Keys.Signer(t.String())
+	if err != nil {
+		return nil, err
+	}
+	if keyMatches(key, crt) {
+		return key, nil
+	}
+	if next, err := d.Keys.Signer(t.String() + ".next"); err == nil && keyMatches(next, crt) {
+		return next, nil
+	}
+	return nil, fmt.Errorf("%s: %w", t, biz.CAKeyMismatchErr)
+}
+
+// keyMatches reports whether key is the private key of crt.
+func keyMatches(key crypto.Signer, crt *x509.Certificate) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(crt.PublicKey)
+}
+
+// CACerts returns the certificate chain of the CA of type t, the CA
+// certificate first.
+// A CA key kept in the depot must still open with the passphrase of t; it
+// is parsed once and then served from the cache of depotKeys.
+func (d *Data) CACerts(t biz.CaType) ([]*x509.Certificate, error) {
+	if _, ok := d.Keys.(*depotKeys); ok {
+		if _, err := d.Keys.Signer(t.String()); err != nil {
+			return nil, err
+		}
+	}
+	return d.Depot.CACerts(t.String())
+}
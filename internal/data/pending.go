@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"kscep/internal/biz"
+	"kscep/internal/depots"
+
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type PendingRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+func NewPendingRepo(data *Data, logger log.Logger) biz.PendingRepo {
+	return &PendingRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "data/scep/pending")),
+	}
+}
+
+func (r *PendingRepo) SavePending(ctx context.Context, pr *biz.PendingRequest) error {
+	if r.data.Pending == nil {
+		return biz.DepotConfigErr
+	}
+	return r.data.Pending.PutPending(pendingRecord(pr))
+}
+
+func (r *PendingRepo) UpdatePending(ctx context.Context, pr *biz.PendingRequest, status biz.PendingStatus) error {
+	if r.data.Pending == nil {
+		return biz.DepotConfigErr
+	}
+	switch err := r.data.Pending.UpdatePending(pendingRecord(pr), string(status)); err {
+	case depots.PendingNotFoundErr:
+		return biz.PendingNotFoundErr
+	case depots.PendingStatusErr:
+		return biz.PendingNotPendingErr
+	default:
+		return err
+	}
+}
+
+func pendingRecord(pr *biz.PendingRequest) *depots.PendingRequest {
+	rec := &depots.PendingRequest{
+		ID:            pr.ID,
+		TransactionID: pr.TransactionID,
+		Profile:       pr.Profile,
+		CaType:        pr.CaType.String(),
+		CSR:           pr.CSR.Raw,
+		Status:        string(pr.Status),
+		Reason:        pr.Reason,
+		CreatedAt:     pr.CreatedAt,
+		UpdatedAt:     pr.UpdatedAt,
+	}
+	if pr.Certificate != nil {
+		rec.Certificate = pr.Certificate.Raw
+	}
+	return rec
+}
+
+func (r *PendingRepo) GetPending(ctx context.Context, id string) (*biz.PendingRequest, error) {
+	if r.data.Pending == nil {
+		return nil, biz.PendingNotFoundErr
+	}
+	rec, err := r.data.Pending.GetPending(id)
+	if err == depots.PendingNotFoundErr {
+		return nil, biz.PendingNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pendingFromRecord(rec)
+}
+
+func (r *PendingRepo) ListPending(ctx context.Context, status biz.PendingStatus) ([]*biz.PendingRequest, error) {
+	if r.data.Pending == nil {
+		return nil, nil
+	}
+	recs, err := r.data.Pending.ListPending(string(status))
+	if err != nil {
+		return nil, err
+	}
+	prs := make([]*biz.PendingRequest, 0, len(recs))
+	for _, rec := range recs {
+		pr, err := pendingFromRecord(rec)
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+func pendingFromRecord(rec *depots.PendingRequest) (*biz.PendingRequest, error) {
+	csr, err := smx509.ParseCertificateRequest(rec.CSR)
+	if err != nil {
+		return nil, err
+	}
+	pr := &biz.PendingRequest{
+		ID:            rec.ID,
+		TransactionID: rec.TransactionID,
+		Profile:       rec.Profile,
+		CaType:        biz.GetCaType(rec.CaType),
+		CSR:           csr.ToX509(),
+		Status:        biz.PendingStatus(rec.Status),
+		Reason:        rec.Reason,
+		CreatedAt:     rec.CreatedAt,
+		UpdatedAt:     rec.UpdatedAt,
+	}
+	if len(rec.Certificate) > 0 {
+		crt, err := smx509.ParseCertificate(rec.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		pr.Certificate = crt.ToX509()
+	}
+	return pr, nil
+}
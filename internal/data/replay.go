@@ -0,0 +1,175 @@
+package data
+
+import (
+	"container/list"
+	"context"
+	"encoding/hex"
+	"kscep/internal/biz"
+	"kscep/internal/conf"
+	"kscep/internal/depots"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// replayPurgeInterval is how often expired records are deleted from the
+// depot.
+const replayPurgeInterval = time.Minute
+
+// NewReplayRepo keeps the replay cache in memory, or in the depot if
+// replay.depot is set so that replicas sharing it see each other's
+// messages.
+func NewReplayRepo(c *conf.Data, data *Data, logger log.Logger) (biz.ReplayRepo, error) {
+	helper := log.NewHelper(log.With(logger, "module", "data/scep/replay"))
+	rc := c.GetReplay()
+	if rc.GetDepot() {
+		if data.Replay == nil {
+			return nil, biz.DepotConfigErr
+		}
+		return &DepotReplayRepo{data: data, log: helper}, nil
+	}
+	size := int(rc.GetMaxEntries())
+	if size <= 0 {
+		size = biz.DefaultReplayEntries
+	}
+	return &MemoryReplayRepo{
+		nonces:       newExpiringCache(size),
+		transactions: newExpiringCache(size),
+		log:          helper,
+	}, nil
+}
+
+// MemoryReplayRepo is a replay cache for a single replica. It holds at most
+// the configured number of nonces and transactions each, forgetting the
+// oldest ones first.
+type MemoryReplayRepo struct {
+	mu           sync.Mutex
+	nonces       *expiringCache
+	transactions *expiringCache
+	log          *log.Helper
+}
+
+func (r *MemoryReplayRepo) PutNonce(ctx context.Context, nonce []byte, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.nonces.get(string(nonce), time.Now()); ok {
+		return biz.NonceReusedErr
+	}
+	r.nonces.put(string(nonce), nil, expiresAt)
+	return nil
+}
+
+func (r *MemoryReplayRepo) PutTransaction(ctx context.Context, id string, serial *big.Int, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transactions.put(id, new(big.Int).Set(serial), expiresAt)
+	return nil
+}
+
+func (r *MemoryReplayRepo) GetTransaction(ctx context.Context, id string) (*big.Int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, ok := r.transactions.get(id, time.Now())
+	if !ok {
+		return nil, biz.TransactionNotFoundErr
+	}
+	return new(big.Int).Set(v.(*big.Int)), nil
+}
+
+// expiringCache is a bounded map whose entries expire. Entries are kept in
+// insertion order, so evicting the oldest one also drops the expired ones
+// first.
+type expiringCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type expiringEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func newExpiringCache(size int) *expiringCache {
+	return &expiringCache{size: size, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *expiringCache) get(key string, now time.Time) (interface{}, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*expiringEntry)
+	if !now.Before(e.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+func (c *expiringCache) put(key string, value interface{}, expiresAt time.Time) {
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+	}
+	c.entries[key] = c.order.PushBack(&expiringEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*expiringEntry).key)
+	}
+}
+
+// DepotReplayRepo is a replay cache shared through the depot.
+type DepotReplayRepo struct {
+	data *Data
+	log  *log.Helper
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func (r *DepotReplayRepo) PutNonce(ctx context.Context, nonce []byte, expiresAt time.Time) error {
+	r.purge()
+	err := r.data.Replay.PutNonce(hex.EncodeToString(nonce), expiresAt)
+	if err == depots.NonceReusedErr {
+		return biz.NonceReusedErr
+	}
+	return err
+}
+
+func (r *DepotReplayRepo) PutTransaction(ctx context.Context, id string, serial *big.Int, expiresAt time.Time) error {
+	return r.data.Replay.PutTransaction(&depots.Transaction{ID: id, Serial: serial, ExpiresAt: expiresAt})
+}
+
+func (r *DepotReplayRepo) GetTransaction(ctx context.Context, id string) (*big.Int, error) {
+	tx, err := r.data.Replay.GetTransaction(id)
+	if err == depots.TransactionNotFoundErr {
+		return nil, biz.TransactionNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tx.Serial, nil
+}
+
+// purge deletes the expired records at most once per replayPurgeInterval,
+// which bounds the depot to the messages of one window.
+func (r *DepotReplayRepo) purge() {
+	now := time.Now()
+	r.mu.Lock()
+	due := now.Sub(r.lastPurge) >= replayPurgeInterval
+	if due {
+		r.lastPurge = now
+	}
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := r.data.Replay.PurgeReplay(now); err != nil {
+		r.log.Errorf("failed to purge the replay cache: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"kscep/internal/biz"
+	"kscep/internal/depots"
+	"math/big"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type RevocationRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+func NewRevocationRepo(data *Data, logger log.Logger) biz.RevocationRepo {
+	return &RevocationRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "data/scep/revocation")),
+	}
+}
+
+func (r *RevocationRepo) Revoke(ctx context.Context, serial *big.Int, reason int) error {
+	if r.data.Revocations == nil {
+		return biz.DepotConfigErr
+	}
+	switch err := r.data.Revocations.Revoke(serial, reason); err {
+	case depots.CertNotFoundErr:
+		return biz.CertNotFoundErr
+	case depots.AlreadyRevokedErr:
+		return biz.AlreadyRevokedErr
+	default:
+		return err
+	}
+}
+
+// GetRevocation returns the revocation of the certificate with the given
+// serial number, or nil if it is not revoked.
+func (r *RevocationRepo) GetRevocation(ctx context.Context, serial *big.Int) (*biz.Revocation, error) {
+	if r.data.Revocations == nil {
+		return nil, nil
+	}
+	rec, err := r.data.Revocations.Revocation(serial)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	return &biz.Revocation{
+		Serial:    rec.Serial,
+		RevokedAt: rec.RevokedAt,
+		Reason:    rec.Reason,
+	}, nil
+}
+
+// ListRevoked returns the revoked certificates issued by issuer.
+func (r *RevocationRepo) ListRevoked(ctx context.Context, issuer *x509.Certificate) ([]*biz.Revocation, error) {
+	if r.data.Revocations == nil {
+		return nil, nil
+	}
+	recs, err := r.data.Revocations.Revocations()
+	if err != nil {
+		return nil, err
+	}
+	var revs []*biz.Revocation
+	for _, rec := range recs {
+		if !bytes.Equal(rec.Issuer, issuer.RawSubject) {
+			continue
+		}
+		revs = append(revs, &biz.Revocation{
+			Serial:    rec.Serial,
+			RevokedAt: rec.RevokedAt,
+			Reason:    rec.Reason,
+		})
+	}
+	return revs, nil
+}
@@ -4,41 +4,39 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"kscep/internal/biz"
 	"time"
 
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
 	"github.com/go-kratos/kratos/v2/log"
-	"github.com/ploynomail/scep"
 	"github.com/ploynomail/scep/cryptoutil"
 )
 
 // Signer signs x509 certificates and stores them in a Depot
 type SignerRepo struct {
 	data             *Data
-	caPass           string
 	allowRenewalDays int
 	validityDays     int
 	serverAttrs      bool
-	signatureAlgo    x509.SignatureAlgorithm
-	log              *log.Helper
+	// metrics time the sign and depot write phases.
+	metrics *biz.Metrics
+	log     *log.Helper
 }
 
 // Option customizes Signer
 type Option func(*SignerRepo)
 
 // NewSigner creates a new Signer
-func NewSigner(data *Data, logger log.Logger) biz.CSRSignerRepo {
+func NewSigner(data *Data, metrics *biz.Metrics, logger log.Logger) biz.CSRSignerRepo {
 	return &SignerRepo{
-		data: data,
-		log:  log.NewHelper(log.With(logger, "module", "data/signer")),
+		data:    data,
+		metrics: metrics,
+		log:     log.NewHelper(log.With(logger, "module", "data/signer")),
 	}
 }
 
-// WithCAPass specifies the password to use with an encrypted CA key
-func (s *SignerRepo) WithCAPass(pass string) {
-	s.caPass = pass
-}
-
 // WithAllowRenewalDays sets the allowable renewal time for existing certs
 func (s *SignerRepo) WithAllowRenewalDays(r int) {
 	s.allowRenewalDays = r
@@ -53,8 +51,17 @@ func (s *SignerRepo) WithSeverAttrs() {
 	s.serverAttrs = true
 }
 
-func (s *SignerRepo) SignCSRContext(ctx context.Context, m *scep.CSRReqMessage) (*x509.Certificate, error) {
-	s.signatureAlgo = m.CSR.SignatureAlgorithm
+func (s *SignerRepo) SignCSRContext(ctx context.Context, req *biz.SignRequest) (*x509.Certificate, error) {
+	m, profile := req.CSR, req.Profile
+	caCerts, err := s.data.CACerts(req.CaType)
+	if err != nil {
+		return nil, err
+	}
+	caKey, err := s.data.CAKey(req.CaType, caCerts[0])
+	if err != nil {
+		return nil, err
+	}
+	signatureAlgo := signatureAlgorithmFor(m.CSR.SignatureAlgorithm, caCerts[0])
 	id, err := cryptoutil.GenerateSubjectKeyID(m.CSR.PublicKey)
 	if err != nil {
 		return nil, err
@@ -65,22 +72,20 @@ func (s *SignerRepo) SignCSRContext(ctx context.Context, m *scep.CSRReqMessage)
 		return nil, err
 	}
 
-	var signatureAlgo x509.SignatureAlgorithm
-	if s.signatureAlgo != 0 {
-		signatureAlgo = s.signatureAlgo
+	validityDays := s.validityDays
+	if profile.ValidityDays > 0 {
+		validityDays = profile.ValidityDays
 	}
 
-	// create cert template
+	// create cert template from the profile
 	tmpl := &x509.Certificate{
-		SerialNumber: serial,
-		Subject:      m.CSR.Subject,
-		NotBefore:    time.Now().Add(time.Second * -600).UTC(),
-		NotAfter:     time.Now().AddDate(0, 0, s.validityDays).UTC(),
-		SubjectKeyId: id,
-		KeyUsage:     x509.KeyUsageDigitalSignature,
-		ExtKeyUsage: []x509.ExtKeyUsage{
-			x509.ExtKeyUsageClientAuth,
-		},
+		SerialNumber:       serial,
+		Subject:            profileSubject(m.CSR.Subject, profile),
+		NotBefore:          time.Now().Add(time.Second * -600).UTC(),
+		NotAfter:           time.Now().AddDate(0, 0, validityDays).UTC(),
+		SubjectKeyId:       id,
+		KeyUsage:           profile.KeyUsage,
+		ExtKeyUsage:        append([]x509.ExtKeyUsage(nil), profile.ExtKeyUsage...),
 		SignatureAlgorithm: signatureAlgo,
 		DNSNames:           m.CSR.DNSNames,
 		EmailAddresses:     m.CSR.EmailAddresses,
@@ -93,29 +98,31 @@ func (s *SignerRepo) SignCSRContext(ctx context.Context, m *scep.CSRReqMessage)
 		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
 	}
 
-	caCerts, caKey, err := s.data.Depot.CA([]byte(s.caPass), "RSA")
-	if err != nil {
-		return nil, err
-	}
-
-	crtBytes, err := x509.CreateCertificate(rand.Reader, tmpl, caCerts[0], m.CSR.PublicKey, caKey)
+	// smx509 signs and parses SM2 certificates as well
+	start := time.Now()
+	crtBytes, err := smx509.CreateCertificate(rand.Reader, tmpl, caCerts[0], m.CSR.PublicKey, caKey)
+	s.metrics.ObservePhase(biz.PhaseSign, start)
 	if err != nil {
 		return nil, err
 	}
 
-	crt, err := x509.ParseCertificate(crtBytes)
+	smCrt, err := smx509.ParseCertificate(crtBytes)
 	if err != nil {
 		return nil, err
 	}
+	crt := smCrt.ToX509()
 
 	name := certName(crt)
 
 	// Test if this certificate is already in the CADB, revoke if needed
 	// revocation is done if the validity of the existing certificate is
 	// less than allowRenewalDays
-	_, err = s.data.Depot.HasCN(name, s.allowRenewalDays, crt, false)
-	if err != nil {
-		return nil, err
+	start = time.Now()
+	defer s.metrics.ObservePhase(biz.PhaseDepotWrite, start)
+	if !req.Serving {
+		if _, err = s.data.Depot.HasCN(name, s.allowRenewalDays, crt, false); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.data.Depot.Put(name, crt); err != nil {
@@ -125,6 +132,50 @@ func (s *SignerRepo) SignCSRContext(ctx context.Context, m *scep.CSRReqMessage)
 	return crt, nil
 }
 
+// profileSubject returns the CSR subject with the attributes set by the
+// profile replaced.
+func profileSubject(subject pkix.Name, p *biz.CertProfile) pkix.Name {
+	if p.Organization != "" {
+		subject.Organization = []string{p.Organization}
+	}
+	if p.OrganizationalUnit != "" {
+		subject.OrganizationalUnit = []string{p.OrganizationalUnit}
+	}
+	if p.Country != "" {
+		subject.Country = []string{p.Country}
+	}
+	if p.Province != "" {
+		subject.Province = []string{p.Province}
+	}
+	if p.Locality != "" {
+		subject.Locality = []string{p.Locality}
+	}
+	return subject
+}
+
+// signatureAlgorithmFor returns the signature algorithm of the CSR if the
+// CA key can produce it, and 0 to let smx509 pick one otherwise. An SM2 CA
+// always signs with SM2-with-SM3.
+func signatureAlgorithmFor(alg x509.SignatureAlgorithm, ca *x509.Certificate) x509.SignatureAlgorithm {
+	if sm2.IsSM2PublicKey(ca.PublicKey) {
+		return smx509.SM2WithSM3
+	}
+	switch ca.PublicKeyAlgorithm {
+	case x509.RSA:
+		switch alg {
+		case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+			x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+			return alg
+		}
+	case x509.ECDSA:
+		switch alg {
+		case x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+			return alg
+		}
+	}
+	return 0
+}
+
 func certName(crt *x509.Certificate) string {
 	if crt.Subject.CommonName != "" {
 		return crt.Subject.CommonName
@@ -0,0 +1,82 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"kscep/internal/depots"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// PutChallenge stores a one-time challenge keyed by its digest. An existing
+// challenge with the same digest is replaced.
+func (db *boltDepot) PutChallenge(ch *depots.Challenge) error {
+	if ch == nil || ch.Digest == "" {
+		return errors.New("invalid challenge digest")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket([]byte(challengeBucket)), ch.Digest, ch)
+	})
+}
+
+// GetChallenge loads the challenge stored for digest.
+func (db *boltDepot) GetChallenge(digest string) (*depots.Challenge, error) {
+	var ch *depots.Challenge
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		ch, err = getChallenge(tx, digest)
+		return err
+	})
+	return ch, err
+}
+
+// ConsumeChallenge marks the challenge as used. The check and the update
+// share one transaction, so concurrent enrollments cannot share a single
+// challenge.
+func (db *boltDepot) ConsumeChallenge(digest string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		ch, err := getChallenge(tx, digest)
+		if err != nil {
+			return err
+		}
+		if ch.Consumed() {
+			return depots.ChallengeConsumedErr
+		}
+		ch.ConsumedAt = time.Now().UTC()
+		return putJSON(tx.Bucket([]byte(challengeBucket)), digest, ch)
+	})
+}
+
+// ReleaseChallenge makes a consumed challenge usable again, for an
+// enrollment that failed after consuming it.
+func (db *boltDepot) ReleaseChallenge(digest string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		ch, err := getChallenge(tx, digest)
+		if err != nil {
+			return err
+		}
+		ch.ConsumedAt = time.Time{}
+		return putJSON(tx.Bucket([]byte(challengeBucket)), digest, ch)
+	})
+}
+
+func getChallenge(tx *bolt.Tx, digest string) (*depots.Challenge, error) {
+	data := tx.Bucket([]byte(challengeBucket)).Get([]byte(digest))
+	if data == nil {
+		return nil, depots.ChallengeNotFoundErr
+	}
+	var ch depots.Challenge
+	if err := json.Unmarshal(data, &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func putJSON(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
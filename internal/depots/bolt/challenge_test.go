@@ -0,0 +1,10 @@
+package bolt
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestBoltDepot_Challenge(t *testing.T) {
+	depottest.Challenge(t, newTestDepot(t))
+}
@@ -1,15 +1,19 @@
-//Note: Not Completed
-
 package bolt
 
 import (
 	"bytes"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"sync"
+	"time"
+
+	"kscep/internal/depots"
 
 	"github.com/boltdb/bolt"
 )
@@ -19,18 +23,31 @@ import (
 type boltDepot struct {
 	*bolt.DB
 	serialMu sync.RWMutex
+	// dbMu serialises revoking old certificates with storing new ones.
+	dbMu sync.Mutex
 }
 
 const (
-	certBucket = "scep_certificates"
+	certBucket      = "scep_certificates"
+	caBucket        = "scep_ca"
+	revokedBucket   = "scep_revoked"
+	challengeBucket = "scep_challenges"
+	pendingBucket   = "scep_pending"
+	// senderNonces and transactions remembered for replay protection
+	nonceBucket       = "scep_replay_nonces"
+	transactionBucket = "scep_replay_transactions"
 )
 
+// serialKey holds the next serial number in the certificate bucket.
+const serialKey = "serial"
+
 // NewBoltDepot creates a depot.Depot backed by BoltDB.
 func NewBoltDepot(db *bolt.DB) (*boltDepot, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(certBucket))
-		if err != nil {
-			return fmt.Errorf("create bucket: %s", err)
+		for _, name := range []string{certBucket, caBucket, revokedBucket, challengeBucket, pendingBucket, nonceBucket, transactionBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create bucket: %s", err)
+			}
 		}
 		return nil
 	})
@@ -54,18 +71,52 @@ func bucketGetCopy(b *bolt.Bucket, key []byte) (out []byte) {
 	return
 }
 
-func (db *boltDepot) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, error) {
+// CA returns the CA stored under namePrefix (RSA, ECC, SM2, ...). The key
+// is stored unencrypted in the database, so pass is not used.
+func (db *boltDepot) CA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error) {
+	if err := db.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	return db.loadCA(namePrefix)
+}
+
+// CACerts returns the certificate of the CA namePrefix like CA, without
+// reading its key.
+func (db *boltDepot) CACerts(namePrefix string) ([]*x509.Certificate, error) {
+	if err := db.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	var cert *x509.Certificate
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		caCert := bucketGetCopy(bucket, []byte(namePrefix+".crt"))
+		if caCert == nil {
+			return fmt.Errorf("no %s CA certificate in bucket", namePrefix)
+		}
+		var err error
+		cert, err = x509.ParseCertificate(caCert)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+func (db *boltDepot) loadCA(namePrefix string) ([]*x509.Certificate, interface{}, error) {
 	chain := []*x509.Certificate{}
-	var key *rsa.PrivateKey
+	var key interface{}
 	err := db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(certBucket))
+		bucket := tx.Bucket([]byte(caBucket))
 		if bucket == nil {
-			return fmt.Errorf("bucket %q not found!", certBucket)
+			return fmt.Errorf("bucket %q not found!", caBucket)
 		}
-		// get ca_certificate
-		caCert := bucketGetCopy(bucket, []byte("ca_certificate"))
+		caCert := bucketGetCopy(bucket, []byte(namePrefix+".crt"))
 		if caCert == nil {
-			return fmt.Errorf("no ca_certificate in bucket")
+			return fmt.Errorf("no %s CA certificate in bucket", namePrefix)
 		}
 		cert, err := x509.ParseCertificate(caCert)
 		if err != nil {
@@ -73,16 +124,12 @@ func (db *boltDepot) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, erro
 		}
 		chain = append(chain, cert)
 
-		// get ca_key
-		caKey := bucket.Get([]byte("ca_key"))
+		caKey := bucket.Get([]byte(namePrefix + ".key"))
 		if caKey == nil {
-			return fmt.Errorf("no ca_key in bucket")
+			return fmt.Errorf("no %s CA key in bucket", namePrefix)
 		}
 		key, err = x509.ParsePKCS1PrivateKey(caKey)
-		if err != nil {
-			return err
-		}
-		return nil
+		return err
 	})
 	if err != nil {
 		return nil, nil, err
@@ -90,10 +137,36 @@ func (db *boltDepot) CA(pass []byte) ([]*x509.Certificate, *rsa.PrivateKey, erro
 	return chain, key, nil
 }
 
+// PutCA stores crt and key as the CA namePrefix. Storing them under
+// <TYPE>.next stages the successor of CA <TYPE>.
+func (db *boltDepot) PutCA(namePrefix string, crt *x509.Certificate, key *rsa.PrivateKey) error {
+	if crt == nil || key == nil {
+		return errors.New("invalid CA certificate or key")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		if err := bucket.Put([]byte(namePrefix+".crt"), crt.Raw); err != nil {
+			return err
+		}
+		return bucket.Put([]byte(namePrefix+".key"), x509.MarshalPKCS1PrivateKey(key))
+	})
+}
+
+// Put stores crt under <cn>.<serial>. Valid certificates with the same
+// subject are revoked.
 func (db *boltDepot) Put(cn string, crt *x509.Certificate) error {
 	if crt == nil || crt.Raw == nil {
 		return fmt.Errorf("%q does not specify a valid certificate for storage", cn)
 	}
+	db.dbMu.Lock()
+	defer db.dbMu.Unlock()
+	// Revoke old certificate
+	if _, err := db.HasCN(cn, 0, crt, true); err != nil {
+		return err
+	}
 	err := db.Update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(certBucket))
 		if bucket == nil {
@@ -105,6 +178,75 @@ func (db *boltDepot) Put(cn string, crt *x509.Certificate) error {
 	return err
 }
 
+// Certificate returns the stored certificate with the given serial number,
+// revoked or not.
+func (db *boltDepot) Certificate(serial *big.Int) (*x509.Certificate, error) {
+	var der []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		if v := findCert(tx, serial); v != nil {
+			der = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if der == nil {
+		return nil, depots.CertNotFoundErr
+	}
+	return x509.ParseCertificate(der)
+}
+
+// QueryCertificates lists the stored certificates that match q.
+func (db *boltDepot) QueryCertificates(q *depots.CertificateQuery) ([]*depots.CertificateRecord, error) {
+	now := time.Now()
+	var recs []*depots.CertificateRecord
+	err := db.View(func(tx *bolt.Tx) error {
+		revoked := tx.Bucket([]byte(revokedBucket))
+		return tx.Bucket([]byte(certBucket)).ForEach(func(k, v []byte) error {
+			if string(k) == serialKey {
+				return nil
+			}
+			// v points into the mmap, which is only valid during the
+			// transaction
+			crt, err := x509.ParseCertificate(append([]byte(nil), v...))
+			if err != nil {
+				return err
+			}
+			if q.Serial != nil && crt.SerialNumber.Cmp(q.Serial) != 0 {
+				return nil
+			}
+			var rev *depots.Revocation
+			if r := revoked.Get([]byte(serialHex(crt.SerialNumber))); r != nil {
+				var stored revocation
+				if err := json.Unmarshal(r, &stored); err != nil {
+					return err
+				}
+				rev = &depots.Revocation{Serial: crt.SerialNumber, RevokedAt: stored.RevokedAt, Reason: stored.Reason}
+			}
+			if rec := depots.NewCertificateRecord(crt, rev, now); q.Matches(rec) {
+				recs = append(recs, rec)
+			}
+			return nil
+		})
+	})
+	return recs, err
+}
+
+// findCert returns the DER of the certificate with the given serial number,
+// stored under <cn>.<serial>.
+func findCert(tx *bolt.Tx, serial *big.Int) []byte {
+	suffix := "." + serial.String()
+	var der []byte
+	tx.Bucket([]byte(certBucket)).ForEach(func(k, v []byte) error {
+		if der == nil && strings.HasSuffix(string(k), suffix) {
+			der = v
+		}
+		return nil
+	})
+	return der
+}
+
 func (db *boltDepot) Serial() (*big.Int, error) {
 	db.serialMu.Lock()
 	defer db.serialMu.Unlock()
@@ -117,7 +259,7 @@ func (db *boltDepot) Serial() (*big.Int, error) {
 
 func (db *boltDepot) readSerial() (*big.Int, error) {
 	s := big.NewInt(2)
-	if !db.hasKey([]byte("serial")) {
+	if !db.hasKey([]byte(serialKey)) {
 		if err := db.writeSerial(s); err != nil {
 			return nil, err
 		}
@@ -128,9 +270,9 @@ func (db *boltDepot) readSerial() (*big.Int, error) {
 		if bucket == nil {
 			return fmt.Errorf("bucket %q not found!", certBucket)
 		}
-		k := bucket.Get([]byte("serial"))
+		k := bucket.Get([]byte(serialKey))
 		if k == nil {
-			return fmt.Errorf("key %q not found", "serial")
+			return fmt.Errorf("key %q not found", serialKey)
 		}
 		s = s.SetBytes(k)
 		return nil
@@ -144,7 +286,7 @@ func (db *boltDepot) writeSerial(s *big.Int) error {
 		if bucket == nil {
 			return fmt.Errorf("bucket %q not found!", certBucket)
 		}
-		return bucket.Put([]byte("serial"), []byte(s.Bytes()))
+		return bucket.Put([]byte(serialKey), []byte(s.Bytes()))
 	})
 	return err
 }
@@ -170,112 +312,138 @@ func (db *boltDepot) incrementSerial(s *big.Int) error {
 	return db.writeSerial(serial)
 }
 
-func (db *boltDepot) HasCN(cn string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error) {
-	// TODO: implement allowTime
-	// TODO: implement revocation
+// HasCN checks the stored, unrevoked certificates with the subject of cert.
+// Like the file depot it fails if one of them is valid for more than
+// allowTime days, when allowTime is set, and revokes them all if
+// revokeOldCertificate is set.
+func (db *boltDepot) HasCN(_ string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error) {
 	if cert == nil {
 		return false, errors.New("nil certificate provided")
 	}
-	var hasCN bool
-	err := db.View(func(tx *bolt.Tx) error {
-		curs := tx.Bucket([]byte(certBucket)).Cursor()
-		prefix := []byte(cert.Subject.CommonName)
-		for k, v := curs.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = curs.Next() {
-			if bytes.Compare(v, cert.Raw) == 0 {
-				hasCN = true
+	minimalRenewDate := time.Now().AddDate(0, 0, allowTime)
+	err := db.Update(func(tx *bolt.Tx) error {
+		revoked := tx.Bucket([]byte(revokedBucket))
+		var candidates []string
+		err := tx.Bucket([]byte(certBucket)).ForEach(func(k, v []byte) error {
+			if string(k) == serialKey {
+				return nil
+			}
+			crt, err := x509.ParseCertificate(v)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(crt.RawSubject, cert.RawSubject) {
+				return nil
+			}
+			serial := serialHex(crt.SerialNumber)
+			if revoked.Get([]byte(serial)) != nil {
 				return nil
 			}
+			// all non renewable certificates
+			if allowTime > 0 && minimalRenewDate.Before(crt.NotAfter) {
+				return fmt.Errorf("DN %s already exists", crt.Subject)
+			}
+			candidates = append(candidates, serial)
+			return nil
+		})
+		if err != nil || !revokeOldCertificate {
+			return err
+		}
+		now := time.Now().UTC()
+		for _, serial := range candidates {
+			if err := putRevocation(revoked, serial, now, depots.ReasonSuperseded); err != nil {
+				return err
+			}
 		}
-
 		return nil
 	})
-	return hasCN, err
+	return err == nil, err
 }
 
-// func (db *boltDepot) CreateOrLoadKey(bits int) (*rsa.PrivateKey, error) {
-// 	var (
-// 		key *rsa.PrivateKey
-// 		err error
-// 	)
-// 	err = db.View(func(tx *bolt.Tx) error {
-// 		bucket := tx.Bucket([]byte(certBucket))
-// 		if bucket == nil {
-// 			return fmt.Errorf("bucket %q not found!", certBucket)
-// 		}
-// 		priv := bucket.Get([]byte("ca_key"))
-// 		if priv == nil {
-// 			return nil
-// 		}
-// 		key, err = x509.ParsePKCS1PrivateKey(priv)
-// 		return err
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	if key != nil {
-// 		return key, nil
-// 	}
-// 	key, err = rsa.GenerateKey(rand.Reader, bits)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	err = db.Update(func(tx *bolt.Tx) error {
-// 		bucket := tx.Bucket([]byte(certBucket))
-// 		if bucket == nil {
-// 			return fmt.Errorf("bucket %q not found!", certBucket)
-// 		}
-// 		return bucket.Put([]byte("ca_key"), x509.MarshalPKCS1PrivateKey(key))
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return key, nil
-// }
+// CreateOrLoadKey returns the key of the CA namePrefix, generating an RSA
+// key of the given size if there is none.
+func (db *boltDepot) CreateOrLoadKey(namePrefix string, bits int) (*rsa.PrivateKey, error) {
+	var (
+		key *rsa.PrivateKey
+		err error
+	)
+	name := []byte(namePrefix + ".key")
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		priv := bucket.Get(name)
+		if priv == nil {
+			return nil
+		}
+		key, err = x509.ParsePKCS1PrivateKey(priv)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		return key, nil
+	}
+	key, err = rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		return bucket.Put(name, x509.MarshalPKCS1PrivateKey(key))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
 
-// func (db *boltDepot) CreateOrLoadCA(key *rsa.PrivateKey, years int, org, country string) (*x509.Certificate, error) {
-// 	var (
-// 		cert *x509.Certificate
-// 		err  error
-// 	)
-// 	err = db.View(func(tx *bolt.Tx) error {
-// 		bucket := tx.Bucket([]byte(certBucket))
-// 		if bucket == nil {
-// 			return fmt.Errorf("bucket %q not found!", certBucket)
-// 		}
-// 		caCert := bucketGetCopy(bucket, []byte("ca_certificate"))
-// 		if caCert == nil {
-// 			return nil
-// 		}
-// 		cert, err = x509.ParseCertificate(caCert)
-// 		return err
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	if cert != nil {
-// 		return cert, nil
-// 	}
+// CreateOrLoadCA returns the certificate of the CA namePrefix, self-signing
+// one with key if there is none.
+func (db *boltDepot) CreateOrLoadCA(namePrefix string, key *rsa.PrivateKey, years int, org, country string) (*x509.Certificate, error) {
+	var (
+		cert *x509.Certificate
+		err  error
+	)
+	name := []byte(namePrefix + ".crt")
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		caCert := bucketGetCopy(bucket, name)
+		if caCert == nil {
+			return nil
+		}
+		cert, err = x509.ParseCertificate(caCert)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		return cert, nil
+	}
 
-// 	newCert := depot.NewCACert(
-// 		depot.WithYears(years),
-// 		depot.WithOrganization(org),
-// 		depot.WithOrganizationalUnit("MICROMDM SCEP CA"),
-// 		depot.WithCountry(country),
-// 	)
-// 	crtBytes, err := newCert.SelfSign(rand.Reader, &key.PublicKey, key)
-// 	if err != nil {
-// 		return nil, err
-// 	}
+	crtBytes, err := depots.SelfSignedCA(namePrefix, key, years, org, country)
+	if err != nil {
+		return nil, err
+	}
 
-// 	err = db.Update(func(tx *bolt.Tx) error {
-// 		bucket := tx.Bucket([]byte(certBucket))
-// 		if bucket == nil {
-// 			return fmt.Errorf("bucket %q not found!", certBucket)
-// 		}
-// 		return bucket.Put([]byte("ca_certificate"), crtBytes)
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return x509.ParseCertificate(crtBytes)
-// }
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		if bucket == nil {
+			return fmt.Errorf("bucket %q not found!", caBucket)
+		}
+		return bucket.Put(name, crtBytes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(crtBytes)
+}
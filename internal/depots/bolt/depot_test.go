@@ -0,0 +1,203 @@
+package bolt
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"kscep/internal/depots/depottest"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func newTestDepot(t *testing.T) *boltDepot {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "depot.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Failed to open bolt db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	depot, err := NewBoltDepot(db)
+	if err != nil {
+		t.Fatalf("NewBoltDepot() error = %v", err)
+	}
+	return depot
+}
+
+func TestBoltDepot_CA(t *testing.T) {
+	depot := newTestDepot(t)
+
+	// Test the CA is not exist
+	if _, _, err := depot.CA(nil, "RSA"); err == nil {
+		t.Fatalf("CA() did not return an error for a missing CA")
+	}
+
+	key, err := depot.CreateOrLoadKey("RSA", 2048)
+	if err != nil {
+		t.Fatalf("CreateOrLoadKey() error = %v", err)
+	}
+	crt, err := depot.CreateOrLoadCA("RSA", key, 1, "kscep", "CN")
+	if err != nil {
+		t.Fatalf("CreateOrLoadCA() error = %v", err)
+	}
+	if !crt.IsCA || crt.Subject.Organization[0] != "kscep" || crt.Subject.Country[0] != "CN" {
+		t.Fatalf("CreateOrLoadCA() = %+v, want a CA for kscep, CN", crt.Subject)
+	}
+
+	// Test a second call loads the stored key and certificate
+	key2, err := depot.CreateOrLoadKey("RSA", 2048)
+	if err != nil {
+		t.Fatalf("CreateOrLoadKey() error = %v", err)
+	}
+	if !key.Equal(key2) {
+		t.Fatalf("CreateOrLoadKey() generated a new key for an existing CA")
+	}
+	crt2, err := depot.CreateOrLoadCA("RSA", key2, 1, "", "")
+	if err != nil {
+		t.Fatalf("CreateOrLoadCA() error = %v", err)
+	}
+	if !crt.Equal(crt2) {
+		t.Fatalf("CreateOrLoadCA() created a new certificate for an existing CA")
+	}
+
+	certs, caKey, err := depot.CA(nil, "RSA")
+	if err != nil {
+		t.Fatalf("CA() error = %v", err)
+	}
+	if len(certs) != 1 || !certs[0].Equal(crt) {
+		t.Fatalf("CA() returned %d certificates, want the created CA", len(certs))
+	}
+	if k, ok := caKey.(*rsa.PrivateKey); !ok || !k.Equal(key) {
+		t.Fatalf("CA() returned key of type %T, want the created *rsa.PrivateKey", caKey)
+	}
+
+	// Test CA types are stored separately
+	if _, _, err := depot.CA(nil, "ECC"); err == nil {
+		t.Fatalf("CA() returned the RSA CA for ECC")
+	}
+}
+
+func TestBoltDepot_Put(t *testing.T) {
+	depot := newTestDepot(t)
+	cert := depottest.Cert(t, "test", 1, time.Now().Add(365*24*time.Hour))
+
+	if err := depot.Put("test", nil); err == nil {
+		t.Fatalf("Put() did not return an error for a nil certificate")
+	}
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Verify the certificate data
+	err := depot.View(func(tx *bolt.Tx) error {
+		der := tx.Bucket([]byte(certBucket)).Get([]byte("test.1"))
+		if der == nil {
+			t.Fatalf("Put() did not store the certificate under test.1")
+		}
+		parsedCert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+		if parsedCert.Subject.CommonName != "test" {
+			t.Fatalf("Put() stored certificate with CommonName = %v, want test", parsedCert.Subject.CommonName)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltDepot_Serial(t *testing.T) {
+	depot := newTestDepot(t)
+
+	// Test the Serial method when the serial does not exist
+	serial, err := depot.Serial()
+	if err != nil {
+		t.Fatalf("Serial() error = %v", err)
+	}
+	if serial.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("Serial() = %v, want %v", serial, big.NewInt(2))
+	}
+
+	// Test the Serial method when the serial exists
+	serial, err = depot.Serial()
+	if err != nil {
+		t.Fatalf("Serial() error = %v", err)
+	}
+	if serial.Cmp(big.NewInt(3)) != 0 {
+		t.Fatalf("Serial() = %v, want %v", serial, big.NewInt(3))
+	}
+}
+
+func TestBoltDepot_HasCN(t *testing.T) {
+	depot := newTestDepot(t)
+	cert := depottest.Cert(t, "test", 1, time.Now().Add(365*24*time.Hour))
+
+	// Write the certificate to the depot
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Test HasCN with allowTime = 0 and revokeOldCertificate = false
+	exists, err := depot.HasCN("test", 0, cert, false)
+	if err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	if !exists {
+		t.Fatalf("HasCN() = %v, want true", exists)
+	}
+
+	// Test HasCN with a renewal window shorter than the remaining validity
+	if _, err := depot.HasCN("test", 30, cert, false); err == nil {
+		t.Fatalf("HasCN() did not return an error for a certificate outside the renewal window")
+	}
+
+	// Test HasCN with a renewal window covering the remaining validity
+	exists, err = depot.HasCN("test", 400, cert, false)
+	if err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	if !exists {
+		t.Fatalf("HasCN() = %v, want true", exists)
+	}
+
+	// Test HasCN with revokeOldCertificate = true
+	if _, err := depot.HasCN("test", 0, cert, true); err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	revoked, err := depot.Revoked(cert.SerialNumber)
+	if err != nil {
+		t.Fatalf("Revoked() error = %v", err)
+	}
+	if !revoked {
+		t.Fatalf("HasCN() did not revoke the old certificate")
+	}
+
+	// Revoked certificates no longer block a renewal
+	if _, err := depot.HasCN("test", 30, cert, false); err != nil {
+		t.Fatalf("HasCN() error = %v for a revoked certificate", err)
+	}
+
+	// Test Put revokes the previous certificate with the same subject
+	cert2 := depottest.Cert(t, "test2", 2, time.Now().Add(365*24*time.Hour))
+	cert3 := depottest.Cert(t, "test2", 3, time.Now().Add(365*24*time.Hour))
+	if err := depot.Put("test2", cert2); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := depot.Put("test2", cert3); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if revoked, _ := depot.Revoked(cert2.SerialNumber); !revoked {
+		t.Fatalf("Put() did not revoke the superseded certificate")
+	}
+	if revoked, _ := depot.Revoked(cert3.SerialNumber); revoked {
+		t.Fatalf("Put() revoked the new certificate")
+	}
+}
+
+func TestBoltDepot_QueryCertificates(t *testing.T) {
+	depottest.QueryCertificates(t, newTestDepot(t))
+}
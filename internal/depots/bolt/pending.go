@@ -0,0 +1,86 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"kscep/internal/depots"
+	"sort"
+
+	"github.com/boltdb/bolt"
+)
+
+// PutPending stores a pending request keyed by its id, replacing any
+// previous version of it.
+func (db *boltDepot) PutPending(pr *depots.PendingRequest) error {
+	if pr == nil || pr.ID == "" {
+		return errors.New("invalid pending request id")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket([]byte(pendingBucket)), pr.ID, pr)
+	})
+}
+
+// UpdatePending replaces the pending request only while the stored one
+// has the given status, and fails with depots.PendingStatusErr otherwise.
+// The check and the update share one transaction.
+func (db *boltDepot) UpdatePending(pr *depots.PendingRequest, status string) error {
+	if pr == nil || pr.ID == "" {
+		return depots.PendingNotFoundErr
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(pendingBucket))
+		data := b.Get([]byte(pr.ID))
+		if data == nil {
+			return depots.PendingNotFoundErr
+		}
+		var cur depots.PendingRequest
+		if err := json.Unmarshal(data, &cur); err != nil {
+			return err
+		}
+		if cur.Status != status {
+			return depots.PendingStatusErr
+		}
+		return putJSON(b, pr.ID, pr)
+	})
+}
+
+// GetPending loads the pending request with the given id.
+func (db *boltDepot) GetPending(id string) (*depots.PendingRequest, error) {
+	var pr depots.PendingRequest
+	err := db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(pendingBucket)).Get([]byte(id))
+		if data == nil {
+			return depots.PendingNotFoundErr
+		}
+		return json.Unmarshal(data, &pr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// ListPending returns the pending requests with the given status, or all of
+// them if status is empty, oldest first.
+func (db *boltDepot) ListPending(status string) ([]*depots.PendingRequest, error) {
+	var prs []*depots.PendingRequest
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pendingBucket)).ForEach(func(_, v []byte) error {
+			var pr depots.PendingRequest
+			if err := json.Unmarshal(v, &pr); err != nil {
+				return err
+			}
+			if status == "" || pr.Status == status {
+				prs = append(prs, &pr)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].CreatedAt.Before(prs[j].CreatedAt)
+	})
+	return prs, nil
+}
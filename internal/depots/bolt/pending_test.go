@@ -0,0 +1,10 @@
+package bolt
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestBoltDepot_Pending(t *testing.T) {
+	depottest.Pending(t, newTestDepot(t))
+}
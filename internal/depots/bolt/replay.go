@@ -0,0 +1,110 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"kscep/internal/depots"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// PutNonce records nonce until expiresAt. The check and the update share
+// one transaction, so a nonce is accepted once.
+func (db *boltDepot) PutNonce(nonce string, expiresAt time.Time) error {
+	if nonce == "" {
+		return errors.New("invalid sender nonce")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(nonceBucket))
+		if v := b.Get([]byte(nonce)); v != nil {
+			var seen time.Time
+			if err := seen.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			if time.Now().Before(seen) {
+				return depots.NonceReusedErr
+			}
+		}
+		v, err := expiresAt.UTC().MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(nonce), v)
+	})
+}
+
+// PutTransaction records the certificate issued for a transaction,
+// replacing an earlier record of it.
+func (db *boltDepot) PutTransaction(t *depots.Transaction) error {
+	if t == nil || t.ID == "" || t.Serial == nil {
+		return errors.New("invalid transaction")
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket([]byte(transactionBucket)), t.ID, t)
+	})
+}
+
+// GetTransaction loads the transaction with the given id unless it has
+// expired.
+func (db *boltDepot) GetTransaction(id string) (*depots.Transaction, error) {
+	var t depots.Transaction
+	err := db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(transactionBucket)).Get([]byte(id))
+		if data == nil {
+			return depots.TransactionNotFoundErr
+		}
+		return json.Unmarshal(data, &t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(t.ExpiresAt) {
+		return nil, depots.TransactionNotFoundErr
+	}
+	return &t, nil
+}
+
+// PurgeReplay deletes the nonces and transactions expired at t.
+func (db *boltDepot) PurgeReplay(t time.Time) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		var nonces, transactions [][]byte
+		err := tx.Bucket([]byte(nonceBucket)).ForEach(func(k, v []byte) error {
+			var expiresAt time.Time
+			if err := expiresAt.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			if !t.Before(expiresAt) {
+				nonces = append(nonces, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket([]byte(transactionBucket)).ForEach(func(k, v []byte) error {
+			var rec depots.Transaction
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if !t.Before(rec.ExpiresAt) {
+				transactions = append(transactions, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range nonces {
+			if err := tx.Bucket([]byte(nonceBucket)).Delete(k); err != nil {
+				return err
+			}
+		}
+		for _, k := range transactions {
+			if err := tx.Bucket([]byte(transactionBucket)).Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
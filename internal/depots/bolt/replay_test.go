@@ -0,0 +1,14 @@
+package bolt
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestBoltDepot_Nonce(t *testing.T) {
+	depottest.Nonce(t, newTestDepot(t))
+}
+
+func TestBoltDepot_Transaction(t *testing.T) {
+	depottest.Transaction(t, newTestDepot(t))
+}
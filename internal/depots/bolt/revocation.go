@@ -0,0 +1,106 @@
+package bolt
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"kscep/internal/depots"
+	"math/big"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// revocation is the value stored in the revoked bucket, keyed by the
+// serial number in hex.
+type revocation struct {
+	RevokedAt time.Time `json:"revoked_at"`
+	Reason    int       `json:"reason"`
+}
+
+func putRevocation(b *bolt.Bucket, serial string, at time.Time, reason int) error {
+	return putJSON(b, serial, &revocation{RevokedAt: at, Reason: reason})
+}
+
+func serialHex(serial *big.Int) string {
+	s := fmt.Sprintf("%X", serial)
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return s
+}
+
+// Revoked reports whether the certificate with the given serial number has
+// been revoked.
+func (db *boltDepot) Revoked(serial *big.Int) (bool, error) {
+	var revoked bool
+	err := db.View(func(tx *bolt.Tx) error {
+		revoked = tx.Bucket([]byte(revokedBucket)).Get([]byte(serialHex(serial))) != nil
+		return nil
+	})
+	return revoked, err
+}
+
+// Revocation returns the revocation of the certificate with the given serial
+// number, or nil if it is not revoked.
+func (db *boltDepot) Revocation(serial *big.Int) (*depots.Revocation, error) {
+	var rev *depots.Revocation
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(revokedBucket)).Get([]byte(serialHex(serial)))
+		if v == nil {
+			return nil
+		}
+		var r revocation
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		rev = &depots.Revocation{Serial: serial, RevokedAt: r.RevokedAt, Reason: r.Reason}
+		return nil
+	})
+	return rev, err
+}
+
+// Revoke revokes the stored certificate with the given serial number.
+func (db *boltDepot) Revoke(serial *big.Int, reason int) error {
+	db.dbMu.Lock()
+	defer db.dbMu.Unlock()
+	return db.Update(func(tx *bolt.Tx) error {
+		if findCert(tx, serial) == nil {
+			return depots.CertNotFoundErr
+		}
+		revoked := tx.Bucket([]byte(revokedBucket))
+		key := serialHex(serial)
+		if revoked.Get([]byte(key)) != nil {
+			return depots.AlreadyRevokedErr
+		}
+		return putRevocation(revoked, key, time.Now().UTC(), reason)
+	})
+}
+
+// Revocations lists the revoked certificates.
+func (db *boltDepot) Revocations() ([]*depots.Revocation, error) {
+	var revs []*depots.Revocation
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(revokedBucket)).ForEach(func(k, v []byte) error {
+			var r revocation
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			serial, ok := new(big.Int).SetString(string(k), 16)
+			if !ok {
+				return fmt.Errorf("invalid serial %q in bucket %s", k, revokedBucket)
+			}
+			rev := &depots.Revocation{Serial: serial, RevokedAt: r.RevokedAt, Reason: r.Reason}
+			if der := findCert(tx, serial); der != nil {
+				if crt, err := x509.ParseCertificate(der); err == nil {
+					// der points into the mmap, which is only valid
+					// during the transaction
+					rev.Issuer = append([]byte(nil), crt.RawIssuer...)
+				}
+			}
+			revs = append(revs, rev)
+			return nil
+		})
+	})
+	return revs, err
+}
@@ -0,0 +1,10 @@
+package bolt
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestBoltDepot_Revoke(t *testing.T) {
+	depottest.Revocation(t, newTestDepot(t))
+}
@@ -0,0 +1,109 @@
+package bolt
+
+import (
+	"crypto/x509"
+	"fmt"
+	"kscep/internal/depots"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// A CA is rolled over by staging its successor as <TYPE>.next.crt and
+// <TYPE>.next.key, see PutCA. Promotion moves the current CA to
+// <TYPE>.prev.crt/.key and the next CA into its place. A promotion
+// scheduled for later is recorded in <TYPE>.next.at and carried out by the
+// first CA lookup once it is due.
+
+// NextCA returns the certificate staged to replace the CA namePrefix.
+func (db *boltDepot) NextCA(namePrefix string) (*x509.Certificate, error) {
+	if err := db.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	var crt *x509.Certificate
+	err := db.View(func(tx *bolt.Tx) error {
+		der := bucketGetCopy(tx.Bucket([]byte(caBucket)), []byte(namePrefix+".next.crt"))
+		if der == nil {
+			return depots.NextCANotFoundErr
+		}
+		var err error
+		crt, err = x509.ParseCertificate(der)
+		return err
+	})
+	return crt, err
+}
+
+// ScheduleCAPromotion promotes the next CA of namePrefix at the given time,
+// or right away if at is not in the future.
+func (db *boltDepot) ScheduleCAPromotion(namePrefix string, at time.Time) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		for _, ext := range []string{".next.crt", ".next.key"} {
+			if bucket.Get([]byte(namePrefix+ext)) == nil {
+				return depots.NextCANotFoundErr
+			}
+		}
+		if !at.After(time.Now()) {
+			return promote(bucket, namePrefix)
+		}
+		return bucket.Put([]byte(namePrefix+".next.at"), []byte(at.UTC().Format(time.RFC3339)))
+	})
+}
+
+// promoteDue carries out a scheduled promotion of namePrefix once now has
+// reached it.
+func (db *boltDepot) promoteDue(namePrefix string, now time.Time) error {
+	var due bool
+	err := db.View(func(tx *bolt.Tx) error {
+		var err error
+		due, err = promotionDue(tx.Bucket([]byte(caBucket)), namePrefix, now)
+		return err
+	})
+	if err != nil || !due {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(caBucket))
+		// another lookup may have promoted it in the meantime
+		due, err := promotionDue(bucket, namePrefix, now)
+		if err != nil || !due {
+			return err
+		}
+		return promote(bucket, namePrefix)
+	})
+}
+
+func promotionDue(b *bolt.Bucket, namePrefix string, now time.Time) (bool, error) {
+	data := b.Get([]byte(namePrefix + ".next.at"))
+	if data == nil {
+		return false, nil
+	}
+	at, err := time.Parse(time.RFC3339, string(data))
+	if err != nil {
+		return false, fmt.Errorf("invalid promotion time for %s: %w", namePrefix, err)
+	}
+	return !now.Before(at), nil
+}
+
+func promote(b *bolt.Bucket, namePrefix string) error {
+	for _, ext := range []string{".crt", ".key"} {
+		cur := []byte(namePrefix + ext)
+		if v := bucketGetCopy(b, cur); v != nil {
+			if err := b.Put([]byte(namePrefix+".prev"+ext), v); err != nil {
+				return err
+			}
+		}
+		next := []byte(namePrefix + ".next" + ext)
+		v := bucketGetCopy(b, next)
+		if v == nil {
+			return depots.NextCANotFoundErr
+		}
+		if err := b.Put(cur, v); err != nil {
+			return err
+		}
+		if err := b.Delete(next); err != nil {
+			return err
+		}
+	}
+	return b.Delete([]byte(namePrefix + ".next.at"))
+}
@@ -0,0 +1,43 @@
+package depots
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"time"
+
+	"github.com/ploynomail/scep/cryptoutil"
+)
+
+// SelfSignedCA creates the DER encoded certificate of a new CA namePrefix
+// valid for the given number of years. Depots that keep their CA
+// themselves use it to bootstrap one on first start.
+func SelfSignedCA(namePrefix string, key *rsa.PrivateKey, years int, org, country string) ([]byte, error) {
+	id, err := cryptoutil.GenerateSubjectKeyID(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	subject := pkix.Name{
+		CommonName:         "KSCEP " + namePrefix + " CA",
+		OrganizationalUnit: []string{"KSCEP CA"},
+	}
+	if org != "" {
+		subject.Organization = []string{org}
+	}
+	if country != "" {
+		subject.Country = []string{country}
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-600 * time.Second).UTC(),
+		NotAfter:              time.Now().AddDate(years, 0, 0).UTC(),
+		SubjectKeyId:          id,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+}
@@ -0,0 +1,145 @@
+// Package depots holds the records shared by the certificate depot
+// implementations (file, bolt, ...).
+package depots
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	ChallengeNotFoundErr   = errors.New("challenge not found")
+	ChallengeConsumedErr   = errors.New("challenge already consumed")
+	PendingNotFoundErr     = errors.New("pending request not found")
+	PendingStatusErr       = errors.New("pending request status changed")
+	NextCANotFoundErr      = errors.New("no next CA staged")
+	CertNotFoundErr        = errors.New("certificate not found")
+	AlreadyRevokedErr      = errors.New("certificate already revoked")
+	NonceReusedErr         = errors.New("sender nonce already used")
+	TransactionNotFoundErr = errors.New("transaction not found")
+)
+
+// ReasonSuperseded is the CRL reason recorded when storing a certificate
+// revokes the previous one with the same subject.
+const ReasonSuperseded = 4
+
+// Challenge is a one-time enrollment challenge. Only the SHA-256 digest of
+// the challenge password is persisted.
+type Challenge struct {
+	Digest string `json:"digest"`
+	// CommonName, if set, is the only subject CN the challenge may enroll.
+	CommonName string    `json:"common_name,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	ConsumedAt time.Time `json:"consumed_at,omitempty"`
+}
+
+// Consumed reports whether the challenge has already been used.
+func (c *Challenge) Consumed() bool {
+	return !c.ConsumedAt.IsZero()
+}
+
+// Expired reports whether the challenge is past its expiry at t.
+func (c *Challenge) Expired(t time.Time) bool {
+	return !c.ExpiresAt.IsZero() && t.After(c.ExpiresAt)
+}
+
+// PendingRequest is an enrollment parked for manual approval.
+type PendingRequest struct {
+	ID            string `json:"id"`
+	TransactionID string `json:"transaction_id"`
+	Profile       string `json:"profile,omitempty"`
+	CaType        string `json:"ca_type,omitempty"`
+	// CSR is the DER encoded PKCS#10 request.
+	CSR    []byte `json:"csr"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Certificate is the DER encoded certificate issued on approval.
+	Certificate []byte    `json:"certificate,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Transaction is an enrollment transaction remembered so that a repeated
+// transactionID gets the certificate issued for it the first time.
+type Transaction struct {
+	ID string `json:"id"`
+	// Serial is the serial number of the certificate issued for it.
+	Serial    *big.Int  `json:"serial"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Revocation is a revoked certificate as listed on a CRL.
+type Revocation struct {
+	Serial    *big.Int
+	RevokedAt time.Time
+	// Reason is the RFC 5280 CRLReason code.
+	Reason int
+	// Issuer is the raw subject of the CA that issued the certificate.
+	Issuer []byte
+}
+
+// Certificate statuses reported by QueryCertificates.
+const (
+	StatusValid   = "valid"
+	StatusRevoked = "revoked"
+	StatusExpired = "expired"
+)
+
+// CertificateQuery filters the certificates listed by QueryCertificates.
+// Zero fields match every certificate.
+type CertificateQuery struct {
+	CommonName string
+	Serial     *big.Int
+	// Status is StatusValid, StatusRevoked or StatusExpired.
+	Status string
+	// ExpiresBefore matches certificates whose NotAfter is before it.
+	ExpiresBefore time.Time
+	// Issuer is the raw subject of the CA that issued the certificate.
+	Issuer []byte
+}
+
+// CertificateRecord is an issued certificate and its status.
+type CertificateRecord struct {
+	Certificate *x509.Certificate
+	Status      string
+	// RevokedAt and Reason are only set for revoked certificates.
+	RevokedAt time.Time
+	Reason    int
+}
+
+// NewCertificateRecord returns the record of crt at now. rev is the
+// revocation of crt, or nil.
+func NewCertificateRecord(crt *x509.Certificate, rev *Revocation, now time.Time) *CertificateRecord {
+	rec := &CertificateRecord{Certificate: crt, Status: StatusValid}
+	switch {
+	case rev != nil:
+		rec.Status = StatusRevoked
+		rec.RevokedAt = rev.RevokedAt
+		rec.Reason = rev.Reason
+	case now.After(crt.NotAfter):
+		rec.Status = StatusExpired
+	}
+	return rec
+}
+
+// Matches reports whether rec passes the filters of q.
+func (q *CertificateQuery) Matches(rec *CertificateRecord) bool {
+	crt := rec.Certificate
+	switch {
+	case q.CommonName != "" && crt.Subject.CommonName != q.CommonName:
+		return false
+	case q.Serial != nil && crt.SerialNumber.Cmp(q.Serial) != 0:
+		return false
+	case q.Status != "" && rec.Status != q.Status:
+		return false
+	case !q.ExpiresBefore.IsZero() && !crt.NotAfter.Before(q.ExpiresBefore):
+		return false
+	case q.Issuer != nil && !bytes.Equal(crt.RawIssuer, q.Issuer):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,454 @@
+// Package depottest is the conformance suite of the depot implementations.
+// Every depot runs the checks of the records it supports, so that the file,
+// bolt and sql depots behave the same.
+package depottest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"kscep/internal/depots"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// CertificateDepot stores, queries and revokes issued certificates.
+type CertificateDepot interface {
+	Put(name string, crt *x509.Certificate) error
+	QueryCertificates(q *depots.CertificateQuery) ([]*depots.CertificateRecord, error)
+	Revoke(serial *big.Int, reason int) error
+	Revocation(serial *big.Int) (*depots.Revocation, error)
+	Revocations() ([]*depots.Revocation, error)
+}
+
+// CertificateCounter counts issued certificates, checked along with the
+// queries of depots that implement it.
+type CertificateCounter interface {
+	CountCertificates(q *depots.CertificateQuery) (int, error)
+}
+
+type ChallengeDepot interface {
+	PutChallenge(ch *depots.Challenge) error
+	GetChallenge(digest string) (*depots.Challenge, error)
+	ConsumeChallenge(digest string) error
+	ReleaseChallenge(digest string) error
+}
+
+type PendingDepot interface {
+	PutPending(pr *depots.PendingRequest) error
+	GetPending(id string) (*depots.PendingRequest, error)
+	ListPending(status string) ([]*depots.PendingRequest, error)
+	UpdatePending(pr *depots.PendingRequest, status string) error
+}
+
+type RolloverDepot interface {
+	CA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error)
+	CACerts(namePrefix string) ([]*x509.Certificate, error)
+	NextCA(namePrefix string) (*x509.Certificate, error)
+	ScheduleCAPromotion(namePrefix string, at time.Time) error
+}
+
+type ReplayDepot interface {
+	PutNonce(nonce string, expiresAt time.Time) error
+	PutTransaction(tx *depots.Transaction) error
+	GetTransaction(id string) (*depots.Transaction, error)
+	PurgeReplay(t time.Time) error
+}
+
+// Key returns a new RSA key.
+func Key(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	return priv
+}
+
+// Cert returns a self-signed certificate valid from now until notAfter.
+func Cert(t *testing.T, cn string, serial int64, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	crt, _ := newCert(t, cn, serial, notAfter, false)
+	return crt
+}
+
+// CA returns a self-signed CA certificate valid for a year and its key.
+func CA(t *testing.T, cn string) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	return newCert(t, cn, 1, time.Now().Add(365*24*time.Hour), true)
+}
+
+func newCert(t *testing.T, cn string, serial int64, notAfter time.Time, ca bool) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	priv := Key(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if ca {
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.BasicConstraintsValid, template.IsCA = true, true
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, priv
+}
+
+// Challenge checks that a one-time challenge is stored and can only be
+// consumed once, unless it is released again.
+func Challenge(t *testing.T, depot ChallengeDepot) {
+	// Test the challenge is not exist
+	if _, err := depot.GetChallenge("missing"); err != depots.ChallengeNotFoundErr {
+		t.Fatalf("GetChallenge() error = %v, want %v", err, depots.ChallengeNotFoundErr)
+	}
+	if err := depot.ConsumeChallenge("missing"); err != depots.ChallengeNotFoundErr {
+		t.Fatalf("ConsumeChallenge() error = %v, want %v", err, depots.ChallengeNotFoundErr)
+	}
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := depot.PutChallenge(&depots.Challenge{Digest: "abc", ExpiresAt: expires}); err != nil {
+		t.Fatalf("PutChallenge() error = %v", err)
+	}
+	ch, err := depot.GetChallenge("abc")
+	if err != nil {
+		t.Fatalf("GetChallenge() error = %v", err)
+	}
+	if !ch.ExpiresAt.Equal(expires) || ch.Consumed() {
+		t.Fatalf("GetChallenge() = %+v, want unconsumed challenge expiring at %v", ch, expires)
+	}
+
+	// Test the challenge can only be consumed once
+	if err := depot.ConsumeChallenge("abc"); err != nil {
+		t.Fatalf("ConsumeChallenge() error = %v", err)
+	}
+	if err := depot.ConsumeChallenge("abc"); err != depots.ChallengeConsumedErr {
+		t.Fatalf("ConsumeChallenge() error = %v, want %v", err, depots.ChallengeConsumedErr)
+	}
+	ch, err = depot.GetChallenge("abc")
+	if err != nil {
+		t.Fatalf("GetChallenge() error = %v", err)
+	}
+	if !ch.Consumed() {
+		t.Fatalf("GetChallenge() returned unconsumed challenge after ConsumeChallenge()")
+	}
+
+	// Test a released challenge can be consumed again
+	if err := depot.ReleaseChallenge("missing"); err != depots.ChallengeNotFoundErr {
+		t.Fatalf("ReleaseChallenge() error = %v, want %v", err, depots.ChallengeNotFoundErr)
+	}
+	if err := depot.ReleaseChallenge("abc"); err != nil {
+		t.Fatalf("ReleaseChallenge() error = %v", err)
+	}
+	ch, err = depot.GetChallenge("abc")
+	if err != nil {
+		t.Fatalf("GetChallenge() error = %v", err)
+	}
+	if ch.Consumed() || !ch.ExpiresAt.Equal(expires) {
+		t.Fatalf("GetChallenge() = %+v after ReleaseChallenge(), want unconsumed challenge", ch)
+	}
+	if err := depot.ConsumeChallenge("abc"); err != nil {
+		t.Fatalf("ConsumeChallenge() error = %v after ReleaseChallenge()", err)
+	}
+}
+
+// Pending checks storing, updating and listing requests parked for manual
+// approval.
+func Pending(t *testing.T, depot PendingDepot) {
+	// Test the pending request is not exist
+	if _, err := depot.GetPending("missing"); err != depots.PendingNotFoundErr {
+		t.Fatalf("GetPending() error = %v, want %v", err, depots.PendingNotFoundErr)
+	}
+	prs, err := depot.ListPending("")
+	if err != nil || len(prs) != 0 {
+		t.Fatalf("ListPending() = %v, %v, want no requests", prs, err)
+	}
+
+	now := time.Now().UTC()
+	// stored in reverse key order to check the creation order is used
+	for i, id := range []string{"b-first", "a-second"} {
+		err := depot.PutPending(&depots.PendingRequest{
+			ID:            id,
+			TransactionID: "tid-" + id,
+			CSR:           []byte{0x30},
+			Status:        "pending",
+			CreatedAt:     now.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatalf("PutPending() error = %v", err)
+		}
+	}
+
+	pr, err := depot.GetPending("b-first")
+	if err != nil {
+		t.Fatalf("GetPending() error = %v", err)
+	}
+	if pr.TransactionID != "tid-b-first" {
+		t.Fatalf("GetPending() TransactionID = %v, want tid-b-first", pr.TransactionID)
+	}
+
+	// Test updating the status of a request
+	pr.Status = "issued"
+	if err := depot.PutPending(pr); err != nil {
+		t.Fatalf("PutPending() error = %v", err)
+	}
+	prs, err = depot.ListPending("pending")
+	if err != nil {
+		t.Fatalf("ListPending() error = %v", err)
+	}
+	if len(prs) != 1 || prs[0].ID != "a-second" {
+		t.Fatalf("ListPending(pending) = %v, want [a-second]", prs)
+	}
+	prs, err = depot.ListPending("")
+	if err != nil {
+		t.Fatalf("ListPending() error = %v", err)
+	}
+	if len(prs) != 2 || prs[0].ID != "b-first" {
+		t.Fatalf("ListPending() returned %d requests, want 2 ordered by creation", len(prs))
+	}
+
+	// Test a conditional update only applies to the expected status
+	pr, err = depot.GetPending("a-second")
+	if err != nil {
+		t.Fatalf("GetPending() error = %v", err)
+	}
+	pr.Status = "approving"
+	if err := depot.UpdatePending(pr, "issued"); err != depots.PendingStatusErr {
+		t.Fatalf("UpdatePending() error = %v, want %v", err, depots.PendingStatusErr)
+	}
+	if err := depot.UpdatePending(pr, "pending"); err != nil {
+		t.Fatalf("UpdatePending() error = %v", err)
+	}
+	if err := depot.UpdatePending(pr, "pending"); err != depots.PendingStatusErr {
+		t.Fatalf("UpdatePending() error = %v for a second transition, want %v", err, depots.PendingStatusErr)
+	}
+	if pr, err := depot.GetPending("a-second"); err != nil || pr.Status != "approving" {
+		t.Fatalf("GetPending() = %+v, %v, want status approving", pr, err)
+	}
+	missing := &depots.PendingRequest{ID: "missing", Status: "issued"}
+	if err := depot.UpdatePending(missing, "pending"); err != depots.PendingNotFoundErr {
+		t.Fatalf("UpdatePending() error = %v, want %v", err, depots.PendingNotFoundErr)
+	}
+}
+
+// Rollover checks staging a next CA and promoting it once due. putCA
+// stores a CA with the given subject CN under name, promoteDue runs the
+// promotion of namePrefix if it is due at the given time.
+func Rollover(t *testing.T, depot RolloverDepot, putCA func(name, cn string), promoteDue func(namePrefix string, at time.Time) error) {
+	putCA("rollover", "current")
+	if _, err := depot.NextCA("rollover"); err != depots.NextCANotFoundErr {
+		t.Fatalf("NextCA() error = %v, want %v", err, depots.NextCANotFoundErr)
+	}
+	if err := depot.ScheduleCAPromotion("rollover", time.Now()); err != depots.NextCANotFoundErr {
+		t.Fatalf("ScheduleCAPromotion() error = %v, want %v", err, depots.NextCANotFoundErr)
+	}
+
+	putCA("rollover.next", "next")
+	next, err := depot.NextCA("rollover")
+	if err != nil {
+		t.Fatalf("NextCA() error = %v", err)
+	}
+	if next.Subject.CommonName != "next" {
+		t.Fatalf("NextCA() CommonName = %v, want next", next.Subject.CommonName)
+	}
+
+	// a promotion in the future leaves the current CA in place
+	if err := depot.ScheduleCAPromotion("rollover", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ScheduleCAPromotion() error = %v", err)
+	}
+	certs, _, err := depot.CA(nil, "rollover")
+	if err != nil {
+		t.Fatalf("CA() error = %v", err)
+	}
+	if certs[0].Subject.CommonName != "current" {
+		t.Fatalf("CA() CommonName = %v before promotion, want current", certs[0].Subject.CommonName)
+	}
+
+	// once due, the next lookup promotes the next CA
+	if err := promoteDue("rollover", time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("promoteDue() error = %v", err)
+	}
+	certs, _, err = depot.CA(nil, "rollover")
+	if err != nil {
+		t.Fatalf("CA() error = %v", err)
+	}
+	if certs[0].Subject.CommonName != "next" {
+		t.Fatalf("CA() CommonName = %v after promotion, want next", certs[0].Subject.CommonName)
+	}
+	if got, err := depot.CACerts("rollover"); err != nil || !got[0].Equal(certs[0]) {
+		t.Fatalf("CACerts() = %v, %v, want the promoted CA", got, err)
+	}
+	prev, _, err := depot.CA(nil, "rollover.prev")
+	if err != nil {
+		t.Fatalf("CA() error = %v for the previous CA", err)
+	}
+	if prev[0].Subject.CommonName != "current" {
+		t.Fatalf("previous CA CommonName = %v, want current", prev[0].Subject.CommonName)
+	}
+	if _, err := depot.NextCA("rollover"); err != depots.NextCANotFoundErr {
+		t.Fatalf("NextCA() error = %v after promotion, want %v", err, depots.NextCANotFoundErr)
+	}
+}
+
+// Revocation checks revoking an issued certificate and listing the
+// revocations.
+func Revocation(t *testing.T, depot CertificateDepot) {
+	cert := Cert(t, "test", 5, time.Now().Add(365*24*time.Hour))
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	if err := depot.Revoke(big.NewInt(6), 1); err != depots.CertNotFoundErr {
+		t.Fatalf("Revoke() error = %v, want %v", err, depots.CertNotFoundErr)
+	}
+	if rev, err := depot.Revocation(cert.SerialNumber); err != nil || rev != nil {
+		t.Fatalf("Revocation() = %+v, %v, want nil before revoking", rev, err)
+	}
+	if err := depot.Revoke(cert.SerialNumber, 1); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if err := depot.Revoke(cert.SerialNumber, 1); err != depots.AlreadyRevokedErr {
+		t.Fatalf("Revoke() error = %v, want %v", err, depots.AlreadyRevokedErr)
+	}
+	rev, err := depot.Revocation(cert.SerialNumber)
+	if err != nil {
+		t.Fatalf("Revocation() error = %v", err)
+	}
+	if rev == nil || rev.Reason != 1 || rev.RevokedAt.IsZero() {
+		t.Fatalf("Revocation() = %+v, want reason 1", rev)
+	}
+
+	revs, err := depot.Revocations()
+	if err != nil {
+		t.Fatalf("Revocations() error = %v", err)
+	}
+	if len(revs) != 1 {
+		t.Fatalf("Revocations() returned %d entries, want 1", len(revs))
+	}
+	if revs[0].Serial.Cmp(cert.SerialNumber) != 0 || revs[0].Reason != 1 || string(revs[0].Issuer) != string(cert.RawIssuer) {
+		t.Fatalf("Revocations() = %+v, want serial %v with reason 1", revs[0], cert.SerialNumber)
+	}
+}
+
+// QueryCertificates checks the filters of the certificate inventory.
+func QueryCertificates(t *testing.T, depot CertificateDepot) {
+	valid := Cert(t, "valid", 2, time.Now().Add(365*24*time.Hour))
+	revoked := Cert(t, "revoked", 3, time.Now().Add(30*24*time.Hour))
+	expired := Cert(t, "expired", 4, time.Now().Add(-time.Hour))
+	for _, crt := range []*x509.Certificate{valid, revoked, expired} {
+		if err := depot.Put(crt.Subject.CommonName, crt); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := depot.Revoke(revoked.SerialNumber, 1); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query depots.CertificateQuery
+		want  []string
+	}{
+		{"all", depots.CertificateQuery{}, []string{"valid", "revoked", "expired"}},
+		{"cn", depots.CertificateQuery{CommonName: "valid"}, []string{"valid"}},
+		{"serial", depots.CertificateQuery{Serial: big.NewInt(3)}, []string{"revoked"}},
+		{"status valid", depots.CertificateQuery{Status: depots.StatusValid}, []string{"valid"}},
+		{"status revoked", depots.CertificateQuery{Status: depots.StatusRevoked}, []string{"revoked"}},
+		{"status expired", depots.CertificateQuery{Status: depots.StatusExpired}, []string{"expired"}},
+		{"expires before", depots.CertificateQuery{ExpiresBefore: time.Now().Add(60 * 24 * time.Hour)}, []string{"revoked", "expired"}},
+		{"issuer", depots.CertificateQuery{Issuer: valid.RawSubject}, []string{"valid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recs, err := depot.QueryCertificates(&tt.query)
+			if err != nil {
+				t.Fatalf("QueryCertificates() error = %v", err)
+			}
+			got := map[string]bool{}
+			for _, rec := range recs {
+				got[rec.Certificate.Subject.CommonName] = true
+			}
+			if len(recs) != len(tt.want) {
+				t.Fatalf("QueryCertificates() returned %d certificates, want %v", len(recs), tt.want)
+			}
+			for _, cn := range tt.want {
+				if !got[cn] {
+					t.Errorf("QueryCertificates() does not list %q", cn)
+				}
+			}
+			if c, ok := depot.(CertificateCounter); ok {
+				if n, err := c.CountCertificates(&tt.query); err != nil || n != len(tt.want) {
+					t.Errorf("CountCertificates() = %d, %v, want %d", n, err, len(tt.want))
+				}
+			}
+		})
+	}
+
+	recs, err := depot.QueryCertificates(&depots.CertificateQuery{Serial: revoked.SerialNumber})
+	if err != nil || len(recs) != 1 {
+		t.Fatalf("QueryCertificates() = %v, %v", recs, err)
+	}
+	if recs[0].Status != depots.StatusRevoked || recs[0].Reason != 1 || recs[0].RevokedAt.IsZero() {
+		t.Errorf("revoked record = %+v, want status revoked with reason 1", recs[0])
+	}
+}
+
+// Nonce checks that a senderNonce is accepted once until it expires.
+func Nonce(t *testing.T, depot ReplayDepot) {
+	// Test a nonce is accepted once until it expires
+	if err := depot.PutNonce("abc", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PutNonce() error = %v", err)
+	}
+	if err := depot.PutNonce("abc", time.Now().Add(time.Hour)); err != depots.NonceReusedErr {
+		t.Fatalf("PutNonce() error = %v, want %v", err, depots.NonceReusedErr)
+	}
+	if err := depot.PutNonce("expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("PutNonce() error = %v", err)
+	}
+	if err := depot.PutNonce("expired", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("PutNonce() of an expired nonce error = %v", err)
+	}
+}
+
+// Transaction checks that transactions are kept until they expire.
+func Transaction(t *testing.T, depot ReplayDepot) {
+	// Test the transaction is not exist
+	if _, err := depot.GetTransaction("missing"); err != depots.TransactionNotFoundErr {
+		t.Fatalf("GetTransaction() error = %v, want %v", err, depots.TransactionNotFoundErr)
+	}
+
+	if err := depot.PutTransaction(&depots.Transaction{ID: "tid", Serial: big.NewInt(42), ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("PutTransaction() error = %v", err)
+	}
+	tx, err := depot.GetTransaction("tid")
+	if err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if tx.Serial.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("GetTransaction() serial = %v, want 42", tx.Serial)
+	}
+
+	// Test expired records are neither returned nor kept by PurgeReplay
+	if err := depot.PutTransaction(&depots.Transaction{ID: "old", Serial: big.NewInt(1), ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("PutTransaction() error = %v", err)
+	}
+	if _, err := depot.GetTransaction("old"); err != depots.TransactionNotFoundErr {
+		t.Fatalf("GetTransaction() of an expired transaction error = %v, want %v", err, depots.TransactionNotFoundErr)
+	}
+	if err := depot.PurgeReplay(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatalf("PurgeReplay() error = %v", err)
+	}
+	if _, err := depot.GetTransaction("tid"); err != depots.TransactionNotFoundErr {
+		t.Fatalf("GetTransaction() after PurgeReplay() error = %v, want %v", err, depots.TransactionNotFoundErr)
+	}
+}
@@ -0,0 +1,102 @@
+package filedepot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kscep/internal/depots"
+	"os"
+	"time"
+)
+
+const (
+	challengeDir  = "challenges"
+	challengePerm = 0600
+)
+
+// PutChallenge stores a one-time challenge under challenges/<digest>.json.
+// An existing challenge with the same digest is replaced.
+func (d *fileDepot) PutChallenge(ch *depots.Challenge) error {
+	if ch == nil || !validRecordID(ch.Digest) {
+		return errors.New("invalid challenge digest")
+	}
+	d.challengeMu.Lock()
+	defer d.challengeMu.Unlock()
+	return d.writeChallenge(ch)
+}
+
+// GetChallenge loads the challenge stored for digest.
+func (d *fileDepot) GetChallenge(digest string) (*depots.Challenge, error) {
+	d.challengeMu.Lock()
+	defer d.challengeMu.Unlock()
+	return d.readChallenge(digest)
+}
+
+// ConsumeChallenge marks the challenge as used. It fails with
+// depots.ChallengeConsumedErr if the challenge was consumed before, so that
+// concurrent enrollments cannot share a single challenge.
+func (d *fileDepot) ConsumeChallenge(digest string) error {
+	d.challengeMu.Lock()
+	defer d.challengeMu.Unlock()
+	ch, err := d.readChallenge(digest)
+	if err != nil {
+		return err
+	}
+	if ch.Consumed() {
+		return depots.ChallengeConsumedErr
+	}
+	ch.ConsumedAt = time.Now().UTC()
+	return d.writeChallenge(ch)
+}
+
+// ReleaseChallenge makes a consumed challenge usable again, for an
+// enrollment that failed after consuming it.
+func (d *fileDepot) ReleaseChallenge(digest string) error {
+	d.challengeMu.Lock()
+	defer d.challengeMu.Unlock()
+	ch, err := d.readChallenge(digest)
+	if err != nil {
+		return err
+	}
+	ch.ConsumedAt = time.Time{}
+	return d.writeChallenge(ch)
+}
+
+func (d *fileDepot) readChallenge(digest string) (*depots.Challenge, error) {
+	if !validRecordID(digest) {
+		return nil, depots.ChallengeNotFoundErr
+	}
+	data, err := os.ReadFile(d.challengePath(digest))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, depots.ChallengeNotFoundErr
+		}
+		return nil, err
+	}
+	var ch depots.Challenge
+	if err := json.Unmarshal(data, &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (d *fileDepot) writeChallenge(ch *depots.Challenge) error {
+	if err := os.MkdirAll(d.path(challengeDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ch)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so a crash never leaves a truncated record
+	name := d.challengePath(ch.Digest)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, challengePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (d *fileDepot) challengePath(digest string) string {
+	return d.path(fmt.Sprintf("%s/%s.json", challengeDir, digest))
+}
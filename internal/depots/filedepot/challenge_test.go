@@ -0,0 +1,14 @@
+package filedepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestFileDepot_Challenge(t *testing.T) {
+	depot, err := NewFileDepot(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	depottest.Challenge(t, depot)
+}
@@ -3,13 +3,13 @@ package filedepot
 import (
 	"bufio"
 	"bytes"
-	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/big"
 	"os"
 	"path/filepath"
@@ -17,6 +17,11 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"kscep/internal/depots"
+	"kscep/internal/keyprovider"
+
+	"github.com/emmansun/gmsm/smx509"
 )
 
 // file permissions
@@ -27,9 +32,13 @@ const (
 )
 
 type fileDepot struct {
-	dirPath  string
-	serialMu sync.Mutex
-	dbMu     sync.Mutex
+	dirPath     string
+	serialMu    sync.Mutex
+	dbMu        sync.Mutex
+	challengeMu sync.Mutex
+	pendingMu   sync.Mutex
+	// rolloverMu guards promoting a staged next CA to current.
+	rolloverMu sync.Mutex
 }
 
 // NewFileDepot returns a new cert depot.
@@ -44,6 +53,18 @@ func NewFileDepot(path string) (*fileDepot, error) {
 }
 
 func (d *fileDepot) CA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	return d.loadCA(pass, namePrefix)
+}
+
+func (d *fileDepot) loadCA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error) {
+	unlock, err := d.lockCA(namePrefix, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
 	caPEM, err := d.getFile(fmt.Sprintf("%s.pem", namePrefix))
 	if err != nil {
 		return nil, nil, err
@@ -53,6 +74,10 @@ func (d *fileDepot) CA(pass []byte, namePrefix string) ([]*x509.Certificate, int
 		return nil, nil, err
 	}
 	keyPEM, err := d.getFile(fmt.Sprintf("%s.key", namePrefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		// the key is held by a key provider, see keyprovider.Provider
+		return []*x509.Certificate{cert}, nil, nil
+	}
 	if err != nil {
 		return nil, nil, err
 	}
@@ -63,6 +88,28 @@ func (d *fileDepot) CA(pass []byte, namePrefix string) ([]*x509.Certificate, int
 	return []*x509.Certificate{cert}, key, nil
 }
 
+// CACerts returns the certificate of the CA namePrefix like CA, without
+// reading its key.
+func (d *fileDepot) CACerts(namePrefix string) ([]*x509.Certificate, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	unlock, err := d.lockCA(namePrefix, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	caPEM, err := d.getFile(fmt.Sprintf("%s.pem", namePrefix))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := loadCert(caPEM.Data)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
 // Put adds a certificate to the depot
 func (d *fileDepot) Put(cn string, crt *x509.Certificate) error {
 	if crt == nil {
@@ -105,6 +152,75 @@ func (d *fileDepot) Put(cn string, crt *x509.Certificate) error {
 	return nil
 }
 
+// Certificate returns the stored certificate with the given serial number,
+// revoked or not.
+func (d *fileDepot) Certificate(serial *big.Int) (*x509.Certificate, error) {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	data, err := os.ReadFile(d.path("index.txt"))
+	if err != nil {
+		return nil, err
+	}
+	want := formatSerial(serial)
+	for _, line := range strings.Split(string(data), "\n") {
+		entries := strings.Split(line, "\t")
+		if len(entries) < 6 || strings.ToUpper(entries[3]) != want {
+			continue
+		}
+		crtPEM, err := d.getFile(entries[4])
+		if err != nil {
+			return nil, err
+		}
+		return loadCert(crtPEM.Data)
+	}
+	return nil, depots.CertNotFoundErr
+}
+
+// QueryCertificates lists the certificates of index.txt that match q.
+func (d *fileDepot) QueryCertificates(q *depots.CertificateQuery) ([]*depots.CertificateRecord, error) {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	data, err := os.ReadFile(d.path("index.txt"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var recs []*depots.CertificateRecord
+	for _, line := range strings.Split(string(data), "\n") {
+		entries := strings.Split(line, "\t")
+		if len(entries) < 6 {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(entries[3], 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %q in index.txt", entries[3])
+		}
+		if q.Serial != nil && serial.Cmp(q.Serial) != 0 {
+			continue
+		}
+		crtPEM, err := d.getFile(entries[4])
+		if err != nil {
+			return nil, err
+		}
+		crt, err := loadCert(crtPEM.Data)
+		if err != nil {
+			return nil, err
+		}
+		var rev *depots.Revocation
+		if entries[0] == "R" {
+			revokedAt, reason, err := parseRevocationField(entries[2])
+			if err != nil {
+				return nil, err
+			}
+			rev = &depots.Revocation{Serial: serial, RevokedAt: revokedAt, Reason: reason}
+		}
+		if rec := depots.NewCertificateRecord(crt, rev, now); q.Matches(rec) {
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
 // Serial retrieves the current serial number from the file depot, increments it,
 // and then returns the updated serial number. If the serial file does not exist,
 // it initializes the serial number to 2 and creates the file. The serial number
@@ -269,14 +385,13 @@ func (d *fileDepot) HasCN(_ string, allowTime int, cert *x509.Certificate, revok
 		}
 	}
 	file.Close()
-	for key, value := range candidates {
+	for _, value := range candidates {
 		if value == "no" {
 			return false, errors.New("DN " + dn + " already exists")
 		}
 		if revokeOldCertificate {
-			fmt.Println("Revoking certificate with serial " + key + " from DB. Recreation of CRL needed.")
 			entries := strings.Split(value, "\t")
-			addDB.WriteString("R\t" + entries[1] + "\t" + makeOpenSSLTime(time.Now()) + "\t" + strings.ToUpper(entries[3]) + "\t" + entries[4] + "\t" + entries[5] + "\n")
+			addDB.WriteString("R\t" + entries[1] + "\t" + revocationField(time.Now(), depots.ReasonSuperseded) + "\t" + strings.ToUpper(entries[3]) + "\t" + entries[4] + "\t" + entries[5] + "\n")
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -435,45 +550,12 @@ func (d *fileDepot) path(name string) string {
 	return filepath.Join(d.dirPath, name)
 }
 
-const (
-	rsaPrivateKeyPEMBlockType   = "RSA PRIVATE KEY"
-	pkcs8PrivateKeyPEMBlockType = "PRIVATE KEY"
-	certificatePEMBlockType     = "CERTIFICATE"
-)
+const certificatePEMBlockType = "CERTIFICATE"
 
-// load an encrypted private key from disk
-func loadKey(data []byte, password []byte) (*rsa.PrivateKey, error) {
-	pemBlock, _ := pem.Decode(data)
-	if pemBlock == nil {
-		return nil, errors.New("PEM decode failed")
-	}
-	switch pemBlock.Type {
-	case rsaPrivateKeyPEMBlockType:
-		if x509.IsEncryptedPEMBlock(pemBlock) {
-			b, err := x509.DecryptPEMBlock(pemBlock, password)
-			if err != nil {
-				return nil, err
-			}
-			return x509.ParsePKCS1PrivateKey(b)
-		}
-		return x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
-	case pkcs8PrivateKeyPEMBlockType:
-		priv, err := x509.ParsePKCS8PrivateKey(pemBlock.Bytes)
-		if err != nil {
-			return nil, err
-		}
-		switch priv := priv.(type) {
-		case *rsa.PrivateKey:
-			return priv, nil
-		// case *dsa.PublicKey:
-		// case *ecdsa.PublicKey:
-		// case ed25519.PublicKey:
-		default:
-			panic("unsupported type of public key. SCEP need RSA private key")
-		}
-	default:
-		return nil, errors.New("unmatched type or headers")
-	}
+// load a private key from disk, see keyprovider.ParsePrivateKeyPEM for the
+// supported formats.
+func loadKey(data []byte, password []byte) (interface{}, error) {
+	return keyprovider.ParsePrivateKeyPEM(data, password)
 }
 
 // loadCert decodes a PEM encoded certificate from the provided byte slice and
@@ -506,7 +588,12 @@ func loadCert(data []byte) (*x509.Certificate, error) {
 		return nil, errors.New("unmatched type or headers")
 	}
 
-	return x509.ParseCertificate(pemBlock.Bytes)
+	// the SM2 CA and the certificates it issues are beyond crypto/x509
+	crt, err := smx509.ParseCertificate(pemBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return crt.ToX509(), nil
 }
 
 // pemCert converts DER-encoded bytes to PEM-encoded bytes.
@@ -1,18 +1,26 @@
 package filedepot
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
 	"fmt"
+	"kscep/internal/depots/depottest"
 	"math/big"
 	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/emmansun/gmsm/pkcs8"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
 	"github.com/magiconair/properties/assert"
 )
 
@@ -312,3 +320,94 @@ func TestFileDepot_HasCN(t *testing.T) {
 	}
 	os.Remove(dir + "/test2.1.pem")
 }
+
+func TestFileDepot_QueryCertificates(t *testing.T) {
+	depot, err := NewFileDepot(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	depottest.QueryCertificates(t, depot)
+}
+
+func TestLoadKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm2Key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pass := []byte("secret")
+
+	encode := func(typ string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	}
+	pkcs8PEM := func(key interface{}, password []byte) []byte {
+		t.Helper()
+		der, err := pkcs8.MarshalPrivateKey(key, password, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password != nil {
+			return encode("ENCRYPTED PRIVATE KEY", der)
+		}
+		return encode("PRIVATE KEY", der)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm2DER, err := smx509.MarshalSM2PrivateKey(sm2Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the legacy encrypted PEM written by openssl ec -aes256
+	encEC, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", ecDER, pass, x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		password []byte
+		want     interface{ Equal(crypto.PrivateKey) bool }
+	}{
+		{"PKCS#1 RSA", encode("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)), nil, rsaKey},
+		{"SEC 1 EC", encode("EC PRIVATE KEY", ecDER), nil, ecKey},
+		{"encrypted SEC 1 EC", pem.EncodeToMemory(encEC), pass, ecKey},
+		{"SEC 1 SM2", encode("EC PRIVATE KEY", sm2DER), nil, sm2Key},
+		{"PKCS#8 RSA", pkcs8PEM(rsaKey, nil), nil, rsaKey},
+		{"PKCS#8 EC", pkcs8PEM(ecKey, nil), nil, ecKey},
+		{"PKCS#8 Ed25519", pkcs8PEM(edKey, nil), nil, edKey},
+		{"PBES2 RSA", pkcs8PEM(rsaKey, pass), pass, rsaKey},
+		{"PBES2 EC", pkcs8PEM(ecKey, pass), pass, ecKey},
+		{"PBES2 SM2", pkcs8PEM(sm2Key, pass), pass, sm2Key},
+	} {
+		key, err := loadKey(tt.data, tt.password)
+		if err != nil {
+			t.Errorf("%s: loadKey() error = %v", tt.name, err)
+			continue
+		}
+		if !tt.want.Equal(key) {
+			t.Errorf("%s: loadKey() returned a different key of type %T", tt.name, key)
+		}
+	}
+
+	encrypted := pkcs8PEM(ecKey, pass)
+	if _, err := loadKey(encrypted, []byte("wrong")); err == nil {
+		t.Error("loadKey() accepted a wrong passphrase")
+	}
+	if _, err := loadKey(encrypted, nil); err == nil {
+		t.Error("loadKey() accepted an encrypted key without a passphrase")
+	}
+}
@@ -0,0 +1,27 @@
+package filedepot
+
+import "os"
+
+// lockCA takes the lock file <namePrefix>.lock, which serialises CA loads
+// and promotions of namePrefix across processes, as kscepctl ca promote runs
+// outside the server. Loads take it shared, promotions exclusive.
+//
+// A depot the lock file cannot be created in is read-only, nothing can be
+// promoted in it and loads go ahead without the lock.
+func (d *fileDepot) lockCA(namePrefix string, exclusive bool) (unlock func(), err error) {
+	f, err := os.OpenFile(d.path(namePrefix+".lock"), os.O_RDWR|os.O_CREATE, dbPerm)
+	if err != nil {
+		if !exclusive {
+			return func() {}, nil
+		}
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,134 @@
+package filedepot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kscep/internal/depots"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	pendingDir  = "pending"
+	pendingPerm = 0600
+)
+
+// PutPending stores a pending request under pending/<id>.json, replacing
+// any previous version of it.
+func (d *fileDepot) PutPending(pr *depots.PendingRequest) error {
+	if pr == nil || !validRecordID(pr.ID) {
+		return errors.New("invalid pending request id")
+	}
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	return d.writePending(pr)
+}
+
+// UpdatePending replaces the pending request only while the stored one
+// has the given status, and fails with depots.PendingStatusErr otherwise.
+func (d *fileDepot) UpdatePending(pr *depots.PendingRequest, status string) error {
+	if pr == nil || !validRecordID(pr.ID) {
+		return depots.PendingNotFoundErr
+	}
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	cur, err := d.readPending(d.pendingPath(pr.ID))
+	if err != nil {
+		return err
+	}
+	if cur.Status != status {
+		return depots.PendingStatusErr
+	}
+	return d.writePending(pr)
+}
+
+func (d *fileDepot) writePending(pr *depots.PendingRequest) error {
+	if err := os.MkdirAll(d.path(pendingDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+	name := d.pendingPath(pr.ID)
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, pendingPerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// GetPending loads the pending request with the given id.
+func (d *fileDepot) GetPending(id string) (*depots.PendingRequest, error) {
+	if !validRecordID(id) {
+		return nil, depots.PendingNotFoundErr
+	}
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	return d.readPending(d.pendingPath(id))
+}
+
+// ListPending returns the pending requests with the given status, or all of
+// them if status is empty, oldest first.
+func (d *fileDepot) ListPending(status string) ([]*depots.PendingRequest, error) {
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+	entries, err := os.ReadDir(d.path(pendingDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var prs []*depots.PendingRequest
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		pr, err := d.readPending(d.path(pendingDir + "/" + e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if status == "" || pr.Status == status {
+			prs = append(prs, pr)
+		}
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].CreatedAt.Before(prs[j].CreatedAt)
+	})
+	return prs, nil
+}
+
+func (d *fileDepot) readPending(name string) (*depots.PendingRequest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, depots.PendingNotFoundErr
+		}
+		return nil, err
+	}
+	var pr depots.PendingRequest
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+func (d *fileDepot) pendingPath(id string) string {
+	return d.path(fmt.Sprintf("%s/%s.json", pendingDir, id))
+}
+
+// validRecordID reports whether id is safe to use as a file name.
+func validRecordID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,20 @@
+package filedepot
+
+import (
+	"kscep/internal/depots"
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestFileDepot_Pending(t *testing.T) {
+	depot, err := NewFileDepot(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	depottest.Pending(t, depot)
+
+	// Test the ID cannot leave the pending directory
+	if _, err := depot.GetPending("../serial"); err != depots.PendingNotFoundErr {
+		t.Fatalf("GetPending() error = %v for path traversal, want %v", err, depots.PendingNotFoundErr)
+	}
+}
@@ -0,0 +1,155 @@
+package filedepot
+
+import (
+	"fmt"
+	"kscep/internal/depots"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+// reasonNames are the CRL reasons as OpenSSL writes them to index.txt,
+// indexed by their RFC 5280 code.
+var reasonNames = []string{
+	"unspecified",
+	"keyCompromise",
+	"CACompromise",
+	"affiliationChanged",
+	"superseded",
+	"cessationOfOperation",
+	"certificateHold",
+	"",
+	"removeFromCRL",
+	"privilegeWithdrawn",
+	"aACompromise",
+}
+
+// revocationField formats the REVOCATIONDATE column of index.txt, e.g.
+// 240102150405Z,keyCompromise.
+func revocationField(t time.Time, reason int) string {
+	field := makeOpenSSLTime(t.UTC())
+	if reason > 0 && reason < len(reasonNames) && reasonNames[reason] != "" {
+		field += "," + reasonNames[reason]
+	}
+	return field
+}
+
+func parseRevocationField(field string) (time.Time, int, error) {
+	date, name, _ := strings.Cut(field, ",")
+	t, err := time.Parse("060102150405Z", date)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid revocation date %q", date)
+	}
+	for code, n := range reasonNames {
+		if n != "" && n == name {
+			return t, code, nil
+		}
+	}
+	return t, 0, nil
+}
+
+func formatSerial(serial *big.Int) string {
+	s := fmt.Sprintf("%X", serial)
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return s
+}
+
+// Revoke marks the certificate with the given serial number revoked in
+// index.txt.
+func (d *fileDepot) Revoke(serial *big.Int, reason int) error {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	name := d.path("index.txt")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	want := formatSerial(serial)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		entries := strings.Split(line, "\t")
+		if len(entries) < 6 || strings.ToUpper(entries[3]) != want {
+			continue
+		}
+		if entries[0] == "R" {
+			return depots.AlreadyRevokedErr
+		}
+		if entries[0] != "V" {
+			continue
+		}
+		entries[0] = "R"
+		entries[2] = revocationField(time.Now(), reason)
+		lines[i] = strings.Join(entries, "\t")
+		found = true
+	}
+	if !found {
+		return depots.CertNotFoundErr
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), dbPerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// Revocation returns the revocation of the certificate with the given serial
+// number, or nil if it is not revoked.
+func (d *fileDepot) Revocation(serial *big.Int) (*depots.Revocation, error) {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	data, err := os.ReadFile(d.path("index.txt"))
+	if err != nil {
+		return nil, err
+	}
+	want := formatSerial(serial)
+	for _, line := range strings.Split(string(data), "\n") {
+		entries := strings.Split(line, "\t")
+		if len(entries) < 6 || entries[0] != "R" || strings.ToUpper(entries[3]) != want {
+			continue
+		}
+		revokedAt, reason, err := parseRevocationField(entries[2])
+		if err != nil {
+			return nil, err
+		}
+		return &depots.Revocation{Serial: serial, RevokedAt: revokedAt, Reason: reason}, nil
+	}
+	return nil, nil
+}
+
+// Revocations lists the revoked certificates of index.txt.
+func (d *fileDepot) Revocations() ([]*depots.Revocation, error) {
+	d.dbMu.Lock()
+	defer d.dbMu.Unlock()
+	data, err := os.ReadFile(d.path("index.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var revs []*depots.Revocation
+	for _, line := range strings.Split(string(data), "\n") {
+		entries := strings.Split(line, "\t")
+		if len(entries) < 6 || entries[0] != "R" {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(entries[3], 16)
+		if !ok {
+			return nil, fmt.Errorf("invalid serial %q in index.txt", entries[3])
+		}
+		revokedAt, reason, err := parseRevocationField(entries[2])
+		if err != nil {
+			return nil, err
+		}
+		rev := &depots.Revocation{Serial: serial, RevokedAt: revokedAt, Reason: reason}
+		// the issuer is only known from the stored certificate
+		if crtPEM, err := d.getFile(entries[4]); err == nil {
+			if crt, err := loadCert(crtPEM.Data); err == nil {
+				rev.Issuer = crt.RawIssuer
+			}
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
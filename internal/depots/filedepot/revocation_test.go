@@ -0,0 +1,30 @@
+package filedepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+	"time"
+)
+
+func TestRevocationField(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	field := revocationField(at, 1)
+	if field != "240102150405Z,keyCompromise" {
+		t.Fatalf("revocationField() = %q", field)
+	}
+	got, reason, err := parseRevocationField(field)
+	if err != nil || !got.Equal(at) || reason != 1 {
+		t.Fatalf("parseRevocationField() = %v, %d, %v", got, reason, err)
+	}
+	if _, reason, _ := parseRevocationField("240102150405Z"); reason != 0 {
+		t.Fatalf("parseRevocationField() reason = %d without a reason, want 0", reason)
+	}
+}
+
+func TestFileDepot_Revoke(t *testing.T) {
+	depot, err := NewFileDepot(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	depottest.Revocation(t, depot)
+}
@@ -0,0 +1,106 @@
+package filedepot
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"kscep/internal/depots"
+	"os"
+	"strings"
+	"time"
+)
+
+// A CA is rolled over by staging its successor next to it as
+// <TYPE>.next.pem and <TYPE>.next.key. Promotion moves the current CA to
+// <TYPE>.prev.pem/.key and the next CA into its place. A promotion
+// scheduled for later is recorded in <TYPE>.next.at and carried out by the
+// first CA lookup once it is due.
+//
+// The renames of a promotion and the reads of a CA load are not atomic on
+// their own, so both hold the lock file of the CA, see lockCA.
+
+// NextCA returns the certificate staged to replace the CA namePrefix.
+func (d *fileDepot) NextCA(namePrefix string) (*x509.Certificate, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	caPEM, err := d.getFile(namePrefix + ".next.pem")
+	if os.IsNotExist(err) {
+		return nil, depots.NextCANotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return loadCert(caPEM.Data)
+}
+
+// ScheduleCAPromotion promotes the next CA of namePrefix at the given time,
+// or right away if at is not in the future.
+func (d *fileDepot) ScheduleCAPromotion(namePrefix string, at time.Time) error {
+	d.rolloverMu.Lock()
+	defer d.rolloverMu.Unlock()
+	for _, ext := range []string{".next.pem", ".next.key"} {
+		if err := d.check(namePrefix + ext); err != nil {
+			if os.IsNotExist(err) {
+				return depots.NextCANotFoundErr
+			}
+			return err
+		}
+	}
+	if !at.After(time.Now()) {
+		return d.promote(namePrefix)
+	}
+	return os.WriteFile(d.path(namePrefix+".next.at"), []byte(at.UTC().Format(time.RFC3339)+"\n"), dbPerm)
+}
+
+func (d *fileDepot) readPromotion(namePrefix string) (time.Time, error) {
+	data, err := os.ReadFile(d.path(namePrefix + ".next.at"))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	at, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid promotion time for %s: %w", namePrefix, err)
+	}
+	return at, nil
+}
+
+// promoteDue carries out a scheduled promotion of namePrefix once now has
+// reached it.
+func (d *fileDepot) promoteDue(namePrefix string, now time.Time) error {
+	d.rolloverMu.Lock()
+	defer d.rolloverMu.Unlock()
+	at, err := d.readPromotion(namePrefix)
+	if err != nil || at.IsZero() || now.Before(at) {
+		return err
+	}
+	return d.promote(namePrefix)
+}
+
+func (d *fileDepot) promote(namePrefix string) error {
+	unlock, err := d.lockCA(namePrefix, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// another process may have promoted the next CA meanwhile
+	if err := d.check(namePrefix + ".next.pem"); os.IsNotExist(err) {
+		return nil
+	}
+	for _, ext := range []string{".pem", ".key"} {
+		cur := d.path(namePrefix + ext)
+		if err := os.Rename(cur, d.path(namePrefix+".prev"+ext)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Rename(d.path(namePrefix+".next"+ext), cur); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(d.path(namePrefix + ".next.at")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package filedepot
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kscep/internal/depots"
+	"kscep/internal/depots/depottest"
+)
+
+func TestFileDepot_Rollover(t *testing.T) {
+	dir := t.TempDir()
+	depot, err := NewFileDepot(dir)
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	putCA := func(name, cn string) {
+		crt, priv := depottest.CA(t, cn)
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+		if err := os.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0644); err != nil {
+			t.Fatalf("Failed to write cert file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+	}
+	depottest.Rollover(t, depot, putCA, depot.promoteDue)
+
+	if err := depot.check("rollover.next.at"); !os.IsNotExist(err) {
+		t.Fatalf("promotion schedule left behind after promotion")
+	}
+}
+
+func TestFileDepot_PromotionAcrossProcesses(t *testing.T) {
+	// two depots on one directory stand in for the server and kscepctl
+	dir := t.TempDir()
+	server, err := NewFileDepot(dir)
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	ctl, err := NewFileDepot(dir)
+	if err != nil {
+		t.Fatalf("NewFileDepot() error = %v", err)
+	}
+	for _, ca := range []struct{ name, cn string }{{"rollover", "current"}, {"rollover.next", "next"}} {
+		crt, priv := depottest.CA(t, ca.cn)
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+		if err := os.WriteFile(filepath.Join(dir, ca.name+".pem"), certPEM, 0644); err != nil {
+			t.Fatalf("Failed to write cert file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ca.name+".key"), keyPEM, 0600); err != nil {
+			t.Fatalf("Failed to write key file: %v", err)
+		}
+	}
+
+	errs := make(chan error, 3)
+	go func() { errs <- ctl.ScheduleCAPromotion("rollover", time.Now()) }()
+	go func() { errs <- server.ScheduleCAPromotion("rollover", time.Now()) }()
+	go func() {
+		// every load sees the certificate with its own key
+		for i := 0; i < 50; i++ {
+			certs, key, err := server.loadCA(nil, "rollover")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !key.(*rsa.PrivateKey).PublicKey.Equal(certs[0].PublicKey) {
+				errs <- fmt.Errorf("loaded %s with the key of another CA", certs[0].Subject.CommonName)
+				return
+			}
+		}
+		errs <- nil
+	}()
+	for i := 0; i < 3; i++ {
+		// the promotion losing the race finds no next CA to promote
+		if err := <-errs; err != nil && err != depots.NextCANotFoundErr {
+			t.Fatalf("concurrent promotion error = %v", err)
+		}
+	}
+
+	certs, _, err := server.CA(nil, "rollover")
+	if err != nil {
+		t.Fatalf("CA() error = %v", err)
+	}
+	if certs[0].Subject.CommonName != "next" {
+		t.Fatalf("CA() CommonName = %v after promotion, want next", certs[0].Subject.CommonName)
+	}
+	prev, _, err := server.CA(nil, "rollover.prev")
+	if err != nil {
+		t.Fatalf("CA() error = %v for the previous CA", err)
+	}
+	if prev[0].Subject.CommonName != "current" {
+		t.Fatalf("previous CA CommonName = %v, want current", prev[0].Subject.CommonName)
+	}
+}
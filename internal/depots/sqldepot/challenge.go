@@ -0,0 +1,73 @@
+package sqldepot
+
+import (
+	"database/sql"
+	"errors"
+	"kscep/internal/depots"
+	"time"
+)
+
+// PutChallenge stores a one-time challenge keyed by its digest. An existing
+// challenge with the same digest is replaced.
+func (d *sqlDepot) PutChallenge(ch *depots.Challenge) error {
+	if ch == nil || ch.Digest == "" {
+		return errors.New("invalid challenge digest")
+	}
+	_, err := d.db.Exec(d.rebind(`INSERT INTO challenges (digest, common_name, created_at, expires_at, consumed_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (digest) DO UPDATE SET common_name = excluded.common_name, created_at = excluded.created_at,
+expires_at = excluded.expires_at, consumed_at = excluded.consumed_at`),
+		ch.Digest, ch.CommonName, ch.CreatedAt.UTC(), nullTime(ch.ExpiresAt), nullTime(ch.ConsumedAt))
+	return err
+}
+
+// GetChallenge loads the challenge stored for digest.
+func (d *sqlDepot) GetChallenge(digest string) (*depots.Challenge, error) {
+	var expiresAt, consumedAt sql.NullTime
+	ch := depots.Challenge{Digest: digest}
+	err := d.db.QueryRow(d.rebind(`SELECT common_name, created_at, expires_at, consumed_at FROM challenges WHERE digest = ?`), digest).
+		Scan(&ch.CommonName, &ch.CreatedAt, &expiresAt, &consumedAt)
+	if err == sql.ErrNoRows {
+		return nil, depots.ChallengeNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch.ExpiresAt, ch.ConsumedAt = expiresAt.Time, consumedAt.Time
+	return &ch, nil
+}
+
+// ConsumeChallenge marks the challenge as used. The update only matches an
+// unconsumed challenge, so concurrent enrollments, even on different
+// replicas, cannot share a single challenge.
+func (d *sqlDepot) ConsumeChallenge(digest string) error {
+	res, err := d.db.Exec(d.rebind(`UPDATE challenges SET consumed_at = ? WHERE digest = ? AND consumed_at IS NULL`), time.Now().UTC(), digest)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	if _, err := d.GetChallenge(digest); err != nil {
+		return err
+	}
+	return depots.ChallengeConsumedErr
+}
+
+// ReleaseChallenge makes a consumed challenge usable again, for an
+// enrollment that failed after consuming it.
+func (d *sqlDepot) ReleaseChallenge(digest string) error {
+	res, err := d.db.Exec(d.rebind(`UPDATE challenges SET consumed_at = NULL WHERE digest = ?`), digest)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	return depots.ChallengeNotFoundErr
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,10 @@
+package sqldepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestSQLDepot_Challenge(t *testing.T) {
+	depottest.Challenge(t, newTestDepot(t))
+}
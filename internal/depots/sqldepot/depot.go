@@ -0,0 +1,449 @@
+// Package sqldepot implements the certificate depot on top of database/sql,
+// so that several kscep replicas can share one store. The queries are
+// written for SQLite ("sqlite") and PostgreSQL ("postgres" or "pgx").
+package sqldepot
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"kscep/internal/depots"
+	"kscep/internal/keyprovider"
+
+	"github.com/emmansun/gmsm/pkcs8"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+type sqlDepot struct {
+	db     *sql.DB
+	driver string
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewSQLDepot creates a depot on db, opened with the database/sql driver
+// of the given name, and migrates its schema.
+func NewSQLDepot(db *sql.DB, driver string) (*sqlDepot, error) {
+	if isSQLite(driver) {
+		// SQLite has a single writer; serialise on one connection rather
+		// than failing with SQLITE_BUSY.
+		db.SetMaxOpenConns(1)
+	}
+	d := &sqlDepot{db: db, driver: driver}
+	if err := d.Migrate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func isSQLite(driver string) bool {
+	return strings.HasPrefix(driver, "sqlite")
+}
+
+func isPostgres(driver string) bool {
+	return driver == "postgres" || driver == "pgx"
+}
+
+// rebind rewrites the ? placeholders of query for drivers that number
+// their parameters.
+func (d *sqlDepot) rebind(query string) string {
+	if !isPostgres(d.driver) {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CA returns the CA stored under namePrefix (RSA, ECC, SM2, ...), its
+// key decrypted with pass.
+func (d *sqlDepot) CA(pass []byte, namePrefix string) ([]*x509.Certificate, interface{}, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, nil, err
+	}
+	crt, key, err := d.loadCA(d.db, namePrefix, pass)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("no %s CA in database", namePrefix)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*x509.Certificate{crt}, key, nil
+}
+
+// CACerts returns the certificate of the CA namePrefix like CA, without
+// decrypting its key.
+func (d *sqlDepot) CACerts(namePrefix string) ([]*x509.Certificate, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	crt, err := d.loadCACert(d.db, namePrefix)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no %s CA in database", namePrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{crt}, nil
+}
+
+func (d *sqlDepot) loadCA(q querier, name string, pass []byte) (*x509.Certificate, crypto.Signer, error) {
+	var crtPEM, keyPEM string
+	err := q.QueryRow(d.rebind(`SELECT certificate, private_key FROM cas WHERE name = ?`), name).Scan(&crtPEM, &keyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	crt, err := parseCertificate(crtPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	// PKCS#8, encrypted when a passphrase is set; older rows hold PKCS#1
+	key, err := keyprovider.ParsePrivateKeyPEM([]byte(keyPEM), pass)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s CA key in database: %w", name, err)
+	}
+	return crt, key, nil
+}
+
+// loadCACert returns the certificate of the CA name, leaving its key alone.
+func (d *sqlDepot) loadCACert(q querier, name string) (*x509.Certificate, error) {
+	var crtPEM string
+	err := q.QueryRow(d.rebind(`SELECT certificate FROM cas WHERE name = ?`), name).Scan(&crtPEM)
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificate(crtPEM)
+}
+
+// PutCA stores crt and key as the CA namePrefix, replacing it if present.
+// The key is stored as PKCS#8, encrypted with pass unless it is empty.
+// Storing them under <TYPE>.next stages the successor of CA <TYPE>.
+func (d *sqlDepot) PutCA(namePrefix string, crt *x509.Certificate, key crypto.Signer, pass []byte) error {
+	if crt == nil || key == nil {
+		return errors.New("invalid CA certificate or key")
+	}
+	keyPEM, err := encodeKey(key, pass)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(d.rebind(`INSERT INTO cas (name, certificate, private_key) VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET certificate = excluded.certificate, private_key = excluded.private_key, promote_at = NULL`),
+		namePrefix, encodeCertificate(crt.Raw), keyPEM)
+	return err
+}
+
+// CreateOrLoadCA returns the CA namePrefix, creating a self-signed one with
+// a new RSA key of the given size, encrypted with pass, if there is none.
+// When replicas race to create it, all of them end up with the CA stored
+// first.
+func (d *sqlDepot) CreateOrLoadCA(namePrefix string, pass []byte, bits, years int, org, country string) (*x509.Certificate, error) {
+	crt, err := d.loadCACert(d.db, namePrefix)
+	if err != sql.ErrNoRows {
+		return crt, err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	der, err := depots.SelfSignedCA(namePrefix, key, years, org, country)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key, pass)
+	if err != nil {
+		return nil, err
+	}
+	_, insertErr := d.db.Exec(d.rebind(`INSERT INTO cas (name, certificate, private_key) VALUES (?, ?, ?)`),
+		namePrefix, encodeCertificate(der), keyPEM)
+	crt, err = d.loadCACert(d.db, namePrefix)
+	if err == sql.ErrNoRows && insertErr != nil {
+		return nil, insertErr
+	}
+	return crt, err
+}
+
+// Put stores crt and revokes the valid certificates with the same subject.
+func (d *sqlDepot) Put(cn string, crt *x509.Certificate) error {
+	if crt == nil || crt.Raw == nil {
+		return fmt.Errorf("%q does not specify a valid certificate for storage", cn)
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// Revoke old certificate
+	if err := d.hasCN(tx, 0, crt, true); err != nil {
+		return err
+	}
+	_, err = tx.Exec(d.rebind(`INSERT INTO certificates (serial, name, subject, issuer, not_after, certificate, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		serialHex(crt.SerialNumber), cn, hex.EncodeToString(crt.RawSubject), hex.EncodeToString(crt.RawIssuer), crt.NotAfter.UTC(), encodeCertificate(crt.Raw), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Certificate returns the stored certificate with the given serial number,
+// revoked or not.
+func (d *sqlDepot) Certificate(serial *big.Int) (*x509.Certificate, error) {
+	var crtPEM string
+	err := d.db.QueryRow(d.rebind(`SELECT certificate FROM certificates WHERE serial = ?`), serialHex(serial)).Scan(&crtPEM)
+	if err == sql.ErrNoRows {
+		return nil, depots.CertNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificate(crtPEM)
+}
+
+// certificateWhere returns the conditions of q the database applies, all
+// but the common name, and their arguments.
+func certificateWhere(q *depots.CertificateQuery, now time.Time) (string, []interface{}) {
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if q.Serial != nil {
+		where += ` AND c.serial = ?`
+		args = append(args, serialHex(q.Serial))
+	}
+	if !q.ExpiresBefore.IsZero() {
+		where += ` AND c.not_after < ?`
+		args = append(args, q.ExpiresBefore.UTC())
+	}
+	if q.Issuer != nil {
+		where += ` AND c.issuer = ?`
+		args = append(args, hex.EncodeToString(q.Issuer))
+	}
+	switch q.Status {
+	case depots.StatusValid:
+		where += ` AND r.serial IS NULL AND c.not_after >= ?`
+		args = append(args, now.UTC())
+	case depots.StatusRevoked:
+		where += ` AND r.serial IS NOT NULL`
+	case depots.StatusExpired:
+		where += ` AND r.serial IS NULL AND c.not_after < ?`
+		args = append(args, now.UTC())
+	}
+	return where, args
+}
+
+// QueryCertificates lists the stored certificates that match q. All
+// filters but the common name are applied by the database.
+func (d *sqlDepot) QueryCertificates(q *depots.CertificateQuery) ([]*depots.CertificateRecord, error) {
+	now := time.Now()
+	where, args := certificateWhere(q, now)
+	rows, err := d.db.Query(d.rebind(`SELECT c.certificate, r.revoked_at, r.reason FROM certificates c
+LEFT JOIN revocations r ON r.serial = c.serial`+where+` ORDER BY c.created_at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recs []*depots.CertificateRecord
+	for rows.Next() {
+		var (
+			crtPEM    string
+			revokedAt sql.NullTime
+			reason    sql.NullInt64
+		)
+		if err := rows.Scan(&crtPEM, &revokedAt, &reason); err != nil {
+			return nil, err
+		}
+		crt, err := parseCertificate(crtPEM)
+		if err != nil {
+			return nil, err
+		}
+		var rev *depots.Revocation
+		if revokedAt.Valid {
+			rev = &depots.Revocation{Serial: crt.SerialNumber, RevokedAt: revokedAt.Time, Reason: int(reason.Int64)}
+		}
+		if rec := depots.NewCertificateRecord(crt, rev, now); q.Matches(rec) {
+			recs = append(recs, rec)
+		}
+	}
+	return recs, rows.Err()
+}
+
+// CountCertificates counts the stored certificates that match q without
+// reading them, unless q filters by common name.
+func (d *sqlDepot) CountCertificates(q *depots.CertificateQuery) (int, error) {
+	if q.CommonName != "" {
+		recs, err := d.QueryCertificates(q)
+		return len(recs), err
+	}
+	where, args := certificateWhere(q, time.Now())
+	var n int
+	err := d.db.QueryRow(d.rebind(`SELECT COUNT(*) FROM certificates c
+LEFT JOIN revocations r ON r.serial = c.serial`+where), args...).Scan(&n)
+	return n, err
+}
+
+// Serial hands out the next serial number. The counter is incremented
+// before it is read, so concurrent replicas never get the same one.
+func (d *sqlDepot) Serial() (*big.Int, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE serials SET next_serial = next_serial + 1 WHERE id = 1`); err != nil {
+		return nil, err
+	}
+	var next int64
+	if err := tx.QueryRow(`SELECT next_serial FROM serials WHERE id = 1`).Scan(&next); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return big.NewInt(next - 1), nil
+}
+
+// HasCN checks the stored, unrevoked certificates with the subject of cert.
+// Like the file depot it fails if one of them is valid for more than
+// allowTime days, when allowTime is set, and revokes them all if
+// revokeOldCertificate is set.
+func (d *sqlDepot) HasCN(_ string, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) (bool, error) {
+	if cert == nil {
+		return false, errors.New("nil certificate provided")
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if err := d.hasCN(tx, allowTime, cert, revokeOldCertificate); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// subjectLockClass is the first key of the PostgreSQL advisory locks taken
+// on a subject, the second is the hash of the subject.
+const subjectLockClass = 0x6b73 // "ks"
+
+// hasCN runs in the transaction q. On PostgreSQL it holds a lock on the
+// subject until q ends, so that, like under the lock of the file depot,
+// concurrent enrollments of one subject on any replica see each other's
+// certificates.
+func (d *sqlDepot) hasCN(q querier, allowTime int, cert *x509.Certificate, revokeOldCertificate bool) error {
+	subject := hex.EncodeToString(cert.RawSubject)
+	if isPostgres(d.driver) {
+		if _, err := q.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, subjectLockClass, subject); err != nil {
+			return err
+		}
+	}
+	rows, err := q.Query(d.rebind(`SELECT c.serial, c.not_after, c.certificate FROM certificates c
+LEFT JOIN revocations r ON r.serial = c.serial
+WHERE c.subject = ? AND r.serial IS NULL`), subject)
+	if err != nil {
+		return err
+	}
+	minimalRenewDate := time.Now().AddDate(0, 0, allowTime)
+	var candidates []string
+	for rows.Next() {
+		var (
+			serial, crtPEM string
+			notAfter       time.Time
+		)
+		if err := rows.Scan(&serial, &notAfter, &crtPEM); err != nil {
+			rows.Close()
+			return err
+		}
+		// all non renewable certificates
+		if allowTime > 0 && minimalRenewDate.Before(notAfter) {
+			rows.Close()
+			crt, err := parseCertificate(crtPEM)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("DN %s already exists", crt.Subject)
+		}
+		candidates = append(candidates, serial)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !revokeOldCertificate {
+		return nil
+	}
+	now := time.Now().UTC()
+	for _, serial := range candidates {
+		if _, err := q.Exec(d.rebind(`INSERT INTO revocations (serial, revoked_at, reason) VALUES (?, ?, ?)`), serial, now, depots.ReasonSuperseded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	certificatePEMBlockType              = "CERTIFICATE"
+	pkcs8PrivateKeyPEMBlockType          = "PRIVATE KEY"
+	encryptedPKCS8PrivateKeyPEMBlockType = "ENCRYPTED PRIVATE KEY"
+)
+
+func encodeCertificate(der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: certificatePEMBlockType, Bytes: der}))
+}
+
+// encodeKey marshals key as PKCS#8, encrypted with PBES2 if pass is set.
+func encodeKey(key crypto.Signer, pass []byte) (string, error) {
+	der, err := pkcs8.MarshalPrivateKey(key, pass, nil)
+	if err != nil {
+		return "", err
+	}
+	blockType := pkcs8PrivateKeyPEMBlockType
+	if len(pass) > 0 {
+		blockType = encryptedPKCS8PrivateKeyPEMBlockType
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})), nil
+}
+
+func parseCertificate(s string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil || block.Type != certificatePEMBlockType {
+		return nil, errors.New("invalid certificate in database")
+	}
+	// the SM2 CA and the certificates it issues are beyond crypto/x509
+	crt, err := smx509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return crt.ToX509(), nil
+}
+
+func serialHex(serial *big.Int) string {
+	s := fmt.Sprintf("%X", serial)
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	return s
+}
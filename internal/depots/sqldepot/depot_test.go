@@ -0,0 +1,352 @@
+package sqldepot
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/hex"
+	"kscep/internal/depots/depottest"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	_ "modernc.org/sqlite"
+)
+
+func newTestDepot(t *testing.T) *sqlDepot {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "depot.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	depot, err := NewSQLDepot(db, "sqlite")
+	if err != nil {
+		t.Fatalf("NewSQLDepot() error = %v", err)
+	}
+	return depot
+}
+
+func TestSQLDepot_Migrate(t *testing.T) {
+	depot := newTestDepot(t)
+	ms, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+
+	// Test migrating an up to date schema is a no-op
+	if err := depot.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	var n, version int
+	if err := depot.db.QueryRow(`SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&n, &version); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(ms) || version != ms[len(ms)-1].version {
+		t.Fatalf("schema_migrations has %d entries up to version %d, want %d up to %d", n, version, len(ms), ms[len(ms)-1].version)
+	}
+
+	// Test the issuer of a certificate stored before the column is filled in
+	cert := depottest.Cert(t, "test", 1, time.Now().Add(time.Hour))
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, err := depot.db.Exec(`UPDATE certificates SET issuer = ''`); err != nil {
+		t.Fatal(err)
+	}
+	if err := depot.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	var issuer string
+	if err := depot.db.QueryRow(`SELECT issuer FROM certificates WHERE serial = '01'`).Scan(&issuer); err != nil {
+		t.Fatal(err)
+	}
+	if issuer != hex.EncodeToString(cert.RawIssuer) {
+		t.Errorf("Migrate() filled in issuer %q, want %x", issuer, cert.RawIssuer)
+	}
+}
+
+func TestSQLDepot_MigrateConcurrently(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "depot.db") + "?_pragma=busy_timeout(5000)"
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := sql.Open("sqlite", source)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer db.Close()
+			_, errs[i] = NewSQLDepot(db, "sqlite")
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("NewSQLDepot() of replica %d error = %v", i, err)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := splitStatements("-- comment\nCREATE TABLE a (x TEXT);\n\nINSERT INTO a VALUES ('y');\n")
+	if len(stmts) != 2 || stmts[0] != "CREATE TABLE a (x TEXT)" || stmts[1] != "INSERT INTO a VALUES ('y')" {
+		t.Fatalf("splitStatements() = %q", stmts)
+	}
+}
+
+func TestSQLDepot_Rebind(t *testing.T) {
+	d := &sqlDepot{driver: "postgres"}
+	if got := d.rebind(`SELECT a FROM b WHERE c = ? AND d = ?`); got != `SELECT a FROM b WHERE c = $1 AND d = $2` {
+		t.Fatalf("rebind() = %q", got)
+	}
+	d.driver = "sqlite"
+	if got := d.rebind(`WHERE c = ?`); got != `WHERE c = ?` {
+		t.Fatalf("rebind() = %q for sqlite", got)
+	}
+}
+
+func TestSQLDepot_CA(t *testing.T) {
+	depot := newTestDepot(t)
+
+	// Test the CA is not exist
+	if _, _, err := depot.CA(nil, "RSA"); err == nil {
+		t.Fatalf("CA() did not return an error for a missing CA")
+	}
+
+	pass := []byte("secret")
+	crt, err := depot.CreateOrLoadCA("RSA", pass, 2048, 1, "kscep", "CN")
+	if err != nil {
+		t.Fatalf("CreateOrLoadCA() error = %v", err)
+	}
+	if !crt.IsCA || crt.Subject.Organization[0] != "kscep" || crt.Subject.Country[0] != "CN" {
+		t.Fatalf("CreateOrLoadCA() = %+v, want a CA for kscep, CN", crt.Subject)
+	}
+
+	// Test a second call loads the stored CA
+	crt2, err := depot.CreateOrLoadCA("RSA", pass, 2048, 1, "", "")
+	if err != nil {
+		t.Fatalf("CreateOrLoadCA() error = %v", err)
+	}
+	if !crt.Equal(crt2) {
+		t.Fatalf("CreateOrLoadCA() created a new certificate for an existing CA")
+	}
+
+	// Test the key is encrypted with the passphrase
+	if _, _, err := depot.CA([]byte("wrong"), "RSA"); err == nil {
+		t.Fatalf("CA() did not return an error for a wrong passphrase")
+	}
+	certs, caKey, err := depot.CA(pass, "RSA")
+	if err != nil {
+		t.Fatalf("CA() error = %v", err)
+	}
+	if len(certs) != 1 || !certs[0].Equal(crt) {
+		t.Fatalf("CA() returned %d certificates, want the created CA", len(certs))
+	}
+	k, ok := caKey.(*rsa.PrivateKey)
+	if !ok {
+		t.Fatalf("CA() returned key of type %T, want *rsa.PrivateKey", caKey)
+	}
+	if !k.PublicKey.Equal(crt.PublicKey) {
+		t.Fatalf("CA() returned a key that does not match the certificate")
+	}
+
+	// Test CA types are stored separately
+	if _, _, err := depot.CA(nil, "ECC"); err == nil {
+		t.Fatalf("CA() returned the RSA CA for ECC")
+	}
+}
+
+func TestSQLDepot_Put(t *testing.T) {
+	depot := newTestDepot(t)
+	cert := depottest.Cert(t, "test", 1, time.Now().Add(365*24*time.Hour))
+
+	if err := depot.Put("test", nil); err == nil {
+		t.Fatalf("Put() did not return an error for a nil certificate")
+	}
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Verify the certificate data
+	var name, crtPEM string
+	if err := depot.db.QueryRow(`SELECT name, certificate FROM certificates WHERE serial = '01'`).Scan(&name, &crtPEM); err != nil {
+		t.Fatalf("Put() did not store the certificate: %v", err)
+	}
+	parsedCert, err := parseCertificate(crtPEM)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if name != "test" || parsedCert.Subject.CommonName != "test" {
+		t.Fatalf("Put() stored certificate %v with CommonName = %v, want test", name, parsedCert.Subject.CommonName)
+	}
+
+	// Test a serial number can only be stored once
+	if err := depot.Put("test", cert); err == nil {
+		t.Fatalf("Put() did not return an error for a duplicate serial number")
+	}
+}
+
+func TestSQLDepot_Serial(t *testing.T) {
+	depot := newTestDepot(t)
+
+	// Test the Serial method on a new database
+	serial, err := depot.Serial()
+	if err != nil {
+		t.Fatalf("Serial() error = %v", err)
+	}
+	if serial.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("Serial() = %v, want %v", serial, big.NewInt(2))
+	}
+
+	// Test the Serial method increments the counter
+	serial, err = depot.Serial()
+	if err != nil {
+		t.Fatalf("Serial() error = %v", err)
+	}
+	if serial.Cmp(big.NewInt(3)) != 0 {
+		t.Fatalf("Serial() = %v, want %v", serial, big.NewInt(3))
+	}
+}
+
+func TestSQLDepot_HasCN(t *testing.T) {
+	depot := newTestDepot(t)
+	cert := depottest.Cert(t, "test", 1, time.Now().Add(365*24*time.Hour))
+
+	// Write the certificate to the depot
+	if err := depot.Put("test", cert); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// Test HasCN with allowTime = 0 and revokeOldCertificate = false
+	exists, err := depot.HasCN("test", 0, cert, false)
+	if err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	if !exists {
+		t.Fatalf("HasCN() = %v, want true", exists)
+	}
+
+	// Test HasCN with a renewal window shorter than the remaining validity
+	if _, err := depot.HasCN("test", 30, cert, false); err == nil {
+		t.Fatalf("HasCN() did not return an error for a certificate outside the renewal window")
+	}
+
+	// Test HasCN with a renewal window covering the remaining validity
+	exists, err = depot.HasCN("test", 400, cert, false)
+	if err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	if !exists {
+		t.Fatalf("HasCN() = %v, want true", exists)
+	}
+
+	// Test HasCN with revokeOldCertificate = true
+	if _, err := depot.HasCN("test", 0, cert, true); err != nil {
+		t.Fatalf("HasCN() error = %v", err)
+	}
+	revoked, err := depot.Revoked(cert.SerialNumber)
+	if err != nil {
+		t.Fatalf("Revoked() error = %v", err)
+	}
+	if !revoked {
+		t.Fatalf("HasCN() did not revoke the old certificate")
+	}
+
+	// Revoked certificates no longer block a renewal
+	if _, err := depot.HasCN("test", 30, cert, false); err != nil {
+		t.Fatalf("HasCN() error = %v for a revoked certificate", err)
+	}
+
+	// Test Put revokes the previous certificate with the same subject
+	cert2 := depottest.Cert(t, "test2", 2, time.Now().Add(365*24*time.Hour))
+	cert3 := depottest.Cert(t, "test2", 3, time.Now().Add(365*24*time.Hour))
+	if err := depot.Put("test2", cert2); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := depot.Put("test2", cert3); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if revoked, _ := depot.Revoked(cert2.SerialNumber); !revoked {
+		t.Fatalf("Put() did not revoke the superseded certificate")
+	}
+	if revoked, _ := depot.Revoked(cert3.SerialNumber); revoked {
+		t.Fatalf("Put() revoked the new certificate")
+	}
+}
+
+func TestSQLDepot_QueryCertificates(t *testing.T) {
+	depottest.QueryCertificates(t, newTestDepot(t))
+}
+
+func TestSQLDepot_PutCA(t *testing.T) {
+	depot := newTestDepot(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm2Key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		pass []byte
+	}{
+		{name: "ECC", key: ecKey, pass: []byte("ecc secret")},
+		{name: "SM2", key: sm2Key, pass: []byte("sm2 secret")},
+		{name: "ECC.next", key: ecKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &x509.Certificate{
+				SerialNumber:          big.NewInt(1),
+				Subject:               pkix.Name{CommonName: tt.name},
+				NotBefore:             time.Now(),
+				NotAfter:              time.Now().Add(time.Hour),
+				KeyUsage:              x509.KeyUsageCertSign,
+				BasicConstraintsValid: true,
+				IsCA:                  true,
+			}
+			der, err := smx509.CreateCertificate(rand.Reader, tmpl, tmpl, tt.key.Public(), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crt, err := parseCertificate(encodeCertificate(der))
+			if err != nil {
+				t.Fatalf("parseCertificate() error = %v", err)
+			}
+			if err := depot.PutCA(tt.name, crt, tt.key, tt.pass); err != nil {
+				t.Fatalf("PutCA() error = %v", err)
+			}
+			certs, key, err := depot.CA(tt.pass, tt.name)
+			if err != nil {
+				t.Fatalf("CA() error = %v", err)
+			}
+			if !certs[0].Equal(crt) {
+				t.Fatalf("CA() returned another certificate")
+			}
+			if !key.(crypto.Signer).Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.key.Public()) {
+				t.Fatalf("CA() returned a key that does not match the stored one")
+			}
+			if len(tt.pass) > 0 {
+				if _, _, err := depot.CA(nil, tt.name); err == nil {
+					t.Fatalf("CA() did not return an error without the passphrase")
+				}
+			}
+		})
+	}
+}
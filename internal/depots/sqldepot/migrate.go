@@ -0,0 +1,180 @@
+package sqldepot
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are the files migrations/<version>_<name>.sql. Each one is
+// applied once, in version order, and recorded in schema_migrations.
+// Applied migrations must never be edited; add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+type migration struct {
+	version int
+	name    string
+	stmts   []string
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var ms []migration
+	for _, e := range entries {
+		version, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing version prefix", e.Name())
+		}
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		data, err := migrationFS.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, migration{version: v, name: e.Name(), stmts: splitStatements(string(data))})
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].version < ms[j].version })
+	return ms, nil
+}
+
+// splitStatements splits a migration into its statements, since not every
+// driver executes several statements at once. Comments are dropped.
+func splitStatements(src string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// migrationLockID is the PostgreSQL advisory lock held while migrating.
+const migrationLockID = 0x6b73636570 // "kscep"
+
+// Migrate brings the schema up to date. It is safe to call on every start,
+// and from several replicas at once: on PostgreSQL they take turns on an
+// advisory lock, elsewhere a replica whose migration fails because another
+// one applied it first carries on.
+func (d *sqlDepot) Migrate() error {
+	ms, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if isPostgres(d.driver) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+	}
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return err
+	}
+	current, err := migrationVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.version <= current {
+			continue
+		}
+		if err := d.apply(ctx, conn, m); err != nil {
+			// another replica may have applied it in the meantime
+			if current, verr := migrationVersion(ctx, conn); verr == nil && current >= m.version {
+				continue
+			}
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+	}
+	return d.backfillIssuers(ctx, conn)
+}
+
+// backfillIssuers fills in the issuer of the certificates stored before the
+// column was added.
+func (d *sqlDepot) backfillIssuers(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx, `SELECT serial, certificate FROM certificates WHERE issuer = ''`)
+	if err != nil {
+		return err
+	}
+	issuers := map[string]string{}
+	for rows.Next() {
+		var serial, crtPEM string
+		if err := rows.Scan(&serial, &crtPEM); err != nil {
+			rows.Close()
+			return err
+		}
+		crt, err := parseCertificate(crtPEM)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("certificate %s: %w", serial, err)
+		}
+		issuers[serial] = hex.EncodeToString(crt.RawIssuer)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for serial, issuer := range issuers {
+		if _, err := conn.ExecContext(ctx, d.rebind(`UPDATE certificates SET issuer = ? WHERE serial = ?`), issuer, serial); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrationVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var current int
+	err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	return current, err
+}
+
+func (d *sqlDepot) apply(ctx context.Context, conn *sql.Conn, m migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range m.stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	// a replica that migrated concurrently makes this insert fail and the
+	// whole migration roll back, see Migrate
+	if _, err := tx.Exec(d.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), m.version, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,55 @@
+-- CAs by name prefix (RSA, ECC, SM2). A staged successor is stored as
+-- <TYPE>.next and the CA it replaced as <TYPE>.prev.
+CREATE TABLE cas (
+    name        TEXT PRIMARY KEY,
+    certificate TEXT NOT NULL,
+    private_key TEXT NOT NULL,
+    promote_at  TIMESTAMP NULL
+);
+
+CREATE TABLE certificates (
+    serial      TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    subject     TEXT NOT NULL,
+    not_after   TIMESTAMP NOT NULL,
+    certificate TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX certificates_subject ON certificates (subject);
+
+CREATE TABLE serials (
+    id          INTEGER PRIMARY KEY,
+    next_serial BIGINT NOT NULL
+);
+
+INSERT INTO serials (id, next_serial) VALUES (1, 2);
+
+CREATE TABLE revocations (
+    serial     TEXT PRIMARY KEY,
+    revoked_at TIMESTAMP NOT NULL,
+    reason     INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE challenges (
+    digest      TEXT PRIMARY KEY,
+    common_name TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL,
+    expires_at  TIMESTAMP NULL,
+    consumed_at TIMESTAMP NULL
+);
+
+CREATE TABLE pending_requests (
+    id             TEXT PRIMARY KEY,
+    transaction_id TEXT NOT NULL,
+    profile        TEXT NOT NULL DEFAULT '',
+    ca_type        TEXT NOT NULL DEFAULT '',
+    csr            TEXT NOT NULL,
+    status         TEXT NOT NULL,
+    reason         TEXT NOT NULL DEFAULT '',
+    certificate    TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL
+);
+
+CREATE INDEX pending_requests_status ON pending_requests (status, created_at);
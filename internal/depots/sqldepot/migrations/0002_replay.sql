@@ -0,0 +1,12 @@
+-- senderNonces seen until they expire, so replayed pkiMessages are rejected
+CREATE TABLE replay_nonces (
+    nonce      TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- the certificate issued for a transactionID, returned when it is repeated
+CREATE TABLE replay_transactions (
+    id         TEXT PRIMARY KEY,
+    serial     TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
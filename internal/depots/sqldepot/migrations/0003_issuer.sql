@@ -0,0 +1,6 @@
+-- the raw subject of the issuing CA, hex encoded like subject, so that the
+-- certificates of one CA are counted by the database. Certificates stored
+-- before are filled in by Migrate.
+ALTER TABLE certificates ADD COLUMN issuer TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX certificates_issuer ON certificates (issuer, not_after);
@@ -0,0 +1,103 @@
+package sqldepot
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"kscep/internal/depots"
+)
+
+const pendingColumns = `id, transaction_id, profile, ca_type, csr, status, reason, certificate, created_at, updated_at`
+
+// PutPending stores a pending request keyed by its id, replacing any
+// previous version of it.
+func (d *sqlDepot) PutPending(pr *depots.PendingRequest) error {
+	if pr == nil || pr.ID == "" {
+		return errors.New("invalid pending request id")
+	}
+	_, err := d.db.Exec(d.rebind(`INSERT INTO pending_requests (`+pendingColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET transaction_id = excluded.transaction_id, profile = excluded.profile,
+ca_type = excluded.ca_type, csr = excluded.csr, status = excluded.status, reason = excluded.reason,
+certificate = excluded.certificate, created_at = excluded.created_at, updated_at = excluded.updated_at`),
+		pr.ID, pr.TransactionID, pr.Profile, pr.CaType, base64.StdEncoding.EncodeToString(pr.CSR), pr.Status, pr.Reason,
+		base64.StdEncoding.EncodeToString(pr.Certificate), pr.CreatedAt.UTC(), pr.UpdatedAt.UTC())
+	return err
+}
+
+// UpdatePending replaces the pending request only while the stored one
+// has the given status, and fails with depots.PendingStatusErr otherwise.
+// The update matches on the status, so concurrent transitions, even on
+// different replicas, cannot both succeed.
+func (d *sqlDepot) UpdatePending(pr *depots.PendingRequest, status string) error {
+	if pr == nil || pr.ID == "" {
+		return depots.PendingNotFoundErr
+	}
+	res, err := d.db.Exec(d.rebind(`UPDATE pending_requests SET transaction_id = ?, profile = ?, ca_type = ?, csr = ?, status = ?,
+reason = ?, certificate = ?, created_at = ?, updated_at = ? WHERE id = ? AND status = ?`),
+		pr.TransactionID, pr.Profile, pr.CaType, base64.StdEncoding.EncodeToString(pr.CSR), pr.Status, pr.Reason,
+		base64.StdEncoding.EncodeToString(pr.Certificate), pr.CreatedAt.UTC(), pr.UpdatedAt.UTC(), pr.ID, status)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n > 0 {
+		return err
+	}
+	if _, err := d.GetPending(pr.ID); err != nil {
+		return err
+	}
+	return depots.PendingStatusErr
+}
+
+// GetPending loads the pending request with the given id.
+func (d *sqlDepot) GetPending(id string) (*depots.PendingRequest, error) {
+	pr, err := scanPending(d.db.QueryRow(d.rebind(`SELECT `+pendingColumns+` FROM pending_requests WHERE id = ?`), id))
+	if err == sql.ErrNoRows {
+		return nil, depots.PendingNotFoundErr
+	}
+	return pr, err
+}
+
+// ListPending returns the pending requests with the given status, or all of
+// them if status is empty, oldest first.
+func (d *sqlDepot) ListPending(status string) ([]*depots.PendingRequest, error) {
+	query, args := `SELECT `+pendingColumns+` FROM pending_requests`, []interface{}{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	rows, err := d.db.Query(d.rebind(query+` ORDER BY created_at`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var prs []*depots.PendingRequest
+	for rows.Next() {
+		pr, err := scanPending(rows)
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+	return prs, rows.Err()
+}
+
+func scanPending(row interface{ Scan(...interface{}) error }) (*depots.PendingRequest, error) {
+	var (
+		pr       depots.PendingRequest
+		csr, crt string
+	)
+	err := row.Scan(&pr.ID, &pr.TransactionID, &pr.Profile, &pr.CaType, &csr, &pr.Status, &pr.Reason, &crt, &pr.CreatedAt, &pr.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if pr.CSR, err = base64.StdEncoding.DecodeString(csr); err != nil {
+		return nil, err
+	}
+	if crt != "" {
+		if pr.Certificate, err = base64.StdEncoding.DecodeString(crt); err != nil {
+			return nil, err
+		}
+	}
+	return &pr, nil
+}
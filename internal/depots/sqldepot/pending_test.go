@@ -0,0 +1,10 @@
+package sqldepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestSQLDepot_Pending(t *testing.T) {
+	depottest.Pending(t, newTestDepot(t))
+}
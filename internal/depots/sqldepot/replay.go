@@ -0,0 +1,73 @@
+package sqldepot
+
+import (
+	"database/sql"
+	"errors"
+	"kscep/internal/depots"
+	"math/big"
+	"time"
+)
+
+// PutNonce records nonce until expiresAt. The insert only replaces an
+// expired nonce, so a nonce is accepted once even across replicas.
+func (d *sqlDepot) PutNonce(nonce string, expiresAt time.Time) error {
+	if nonce == "" {
+		return errors.New("invalid sender nonce")
+	}
+	res, err := d.db.Exec(d.rebind(`INSERT INTO replay_nonces (nonce, expires_at) VALUES (?, ?)
+ON CONFLICT (nonce) DO UPDATE SET expires_at = excluded.expires_at WHERE replay_nonces.expires_at <= ?`),
+		nonce, expiresAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return depots.NonceReusedErr
+	}
+	return nil
+}
+
+// PutTransaction records the certificate issued for a transaction,
+// replacing an earlier record of it.
+func (d *sqlDepot) PutTransaction(tx *depots.Transaction) error {
+	if tx == nil || tx.ID == "" || tx.Serial == nil {
+		return errors.New("invalid transaction")
+	}
+	_, err := d.db.Exec(d.rebind(`INSERT INTO replay_transactions (id, serial, expires_at) VALUES (?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET serial = excluded.serial, expires_at = excluded.expires_at`),
+		tx.ID, serialHex(tx.Serial), tx.ExpiresAt.UTC())
+	return err
+}
+
+// GetTransaction loads the transaction with the given id unless it has
+// expired.
+func (d *sqlDepot) GetTransaction(id string) (*depots.Transaction, error) {
+	var serial string
+	tx := depots.Transaction{ID: id}
+	err := d.db.QueryRow(d.rebind(`SELECT serial, expires_at FROM replay_transactions WHERE id = ? AND expires_at > ?`), id, time.Now().UTC()).
+		Scan(&serial, &tx.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, depots.TransactionNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if tx.Serial, ok = new(big.Int).SetString(serial, 16); !ok {
+		return nil, errors.New("invalid serial number in database")
+	}
+	return &tx, nil
+}
+
+// PurgeReplay deletes the nonces and transactions expired at t.
+func (d *sqlDepot) PurgeReplay(t time.Time) error {
+	for _, table := range []string{"replay_nonces", "replay_transactions"} {
+		if _, err := d.db.Exec(d.rebind(`DELETE FROM `+table+` WHERE expires_at <= ?`), t.UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package sqldepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestSQLDepot_Nonce(t *testing.T) {
+	depottest.Nonce(t, newTestDepot(t))
+}
+
+func TestSQLDepot_Transaction(t *testing.T) {
+	depottest.Transaction(t, newTestDepot(t))
+}
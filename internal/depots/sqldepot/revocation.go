@@ -0,0 +1,90 @@
+package sqldepot
+
+import (
+	"database/sql"
+	"fmt"
+	"kscep/internal/depots"
+	"math/big"
+	"time"
+)
+
+// Revoked reports whether the certificate with the given serial number has
+// been revoked.
+func (d *sqlDepot) Revoked(serial *big.Int) (bool, error) {
+	var n int
+	err := d.db.QueryRow(d.rebind(`SELECT COUNT(*) FROM revocations WHERE serial = ?`), serialHex(serial)).Scan(&n)
+	return n > 0, err
+}
+
+// Revocation returns the revocation of the certificate with the given serial
+// number, or nil if it is not revoked.
+func (d *sqlDepot) Revocation(serial *big.Int) (*depots.Revocation, error) {
+	rev := &depots.Revocation{Serial: serial}
+	err := d.db.QueryRow(d.rebind(`SELECT revoked_at, reason FROM revocations WHERE serial = ?`), serialHex(serial)).Scan(&rev.RevokedAt, &rev.Reason)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Revoke revokes the stored certificate with the given serial number.
+func (d *sqlDepot) Revoke(serial *big.Int, reason int) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	key := serialHex(serial)
+	var revoked sql.NullString
+	err = tx.QueryRow(d.rebind(`SELECT r.serial FROM certificates c
+LEFT JOIN revocations r ON r.serial = c.serial
+WHERE c.serial = ?`), key).Scan(&revoked)
+	if err == sql.ErrNoRows {
+		return depots.CertNotFoundErr
+	}
+	if err != nil {
+		return err
+	}
+	if revoked.Valid {
+		return depots.AlreadyRevokedErr
+	}
+	if _, err := tx.Exec(d.rebind(`INSERT INTO revocations (serial, revoked_at, reason) VALUES (?, ?, ?)`), key, time.Now().UTC(), reason); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Revocations lists the revoked certificates.
+func (d *sqlDepot) Revocations() ([]*depots.Revocation, error) {
+	rows, err := d.db.Query(`SELECT r.serial, r.revoked_at, r.reason, c.certificate FROM revocations r
+JOIN certificates c ON c.serial = r.serial
+ORDER BY r.revoked_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var revs []*depots.Revocation
+	for rows.Next() {
+		var (
+			serial, crtPEM string
+			rev            depots.Revocation
+		)
+		if err := rows.Scan(&serial, &rev.RevokedAt, &rev.Reason, &crtPEM); err != nil {
+			return nil, err
+		}
+		var ok bool
+		if rev.Serial, ok = new(big.Int).SetString(serial, 16); !ok {
+			return nil, fmt.Errorf("invalid serial %q in revocations", serial)
+		}
+		crt, err := parseCertificate(crtPEM)
+		if err != nil {
+			return nil, err
+		}
+		rev.Issuer = crt.RawIssuer
+		revs = append(revs, &rev)
+	}
+	return revs, rows.Err()
+}
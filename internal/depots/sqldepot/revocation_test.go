@@ -0,0 +1,10 @@
+package sqldepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestSQLDepot_Revoke(t *testing.T) {
+	depottest.Revocation(t, newTestDepot(t))
+}
@@ -0,0 +1,110 @@
+package sqldepot
+
+import (
+	"crypto/x509"
+	"database/sql"
+	"kscep/internal/depots"
+	"time"
+)
+
+// A CA is rolled over by staging its successor as <TYPE>.next, see PutCA.
+// Promotion renames the current CA to <TYPE>.prev and the next CA to
+// <TYPE>. A promotion scheduled for later is recorded in promote_at of the
+// next CA and carried out by the first CA lookup once it is due.
+
+// NextCA returns the certificate staged to replace the CA namePrefix.
+func (d *sqlDepot) NextCA(namePrefix string) (*x509.Certificate, error) {
+	if err := d.promoteDue(namePrefix, time.Now()); err != nil {
+		return nil, err
+	}
+	crt, err := d.loadCACert(d.db, namePrefix+".next")
+	if err == sql.ErrNoRows {
+		return nil, depots.NextCANotFoundErr
+	}
+	return crt, err
+}
+
+// ScheduleCAPromotion promotes the next CA of namePrefix at the given time,
+// or right away if at is not in the future.
+func (d *sqlDepot) ScheduleCAPromotion(namePrefix string, at time.Time) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if !at.After(time.Now()) {
+		err = d.promote(tx, namePrefix)
+	} else {
+		err = d.schedule(tx, namePrefix, at)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqlDepot) schedule(tx *sql.Tx, namePrefix string, at time.Time) error {
+	res, err := tx.Exec(d.rebind(`UPDATE cas SET promote_at = ? WHERE name = ?`), at.UTC(), namePrefix+".next")
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = depots.NextCANotFoundErr
+		}
+		return err
+	}
+	return nil
+}
+
+// promoteDue carries out a scheduled promotion of namePrefix once now has
+// reached it.
+func (d *sqlDepot) promoteDue(namePrefix string, now time.Time) error {
+	due, err := d.promotionDue(d.db, namePrefix, now)
+	if err != nil || !due {
+		return err
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// another replica may have promoted it in the meantime
+	if due, err = d.promotionDue(tx, namePrefix, now); err != nil || !due {
+		return err
+	}
+	if err := d.promote(tx, namePrefix); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqlDepot) promotionDue(q querier, namePrefix string, now time.Time) (bool, error) {
+	var at sql.NullTime
+	err := q.QueryRow(d.rebind(`SELECT promote_at FROM cas WHERE name = ?`), namePrefix+".next").Scan(&at)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return at.Valid && !now.Before(at.Time), nil
+}
+
+func (d *sqlDepot) promote(tx *sql.Tx, namePrefix string) error {
+	var n int
+	if err := tx.QueryRow(d.rebind(`SELECT COUNT(*) FROM cas WHERE name = ?`), namePrefix+".next").Scan(&n); err != nil {
+		return err
+	}
+	if n == 0 {
+		return depots.NextCANotFoundErr
+	}
+	if _, err := tx.Exec(d.rebind(`DELETE FROM cas WHERE name = ?`), namePrefix+".prev"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(d.rebind(`UPDATE cas SET name = ? WHERE name = ?`), namePrefix+".prev", namePrefix); err != nil {
+		return err
+	}
+	_, err := tx.Exec(d.rebind(`UPDATE cas SET name = ?, promote_at = NULL WHERE name = ?`), namePrefix, namePrefix+".next")
+	return err
+}
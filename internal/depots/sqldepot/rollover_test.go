@@ -0,0 +1,17 @@
+package sqldepot
+
+import (
+	"kscep/internal/depots/depottest"
+	"testing"
+)
+
+func TestSQLDepot_Rollover(t *testing.T) {
+	depot := newTestDepot(t)
+	putCA := func(name, cn string) {
+		crt, priv := depottest.CA(t, cn)
+		if err := depot.PutCA(name, crt, priv, nil); err != nil {
+			t.Fatalf("PutCA() error = %v", err)
+		}
+	}
+	depottest.Rollover(t, depot, putCA, depot.promoteDue)
+}
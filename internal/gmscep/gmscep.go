@@ -0,0 +1,276 @@
+// Package gmscep is the client side of GM/T 0089 SCEP: CSRs signed with
+// SM2 and SM3, pkiMessages signed in a GM/T 0010 SignedData and CSRs and
+// issued certificates enveloped with SM4. The scep package only speaks
+// RSA, it is used for the message types and attributes.
+package gmscep
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/emmansun/gmsm/pkcs"
+	smpkcs7 "github.com/emmansun/gmsm/pkcs7"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/ploynomail/scep"
+	"github.com/ploynomail/scep/cryptoutil"
+)
+
+var (
+	oidSCEPmessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidSCEPpkiStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidSCEPfailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidSCEPsenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidSCEPrecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidSCEPtransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+
+	oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+)
+
+// PKIMessage is a GM/T 0089 CertRep.
+type PKIMessage struct {
+	scep.PKIMessage
+
+	p7 *smpkcs7.PKCS7
+}
+
+// CreateCertificateRequest creates a CSR from tmpl signed with SM2 and SM3,
+// carrying challenge as its challengePassword if set.
+func CreateCertificateRequest(rand io.Reader, tmpl *x509.CertificateRequest, challenge string, key *sm2.PrivateKey) ([]byte, error) {
+	der, err := smx509.CreateCertificateRequest(rand, tmpl, key)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == "" {
+		return der, nil
+	}
+	return addChallenge(rand, der, challenge, key)
+}
+
+type tbsCertificateRequest struct {
+	Raw           asn1.RawContent
+	Version       int
+	Subject       asn1.RawValue
+	PublicKey     asn1.RawValue
+	RawAttributes []asn1.RawValue `asn1:"tag:0"`
+}
+
+type certificateRequest struct {
+	Raw                asn1.RawContent
+	TBSCSR             tbsCertificateRequest
+	SignatureAlgorithm asn1.RawValue
+	SignatureValue     asn1.BitString
+}
+
+type passwordChallengeAttribute struct {
+	Type  asn1.ObjectIdentifier
+	Value []string `asn1:"set"`
+}
+
+// addChallenge adds the challengePassword attribute to the CSR der and
+// signs it again.
+func addChallenge(rand io.Reader, der []byte, challenge string, key *sm2.PrivateKey) ([]byte, error) {
+	var csr certificateRequest
+	if _, err := asn1.Unmarshal(der, &csr); err != nil {
+		return nil, err
+	}
+	attr, err := asn1.Marshal(passwordChallengeAttribute{
+		Type:  oidChallengePassword,
+		Value: []string{challenge},
+	})
+	if err != nil {
+		return nil, err
+	}
+	csr.TBSCSR.RawAttributes = append(csr.TBSCSR.RawAttributes, asn1.RawValue{FullBytes: attr})
+	csr.TBSCSR.Raw = nil
+	tbs, err := asn1.Marshal(csr.TBSCSR)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := key.SignWithSM2(rand, nil, tbs)
+	if err != nil {
+		return nil, err
+	}
+	csr.Raw = nil
+	csr.TBSCSR.Raw = tbs
+	csr.SignatureValue = asn1.BitString{Bytes: sig, BitLength: len(sig) * 8}
+	return asn1.Marshal(csr)
+}
+
+// ParseCertificateRequest parses an SM2 or RSA/ECDSA CSR.
+func ParseCertificateRequest(der []byte) (*x509.CertificateRequest, error) {
+	csr, err := smx509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+	return csr.ToX509(), nil
+}
+
+// ParseCertificate parses an SM2 or RSA/ECDSA certificate.
+func ParseCertificate(der []byte) (*x509.Certificate, error) {
+	crt, err := smx509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return crt.ToX509(), nil
+}
+
+// CACerts parses a GetCACert response of certNum certificates, a single DER
+// certificate or a certs-only SignedData.
+func CACerts(data []byte, certNum int) ([]*x509.Certificate, error) {
+	var smCerts []*smx509.Certificate
+	if certNum > 1 {
+		p7, err := smpkcs7.Parse(data)
+		if err != nil {
+			return nil, err
+		}
+		smCerts = p7.Certificates
+	} else {
+		var err error
+		if smCerts, err = smx509.ParseCertificates(data); err != nil {
+			return nil, err
+		}
+	}
+	certs := make([]*x509.Certificate, 0, len(smCerts))
+	for _, crt := range smCerts {
+		certs = append(certs, crt.ToX509())
+	}
+	return certs, nil
+}
+
+// NewCSRRequest creates a PKCSReq or RenewalReq for csr as described by
+// tmpl. The CSR is enveloped with SM4 to tmpl.Recipients and the message
+// signed by tmpl.SignerCert with the SM2 key tmpl.SignerKey.
+func NewCSRRequest(csr *x509.CertificateRequest, tmpl *scep.PKIMessage) (*scep.PKIMessage, error) {
+	if len(tmpl.Recipients) < 1 {
+		return nil, errors.New("gmscep: no CA/RA recipients")
+	}
+	e7, err := smpkcs7.EncryptSM(pkcs.SM4CBC, csr.Raw, smCertificates(tmpl.Recipients))
+	if err != nil {
+		return nil, err
+	}
+	sd, err := smpkcs7.NewSMSignedData(e7)
+	if err != nil {
+		return nil, err
+	}
+
+	// transactionID is the hash of the public key, as in the scep package
+	id, err := cryptoutil.GenerateSubjectKeyID(csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	tID := scep.TransactionID(base64.StdEncoding.EncodeToString(id))
+	sn := make(scep.SenderNonce, 16)
+	if _, err := rand.Read(sn); err != nil {
+		return nil, err
+	}
+
+	config := smpkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []smpkcs7.Attribute{
+			{Type: oidSCEPtransactionID, Value: tID},
+			{Type: oidSCEPmessageType, Value: tmpl.MessageType},
+			{Type: oidSCEPsenderNonce, Value: sn},
+		},
+	}
+	if err := sd.AddSigner((*smx509.Certificate)(tmpl.SignerCert), tmpl.SignerKey, config); err != nil {
+		return nil, err
+	}
+	raw, err := sd.Finish()
+	if err != nil {
+		return nil, err
+	}
+	return &scep.PKIMessage{
+		Raw:           raw,
+		MessageType:   tmpl.MessageType,
+		TransactionID: tID,
+		SenderNonce:   sn,
+		CSRReqMessage: &scep.CSRReqMessage{CSR: csr},
+		Recipients:    tmpl.Recipients,
+	}, nil
+}
+
+// ParsePKIMessage verifies a CertRep signed by one of caCerts and reads its
+// status.
+func ParsePKIMessage(data []byte, caCerts []*x509.Certificate) (*PKIMessage, error) {
+	p7, err := smpkcs7.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(caCerts) > 0 {
+		// the CA may leave out its certificates, they were fetched
+		// with GetCACert
+		p7.Certificates = smCertificates(caCerts)
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, err
+	}
+
+	msg := &PKIMessage{p7: p7}
+	msg.Raw = data
+	if err := p7.UnmarshalSignedAttribute(oidSCEPtransactionID, &msg.TransactionID); err != nil {
+		return nil, err
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPmessageType, &msg.MessageType); err != nil {
+		return nil, err
+	}
+	if msg.MessageType != scep.CertRep {
+		return nil, fmt.Errorf("gmscep: unexpected message type %s", msg.MessageType)
+	}
+	cr := &scep.CertRepMessage{}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPpkiStatus, &cr.PKIStatus); err != nil {
+		return nil, err
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSCEPrecipientNonce, &cr.RecipientNonce); err != nil {
+		return nil, err
+	}
+	if len(cr.RecipientNonce) == 0 {
+		return nil, errors.New("gmscep: pkiMessage must include recipientNonce attribute")
+	}
+	switch cr.PKIStatus {
+	case scep.SUCCESS, scep.PENDING:
+	case scep.FAILURE:
+		if err := p7.UnmarshalSignedAttribute(oidSCEPfailInfo, &cr.FailInfo); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("gmscep: unknown pkiStatus %s", cr.PKIStatus)
+	}
+	msg.CertRepMessage = cr
+	return msg, nil
+}
+
+// DecryptPKIEnvelope opens the SM4 envelope of a SUCCESS CertRep with the
+// signer certificate and key of the request and sets the issued
+// certificate.
+func (msg *PKIMessage) DecryptPKIEnvelope(cert *x509.Certificate, key *sm2.PrivateKey) error {
+	e7, err := smpkcs7.Parse(msg.p7.Content)
+	if err != nil {
+		return err
+	}
+	deg, err := e7.Decrypt((*smx509.Certificate)(cert), key)
+	if err != nil {
+		return err
+	}
+	certs, err := smpkcs7.Parse(deg)
+	if err != nil {
+		return err
+	}
+	if len(certs.Certificates) == 0 {
+		return errors.New("gmscep: no certificate in pkiEnvelope")
+	}
+	msg.CertRepMessage.Certificate = certs.Certificates[0].ToX509()
+	return nil
+}
+
+func smCertificates(certs []*x509.Certificate) []*smx509.Certificate {
+	smCerts := make([]*smx509.Certificate, 0, len(certs))
+	for _, crt := range certs {
+		smCerts = append(smCerts, (*smx509.Certificate)(crt))
+	}
+	return smCerts
+}
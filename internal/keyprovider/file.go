@@ -0,0 +1,68 @@
+package keyprovider
+
+import (
+	"crypto"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileProvider reads the key of CA name from the PEM file name.key in a
+// directory, the layout of the file depot.
+type FileProvider struct {
+	dir    string
+	passes map[string][]byte
+
+	mu      sync.Mutex
+	signers map[string]*fileSigner
+}
+
+// fileSigner is a parsed key and the state of the file it was read from.
+type fileSigner struct {
+	modTime time.Time
+	size    int64
+	signer  crypto.Signer
+}
+
+// NewFileProvider returns a FileProvider for the keys in dir. passes are the
+// passphrases of the encrypted keys by CA name.
+func NewFileProvider(dir string, passes map[string][]byte) *FileProvider {
+	return &FileProvider{dir: dir, passes: passes, signers: map[string]*fileSigner{}}
+}
+
+// Signer returns the key of CA name. Parsed keys are kept until their file
+// changes, as it does when a CA is rolled over.
+func (p *FileProvider) Signer(name string) (crypto.Signer, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, KeyNotFoundErr
+	}
+	path := filepath.Join(p.dir, name+".key")
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, KeyNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.signers[name]; ok && s.modTime.Equal(fi.ModTime()) && s.size == fi.Size() {
+		return s.signer, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, KeyNotFoundErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ParsePrivateKeyPEM(data, p.passes[name])
+	if err != nil {
+		return nil, err
+	}
+	p.signers[name] = &fileSigner{modTime: fi.ModTime(), size: fi.Size(), signer: signer}
+	return signer, nil
+}
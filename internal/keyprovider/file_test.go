@@ -0,0 +1,72 @@
+package keyprovider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/pkcs8"
+)
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ECC.key")
+	writeKey := func(modTime time.Time) *ecdsa.PrivateKey {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := pkcs8.MarshalPrivateKey(key, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	p := NewFileProvider(dir, nil)
+
+	key := writeKey(time.Now().Add(-time.Hour))
+	first, err := p.Signer("ECC")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	if !key.PublicKey.Equal(first.Public()) {
+		t.Fatalf("Signer() returned another key")
+	}
+	// Test the parsed key is kept while the file is unchanged
+	second, err := p.Signer("ECC")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	if second != first {
+		t.Errorf("Signer() parsed an unchanged key again")
+	}
+
+	// Test a replaced key, as after a rollover, is read again
+	key = writeKey(time.Now())
+	third, err := p.Signer("ECC")
+	if err != nil {
+		t.Fatalf("Signer() error = %v", err)
+	}
+	if !key.PublicKey.Equal(third.Public()) {
+		t.Errorf("Signer() returned the replaced key")
+	}
+
+	for _, name := range []string{"RSA", "", "../ECC"} {
+		if _, err := p.Signer(name); !errors.Is(err, KeyNotFoundErr) {
+			t.Errorf("Signer(%q) error = %v, want KeyNotFoundErr", name, err)
+		}
+	}
+}
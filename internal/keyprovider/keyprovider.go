@@ -0,0 +1,99 @@
+// Package keyprovider gives access to the private keys of the CAs as
+// crypto.Signers, wherever they are kept: in files, or in a signing daemon
+// reached over a Unix socket so that the keys stay out of the SCEP server.
+package keyprovider
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/emmansun/gmsm/pkcs8"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+var KeyNotFoundErr = errors.New("CA key not found")
+
+// Provider returns the private keys of the CAs by name, the CA type: RSA,
+// ECC or SM2. RSA and SM2 keys also implement crypto.Decrypter, which
+// opening the pkcsPKIEnvelope of a request requires.
+type Provider interface {
+	Signer(name string) (crypto.Signer, error)
+}
+
+// ToSigner returns key as a crypto.Signer, or an error if it cannot sign.
+func ToSigner(key interface{}) (crypto.Signer, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+const (
+	rsaPrivateKeyPEMBlockType            = "RSA PRIVATE KEY"
+	ecPrivateKeyPEMBlockType             = "EC PRIVATE KEY"
+	pkcs8PrivateKeyPEMBlockType          = "PRIVATE KEY"
+	encryptedPKCS8PrivateKeyPEMBlockType = "ENCRYPTED PRIVATE KEY"
+)
+
+// ParsePrivateKeyPEM parses PKCS#1 RSA or SEC 1 EC keys, optionally
+// encrypted with the legacy PEM headers, and PKCS#8 keys, optionally
+// encrypted with PBES2. RSA keys are returned as *rsa.PrivateKey, EC keys
+// as *ecdsa.PrivateKey, SM2 keys as *sm2.PrivateKey and Ed25519 keys as
+// ed25519.PrivateKey.
+func ParsePrivateKeyPEM(data []byte, password []byte) (crypto.Signer, error) {
+	pemBlock, _ := pem.Decode(data)
+	if pemBlock == nil {
+		return nil, errors.New("PEM decode failed")
+	}
+	der := pemBlock.Bytes
+	switch pemBlock.Type {
+	case rsaPrivateKeyPEMBlockType, ecPrivateKeyPEMBlockType:
+		if x509.IsEncryptedPEMBlock(pemBlock) {
+			b, err := x509.DecryptPEMBlock(pemBlock, password)
+			if err != nil {
+				return nil, err
+			}
+			der = b
+		}
+		if pemBlock.Type == rsaPrivateKeyPEMBlockType {
+			return x509.ParsePKCS1PrivateKey(der)
+		}
+		key, err := smx509.ParseTypedECPrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		return ToSigner(key)
+	case pkcs8PrivateKeyPEMBlockType:
+		return parsePKCS8Key(der, nil)
+	case encryptedPKCS8PrivateKeyPEMBlockType:
+		if len(password) == 0 {
+			return nil, errors.New("encrypted PKCS#8 key without a passphrase")
+		}
+		return parsePKCS8Key(der, password)
+	default:
+		return nil, errors.New("unmatched type or headers")
+	}
+}
+
+// parsePKCS8Key parses a PKCS#8 key, encrypted with PBES2 if password is
+// set, and rejects key types that cannot sign certificates.
+func parsePKCS8Key(der []byte, password []byte) (crypto.Signer, error) {
+	priv, err := pkcs8.ParsePKCS8PrivateKey(der, password)
+	if err != nil {
+		return nil, err
+	}
+	switch priv := priv.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, *sm2.PrivateKey, ed25519.PrivateKey:
+		return priv.(crypto.Signer), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+}
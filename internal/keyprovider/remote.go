@@ -0,0 +1,256 @@
+package keyprovider
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"time"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+)
+
+// DefaultRemoteTimeout bounds a remote key operation, dialing included,
+// when no timeout is configured.
+const DefaultRemoteTimeout = 5 * time.Second
+
+// decryption schemes of DecryptArgs
+const (
+	schemePKCS1v15 = "pkcs1v15"
+	schemeOAEP     = "oaep"
+	// SM2 ciphertexts: in any encoding the key recognizes, ASN.1, or plain
+	// C1C2C3 as in legacy CFCA envelopes
+	schemeSM2      = "sm2"
+	schemeSM2ASN1  = "sm2-asn1"
+	schemeSM2Plain = "sm2-c1c2c3"
+)
+
+// PublicArgs asks the signing daemon for the public key of CA Name.
+type PublicArgs struct {
+	Name string
+}
+
+// PublicReply is the PKIX DER public key of a CA.
+type PublicReply struct {
+	PKIX []byte
+}
+
+// SignArgs asks the signing daemon to sign Digest with the key of CA Name.
+type SignArgs struct {
+	Name   string
+	Digest []byte
+	// Hash is the hash of the SignerOpts, 0 for Ed25519 and SM2.
+	Hash crypto.Hash
+	// PSS signs with RSA-PSS and PSSSaltLength.
+	PSS           bool
+	PSSSaltLength int
+	// SM2 signs the message, not a digest, with SM3 and the default user
+	// ID, as smx509 and the pkcs7 package do.
+	SM2 bool
+}
+
+// DecryptArgs asks the signing daemon to decrypt Ciphertext with the key of
+// CA Name.
+type DecryptArgs struct {
+	Name       string
+	Ciphertext []byte
+	Scheme     string
+	// OAEP parameters
+	Hash    crypto.Hash
+	MGFHash crypto.Hash
+	Label   []byte
+}
+
+// RemoteProvider provides keys held by a signing daemon, see Serve, that
+// listens on a Unix socket. The keys never leave the daemon, every
+// signature and decryption is a call to it.
+type RemoteProvider struct {
+	socket  string
+	timeout time.Duration
+}
+
+// NewRemoteProvider returns a RemoteProvider for the daemon listening on
+// socket. Each operation fails after timeout, DefaultRemoteTimeout if 0.
+func NewRemoteProvider(socket string, timeout time.Duration) *RemoteProvider {
+	if timeout <= 0 {
+		timeout = DefaultRemoteTimeout
+	}
+	return &RemoteProvider{socket: socket, timeout: timeout}
+}
+
+func (p *RemoteProvider) Signer(name string) (crypto.Signer, error) {
+	var reply PublicReply
+	if err := p.call("KeyService.Public", &PublicArgs{Name: name}, &reply); err != nil {
+		return nil, err
+	}
+	pub, err := smx509.ParsePKIXPublicKey(reply.PKIX)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteKey{p: p, name: name, pub: pub}, nil
+}
+
+// call runs method on a new connection to the daemon, so that the daemon
+// may be restarted without restarting the server.
+func (p *RemoteProvider) call(method string, args interface{}, reply interface{}) error {
+	conn, err := net.DialTimeout("unix", p.socket, p.timeout)
+	if err != nil {
+		return fmt.Errorf("remote signer: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return err
+	}
+	client := rpc.NewClient(conn)
+	defer client.Close()
+	if err := client.Call(method, args, reply); err != nil {
+		return fmt.Errorf("remote signer: %s: %w", method, err)
+	}
+	return nil
+}
+
+// remoteKey is a CA key held by the signing daemon.
+type remoteKey struct {
+	p    *RemoteProvider
+	name string
+	pub  crypto.PublicKey
+}
+
+func (k *remoteKey) Public() crypto.PublicKey {
+	return k.pub
+}
+
+func (k *remoteKey) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	args := &SignArgs{Name: k.name, Digest: digest}
+	switch o := opts.(type) {
+	case *sm2.SM2SignerOption:
+		args.SM2 = true
+	case *rsa.PSSOptions:
+		args.Hash, args.PSS, args.PSSSaltLength = o.Hash, true, o.SaltLength
+	case nil:
+	default:
+		args.Hash = o.HashFunc()
+	}
+	var sig []byte
+	if err := k.p.call("KeyService.Sign", args, &sig); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (k *remoteKey) Decrypt(_ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	args := &DecryptArgs{Name: k.name, Ciphertext: msg}
+	switch o := opts.(type) {
+	case *rsa.OAEPOptions:
+		args.Scheme, args.Hash, args.MGFHash, args.Label = schemeOAEP, o.Hash, o.MGFHash, o.Label
+	case *sm2.DecrypterOpts:
+		switch *o {
+		case *sm2.ASN1DecrypterOpts:
+			args.Scheme = schemeSM2ASN1
+		case *sm2.NewPlainDecrypterOpts(sm2.C1C2C3):
+			args.Scheme = schemeSM2Plain
+		default:
+			return nil, errors.New("remote signer: unsupported SM2 ciphertext encoding")
+		}
+	case *rsa.PKCS1v15DecryptOptions:
+		args.Scheme = schemePKCS1v15
+	case nil:
+		args.Scheme = schemePKCS1v15
+		if sm2.IsSM2PublicKey(k.pub) {
+			args.Scheme = schemeSM2
+		}
+	default:
+		return nil, fmt.Errorf("remote signer: unsupported decrypter options %T", opts)
+	}
+	var plaintext []byte
+	if err := k.p.call("KeyService.Decrypt", args, &plaintext); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// Serve answers the RemoteProviders connecting to l with the keys of p,
+// until l is closed.
+func Serve(l net.Listener, p Provider) error {
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("KeyService", &keyService{p: p}); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go srv.ServeConn(conn)
+	}
+}
+
+// keyService is the daemon side of a RemoteProvider.
+type keyService struct {
+	p Provider
+}
+
+func (s *keyService) Public(args *PublicArgs, reply *PublicReply) error {
+	key, err := s.p.Signer(args.Name)
+	if err != nil {
+		return err
+	}
+	der, err := smx509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+	reply.PKIX = der
+	return nil
+}
+
+func (s *keyService) Sign(args *SignArgs, sig *[]byte) error {
+	key, err := s.p.Signer(args.Name)
+	if err != nil {
+		return err
+	}
+	var opts crypto.SignerOpts = args.Hash
+	switch {
+	case args.SM2:
+		opts = sm2.DefaultSM2SignerOpts
+	case args.PSS:
+		opts = &rsa.PSSOptions{Hash: args.Hash, SaltLength: args.PSSSaltLength}
+	}
+	*sig, err = key.Sign(rand.Reader, args.Digest, opts)
+	return err
+}
+
+func (s *keyService) Decrypt(args *DecryptArgs, plaintext *[]byte) error {
+	key, err := s.p.Signer(args.Name)
+	if err != nil {
+		return err
+	}
+	decrypter, ok := key.(crypto.Decrypter)
+	if !ok {
+		return fmt.Errorf("%s key of type %T cannot decrypt", args.Name, key)
+	}
+	var opts crypto.DecrypterOpts
+	switch args.Scheme {
+	case schemePKCS1v15:
+		opts = &rsa.PKCS1v15DecryptOptions{}
+	case schemeOAEP:
+		opts = &rsa.OAEPOptions{Hash: args.Hash, MGFHash: args.MGFHash, Label: args.Label}
+	case schemeSM2:
+		// no options, the key recognizes the encoding
+	case schemeSM2ASN1:
+		opts = sm2.ASN1DecrypterOpts
+	case schemeSM2Plain:
+		opts = sm2.NewPlainDecrypterOpts(sm2.C1C2C3)
+	default:
+		return fmt.Errorf("unknown decryption scheme %q", args.Scheme)
+	}
+	*plaintext, err = decrypter.Decrypt(rand.Reader, args.Ciphertext, opts)
+	return err
+}
@@ -0,0 +1,178 @@
+package keyprovider
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emmansun/gmsm/pkcs8"
+	"github.com/emmansun/gmsm/sm2"
+)
+
+// serveKeys writes the keys to a directory, serves them on a Unix socket
+// and returns a RemoteProvider for them.
+func serveKeys(t *testing.T, keys map[string]interface{}, passes map[string][]byte) *RemoteProvider {
+	t.Helper()
+	dir := t.TempDir()
+	for name, key := range keys {
+		der, err := pkcs8.MarshalPrivateKey(key, passes[name], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ := "PRIVATE KEY"
+		if passes[name] != nil {
+			typ = "ENCRYPTED PRIVATE KEY"
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, name+".key"), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	socket := filepath.Join(dir, "signer.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- Serve(ln, NewFileProvider(dir, passes)) }()
+	t.Cleanup(func() {
+		ln.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	})
+	return NewRemoteProvider(socket, time.Second)
+}
+
+func TestRemoteProvider(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm2Key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := serveKeys(t,
+		map[string]interface{}{"RSA": rsaKey, "ECC": ecKey, "SM2": sm2Key},
+		map[string][]byte{"ECC": []byte("secret")})
+
+	signer := func(name string) crypto.Signer {
+		t.Helper()
+		key, err := p.Signer(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return key
+	}
+	msg := []byte("pkiMessage")
+	digest := sha256.Sum256(msg)
+
+	t.Run("RSA", func(t *testing.T) {
+		key := signer("RSA")
+		if !rsaKey.PublicKey.Equal(key.Public()) {
+			t.Fatal("public key mismatch")
+		}
+		sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			t.Fatal(err)
+		}
+		pss := &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}
+		if sig, err = key.Sign(rand.Reader, digest[:], pss); err != nil {
+			t.Fatal(err)
+		}
+		if err := rsa.VerifyPSS(&rsaKey.PublicKey, crypto.SHA256, digest[:], sig, pss); err != nil {
+			t.Fatal(err)
+		}
+
+		decrypter := key.(crypto.Decrypter)
+		ct, err := rsa.EncryptPKCS1v15(rand.Reader, &rsaKey.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err := decrypter.Decrypt(rand.Reader, ct, nil)
+		if err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("PKCS #1 v1.5: got %q, %v", pt, err)
+		}
+		ct, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, &rsaKey.PublicKey, msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pt, err = decrypter.Decrypt(rand.Reader, ct, &rsa.OAEPOptions{Hash: crypto.SHA256})
+		if err != nil || !bytes.Equal(pt, msg) {
+			t.Fatalf("OAEP: got %q, %v", pt, err)
+		}
+	})
+
+	t.Run("ECC", func(t *testing.T) {
+		key := signer("ECC")
+		sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], sig) {
+			t.Fatal("invalid ECDSA signature")
+		}
+	})
+
+	t.Run("SM2", func(t *testing.T) {
+		key := signer("SM2")
+		if !sm2.IsSM2PublicKey(key.Public()) {
+			t.Fatalf("public key of type %T", key.Public())
+		}
+		sig, err := key.Sign(rand.Reader, msg, sm2.DefaultSM2SignerOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sm2.VerifyASN1WithSM2(&sm2Key.PublicKey, nil, msg, sig) {
+			t.Fatal("invalid SM2 signature")
+		}
+
+		decrypter := key.(crypto.Decrypter)
+		ct, err := sm2.EncryptASN1(rand.Reader, &sm2Key.PublicKey, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, opts := range []crypto.DecrypterOpts{nil, sm2.ASN1DecrypterOpts} {
+			pt, err := decrypter.Decrypt(rand.Reader, ct, opts)
+			if err != nil || !bytes.Equal(pt, msg) {
+				t.Fatalf("%v: got %q, %v", opts, pt, err)
+			}
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		for _, name := range []string{"DSA", "../RSA"} {
+			_, err := p.Signer(name)
+			if err == nil || !strings.Contains(err.Error(), KeyNotFoundErr.Error()) {
+				t.Errorf("%s: got %v, want %v", name, err, KeyNotFoundErr)
+			}
+		}
+	})
+}
+
+func TestRemoteProvider_DaemonDown(t *testing.T) {
+	p := NewRemoteProvider(filepath.Join(t.TempDir(), "signer.sock"), time.Second)
+	if _, err := p.Signer("RSA"); err == nil || errors.Is(err, KeyNotFoundErr) {
+		t.Fatalf("got %v, want a dial error", err)
+	}
+}
@@ -1,21 +1,39 @@
 package server
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"kscep/internal/biz"
 	"kscep/internal/conf"
 	"kscep/internal/service"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-kratos/kratos/v2/log"
 	"github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// DefaultMetricsPath is where the metrics are served when metrics.path is
+// not set.
+const DefaultMetricsPath = "/metrics"
+
 // NewGinhttpServer new an Gin HTTP server.
 func NewGinhttpServer(c *conf.Server,
 	logger log.Logger,
 	hwService *service.HelloWorldService,
 	secpSerivce *service.SCEPService,
-) *http.Server {
+	revocationService *service.RevocationService,
+	ocspService *service.OCSPService,
+	certificateService *service.CertificateService,
+	estService *service.ESTService,
+	tlsUc *biz.ServerTLSUsecase,
+	metrics *biz.Metrics,
+) (*http.Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(GinLogger(logger))
@@ -24,13 +42,110 @@ func NewGinhttpServer(c *conf.Server,
 	{
 		hwService.RegisterServiceRouter(apiv1)
 		secpSerivce.RegisterServiceRouter(apiv1)
+		revocationService.RegisterServiceRouter(apiv1)
+		ocspService.RegisterServiceRouter(apiv1)
+	}
+	adminAuth := AdminAuth(c.Admin, tlsUc, logger)
+	// Prometheus
+	if mc := c.GetMetrics(); mc.GetEnabled() {
+		metricsPath := mc.GetPath()
+		if metricsPath == "" {
+			metricsPath = DefaultMetricsPath
+		}
+		handlers := []gin.HandlerFunc{gin.WrapH(promhttp.HandlerFor(metrics.Gatherer(), promhttp.HandlerOpts{
+			// the expiry gauges are left out if the depot cannot be read
+			ErrorHandling: promhttp.ContinueOnError,
+		}))}
+		if mc.GetAdminAuth() {
+			handlers = append([]gin.HandlerFunc{adminAuth}, handlers...)
+		}
+		router.GET(metricsPath, handlers...)
+	}
+	// EST (RFC 7030)
+	estService.RegisterServiceRouter(router.Group("/.well-known/est"))
+	// 管理接口
+	admin := apiv1.Group("/admin", adminAuth)
+	{
+		secpSerivce.RegisterAdminRouter(admin)
+		revocationService.RegisterAdminRouter(admin)
+		certificateService.RegisterAdminRouter(admin)
 	}
-	httpSrv := http.NewServer(
+	opts := []http.ServerOption{
 		http.Address(c.Http.Addr),
 		http.Timeout(c.Http.Timeout.AsDuration()),
-	)
+	}
+	if tc := c.Http.GetTls(); tc != nil {
+		tlsConf, err := newTLSConfig(tc, tlsUc, log.NewHelper(log.With(logger, "module", "server/tls")))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, http.TLSConfig(tlsConf))
+	}
+	httpSrv := http.NewServer(opts...)
 	httpSrv.HandlePrefix("/", router)
-	return httpSrv
+	return httpSrv, nil
+}
+
+// AdminAuth rejects requests that do not carry one of the configured admin
+// bearer tokens, or with client_cert a TLS client certificate accepted by
+// uc and on the admin allow-list: every enrolled device holds a certificate
+// of the CA. With neither configured the admin API is disabled.
+func AdminAuth(c *conf.Server_Admin, uc *biz.ServerTLSUsecase, logger log.Logger) gin.HandlerFunc {
+	l := log.NewHelper(logger)
+	tokens := c.GetTokens()
+	clientCert := c.GetClientCert() && (len(c.GetClientCertSubjects()) > 0 || len(c.GetClientCertFingerprints()) > 0)
+	if c.GetClientCert() && !clientCert {
+		l.Warn("admin client_cert is set without client_cert_subjects or client_cert_fingerprints, no client certificate is accepted")
+	}
+	if len(tokens) == 0 && !clientCert {
+		l.Warn("no admin token or client certificate configured, admin API is disabled")
+	}
+	fingerprints := make([]string, 0, len(c.GetClientCertFingerprints()))
+	for _, fp := range c.GetClientCertFingerprints() {
+		fingerprints = append(fingerprints, strings.ToLower(strings.ReplaceAll(fp, ":", "")))
+	}
+	return func(ctx *gin.Context) {
+		if tls := ctx.Request.TLS; clientCert && tls != nil && len(tls.PeerCertificates) > 0 {
+			err := uc.VerifyClient(ctx, tls.PeerCertificates)
+			if err == nil {
+				err = adminClient(tls.PeerCertificates[0], c.GetClientCertSubjects(), fingerprints)
+			}
+			if err == nil {
+				ctx.Next()
+				return
+			}
+			l.Warnf("rejected admin client certificate: %v", err)
+		}
+		token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if ok {
+			for _, t := range tokens {
+				if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+					ctx.Next()
+					return
+				}
+			}
+		}
+		ctx.Header("WWW-Authenticate", `Bearer realm="kscep admin"`)
+		ctx.AbortWithStatus(401)
+	}
+}
+
+// adminClient returns an error unless the subject of crt is one of
+// subjects, or its SHA-256 fingerprint one of fingerprints.
+func adminClient(crt *x509.Certificate, subjects, fingerprints []string) error {
+	for _, s := range subjects {
+		if s == crt.Subject.String() {
+			return nil
+		}
+	}
+	sum := sha256.Sum256(crt.Raw)
+	fp := hex.EncodeToString(sum[:])
+	for _, f := range fingerprints {
+		if subtle.ConstantTimeCompare([]byte(f), []byte(fp)) == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate %q (%s) is not an admin", crt.Subject, fp)
 }
 
 func GinLogger(logger log.Logger) gin.HandlerFunc {
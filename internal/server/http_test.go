@@ -0,0 +1,2357 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kscep/internal/biz"
+	"kscep/internal/client"
+	"kscep/internal/conf"
+	"kscep/internal/data"
+	"kscep/internal/gmscep"
+	"kscep/internal/keyprovider"
+	"kscep/internal/service"
+	"kscep/internal/utils"
+
+	"github.com/emmansun/gmsm/pkcs8"
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
+	"github.com/go-kratos/kratos/v2/log"
+	khttp "github.com/go-kratos/kratos/v2/transport/http"
+	"github.com/ploynomail/pkcs7"
+	"github.com/ploynomail/scep"
+	"github.com/ploynomail/scep/x509util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ocsp"
+	"google.golang.org/protobuf/types/known/durationpb"
+	_ "modernc.org/sqlite"
+)
+
+// writeCA stores a self-signed RSA CA as RSA.pem and RSA.key in dir.
+func writeCA(t *testing.T, dir string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kscep test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crtPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(dir, "RSA.pem"), crtPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "RSA.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestServer serves newTestHandler over plain HTTP.
+func newTestServer(t *testing.T, cs *conf.Server, cd *conf.Data) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(newTestHandler(t, cs, cd))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newTestHandler assembles the server the way wireApp does.
+func newTestHandler(t *testing.T, cs *conf.Server, cd *conf.Data) *khttp.Server {
+	t.Helper()
+	logger := log.DefaultLogger
+	d, cleanup, err := data.NewData(cd, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	caRepo, err := data.NewSCEPCARepo(cd, d, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caUc := biz.NewSCEPCAUsecase(caRepo, logger)
+	certUc := biz.NewCertificateUsecase(data.NewCertificateRepo(d, logger), caUc, logger)
+	metrics := biz.NewMetrics(cs, certUc, logger)
+	signerUc, err := biz.NewCSRSignerUsecase(cd, data.NewSigner(d, metrics, logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challengeUc := biz.NewChallengeUsecase(cd, data.NewChallengeRepo(d, logger), logger)
+	approvalUc := biz.NewApprovalUsecase(cd, data.NewPendingRepo(d, logger), signerUc, logger)
+	policy, err := biz.NewCSRPolicy(cd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocationUc := biz.NewRevocationUsecase(cd, data.NewRevocationRepo(d, logger), caUc, logger)
+	replayRepo, err := data.NewReplayRepo(cd, d, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayUc := biz.NewReplayUsecase(cd, replayRepo, logger)
+	caps, err := biz.NewCACaps(cd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scepUc := biz.NewSCEPUsecase(caUc, signerUc, challengeUc, approvalUc, policy, certUc, revocationUc, replayUc, caps, metrics, logger)
+	ocspUc, err := biz.NewOCSPUsecase(cd, caUc, data.NewCertificateRepo(d, logger), data.NewRevocationRepo(d, logger), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := NewGinhttpServer(cs, logger,
+		service.NewHelloWorldService(biz.NewHelloWorldUsecase(logger), logger),
+		service.NewSCEPService(scepUc, challengeUc, approvalUc, metrics, logger),
+		service.NewRevocationService(revocationUc, logger),
+		service.NewOCSPService(ocspUc, logger),
+		service.NewCertificateService(certUc, logger),
+		service.NewESTService(biz.NewESTUsecase(scepUc, logger), logger),
+		biz.NewServerTLSUsecase(scepUc, logger),
+		metrics,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// enroll requests a certificate for tmpl from the SCEP endpoint at url and
+// returns it along with the CA certificate.
+func enroll(t *testing.T, url string, tmpl x509.CertificateRequest, challenge string) (*x509.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, ca := sendCSR(t, url, scep.PKCSReq, tmpl, challenge, key, nil, key)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("pkiStatus = %v, failInfo %v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	return rep.CertRepMessage.Certificate, ca
+}
+
+// sendCSR sends a msgType pkiMessage with a CSR for tmpl and csrKey to the
+// SCEP endpoint at url. It is signed by signer and signerKey, or by a
+// self-signed certificate if signer is nil. It returns the CertRep, with the
+// certificate decrypted on SUCCESS, along with the CA certificate.
+func sendCSR(t *testing.T, url string, msgType scep.MessageType, tmpl x509.CertificateRequest, challenge string, csrKey *rsa.PrivateKey, signer *x509.Certificate, signerKey *rsa.PrivateKey) (*scep.PKIMessage, *x509.Certificate) {
+	t.Helper()
+	cas := getCACerts(t, url)
+	msg, signer := newCSRMessage(t, cas, msgType, tmpl, challenge, csrKey, signer, signerKey)
+	return pkiOperation(t, url, msg.Raw, cas, signer, signerKey), cas[0]
+}
+
+// getCACerts fetches the CA certificates of the SCEP endpoint at url.
+func getCACerts(t *testing.T, url string) []*x509.Certificate {
+	t.Helper()
+	cl, err := client.NewClient(url, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, num, err := cl.GetCACert(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetCACert() error = %v", err)
+	}
+	var caCerts []*x509.Certificate
+	if num > 1 {
+		caCerts, err = scep.CACerts(resp)
+	} else {
+		caCerts, err = x509.ParseCertificates(resp)
+	}
+	if err != nil {
+		t.Fatalf("failed to parse GetCACert response: %v", err)
+	}
+	return caCerts
+}
+
+// newCSRMessage builds the pkiMessage sent by sendCSR and returns it along
+// with the certificate it is signed with.
+func newCSRMessage(t *testing.T, caCerts []*x509.Certificate, msgType scep.MessageType, tmpl x509.CertificateRequest, challenge string, csrKey *rsa.PrivateKey, signer *x509.Certificate, signerKey *rsa.PrivateKey) (*scep.PKIMessage, *x509.Certificate) {
+	t.Helper()
+	csrDER, err := x509util.CreateCertificateRequest(rand.Reader, &x509util.CertificateRequest{
+		CertificateRequest: tmpl,
+		ChallengePassword:  challenge,
+	}, csrKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer == nil {
+		selfTmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      csr.Subject,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		}
+		selfDER, err := x509.CreateCertificate(rand.Reader, selfTmpl, selfTmpl, &signerKey.PublicKey, signerKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signer, err = x509.ParseCertificate(selfDER); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := scep.NewCSRRequest(csr, &scep.PKIMessage{
+		MessageType:   msgType,
+		Recipients:    caCerts,
+		SignerKey:     signerKey,
+		SignerCert:    signer,
+		CSRReqMessage: &scep.CSRReqMessage{ChallengePassword: challenge},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, signer
+}
+
+// pkiOperation posts the pkiMessage raw to the SCEP endpoint at url and
+// returns the CertRep, decrypted with signer and signerKey on SUCCESS.
+func pkiOperation(t *testing.T, url string, raw []byte, caCerts []*x509.Certificate, signer *x509.Certificate, signerKey *rsa.PrivateKey) *scep.PKIMessage {
+	t.Helper()
+	cl, err := client.NewClient(url, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBytes, err := cl.PKIOperation(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("PKIOperation() error = %v", err)
+	}
+	respMsg, err := scep.ParsePKIMessage(respBytes, scep.WithCACerts(caCerts))
+	if err != nil {
+		t.Fatalf("failed to parse CertRep: %v", err)
+	}
+	if respMsg.PKIStatus == scep.SUCCESS {
+		if err := respMsg.DecryptPKIEnvelope(signer, signerKey); err != nil {
+			t.Fatalf("failed to decrypt CertRep: %v", err)
+		}
+	}
+	return respMsg
+}
+
+func TestEnrollment(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	const validityDays = 30
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: validityDays},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+
+	before := time.Now()
+	crt, ca := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	if crt.Subject.CommonName != "device-1" {
+		t.Errorf("CommonName = %q, want device-1", crt.Subject.CommonName)
+	}
+	if err := crt.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+	if !crt.NotBefore.Before(before) {
+		t.Errorf("NotBefore = %v, want before the request at %v", crt.NotBefore, before)
+	}
+	wantNotAfter := before.AddDate(0, 0, validityDays)
+	if d := crt.NotAfter.Sub(wantNotAfter); d < -time.Minute || d > time.Minute {
+		t.Errorf("NotAfter = %v, want %v (validityDay %d)", crt.NotAfter, wantNotAfter, validityDays)
+	}
+}
+
+func TestEnrollment_Bolt(t *testing.T) {
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "bolt",
+		Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(t.TempDir(), "kscep.db"), CaOrganization: "kscep"},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+
+	crt, ca := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	if ca.Subject.Organization[0] != "kscep" {
+		t.Errorf("CA Organization = %v, want [kscep]", ca.Subject.Organization)
+	}
+	if err := crt.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+}
+
+func TestEnrollment_RemoteSigner(t *testing.T) {
+	dir, keyDir := t.TempDir(), t.TempDir()
+	writeCA(t, dir)
+	// the depot keeps the CA certificate, the signing daemon its key
+	if err := os.Rename(filepath.Join(dir, "RSA.key"), filepath.Join(keyDir, "RSA.key")); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(keyDir, "signer.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go keyprovider.Serve(ln, keyprovider.NewFileProvider(keyDir, nil))
+	t.Cleanup(func() { ln.Close() })
+
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+		KeyProvider:    &conf.Data_KeyProvider{Type: "remote", Socket: socket},
+	})
+	url := ts.URL + "/api/v1/scep"
+
+	crt, ca := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	if err := crt.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+
+	// a rollover replaces the CA in the depot, not the key of the daemon
+	nextDir := t.TempDir()
+	writeCA(t, nextDir)
+	data, err := os.ReadFile(filepath.Join(nextDir, "RSA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "RSA.pem"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := newCSRMessage(t, getCACerts(t, url), scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "secret", key, nil, key)
+	resp, err := http.Post(url+"?operation=PKIOperation", "application/x-pki-message", bytes.NewReader(msg.Raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Errorf("PKIOperation succeeded with the key of the previous CA")
+	}
+
+	// the next key staged with the daemon signs for the promoted CA
+	if err := os.Rename(filepath.Join(nextDir, "RSA.key"), filepath.Join(keyDir, "RSA.next.key")); err != nil {
+		t.Fatal(err)
+	}
+	crt, next := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "secret")
+	if next.Equal(ca) {
+		t.Fatalf("GetCACert returned the previous CA")
+	}
+	if err := crt.CheckSignatureFrom(next); err != nil {
+		t.Errorf("certificate not signed by the promoted CA: %v", err)
+	}
+}
+
+// writeSM2CA stores a self-signed SM2 CA as SM2.pem and SM2.key in dir.
+func writeSM2CA(t *testing.T, dir string) {
+	t.Helper()
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kscep test SM2 CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := smx509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := smx509.MarshalSM2PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, "SM2.pem"), utils.PemCert(der), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "SM2.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// enrollSM2 requests a certificate for an SM2 key from the SM2 CA of the
+// SCEP endpoint at url with GM/T 0089 messages. It returns the CertRep
+// along with the CA certificate.
+func enrollSM2(t *testing.T, url, challenge string) (*gmscep.PKIMessage, *x509.Certificate) {
+	t.Helper()
+	cl, err := client.NewClient(url, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, num, err := cl.GetCACert(context.Background(), "SM2")
+	if err != nil {
+		t.Fatalf("GetCACert() error = %v", err)
+	}
+	cas, err := gmscep.CACerts(resp, num)
+	if err != nil {
+		t.Fatalf("failed to parse GetCACert response: %v", err)
+	}
+
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrDER, err := gmscep.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "sm2-device"},
+	}, challenge, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := gmscep.ParseCertificateRequest(csrDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      csr.Subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	selfDER, err := smx509.CreateCertificate(rand.Reader, selfTmpl, selfTmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, err := gmscep.ParseCertificate(selfDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := gmscep.NewCSRRequest(csr, &scep.PKIMessage{
+		MessageType: scep.PKCSReq,
+		Recipients:  cas,
+		SignerKey:   key,
+		SignerCert:  self,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	respBytes, err := cl.PKIOperation(context.Background(), msg.Raw)
+	if err != nil {
+		t.Fatalf("PKIOperation() error = %v", err)
+	}
+	rep, err := gmscep.ParsePKIMessage(respBytes, cas)
+	if err != nil {
+		t.Fatalf("failed to parse CertRep: %v", err)
+	}
+	if rep.PKIStatus == scep.SUCCESS {
+		if err := rep.DecryptPKIEnvelope(self, key); err != nil {
+			t.Fatalf("failed to decrypt CertRep: %v", err)
+		}
+	}
+	return rep, cas[0]
+}
+
+func TestSM2Enrollment(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	writeSM2CA(t, dir)
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	url := ts.URL + "/api/v1/scep"
+
+	rep, ca := enrollSM2(t, url, "secret")
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("pkiStatus = %v, failInfo %v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	crt := rep.CertRepMessage.Certificate
+	if crt.Subject.CommonName != "sm2-device" {
+		t.Errorf("CommonName = %q, want sm2-device", crt.Subject.CommonName)
+	}
+	if crt.SignatureAlgorithm != smx509.SM2WithSM3 {
+		t.Errorf("SignatureAlgorithm = %v, want SM2-SM3", crt.SignatureAlgorithm)
+	}
+	if !sm2.IsSM2PublicKey(crt.PublicKey) {
+		t.Errorf("certificate public key is %T, want an SM2 key", crt.PublicKey)
+	}
+	if err := (*smx509.Certificate)(crt).CheckSignatureFrom((*smx509.Certificate)(ca)); err != nil {
+		t.Errorf("certificate not signed by the SM2 CA: %v", err)
+	}
+
+	if rep, _ := enrollSM2(t, url, "wrong"); rep.PKIStatus != scep.FAILURE {
+		t.Errorf("pkiStatus with a wrong challenge = %v, want FAILURE", rep.PKIStatus)
+	}
+
+	// RSA clients keep enrolling with the RSA CA
+	crt, rsaCA := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	if err := crt.CheckSignatureFrom(rsaCA); err != nil {
+		t.Errorf("certificate not signed by the RSA CA: %v", err)
+	}
+}
+
+func TestGetCACert_EncryptedECCKey(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kscep test ECC CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := pkcs8.MarshalPrivateKey(key, []byte("ecc secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, "ECC.pem"), utils.PemCert(der), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ECC.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	getCACert := func(capass map[string]string) int {
+		t.Helper()
+		ts := newTestServer(t, &conf.Server{
+			Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		}, &conf.Data{
+			DepotType:      "file",
+			Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+			RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+			Capass:         capass,
+		})
+		resp, err := http.Get(ts.URL + "/api/v1/scep?operation=GetCACert&message=ECC")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := getCACert(map[string]string{"ecc": "ecc secret"}); status != http.StatusOK {
+		t.Errorf("GetCACert(ECC) status = %d with the ECC passphrase, want 200", status)
+	}
+	if status := getCACert(map[string]string{"ECC": "wrong"}); status == http.StatusOK {
+		t.Error("GetCACert(ECC) succeeded with a wrong passphrase")
+	}
+	if status := getCACert(nil); status == http.StatusOK {
+		t.Error("GetCACert(ECC) succeeded without a passphrase")
+	}
+}
+
+func TestGetCACaps(t *testing.T) {
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "bolt",
+		Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(t.TempDir(), "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Caps:           map[string]*conf.Data_Caps{"SM2": {Caps: []string{"SM3", "SM4"}}},
+	})
+	getCaps := func(message string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/v1/scep?operation=GetCACaps&message=" + message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(body)
+	}
+
+	for _, message := range []string{"", "RSA"} {
+		status, caps := getCaps(message)
+		if status != http.StatusOK {
+			t.Fatalf("GetCACaps(%q) status = %d", message, status)
+		}
+		for _, want := range []string{"AES", "SHA-256", "POSTPKIOperation", "Renewal", "SCEPStandard"} {
+			if !strings.Contains(caps, want) {
+				t.Errorf("GetCACaps(%q) = %q, missing %s", message, caps, want)
+			}
+		}
+		if strings.Contains(caps, "GetNextCACert") || strings.Contains(caps, "SM4") {
+			t.Errorf("GetCACaps(%q) = %q, want no GetNextCACert or SM4", message, caps)
+		}
+	}
+	if _, caps := getCaps("SM2"); caps != "SM3\nSM4" {
+		t.Errorf("GetCACaps(SM2) = %q, want the configured SM3 and SM4", caps)
+	}
+	if status, _ := getCaps("DSA"); status == http.StatusOK {
+		t.Errorf("GetCACaps(DSA) succeeded, want an unsupported CA type")
+	}
+}
+
+func TestEnrollment_SQL(t *testing.T) {
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "sql",
+		Database:       &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(t.TempDir(), "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+
+	tmpl := x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}
+	first, ca := enroll(t, ts.URL+"/api/v1/scep", tmpl, "secret")
+	if err := first.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+	second, _ := enroll(t, ts.URL+"/api/v1/scep", tmpl, "secret")
+	if first.SerialNumber.Cmp(second.SerialNumber) == 0 {
+		t.Errorf("both enrollments got serial number %v", first.SerialNumber)
+	}
+}
+
+func TestEnrollment_Profile(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 365},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+		Profiles: map[string]*conf.Data_Profile{
+			"server": {
+				KeyUsage:    []string{"digital_signature", "key_encipherment"},
+				ExtKeyUsage: []string{"server_auth"},
+				ValidityDay: 90,
+				AllowedSan:  []string{"dns"},
+				Subject:     &conf.Data_Profile_Subject{Organization: "kscep"},
+			},
+		},
+	})
+
+	before := time.Now()
+	crt, _ := enroll(t, ts.URL+"/api/v1/scep/server", x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "www.example.com", Organization: []string{"someone else"}},
+		DNSNames: []string{"www.example.com"},
+	}, "secret")
+	if crt.KeyUsage != x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment {
+		t.Errorf("KeyUsage = %v, want digital_signature|key_encipherment", crt.KeyUsage)
+	}
+	if len(crt.ExtKeyUsage) != 1 || crt.ExtKeyUsage[0] != x509.ExtKeyUsageServerAuth {
+		t.Errorf("ExtKeyUsage = %v, want [server_auth]", crt.ExtKeyUsage)
+	}
+	if d := crt.NotAfter.Sub(before.AddDate(0, 0, 90)); d < -time.Minute || d > time.Minute {
+		t.Errorf("NotAfter = %v, want 90 days from now", crt.NotAfter)
+	}
+	if len(crt.Subject.Organization) != 1 || crt.Subject.Organization[0] != "kscep" {
+		t.Errorf("Organization = %v, want [kscep]", crt.Subject.Organization)
+	}
+	if len(crt.DNSNames) != 1 || crt.DNSNames[0] != "www.example.com" {
+		t.Errorf("DNSNames = %v, want [www.example.com]", crt.DNSNames)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v1/scep/unknown?operation=GetCACaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GetCACaps on unknown profile status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestDynamicChallenge(t *testing.T) {
+	ts := newTestServer(t, &conf.Server{
+		Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+	}, &conf.Data{
+		DepotType:      "sql",
+		Database:       &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(t.TempDir(), "kscep.sqlite")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Dynamic: true},
+	})
+	issue := func(token, body string) (int, service.IssueChallengeResponse) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/admin/scep/challenges", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var ch service.IssueChallengeResponse
+		if resp.StatusCode == http.StatusCreated {
+			if err := json.NewDecoder(resp.Body).Decode(&ch); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, ch
+	}
+
+	if code, _ := issue("", "{}"); code != http.StatusUnauthorized {
+		t.Errorf("issue without a token = %d, want 401", code)
+	}
+	if code, _ := issue("admin-token", `{"ttl":-1}`); code != http.StatusBadRequest {
+		t.Errorf("issue with a negative ttl = %d, want 400", code)
+	}
+	before := time.Now()
+	code, ch := issue("admin-token", `{"ttl":600,"common_name":"device-1"}`)
+	if code != http.StatusCreated {
+		t.Fatalf("issue status = %d, want 201", code)
+	}
+	if len(ch.Challenge) != 32 || ch.CommonName != "device-1" {
+		t.Errorf("issued challenge = %+v, want 32 hex characters bound to device-1", ch)
+	}
+	if d := ch.ExpiresAt.Sub(before.Add(10 * time.Minute)); d < -time.Minute || d > time.Minute {
+		t.Errorf("ExpiresAt = %v, want about 10 minutes from now", ch.ExpiresAt)
+	}
+
+	url := ts.URL + "/api/v1/scep"
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, _ := sendCSR(t, url, scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, ch.Challenge, key, nil, key)
+	if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadRequest {
+		t.Errorf("enrollment of another cn = %v/%v, want FAILURE/badRequest", rep.PKIStatus, rep.FailInfo)
+	}
+	crt, _ := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, ch.Challenge)
+	if crt.Subject.CommonName != "device-1" {
+		t.Errorf("CommonName = %q, want device-1", crt.Subject.CommonName)
+	}
+
+	// the challenge is used up by the enrollment
+	key, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, _ = sendCSR(t, url, scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, ch.Challenge, key, nil, key)
+	if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadRequest {
+		t.Errorf("second enrollment with the challenge = %v/%v, want FAILURE/badRequest", rep.PKIStatus, rep.FailInfo)
+	}
+}
+
+// certPoll sends a CertPoll for transactionID, signed by signer and
+// signerKey, to the SCEP endpoint at url and returns the CertRep.
+func certPoll(t *testing.T, url string, caCerts []*x509.Certificate, transactionID scep.TransactionID, subject []byte, signer *x509.Certificate, signerKey *rsa.PrivateKey) *scep.PKIMessage {
+	t.Helper()
+	ias, err := asn1.Marshal(struct {
+		Issuer  asn1.RawValue
+		Subject asn1.RawValue
+	}{asn1.RawValue{FullBytes: caCerts[0].RawSubject}, asn1.RawValue{FullBytes: subject}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := pkcs7.Encrypt(ias, caCerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := pkcs7.NewSignedData(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.AddCertificate(signer)
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	err = sd.AddSigner(signer, signerKey, pkcs7.SignerInfoConfig{ExtraSignedAttributes: []pkcs7.Attribute{
+		{Type: oidSCEPtransactionID, Value: transactionID},
+		{Type: oidSCEPmessageType, Value: scep.CertPoll},
+		{Type: oidSCEPsenderNonce, Value: scep.SenderNonce(nonce)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkiOperation(t, url, msg, caCerts, signer, signerKey)
+}
+
+func TestManualApproval(t *testing.T) {
+	ts := newTestServer(t, &conf.Server{
+		Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+	}, &conf.Data{
+		DepotType:      "sql",
+		Database:       &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(t.TempDir(), "kscep.sqlite")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+		ManualApproval: true,
+	})
+	url := ts.URL + "/api/v1/scep"
+	admin := func(method, path, body string) (int, service.PendingRequestView) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+"/api/v1/admin/scep"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer admin-token")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var view service.PendingRequestView
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, view
+	}
+	request := func(cn string) (*scep.PKIMessage, *x509.Certificate, *rsa.PrivateKey) {
+		t.Helper()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, self := newCSRMessage(t, getCACerts(t, url), scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}}, "secret", key, nil, key)
+		return msg, self, key
+	}
+	cas := getCACerts(t, url)
+
+	approved, self, key := request("device-1")
+	rep := pkiOperation(t, url, approved.Raw, cas, self, key)
+	if rep.PKIStatus != scep.PENDING {
+		t.Fatalf("PKCSReq = %v/%v, want PENDING", rep.PKIStatus, rep.FailInfo)
+	}
+	if rep := certPoll(t, url, cas, approved.TransactionID, self.RawSubject, self, key); rep.PKIStatus != scep.PENDING {
+		t.Fatalf("CertPoll before approval = %v/%v, want PENDING", rep.PKIStatus, rep.FailInfo)
+	}
+	id := biz.PendingRequestID(string(approved.TransactionID))
+	if code, view := admin(http.MethodGet, "/requests/"+id, ""); code != http.StatusOK || view.Status != "pending" || view.Subject != "CN=device-1" {
+		t.Fatalf("get request = %d %+v, want device-1 pending", code, view)
+	}
+	code, view := admin(http.MethodPost, "/requests/"+id+"/approve", "")
+	if code != http.StatusOK || view.Status != "issued" || view.Serial == "" {
+		t.Fatalf("approve = %d %+v, want issued", code, view)
+	}
+	if code, _ := admin(http.MethodPost, "/requests/"+id+"/approve", ""); code != http.StatusConflict {
+		t.Errorf("second approve = %d, want 409", code)
+	}
+	if code, _ := admin(http.MethodPost, "/requests/"+id+"/reject", ""); code != http.StatusConflict {
+		t.Errorf("reject of an issued request = %d, want 409", code)
+	}
+	rep = certPoll(t, url, cas, approved.TransactionID, self.RawSubject, self, key)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("CertPoll after approval = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	if crt := rep.CertRepMessage.Certificate; crt.Subject.CommonName != "device-1" || crt.SerialNumber.Text(16) != view.Serial {
+		t.Errorf("CertPoll returned %q serial %s, want device-1 serial %s", crt.Subject.CommonName, crt.SerialNumber.Text(16), view.Serial)
+	}
+
+	rejected, self, key := request("device-2")
+	if rep := pkiOperation(t, url, rejected.Raw, cas, self, key); rep.PKIStatus != scep.PENDING {
+		t.Fatalf("PKCSReq = %v/%v, want PENDING", rep.PKIStatus, rep.FailInfo)
+	}
+	id = biz.PendingRequestID(string(rejected.TransactionID))
+	if code, view := admin(http.MethodPost, "/requests/"+id+"/reject", `{"reason":"unknown device"}`); code != http.StatusOK || view.Status != "rejected" || view.Reason != "unknown device" {
+		t.Fatalf("reject = %d %+v, want rejected", code, view)
+	}
+	if code, _ := admin(http.MethodPost, "/requests/"+id+"/approve", ""); code != http.StatusConflict {
+		t.Errorf("approve of a rejected request = %d, want 409", code)
+	}
+	rep = certPoll(t, url, cas, rejected.TransactionID, self.RawSubject, self, key)
+	if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadRequest {
+		t.Errorf("CertPoll after rejection = %v/%v, want FAILURE/badRequest", rep.PKIStatus, rep.FailInfo)
+	}
+
+	if code, _ := admin(http.MethodPost, "/requests/ffff/approve", ""); code != http.StatusNotFound {
+		t.Errorf("approve of an unknown request = %d, want 404", code)
+	}
+	rep = certPoll(t, url, cas, "unknown", self.RawSubject, self, key)
+	if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadCertID {
+		t.Errorf("CertPoll of an unknown transaction = %v/%v, want FAILURE/badCertID", rep.PKIStatus, rep.FailInfo)
+	}
+}
+
+func TestRenewal(t *testing.T) {
+	newServer := func(allowRenewal int32) string {
+		ts := newTestServer(t, &conf.Server{
+			Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		}, &conf.Data{
+			DepotType:      "bolt",
+			Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(t.TempDir(), "kscep.db")},
+			RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30, AllowRenewal: allowRenewal},
+			Challenge:      &conf.Data_Challenge{Static: "secret"},
+			Policy:         &conf.Data_Policy{RenewalSubject: "cn"},
+		})
+		return ts.URL + "/api/v1/scep"
+	}
+	newKey := func() *rsa.PrivateKey {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	wantFailure := func(name string, rep *scep.PKIMessage, failInfo scep.FailInfo) {
+		t.Helper()
+		if rep.PKIStatus != scep.FAILURE || rep.FailInfo != failInfo {
+			t.Errorf("%s = %v/%v, want FAILURE/%v", name, rep.PKIStatus, rep.FailInfo, failInfo)
+		}
+	}
+
+	url := newServer(30)
+	subject := pkix.Name{CommonName: "device-1"}
+	oldKey := newKey()
+	rep, _ := sendCSR(t, url, scep.PKCSReq, x509.CertificateRequest{Subject: subject}, "secret", oldKey, nil, oldKey)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("enrollment = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	old := rep.CertRepMessage.Certificate
+
+	// a re-key without a challenge, with a subject that only matches by CN
+	// so that the depot does not supersede the old certificate on its own
+	newSubject := pkix.Name{CommonName: "device-1", Organization: []string{"example"}}
+	rekey := newKey()
+	rep, ca := sendCSR(t, url, scep.RenewalReq, x509.CertificateRequest{Subject: newSubject}, "", rekey, old, oldKey)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("renewal = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	renewed := rep.CertRepMessage.Certificate
+	if err := renewed.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("renewed certificate not signed by the CA: %v", err)
+	}
+	if !renewed.PublicKey.(*rsa.PublicKey).Equal(&rekey.PublicKey) {
+		t.Errorf("renewed certificate does not carry the new key")
+	}
+
+	rep, _ = sendCSR(t, url, scep.RenewalReq, x509.CertificateRequest{Subject: subject}, "", oldKey, old, oldKey)
+	wantFailure("renewal with the superseded certificate", rep, scep.BadCertID)
+	rep, _ = sendCSR(t, url, scep.RenewalReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "", newKey(), renewed, rekey)
+	wantFailure("renewal of another subject", rep, scep.BadRequest)
+	foreign := newKey()
+	rep, _ = sendCSR(t, url, scep.RenewalReq, x509.CertificateRequest{Subject: subject}, "secret", foreign, nil, foreign)
+	wantFailure("renewal with a self-signed certificate", rep, scep.BadCertID)
+
+	url = newServer(7)
+	rep, _ = sendCSR(t, url, scep.PKCSReq, x509.CertificateRequest{Subject: subject}, "secret", oldKey, nil, oldKey)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("enrollment = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	rep, _ = sendCSR(t, url, scep.RenewalReq, x509.CertificateRequest{Subject: subject}, "", oldKey, rep.CertRepMessage.Certificate, oldKey)
+	wantFailure("renewal outside the allowRenewal window", rep, scep.BadTime)
+}
+
+func TestReplay(t *testing.T) {
+	for _, depot := range []bool{false, true} {
+		t.Run(map[bool]string{false: "memory", true: "depot"}[depot], func(t *testing.T) {
+			cd := &conf.Data{
+				DepotType:      "sql",
+				Database:       &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(t.TempDir(), "kscep.sqlite")},
+				RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+				Challenge:      &conf.Data_Challenge{Static: "secret"},
+				Replay:         &conf.Data_Replay{Depot: depot},
+			}
+			cs := &conf.Server{Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)}}
+			url := newTestServer(t, cs, cd).URL + "/api/v1/scep"
+
+			cas := getCACerts(t, url)
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl := x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}
+			msg, self := newCSRMessage(t, cas, scep.PKCSReq, tmpl, "secret", key, nil, key)
+			rep := pkiOperation(t, url, msg.Raw, cas, self, key)
+			if rep.PKIStatus != scep.SUCCESS {
+				t.Fatalf("enrollment = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+			}
+			crt := rep.CertRepMessage.Certificate
+
+			rep = pkiOperation(t, url, msg.Raw, cas, self, key)
+			if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadRequest {
+				t.Errorf("replayed message = %v/%v, want FAILURE/badRequest", rep.PKIStatus, rep.FailInfo)
+			}
+
+			// the same key gives the same transactionID under a new nonce
+			again, self := newCSRMessage(t, cas, scep.PKCSReq, tmpl, "secret", key, nil, key)
+			if again.TransactionID != msg.TransactionID {
+				t.Fatalf("transactionID = %s, want %s", again.TransactionID, msg.TransactionID)
+			}
+			rep = pkiOperation(t, url, again.Raw, cas, self, key)
+			if rep.PKIStatus != scep.SUCCESS {
+				t.Fatalf("repeated transaction = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+			}
+			if !rep.CertRepMessage.Certificate.Equal(crt) {
+				t.Errorf("repeated transaction issued serial %v, want %v", rep.CertRepMessage.Certificate.SerialNumber, crt.SerialNumber)
+			}
+
+			if depot {
+				// a replica sharing the depot rejects the replay as well
+				replica := newTestServer(t, cs, cd).URL + "/api/v1/scep"
+				rep = pkiOperation(t, replica, again.Raw, cas, self, key)
+				if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadRequest {
+					t.Errorf("message replayed to a replica = %v/%v, want FAILURE/badRequest", rep.PKIStatus, rep.FailInfo)
+				}
+			}
+		})
+	}
+}
+
+func TestGetCACert_Chain(t *testing.T) {
+	issue := func(cn string, isCA bool, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+		t.Helper()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().AddDate(1, 0, 0),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt, key
+	}
+	root, rootKey := issue("kscep test root", true, nil, nil)
+	inter, interKey := issue("kscep test intermediate", true, root, rootKey)
+	ca, caKey := issue("kscep test CA", true, inter, interKey)
+	other, _ := issue("unrelated root", true, nil, nil)
+	leaf, _ := issue("leaf", false, root, rootKey)
+
+	dir, addl := t.TempDir(), t.TempDir()
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(caKey)})
+	bundle := append(append(utils.PemCert(root.Raw), utils.PemCert(other.Raw)...), utils.PemCert(leaf.Raw)...)
+	for name, data := range map[string][]byte{
+		filepath.Join(dir, "RSA.pem"):     utils.PemCert(ca.Raw),
+		filepath.Join(dir, "RSA.key"):     keyPEM,
+		filepath.Join(addl, "bundle.pem"): bundle,
+		filepath.Join(addl, "inter.der"):  inter.Raw,
+		filepath.Join(addl, "README"):     []byte("not a certificate"),
+	} {
+		if err := os.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: addl},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	url := ts.URL + "/api/v1/scep"
+
+	cas := getCACerts(t, url)
+	want := []*x509.Certificate{ca, inter, root}
+	if len(cas) != len(want) {
+		t.Fatalf("GetCACert returned %d certificates, want the CA, the intermediate and the root", len(cas))
+	}
+	for i := range want {
+		if !cas[i].Equal(want[i]) {
+			t.Errorf("GetCACert certificate %d = %s, want %s", i, cas[i].Subject, want[i].Subject)
+		}
+	}
+
+	crt, _ := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	if err := crt.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("certificate not signed by the CA: %v", err)
+	}
+}
+
+// writeRA issues an RA certificate from the CA written to dir by writeCA and
+// stores it as ra.pem and ra.key.
+func writeRA(t *testing.T, dir string) *x509.Certificate {
+	t.Helper()
+	ca, err := utils.LoadPEMCertFromFile(filepath.Join(dir, "RSA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	caKey, err := utils.LoadPEMKeyFromFile(filepath.Join(dir, "RSA.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		// clear of the serial numbers the depot hands out
+		SerialNumber: big.NewInt(1000),
+		Subject:      pkix.Name{CommonName: "kscep test RA"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(dir, "ra.pem"), utils.PemCert(der), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ra.key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestRAMode(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	ra := writeRA(t, dir)
+	cd := &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+		Ra: map[string]*conf.Data_Ra{
+			"RSA": {Cert: filepath.Join(dir, "ra.pem"), Key: filepath.Join(dir, "ra.key")},
+		},
+	}
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, cd)
+	url := ts.URL + "/api/v1/scep"
+
+	resp, err := http.Get(url + "?operation=GetCACert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != utils.CertChainHeader {
+		t.Errorf("GetCACert Content-Type = %q, want %q", ct, utils.CertChainHeader)
+	}
+	cas := getCACerts(t, url)
+	if len(cas) != 2 || !cas[1].Equal(ra) {
+		t.Fatalf("GetCACert returned %d certificates, want the CA and the RA", len(cas))
+	}
+
+	// encrypted to the RA alone, so only the RA key can decrypt it
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, self := newCSRMessage(t, []*x509.Certificate{ra}, scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret", key, nil, key)
+	rep := pkiOperation(t, url, msg.Raw, cas, self, key)
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("enrollment through the RA = %v/%v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	if err := rep.CertRepMessage.Certificate.CheckSignatureFrom(cas[0]); err != nil {
+		t.Errorf("certificate not issued by the CA: %v", err)
+	}
+	p7, err := pkcs7.Parse(rep.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := p7.GetOnlySigner(); signer == nil || !signer.Equal(ra) {
+		t.Errorf("CertRep not signed by the RA")
+	}
+
+	// a key that does not belong to the RA certificate is refused
+	cd.Ra["RSA"].Key = filepath.Join(dir, "RSA.key")
+	d, cleanup, err := data.NewData(cd, log.DefaultLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if _, err := data.NewSCEPCARepo(cd, d, log.DefaultLogger); err == nil {
+		t.Errorf("NewSCEPCARepo() accepted an RA key that does not match its certificate")
+	}
+}
+
+func TestRevocation_CRL(t *testing.T) {
+	for _, depotType := range []string{"file", "bolt", "sql"} {
+		t.Run(depotType, func(t *testing.T) {
+			dir := t.TempDir()
+			cd := &conf.Data{
+				DepotType:      depotType,
+				RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+				Challenge:      &conf.Data_Challenge{Static: "secret"},
+				Crl:            &conf.Data_Crl{NextUpdate: durationpb.New(time.Hour)},
+			}
+			switch depotType {
+			case "file":
+				writeCA(t, dir)
+				cd.Filedepot = &conf.Data_Filedepot{Capath: dir, Addlcapath: dir}
+			case "bolt":
+				cd.Boltdepot = &conf.Data_Boltdepot{Path: filepath.Join(dir, "kscep.db")}
+			case "sql":
+				cd.Database = &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(dir, "kscep.sqlite")}
+			}
+			ts := newTestServer(t, &conf.Server{
+				Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+				Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+			}, cd)
+
+			crt, ca := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+			revoke := func(serial, body string) int {
+				req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/admin/certificates/"+serial+"/revoke", strings.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer admin-token")
+				req.Header.Set("Content-Type", "application/json")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			serial := crt.SerialNumber.Text(16)
+			if code := revoke(serial, `{"reason":"no_such_reason"}`); code != http.StatusBadRequest {
+				t.Errorf("revoke with an unknown reason status = %d, want 400", code)
+			}
+			if code := revoke("ffff", `{"reason":"key_compromise"}`); code != http.StatusNotFound {
+				t.Errorf("revoke of an unknown serial status = %d, want 404", code)
+			}
+			if code := revoke(serial, `{"reason":"key_compromise"}`); code != http.StatusNoContent {
+				t.Fatalf("revoke status = %d, want 204", code)
+			}
+			if code := revoke(serial, `{"reason":"key_compromise"}`); code != http.StatusConflict {
+				t.Errorf("second revoke status = %d, want 409", code)
+			}
+
+			resp, err := http.Get(ts.URL + "/api/v1/crl/RSA.crl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "application/pkix-crl" {
+				t.Errorf("Content-Type = %q, want application/pkix-crl", ct)
+			}
+			der, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %v", err)
+			}
+			if err := crl.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("CRL not signed by the CA: %v", err)
+			}
+			if d := crl.NextUpdate.Sub(crl.ThisUpdate); d != time.Hour {
+				t.Errorf("CRL nextUpdate - thisUpdate = %v, want 1h", d)
+			}
+			if len(crl.RevokedCertificateEntries) != 1 {
+				t.Fatalf("CRL lists %d certificates, want 1", len(crl.RevokedCertificateEntries))
+			}
+			entry := crl.RevokedCertificateEntries[0]
+			if entry.SerialNumber.Cmp(crt.SerialNumber) != 0 || entry.ReasonCode != 1 {
+				t.Errorf("CRL entry = serial %v reason %d, want serial %v reason 1", entry.SerialNumber, entry.ReasonCode, crt.SerialNumber)
+			}
+		})
+	}
+}
+
+// SCEP attribute OIDs, see RFC 8894 3.2.1.
+var (
+	oidSCEPmessageType   = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidSCEPsenderNonce   = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidSCEPtransactionID = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+)
+
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// query sends a GetCert or GetCRL for the certificate issuer/serial to the
+// SCEP endpoint at url. It returns the CertRep and, for SUCCESS, its
+// decrypted degenerate PKCS#7.
+func query(t *testing.T, url string, msgType scep.MessageType, issuer []byte, serial *big.Int) (*scep.PKIMessage, *pkcs7.PKCS7) {
+	t.Helper()
+	ctx := context.Background()
+	cl, err := client.NewClient(url, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := cl.GetCACert(ctx, "")
+	if err != nil {
+		t.Fatalf("GetCACert() error = %v", err)
+	}
+	caCerts, err := x509.ParseCertificates(resp)
+	if err != nil {
+		t.Fatalf("failed to parse GetCACert response: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selfTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "relying party"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	selfDER, err := x509.CreateCertificate(rand.Reader, selfTmpl, selfTmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, err := x509.ParseCertificate(selfDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	ias, err := asn1.Marshal(issuerAndSerial{IssuerName: asn1.RawValue{FullBytes: issuer}, SerialNumber: serial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := pkcs7.Encrypt(ias, caCerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := pkcs7.NewSignedData(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.AddCertificate(self)
+	err = sd.AddSigner(self, key, pkcs7.SignerInfoConfig{ExtraSignedAttributes: []pkcs7.Attribute{
+		{Type: oidSCEPtransactionID, Value: scep.TransactionID("query-" + string(msgType))},
+		{Type: oidSCEPmessageType, Value: msgType},
+		{Type: oidSCEPsenderNonce, Value: scep.SenderNonce(nonce)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	respBytes, err := cl.PKIOperation(ctx, msg)
+	if err != nil {
+		t.Fatalf("PKIOperation() error = %v", err)
+	}
+	respMsg, err := scep.ParsePKIMessage(respBytes, scep.WithCACerts(caCerts))
+	if err != nil {
+		t.Fatalf("failed to parse CertRep: %v", err)
+	}
+	if respMsg.PKIStatus != scep.SUCCESS {
+		return respMsg, nil
+	}
+	p7, err := pkcs7.Parse(respMsg.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := pkcs7.Parse(p7.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deg, err := envelope.Decrypt(self, key)
+	if err != nil {
+		t.Fatalf("failed to decrypt CertRep: %v", err)
+	}
+	degP7, err := pkcs7.Parse(deg)
+	if err != nil {
+		t.Fatalf("failed to parse degenerate PKCS#7: %v", err)
+	}
+	return respMsg, degP7
+}
+
+func TestGetCertGetCRL(t *testing.T) {
+	for _, depotType := range []string{"file", "bolt", "sql"} {
+		t.Run(depotType, func(t *testing.T) {
+			dir := t.TempDir()
+			cd := &conf.Data{
+				DepotType:      depotType,
+				RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+				Challenge:      &conf.Data_Challenge{Static: "secret"},
+			}
+			switch depotType {
+			case "file":
+				writeCA(t, dir)
+				cd.Filedepot = &conf.Data_Filedepot{Capath: dir, Addlcapath: dir}
+			case "bolt":
+				cd.Boltdepot = &conf.Data_Boltdepot{Path: filepath.Join(dir, "kscep.db")}
+			case "sql":
+				cd.Database = &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(dir, "kscep.sqlite")}
+			}
+			ts := newTestServer(t, &conf.Server{
+				Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+			}, cd)
+			url := ts.URL + "/api/v1/scep"
+
+			crt, ca := enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+
+			rep, deg := query(t, url, scep.GetCert, crt.RawIssuer, crt.SerialNumber)
+			if rep.PKIStatus != scep.SUCCESS {
+				t.Fatalf("GetCert pkiStatus = %v, failInfo %v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+			}
+			if len(deg.Certificates) != 1 || !deg.Certificates[0].Equal(crt) {
+				t.Errorf("GetCert returned %d certificates, want the enrolled one", len(deg.Certificates))
+			}
+
+			rep, _ = query(t, url, scep.GetCert, crt.RawIssuer, big.NewInt(0xffff))
+			if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadCertID {
+				t.Errorf("GetCert of an unknown serial = %v/%v, want FAILURE/badCertID", rep.PKIStatus, rep.FailInfo)
+			}
+			rep, _ = query(t, url, scep.GetCert, crt.RawSubject, crt.SerialNumber)
+			if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadCertID {
+				t.Errorf("GetCert with the wrong issuer = %v/%v, want FAILURE/badCertID", rep.PKIStatus, rep.FailInfo)
+			}
+
+			rep, deg = query(t, url, scep.GetCRL, crt.RawIssuer, crt.SerialNumber)
+			if rep.PKIStatus != scep.SUCCESS {
+				t.Fatalf("GetCRL pkiStatus = %v, failInfo %v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+			}
+			if len(deg.CRLs) != 1 {
+				t.Fatalf("GetCRL returned %d CRLs, want 1", len(deg.CRLs))
+			}
+			der, err := asn1.Marshal(deg.CRLs[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %v", err)
+			}
+			if err := crl.CheckSignatureFrom(ca); err != nil {
+				t.Errorf("CRL not signed by the CA: %v", err)
+			}
+
+			rep, _ = query(t, url, scep.GetCRL, crt.RawSubject, crt.SerialNumber)
+			if rep.PKIStatus != scep.FAILURE || rep.FailInfo != scep.BadCertID {
+				t.Errorf("GetCRL for an unknown issuer = %v/%v, want FAILURE/badCertID", rep.PKIStatus, rep.FailInfo)
+			}
+		})
+	}
+}
+
+func TestOCSP(t *testing.T) {
+	dir := t.TempDir()
+	ts := newTestServer(t, &conf.Server{
+		Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+	}, &conf.Data{
+		DepotType:      "bolt",
+		Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(dir, "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	crt, ca := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	ocspReq, err := ocsp.CreateRequest(crt, ca, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(resp *http.Response, err error) *ocsp.Response {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/ocsp-response" {
+			t.Errorf("Content-Type = %q, want application/ocsp-response", ct)
+		}
+		der, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := ocsp.ParseResponseForCert(der, crt, ca)
+		if err != nil {
+			t.Fatalf("failed to parse OCSP response: %v", err)
+		}
+		return r
+	}
+	post := func() *ocsp.Response {
+		return check(http.Post(ts.URL+"/api/v1/ocsp", "application/ocsp-request", bytes.NewReader(ocspReq)))
+	}
+	get := func() *ocsp.Response {
+		return check(http.Get(ts.URL + "/api/v1/ocsp/" + url.PathEscape(base64.StdEncoding.EncodeToString(ocspReq))))
+	}
+
+	if r := post(); r.Status != ocsp.Good {
+		t.Errorf("POST status = %d, want good", r.Status)
+	}
+	if r := get(); r.Status != ocsp.Good {
+		t.Errorf("GET status = %d, want good", r.Status)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/admin/certificates/"+crt.SerialNumber.Text(16)+"/revoke", strings.NewReader(`{"reason":"key_compromise"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("revoke status = %d, want 204", resp.StatusCode)
+	}
+
+	// the cached good response must not outlive the revocation
+	if r := get(); r.Status != ocsp.Revoked || r.RevocationReason != ocsp.KeyCompromise {
+		t.Errorf("GET status after revocation = %d reason %d, want revoked key_compromise", r.Status, r.RevocationReason)
+	}
+	if r := post(); r.Status != ocsp.Revoked {
+		t.Errorf("POST status after revocation = %d, want revoked", r.Status)
+	}
+}
+
+func TestSM2RevocationCRLAndOCSP(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	writeSM2CA(t, dir)
+	ts := newTestServer(t, &conf.Server{
+		Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	rep, ca := enrollSM2(t, ts.URL+"/api/v1/scep", "secret")
+	if rep.PKIStatus != scep.SUCCESS {
+		t.Fatalf("pkiStatus = %v, failInfo %v, want SUCCESS", rep.PKIStatus, rep.FailInfo)
+	}
+	crt := rep.CertRepMessage.Certificate
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/admin/certificates/"+crt.SerialNumber.Text(16)+"/revoke", strings.NewReader(`{"reason":"key_compromise"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer admin-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("revoke status = %d, want 204", resp.StatusCode)
+	}
+	// crypto/x509 and x/crypto/ocsp cannot verify SM2 signatures
+	checkSM2Signature := func(what string, signed, signature []byte) {
+		t.Helper()
+		if err := (*smx509.Certificate)(ca).CheckSignature(smx509.SM2WithSM3, signed, signature); err != nil {
+			t.Errorf("%s not signed with SM2 by the SM2 CA: %v", what, err)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/api/v1/crl/SM2.crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CRL status = %d, want 200", resp.StatusCode)
+	}
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %v", err)
+	}
+	checkSM2Signature("CRL", crl.RawTBSRevocationList, crl.Signature)
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(crt.SerialNumber) != 0 {
+		t.Errorf("CRL lists %d certificates, want the revoked one", len(crl.RevokedCertificateEntries))
+	}
+
+	ocspReq, err := ocsp.CreateRequest(crt, ca, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Post(ts.URL+"/api/v1/ocsp", "application/ocsp-request", bytes.NewReader(ocspReq))
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ocsp.ParseResponse(der, nil)
+	if err != nil {
+		t.Fatalf("failed to parse OCSP response: %v", err)
+	}
+	checkSM2Signature("OCSP response", r.TBSResponseData, r.Signature)
+	if r.Status != ocsp.Revoked || r.SerialNumber.Cmp(crt.SerialNumber) != 0 {
+		t.Errorf("OCSP status = %d for %v, want revoked for %v", r.Status, r.SerialNumber, crt.SerialNumber)
+	}
+}
+
+func TestCertificateInventory(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	ts := newTestServer(t, &conf.Server{
+		Http:  &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin: &conf.Server_Admin{Tokens: []string{"admin-token"}},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	one, _ := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	two, _ := enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "secret")
+
+	admin := func(method, path string, body io.Reader) (int, []byte, http.Header) {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+"/api/v1/admin"+path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer admin-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, data, resp.Header
+	}
+	list := func(query string) service.CertificateListResponse {
+		t.Helper()
+		code, data, _ := admin(http.MethodGet, "/certificates?"+query, nil)
+		if code != http.StatusOK {
+			t.Fatalf("list %q status = %d: %s", query, code, data)
+		}
+		var resp service.CertificateListResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if code, _, _ := admin(http.MethodPost, "/certificates/"+two.SerialNumber.Text(16)+"/revoke", strings.NewReader(`{"reason":"superseded"}`)); code != http.StatusNoContent {
+		t.Fatalf("revoke status = %d, want 204", code)
+	}
+
+	if resp := list(""); resp.Total != 2 || len(resp.Certificates) != 2 {
+		t.Errorf("list returned %d of %d certificates, want 2", len(resp.Certificates), resp.Total)
+	}
+	resp := list("cn=device-1&ca_type=rsa")
+	if resp.Total != 1 || resp.Certificates[0].Serial != one.SerialNumber.Text(16) || resp.Certificates[0].CaType != "RSA" {
+		t.Errorf("list by cn = %+v, want device-1 of the RSA CA", resp)
+	}
+	resp = list("status=revoked")
+	if resp.Total != 1 || resp.Certificates[0].CommonName != "device-2" || resp.Certificates[0].RevocationReason != "superseded" {
+		t.Errorf("list by status = %+v, want device-2 revoked as superseded", resp)
+	}
+	if resp := list("serial=" + one.SerialNumber.Text(16)); resp.Total != 1 || resp.Certificates[0].CommonName != "device-1" {
+		t.Errorf("list by serial = %+v, want device-1", resp)
+	}
+	if resp := list("expiring_before=" + url.QueryEscape(time.Now().Format(time.RFC3339))); resp.Total != 0 {
+		t.Errorf("list expiring before now = %+v, want none", resp)
+	}
+	if resp := list("limit=1&offset=1"); resp.Total != 2 || len(resp.Certificates) != 1 || resp.Certificates[0].CommonName != "device-2" {
+		t.Errorf("second page = %+v, want device-2 of 2", resp)
+	}
+	if code, _, _ := admin(http.MethodGet, "/certificates?status=lost", nil); code != http.StatusBadRequest {
+		t.Errorf("list by an unknown status = %d, want 400", code)
+	}
+
+	code, data, header := admin(http.MethodGet, "/certificates/"+one.SerialNumber.Text(16), nil)
+	if code != http.StatusOK || header.Get("Content-Type") != "application/x-pem-file" {
+		t.Fatalf("get PEM = %d %q", code, header.Get("Content-Type"))
+	}
+	if block, _ := pem.Decode(data); block == nil || !bytes.Equal(block.Bytes, one.Raw) {
+		t.Errorf("get PEM did not return the certificate")
+	}
+	code, data, _ = admin(http.MethodGet, "/certificates/"+one.SerialNumber.Text(16)+"?format=der", nil)
+	if code != http.StatusOK || !bytes.Equal(data, one.Raw) {
+		t.Errorf("get DER = %d, did not return the certificate", code)
+	}
+	if code, _, _ := admin(http.MethodGet, "/certificates/ffff", nil); code != http.StatusNotFound {
+		t.Errorf("get of an unknown serial = %d, want 404", code)
+	}
+
+	code, data, _ = admin(http.MethodGet, "/certificates/counts", nil)
+	if code != http.StatusOK {
+		t.Fatalf("counts status = %d", code)
+	}
+	var counts service.CertificateCountsResponse
+	if err := json.Unmarshal(data, &counts); err != nil {
+		t.Fatal(err)
+	}
+	if counts.Total != 2 || counts.ByStatus["valid"] != 1 || counts.ByStatus["revoked"] != 1 || counts.ByCaType["RSA"] != 2 {
+		t.Errorf("counts = %+v, want 2 RSA certificates, 1 valid and 1 revoked", counts)
+	}
+
+	unauth, err := http.Get(ts.URL + "/api/v1/admin/certificates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unauth.Body.Close()
+	if unauth.StatusCode != http.StatusUnauthorized {
+		t.Errorf("list without a token = %d, want 401", unauth.StatusCode)
+	}
+}
+
+// estDo sends an EST request and returns the status and the base64 decoded
+// response body.
+func estDo(t *testing.T, client *http.Client, method, url string, body []byte, password string) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(base64.StdEncoding.EncodeToString(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/pkcs10")
+	if password != "" {
+		req.SetBasicAuth("device", password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, data
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		t.Fatalf("%s: %v", url, err)
+	}
+	return resp.StatusCode, decoded
+}
+
+func TestEST(t *testing.T) {
+	ts := httptest.NewUnstartedServer(newTestHandler(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "bolt",
+		Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(t.TempDir(), "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30, AllowRenewal: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+		Policy:         &conf.Data_Policy{RenewalSubject: "cn"},
+	}))
+	// client certificates are verified against the CA by the EST usecase
+	ts.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	est := ts.URL + "/.well-known/est"
+	client := ts.Client()
+
+	status, data := estDo(t, client, "GET", est+"/cacerts", nil, "")
+	if status != http.StatusOK {
+		t.Fatalf("cacerts status = %d, want 200", status)
+	}
+	p7, err := pkcs7.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := p7.Certificates[0]
+	if status, _ := estDo(t, client, "GET", est+"/DSA/cacerts", nil, ""); status != http.StatusNotFound {
+		t.Errorf("cacerts of an unknown label status = %d, want 404", status)
+	}
+	status, data = estDo(t, client, "GET", est+"/rsa/csrattrs", nil, "")
+	var oids []asn1.ObjectIdentifier
+	if status != http.StatusOK {
+		t.Errorf("csrattrs status = %d, want 200", status)
+	} else if _, err := asn1.Unmarshal(data, &oids); err != nil || len(oids) != 1 || !oids[0].Equal(asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}) {
+		t.Errorf("csrattrs = %v, %v, want [sha256WithRSAEncryption]", oids, err)
+	}
+
+	newCSR := func(subject pkix.Name) (*rsa.PrivateKey, []byte) {
+		t.Helper()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, der
+	}
+	issued := func(name string, data []byte) *x509.Certificate {
+		t.Helper()
+		p7, err := pkcs7.Parse(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		crt := p7.Certificates[0]
+		if err := crt.CheckSignatureFrom(ca); err != nil {
+			t.Fatalf("%s: certificate not signed by the CA: %v", name, err)
+		}
+		return crt
+	}
+
+	key, csr := newCSR(pkix.Name{CommonName: "est-device"})
+	for _, password := range []string{"", "wrong"} {
+		if status, _ := estDo(t, client, "POST", est+"/simpleenroll", csr, password); status != http.StatusUnauthorized {
+			t.Errorf("simpleenroll with password %q status = %d, want 401", password, status)
+		}
+	}
+	status, data = estDo(t, client, "POST", est+"/simpleenroll", csr, "secret")
+	if status != http.StatusOK {
+		t.Fatalf("simpleenroll status = %d (%s), want 200", status, data)
+	}
+	old := issued("simpleenroll", data)
+	if old.Subject.CommonName != "est-device" {
+		t.Errorf("CommonName = %q, want est-device", old.Subject.CommonName)
+	}
+
+	// a client certificate only vouches for its own subject and names
+	tr := client.Transport.(*http.Transport).Clone()
+	tr.TLSClientConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{old.Raw}, PrivateKey: key}}
+	certClient := &http.Client{Transport: tr}
+	_, otherCSR := newCSR(pkix.Name{CommonName: "other-device"})
+	if status, _ := estDo(t, certClient, "POST", est+"/simpleenroll", otherCSR, ""); status != http.StatusUnauthorized {
+		t.Errorf("simpleenroll of another subject with a client certificate status = %d, want 401", status)
+	}
+	sanCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  old.Subject,
+		DNSNames: []string{"other.example.com"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := estDo(t, certClient, "POST", est+"/simpleenroll", sanCSR, ""); status != http.StatusUnauthorized {
+		t.Errorf("simpleenroll of a name missing from the client certificate status = %d, want 401", status)
+	}
+
+	// reenrollment is authenticated by the TLS client certificate
+	rekeyKey, rekeyCSR := newCSR(pkix.Name{CommonName: "est-device", Organization: []string{"example"}})
+	if status, _ := estDo(t, client, "POST", est+"/simplereenroll", rekeyCSR, "secret"); status != http.StatusUnauthorized {
+		t.Errorf("simplereenroll without a client certificate status = %d, want 401", status)
+	}
+	status, data = estDo(t, certClient, "POST", est+"/simplereenroll", rekeyCSR, "")
+	if status != http.StatusOK {
+		t.Fatalf("simplereenroll status = %d (%s), want 200", status, data)
+	}
+	renewed := issued("simplereenroll", data)
+	if renewed.SerialNumber.Cmp(old.SerialNumber) == 0 {
+		t.Error("simplereenroll returned the old certificate")
+	}
+	// the renewed certificate has been superseded
+	if status, _ := estDo(t, certClient, "POST", est+"/simplereenroll", rekeyCSR, ""); status != http.StatusForbidden {
+		t.Errorf("simplereenroll with a superseded certificate status = %d, want 403", status)
+	}
+
+	// the current certificate enrolls its own subject without a password
+	tr = client.Transport.(*http.Transport).Clone()
+	tr.TLSClientConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{renewed.Raw}, PrivateKey: rekeyKey}}
+	_, sameCSR := newCSR(renewed.Subject)
+	if status, data := estDo(t, &http.Client{Transport: tr}, "POST", est+"/simpleenroll", sameCSR, ""); status != http.StatusOK {
+		t.Errorf("simpleenroll of the client certificate subject status = %d (%s), want 200", status, data)
+	}
+
+	// enrollment is refused outside TLS, the password would travel in the clear
+	plain := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, &conf.Data{
+		DepotType:      "bolt",
+		Boltdepot:      &conf.Data_Boltdepot{Path: filepath.Join(t.TempDir(), "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	if status, _ := estDo(t, http.DefaultClient, "POST", plain.URL+"/.well-known/est/simpleenroll", csr, "secret"); status != http.StatusForbidden {
+		t.Errorf("simpleenroll over plain HTTP status = %d, want 403", status)
+	}
+}
+
+func TestAdminClient(t *testing.T) {
+	crt := &x509.Certificate{Raw: []byte("operator certificate"), Subject: pkix.Name{CommonName: "operator", Organization: []string{"example"}}}
+	sum := sha256.Sum256(crt.Raw)
+	fp := hex.EncodeToString(sum[:])
+	for _, tt := range []struct {
+		name         string
+		subjects     []string
+		fingerprints []string
+		ok           bool
+	}{
+		{"subject", []string{"CN=operator,O=example"}, nil, true},
+		{"fingerprint", nil, []string{fp}, true},
+		{"other subject", []string{"CN=operator"}, nil, false},
+		{"other fingerprint", []string{"CN=admin"}, []string{strings.Repeat("0", 64)}, false},
+		{"empty", nil, nil, false},
+	} {
+		if err := adminClient(crt, tt.subjects, tt.fingerprints); (err == nil) != tt.ok {
+			t.Errorf("%s: adminClient() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	// check the files and the renewal on every handshake
+	checkInterval, renewInterval := certCheckInterval, renewCheckInterval
+	certCheckInterval, renewCheckInterval = 0, 0
+	t.Cleanup(func() { certCheckInterval, renewCheckInterval = checkInterval, renewInterval })
+	dir, tlsDir := t.TempDir(), t.TempDir()
+	writeCA(t, dir)
+	certFile, keyFile := filepath.Join(tlsDir, "tls.pem"), filepath.Join(tlsDir, "tls.key")
+	cs := &conf.Server{
+		Http: &conf.Server_HTTP{
+			Addr:    "127.0.0.1:0",
+			Timeout: durationpb.New(time.Second),
+			Tls: &conf.Server_HTTP_TLS{
+				Cert:       certFile,
+				Key:        keyFile,
+				MinVersion: "1.2",
+				Issue:      &conf.Server_HTTP_TLS_Issue{CommonName: "localhost"},
+			},
+		},
+		Admin: &conf.Server_Admin{ClientCert: true, ClientCertSubjects: []string{"CN=operator"}},
+	}
+	cd := &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	}
+	srv := newTestHandler(t, cs, cd)
+	ts := httptest.NewUnstartedServer(srv)
+	ts.TLS = srv.TLSConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	ca, err := utils.LoadPEMCertFromFile(filepath.Join(dir, "RSA.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued, err := utils.LoadPEMCertFromFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := issued.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("serving certificate not signed by the CA: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs},
+		}}
+	}
+	get := func(client *http.Client, path string) (int, *x509.Certificate) {
+		t.Helper()
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.TLS.PeerCertificates[0]
+	}
+	if _, served := get(newClient(), "/api/v1/scep?operation=GetCACaps"); !served.Equal(issued) {
+		t.Errorf("served certificate %s, want the issued %s", served.SerialNumber, issued.SerialNumber)
+	}
+
+	// the admin API accepts the allowed client certificates issued by the CA
+	if status, _ := get(newClient(), "/api/v1/admin/certificates"); status != http.StatusUnauthorized {
+		t.Errorf("admin without a client certificate status = %d, want 401", status)
+	}
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert := func(cn string) tls.Certificate {
+		t.Helper()
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, data := estDo(t, newClient(), "POST", ts.URL+"/.well-known/est/simpleenroll", csr, "secret")
+		if status != http.StatusOK {
+			t.Fatalf("simpleenroll status = %d (%s), want 200", status, data)
+		}
+		p7, err := pkcs7.Parse(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.Certificate{Certificate: [][]byte{p7.Certificates[0].Raw}, PrivateKey: key}
+	}
+	if status, _ := get(newClient(clientCert("operator")), "/api/v1/admin/certificates"); status != http.StatusOK {
+		t.Errorf("admin with an allowed client certificate of the CA status = %d, want 200", status)
+	}
+	if status, _ := get(newClient(clientCert("device-1")), "/api/v1/admin/certificates"); status != http.StatusUnauthorized {
+		t.Errorf("admin with the client certificate of an enrolled device status = %d, want 401", status)
+	}
+	selfSigned, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "operator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}, &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "operator"}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := get(newClient(tls.Certificate{Certificate: [][]byte{selfSigned}, PrivateKey: key}), "/api/v1/admin/certificates"); status != http.StatusUnauthorized {
+		t.Errorf("admin with a self-signed client certificate status = %d, want 401", status)
+	}
+
+	// a restart keeps the current serving certificate
+	newTestHandler(t, cs, cd)
+	if crt, err := utils.LoadPEMCertFromFile(certFile); err != nil || !crt.Equal(issued) {
+		t.Errorf("serving certificate reissued on restart: %v", err)
+	}
+
+	// a certificate rotated on disk is served without a restart
+	caKey, err := utils.LoadPEMKeyFromFile(filepath.Join(dir, "RSA.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotate := func(serial int64, notBefore, notAfter time.Time, mod time.Time) {
+		t.Helper()
+		rotatedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			DNSNames:     []string{"localhost"},
+			NotBefore:    notBefore,
+			NotAfter:     notAfter,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca, &rotatedKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(rotatedKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the key first, as a renewal may read the certificate meanwhile
+		for _, f := range []struct {
+			name string
+			data []byte
+		}{
+			{keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})},
+			{certFile, utils.PemCert(der)},
+		} {
+			if err := writeFileAtomic(f.name, f.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+			// file systems with a coarse mtime would otherwise hide the change
+			if err := os.Chtimes(f.name, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	rotate(4242, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), time.Now().Add(time.Minute))
+	if _, served := get(newClient(), "/api/v1/scep?operation=GetCACaps"); served.SerialNumber.Int64() != 4242 {
+		t.Errorf("served certificate %s after the rotation, want 4242", served.SerialNumber)
+	}
+
+	// a certificate past two thirds of its validity is renewed while serving
+	rotate(4343, time.Now().Add(-5*time.Hour), time.Now().Add(time.Hour), time.Now().Add(2*time.Minute))
+	rotated := func(crt *x509.Certificate) bool {
+		return crt.SerialNumber.Cmp(big.NewInt(4242)) == 0 || crt.SerialNumber.Cmp(big.NewInt(4343)) == 0
+	}
+	var served *x509.Certificate
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if _, served = get(newClient(), "/api/v1/scep?operation=GetCACaps"); !rotated(served) {
+			break
+		}
+	}
+	if rotated(served) {
+		t.Fatalf("served certificate %s, want a renewed one", served.SerialNumber)
+	}
+	if err := served.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("renewed serving certificate not signed by the CA: %v", err)
+	}
+}
+
+func TestTLS_RenewLongValidity(t *testing.T) {
+	dir, tlsDir := t.TempDir(), t.TempDir()
+	writeCA(t, dir)
+	certFile, keyFile := filepath.Join(tlsDir, "tls.pem"), filepath.Join(tlsDir, "tls.key")
+	cs := &conf.Server{
+		Http: &conf.Server_HTTP{
+			Addr:    "127.0.0.1:0",
+			Timeout: durationpb.New(time.Second),
+			Tls: &conf.Server_HTTP_TLS{
+				Cert:  certFile,
+				Key:   keyFile,
+				Issue: &conf.Server_HTTP_TLS_Issue{CommonName: "localhost", ValidityDay: 365},
+			},
+		},
+	}
+	cd := &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30, AllowRenewal: 30},
+	}
+	newTestHandler(t, cs, cd)
+	issued, err := utils.LoadPEMCertFromFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := issued.NotAfter.Sub(issued.NotBefore) / (24 * time.Hour); days < 365 {
+		t.Fatalf("serving certificate valid for %d days, want 365", days)
+	}
+
+	// the serving certificate is reissued long before the renewal window
+	// of enrolled certificates, while the previous one is in the depot
+	for _, name := range []string{certFile, keyFile} {
+		if err := os.Remove(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newTestHandler(t, cs, cd)
+	renewed, err := utils.LoadPEMCertFromFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Equal(issued) {
+		t.Error("serving certificate not reissued")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.pem"), filepath.Join(dir, "tls.key")
+	write := func(serial int64, mod time.Time) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "localhost"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(certFile, utils.PemCert(der), 0600); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{keyFile, certFile} {
+			if err := os.Chtimes(name, mod, mod); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	serial := func(c *tls.Certificate) int64 {
+		t.Helper()
+		crt, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crt.SerialNumber.Int64()
+	}
+	write(1, time.Now().Add(-time.Minute))
+	r, err := newCertReloader(certFile, keyFile, nil, log.NewHelper(log.DefaultLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Test the files are not checked again within certCheckInterval
+	write(2, time.Now())
+	c, _ := r.GetCertificate(nil)
+	if n := serial(c); n != 1 {
+		t.Errorf("served serial %d right after the rotation, want 1", n)
+	}
+	r.checked = r.checked.Add(-certCheckInterval)
+	c, _ = r.GetCertificate(nil)
+	if n := serial(c); n != 2 {
+		t.Errorf("served serial %d after certCheckInterval, want 2", n)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	writeCA(t, dir)
+	ts := newTestServer(t, &conf.Server{
+		Http:    &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Admin:   &conf.Server_Admin{Tokens: []string{"admin-token"}},
+		Metrics: &conf.Server_Metrics{Enabled: true, AdminAuth: true, Path: "/internal/metrics", ExpiringDays: 60},
+	}, &conf.Data{
+		DepotType:      "file",
+		Filedepot:      &conf.Data_Filedepot{Capath: dir, Addlcapath: dir},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	})
+	url := ts.URL + "/api/v1/scep"
+
+	enroll(t, url, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep, _ := sendCSR(t, url, scep.PKCSReq, x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "wrong", key, nil, key)
+	if rep.PKIStatus != scep.FAILURE {
+		t.Fatalf("pkiStatus = %v with a wrong challenge, want FAILURE", rep.PKIStatus)
+	}
+	for _, u := range []string{url + "?operation=Bogus", url + "?operation=PKIOperation"} {
+		resp, err := http.Post(u, "application/x-pki-message", strings.NewReader("junk"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if status, _ := scrape(t, ts.URL+"/internal/metrics", ""); status != http.StatusUnauthorized {
+		t.Errorf("metrics without a token status = %d, want 401", status)
+	}
+	status, body := scrape(t, ts.URL+"/internal/metrics", "admin-token")
+	if status != http.StatusOK {
+		t.Fatalf("metrics status = %d, want 200", status)
+	}
+	for _, want := range []string{
+		`kscep_scep_requests_total{code="200",operation="PKIOperation"} 2`,
+		`kscep_scep_requests_total{code="400",operation="PKIOperation"} 1`,
+		`kscep_scep_requests_total{code="400",operation="unknown"} 1`,
+		`kscep_scep_requests_total{code="200",operation="GetCACert"} 2`,
+		`kscep_scep_pki_operations_total{fail_info="",message_type="PKCSReq",outcome="success"} 1`,
+		`kscep_scep_pki_operations_total{fail_info="badRequest",message_type="PKCSReq",outcome="failure"} 1`,
+		`kscep_scep_pki_operations_total{fail_info="",message_type="unknown",outcome="error"} 1`,
+		`kscep_scep_pki_operation_phase_seconds_count{phase="decrypt"} 2`,
+		`kscep_scep_pki_operation_phase_seconds_count{phase="sign"} 1`,
+		`kscep_scep_pki_operation_phase_seconds_count{phase="depot_write"} 1`,
+		`kscep_certificates_expiring{ca_type="RSA"} 1`,
+		`kscep_ca_certificate_expiry_timestamp_seconds{ca_type="RSA"} `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+}
+
+func TestMetrics_SQL(t *testing.T) {
+	cd := &conf.Data{
+		DepotType:      "sql",
+		Database:       &conf.Data_Database{Driver: "sqlite", Source: filepath.Join(t.TempDir(), "kscep.db")},
+		RSAsigerconfig: &conf.Data_RSASigerConfig{ValidityDay: 30},
+		Challenge:      &conf.Data_Challenge{Static: "secret"},
+	}
+	// Test the metrics are only served when enabled
+	ts := newTestServer(t, &conf.Server{
+		Http: &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+	}, cd)
+	if status, _ := scrape(t, ts.URL+DefaultMetricsPath, ""); status != http.StatusNotFound {
+		t.Errorf("metrics status = %d when not enabled, want 404", status)
+	}
+
+	ts = newTestServer(t, &conf.Server{
+		Http:    &conf.Server_HTTP{Addr: "127.0.0.1:0", Timeout: durationpb.New(time.Second)},
+		Metrics: &conf.Server_Metrics{Enabled: true, ExpiringDays: 60},
+	}, cd)
+	enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}, "secret")
+	enroll(t, ts.URL+"/api/v1/scep", x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-2"}}, "secret")
+	status, body := scrape(t, ts.URL+DefaultMetricsPath, "")
+	if status != http.StatusOK {
+		t.Fatalf("metrics status = %d, want 200", status)
+	}
+	if want := `kscep_certificates_expiring{ca_type="RSA"} 2`; !strings.Contains(body, want) {
+		t.Errorf("metrics lack %s", want)
+	}
+}
+
+// scrape gets the metrics at url, with token as the bearer token if set.
+func scrape(t *testing.T, url, token string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
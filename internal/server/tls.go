@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"kscep/internal/biz"
+	"kscep/internal/conf"
+	"kscep/internal/utils"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig returns the configuration of the HTTPS listener, issuing the
+// serving certificate first if c asks for it.
+func newTLSConfig(c *conf.Server_HTTP_TLS, uc *biz.ServerTLSUsecase, l *log.Helper) (*tls.Config, error) {
+	if c.GetCert() == "" || c.GetKey() == "" {
+		return nil, fmt.Errorf("tls: cert and key are required")
+	}
+	minVersion := uint16(tls.VersionTLS12)
+	if v := c.GetMinVersion(); v != "" {
+		var ok bool
+		if minVersion, ok = tlsVersions[v]; !ok {
+			return nil, fmt.Errorf("tls: unknown min_version %q", v)
+		}
+	}
+	var suites []uint16
+	if names := c.GetCipherSuites(); len(names) > 0 {
+		known := map[string]uint16{}
+		for _, s := range tls.CipherSuites() {
+			known[s.Name] = s.ID
+		}
+		for _, name := range names {
+			id, ok := known[name]
+			if !ok {
+				return nil, fmt.Errorf("tls: unknown or insecure cipher suite %q", name)
+			}
+			suites = append(suites, id)
+		}
+	}
+	var renew func() error
+	if ic := c.GetIssue(); ic != nil {
+		renew = func() error {
+			return issueServingCert(context.Background(), c, ic, uc, l)
+		}
+		if err := renew(); err != nil {
+			return nil, err
+		}
+	}
+	r, err := newCertReloader(c.GetCert(), c.GetKey(), renew, l)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: r.GetCertificate,
+		// EST reenrollment and the admin API verify client certificates
+		// against the CA themselves
+		ClientAuth: tls.RequestClientCert,
+	}, nil
+}
+
+// issueServingCert issues the serving certificate and key into the files of
+// c unless they already hold a current certificate of the CA.
+func issueServingCert(ctx context.Context, c *conf.Server_HTTP_TLS, ic *conf.Server_HTTP_TLS_Issue, uc *biz.ServerTLSUsecase, l *log.Helper) error {
+	t, err := biz.ServingCertCaType(ic.GetCa())
+	if err != nil {
+		return err
+	}
+	if crt, err := utils.LoadPEMCertFromFile(c.GetCert()); err == nil && uc.ServingCertCurrent(t, crt) {
+		return nil
+	}
+	req := &biz.ServingCertRequest{
+		CaType:       t,
+		CommonName:   ic.GetCommonName(),
+		DNSNames:     ic.GetDnsNames(),
+		ValidityDays: int(ic.GetValidityDay()),
+	}
+	for _, s := range ic.GetIpAddresses() {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return fmt.Errorf("tls issue: invalid IP address %q", s)
+		}
+		req.IPAddresses = append(req.IPAddresses, ip)
+	}
+	chain, key, err := uc.IssueServingCert(ctx, req)
+	if err != nil {
+		return fmt.Errorf("tls issue: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	var certPEM bytes.Buffer
+	for _, crt := range chain {
+		certPEM.Write(utils.PemCert(crt.Raw))
+	}
+	// the key first, the reloader waits for a pair that matches
+	if err := writeFileAtomic(c.GetKey(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(c.GetCert(), certPEM.Bytes(), 0644); err != nil {
+		return err
+	}
+	l.Infof("wrote serving certificate %s to %s", chain[0].SerialNumber.Text(16), c.GetCert())
+	return nil
+}
+
+// writeFileAtomic replaces name with data, so that it is never seen half
+// written.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// certCheckInterval is how often the files of the serving certificate are
+// checked for changes, renewCheckInterval how often a serving certificate
+// kscep issues itself is checked for renewal.
+var (
+	certCheckInterval  = 10 * time.Second
+	renewCheckInterval = time.Hour
+)
+
+// certReloader serves the certificate of a cert and key file pair and
+// reloads it when either file changes on disk, so that a rotated
+// certificate is picked up without a restart.
+type certReloader struct {
+	certFile, keyFile string
+	// renew reissues the pair if it is due, nil unless kscep issues it
+	renew func() error
+	log   *log.Helper
+
+	mu           sync.Mutex
+	cert         *tls.Certificate
+	certMod      time.Time
+	keyMod       time.Time
+	checked      time.Time
+	renewChecked time.Time
+	renewing     bool
+}
+
+func newCertReloader(certFile, keyFile string, renew func() error, l *log.Helper) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, renew: renew, log: l}
+	if err := r.reload(); err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	r.checked, r.renewChecked = time.Now(), time.Now()
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.renew != nil && !r.renewing && now.Sub(r.renewChecked) >= renewCheckInterval {
+		r.renewChecked, r.renewing = now, true
+		go r.renewCert()
+	}
+	if now.Sub(r.checked) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = now
+	if err := r.reload(); err != nil {
+		// a pair half way through its rotation, retried on the next
+		// handshake
+		r.log.Errorf("keeping the current serving certificate: %v", err)
+	}
+	return r.cert, nil
+}
+
+// renewCert reissues the pair off the handshake, which loads it on the next
+// check.
+func (r *certReloader) renewCert() {
+	err := r.renew()
+	if err != nil {
+		r.log.Errorf("failed to renew the serving certificate: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.renewing = false
+	if err == nil {
+		r.checked = time.Time{}
+	}
+}
+
+// reload loads the pair if either file was modified since it was last
+// loaded.
+func (r *certReloader) reload() error {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return err
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && ci.ModTime().Equal(r.certMod) && ki.ModTime().Equal(r.keyMod) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil {
+		r.log.Infof("reloaded the serving certificate from %s", r.certFile)
+	}
+	r.cert, r.certMod, r.keyMod = &cert, ci.ModTime(), ki.ModTime()
+	return nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"errors"
+	"kscep/internal/biz"
+	"kscep/internal/utils"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// maxListLimit caps the page size of the certificate list.
+const maxListLimit = 1000
+
+type CertificateService struct {
+	uc  *biz.CertificateUsecase
+	log *log.Helper
+}
+
+func NewCertificateService(uc *biz.CertificateUsecase, logger log.Logger) *CertificateService {
+	return &CertificateService{
+		uc:  uc,
+		log: log.NewHelper(log.With(logger, "module", "service/certificate")),
+	}
+}
+
+// RegisterAdminRouter registers the certificate inventory routes. r must be
+// protected by the admin authentication middleware.
+func (cs *CertificateService) RegisterAdminRouter(r *gin.RouterGroup) {
+	groupGroupRouter := r.Group("/certificates")
+	{
+		groupGroupRouter.GET("", cs.list)
+		groupGroupRouter.GET("/counts", cs.counts)
+		groupGroupRouter.GET("/:serial", cs.get)
+	}
+}
+
+// CertificateView is the admin API representation of an issued
+// certificate.
+type CertificateView struct {
+	Serial           string     `json:"serial"`
+	CommonName       string     `json:"common_name"`
+	Subject          string     `json:"subject"`
+	Issuer           string     `json:"issuer"`
+	CaType           string     `json:"ca_type,omitempty"`
+	DNSNames         []string   `json:"dns_names,omitempty"`
+	NotBefore        time.Time  `json:"not_before"`
+	NotAfter         time.Time  `json:"not_after"`
+	Status           string     `json:"status"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	RevocationReason string     `json:"revocation_reason,omitempty"`
+}
+
+func newCertificateView(c *biz.IssuedCertificate) CertificateView {
+	crt := c.Certificate
+	v := CertificateView{
+		Serial:     crt.SerialNumber.Text(16),
+		CommonName: crt.Subject.CommonName,
+		Subject:    crt.Subject.String(),
+		Issuer:     crt.Issuer.String(),
+		CaType:     c.CaType,
+		DNSNames:   crt.DNSNames,
+		NotBefore:  crt.NotBefore,
+		NotAfter:   crt.NotAfter,
+		Status:     string(c.Status),
+	}
+	if c.Status == biz.CertificateRevoked {
+		v.RevokedAt = &c.RevokedAt
+		for name, code := range biz.RevocationReasons {
+			if code == c.Reason {
+				v.RevocationReason = name
+			}
+		}
+	}
+	return v
+}
+
+type CertificateListResponse struct {
+	// Total is the number of matching certificates, of which Certificates
+	// is the requested page.
+	Total        int               `json:"total"`
+	Certificates []CertificateView `json:"certificates"`
+}
+
+type CertificateCountsResponse struct {
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	ByCaType map[string]int `json:"by_ca_type"`
+}
+
+// list lists the issued certificates. The query parameters cn, serial (hex),
+// status (valid, revoked, expired), expiring_before (RFC 3339) and ca_type
+// filter the list, offset and limit page it.
+func (s *CertificateService) list(c *gin.Context) {
+	var f biz.CertificateFilter
+	f.CommonName = c.Query("cn")
+	if v := c.Query("serial"); v != "" {
+		serial, ok := new(big.Int).SetString(v, 16)
+		if !ok {
+			ClientError(errors.New("serial must be a hex number"), c)
+			return
+		}
+		f.Serial = serial
+	}
+	f.Status = biz.CertificateStatus(c.Query("status"))
+	if v := c.Query("expiring_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			ClientError(errors.New("expiring_before must be an RFC 3339 time"), c)
+			return
+		}
+		f.ExpiresBefore = t
+	}
+	if v := strings.ToUpper(c.Query("ca_type")); v != "" {
+		if !utils.IsInArray(biz.SupportedCaTypes, v) {
+			ClientError(biz.UnsupportedCaTypeErr, c)
+			return
+		}
+		f.CaType = v
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		ClientError(errors.New("offset must be a non-negative number"), c)
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > maxListLimit {
+		ClientError(errors.New("limit must be between 1 and 1000"), c)
+		return
+	}
+
+	certs, total, err := s.uc.List(c, f, offset, limit)
+	if errors.Is(err, biz.UnknownCertificateStatusErr) {
+		ClientError(err, c)
+		return
+	}
+	if err != nil {
+		ServerInternalError(err, c)
+		return
+	}
+	resp := CertificateListResponse{Total: total, Certificates: make([]CertificateView, 0, len(certs))}
+	for _, crt := range certs {
+		resp.Certificates = append(resp.Certificates, newCertificateView(crt))
+	}
+	c.JSON(200, resp)
+}
+
+func (s *CertificateService) counts(c *gin.Context) {
+	counts, err := s.uc.Counts(c)
+	if err != nil {
+		ServerInternalError(err, c)
+		return
+	}
+	resp := CertificateCountsResponse{
+		Total:    counts.Total,
+		ByStatus: map[string]int{},
+		ByCaType: counts.ByCaType,
+	}
+	for status, n := range counts.ByStatus {
+		resp.ByStatus[string(status)] = n
+	}
+	c.JSON(200, resp)
+}
+
+// get returns a certificate as PEM, or as DER with format=der.
+func (s *CertificateService) get(c *gin.Context) {
+	serial, ok := new(big.Int).SetString(c.Param("serial"), 16)
+	if !ok {
+		ClientError(errors.New("serial must be a hex number"), c)
+		return
+	}
+	format := c.DefaultQuery("format", "pem")
+	if format != "pem" && format != "der" {
+		ClientError(errors.New("format must be pem or der"), c)
+		return
+	}
+	crt, err := s.uc.Get(c, serial)
+	if errors.Is(err, biz.CertNotFoundErr) {
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+		return
+	}
+	if err != nil {
+		ServerInternalError(err, c)
+		return
+	}
+	if format == "der" {
+		c.Data(200, utils.CertHeader, crt.Raw)
+		return
+	}
+	c.Data(200, utils.PEMHeader, utils.PemCert(crt.Raw))
+}
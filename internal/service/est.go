@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"kscep/internal/biz"
+	"kscep/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/ploynomail/scep"
+)
+
+// estRetryAfter is when an EST client should retry a pending enrollment.
+const estRetryAfter = time.Minute
+
+type ESTService struct {
+	uc  *biz.ESTUsecase
+	log *log.Helper
+}
+
+func NewESTService(uc *biz.ESTUsecase, logger log.Logger) *ESTService {
+	return &ESTService{
+		uc:  uc,
+		log: log.NewHelper(log.With(logger, "module", "service/est")),
+	}
+}
+
+// RegisterServiceRouter registers the EST operations of RFC 7030 3.2.2,
+// r is /.well-known/est. The optional label selects the CA type.
+func (s *ESTService) RegisterServiceRouter(r *gin.RouterGroup) {
+	for _, prefix := range []string{"", "/:label"} {
+		r.GET(prefix+"/cacerts", s.cacerts)
+		r.POST(prefix+"/simpleenroll", s.simpleenroll)
+		r.POST(prefix+"/simplereenroll", s.simplereenroll)
+		r.GET(prefix+"/csrattrs", s.csrattrs)
+	}
+}
+
+func (s *ESTService) cacerts(c *gin.Context) {
+	data, err := s.uc.CACerts(c, c.Param("label"))
+	if err != nil {
+		estError(err, c)
+		return
+	}
+	estResult(utils.ESTCACertsHeader, data, c)
+}
+
+func (s *ESTService) csrattrs(c *gin.Context) {
+	data, err := s.uc.CSRAttrs(c, c.Param("label"))
+	if err != nil {
+		estError(err, c)
+		return
+	}
+	estResult(utils.CSRAttrsHeader, data, c)
+}
+
+func (s *ESTService) simpleenroll(c *gin.Context) {
+	s.enroll(c, s.uc.SimpleEnroll)
+}
+
+func (s *ESTService) simplereenroll(c *gin.Context) {
+	s.enroll(c, s.uc.SimpleReenroll)
+}
+
+// enroll reads the base64 encoded PKCS#10 CSR of the request body and
+// returns the certificate issued by op. Enrollment is refused outside TLS,
+// RFC 7030 3.3, which the basic auth password would otherwise cross in the
+// clear.
+func (s *ESTService) enroll(c *gin.Context, op func(context.Context, string, []byte, *biz.ESTAuth) ([]byte, error)) {
+	if c.Request.TLS == nil {
+		ResultErr(403, biz.SCEPResponse{Err: biz.ESTTLSRequiredErr}, c)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, biz.MaxPayloadSize))
+	if err != nil {
+		ClientError(err, c)
+		return
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		ClientError(err, c)
+		return
+	}
+	auth := &biz.ESTAuth{}
+	if _, pass, ok := c.Request.BasicAuth(); ok {
+		auth.Password = pass
+	}
+	if tls := c.Request.TLS; len(tls.PeerCertificates) > 0 {
+		auth.ClientCert = tls.PeerCertificates[0]
+	}
+	data, err := op(c, c.Param("label"), der, auth)
+	if err != nil {
+		estError(err, c)
+		return
+	}
+	estResult(utils.ESTCertsHeader, data, c)
+}
+
+// estResult writes data base64 encoded, as RFC 7030 4 requires.
+func estResult(contentType string, data []byte, c *gin.Context) {
+	c.Header("Content-Transfer-Encoding", "base64")
+	c.Data(200, contentType, []byte(base64.StdEncoding.EncodeToString(data)))
+}
+
+func estError(err error, c *gin.Context) {
+	var v *biz.PolicyViolation
+	switch {
+	case errors.Is(err, biz.ESTPendingErr):
+		c.Header("Retry-After", strconv.Itoa(int(estRetryAfter.Seconds())))
+		c.Status(202)
+	case errors.Is(err, biz.ESTAuthErr):
+		c.Header("WWW-Authenticate", `Basic realm="kscep EST"`)
+		ResultErr(401, biz.SCEPResponse{Err: err}, c)
+	case errors.Is(err, biz.UnsupportedCaTypeErr):
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+	case errors.As(err, &v) && v.FailInfo == scep.BadCertID:
+		// the client certificate of a reenrollment is not acceptable
+		ResultErr(403, biz.SCEPResponse{Err: err}, c)
+	case errors.As(err, &v):
+		ClientError(err, c)
+	default:
+		ServerInternalError(err, c)
+	}
+}
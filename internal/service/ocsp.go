@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/base64"
+	"io"
+	"kscep/internal/biz"
+	"kscep/internal/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// maxOCSPRequestSize bounds the body of a POSTed OCSP request.
+const maxOCSPRequestSize = 64 << 10
+
+type OCSPService struct {
+	uc  *biz.OCSPUsecase
+	log *log.Helper
+}
+
+func NewOCSPService(uc *biz.OCSPUsecase, logger log.Logger) *OCSPService {
+	return &OCSPService{
+		uc:  uc,
+		log: log.NewHelper(log.With(logger, "module", "service/ocsp")),
+	}
+}
+
+// RegisterServiceRouter registers the OCSP responder of RFC 6960 appendix
+// A.1: POST /ocsp with a DER request body and GET /ocsp/<base64 request>.
+func (s *OCSPService) RegisterServiceRouter(r *gin.RouterGroup) {
+	r.POST("/ocsp", s.post)
+	r.GET("/ocsp/*request", s.get)
+}
+
+func (s *OCSPService) post(c *gin.Context) {
+	if c.ContentType() != "application/ocsp-request" {
+		c.Status(415)
+		return
+	}
+	req, err := io.ReadAll(io.LimitReader(c.Request.Body, maxOCSPRequestSize))
+	if err != nil {
+		ClientError(err, c)
+		return
+	}
+	c.Data(200, utils.OCSPHeader, s.uc.Respond(c, req))
+}
+
+func (s *OCSPService) get(c *gin.Context) {
+	// the router has already undone the url-encoding of the base64
+	req, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(c.Param("request"), "/"))
+	if err != nil {
+		ClientError(err, c)
+		return
+	}
+	c.Data(200, utils.OCSPHeader, s.uc.Respond(c, req))
+}
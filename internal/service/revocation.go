@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"kscep/internal/biz"
+	"kscep/internal/utils"
+	"math/big"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type RevocationService struct {
+	uc  *biz.RevocationUsecase
+	log *log.Helper
+}
+
+func NewRevocationService(uc *biz.RevocationUsecase, logger log.Logger) *RevocationService {
+	return &RevocationService{
+		uc:  uc,
+		log: log.NewHelper(log.With(logger, "module", "service/revocation")),
+	}
+}
+
+// RegisterServiceRouter registers the CRL distribution point,
+// /crl/<CA type>[.crl].
+func (rs *RevocationService) RegisterServiceRouter(r *gin.RouterGroup) {
+	r.GET("/crl/:ca", rs.crl)
+}
+
+// RegisterAdminRouter registers the revocation routes. r must be protected
+// by the admin authentication middleware.
+func (rs *RevocationService) RegisterAdminRouter(r *gin.RouterGroup) {
+	groupGroupRouter := r.Group("/certificates")
+	{
+		groupGroupRouter.POST("/:serial/revoke", rs.revoke)
+	}
+}
+
+// RevokeRequest is the body of a revoke request. Reason is one of the keys
+// of biz.RevocationReasons and defaults to unspecified.
+type RevokeRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *RevocationService) crl(c *gin.Context) {
+	caType := strings.TrimSuffix(c.Param("ca"), ".crl")
+	if caType == "" || !utils.IsInArray(biz.SupportedCaTypes, caType) {
+		ResultErr(404, biz.SCEPResponse{Err: biz.UnsupportedCaTypeErr}, c)
+		return
+	}
+	crl, err := s.uc.CRL(c, biz.GetCaType(caType))
+	if err != nil {
+		s.log.Errorf("failed to sign %s CRL: %v", caType, err)
+		ServerInternalError(err, c)
+		return
+	}
+	c.Data(200, utils.CRLHeader, crl)
+}
+
+func (s *RevocationService) revoke(c *gin.Context) {
+	serial, ok := new(big.Int).SetString(c.Param("serial"), 16)
+	if !ok {
+		ClientError(errors.New("serial must be a hex number"), c)
+		return
+	}
+	req := RevokeRequest{Reason: "unspecified"}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ClientError(err, c)
+		return
+	}
+	err := s.uc.Revoke(c, serial, req.Reason)
+	switch {
+	case err == nil:
+		c.Status(204)
+	case errors.Is(err, biz.InvalidRevocationReasonErr):
+		ClientError(err, c)
+	case errors.Is(err, biz.CertNotFoundErr):
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+	case errors.Is(err, biz.AlreadyRevokedErr):
+		ResultErr(409, biz.SCEPResponse{Err: err}, c)
+	default:
+		ServerInternalError(err, c)
+	}
+}
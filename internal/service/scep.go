@@ -2,30 +2,197 @@ package service
 
 import (
 	"encoding/base64"
+	"errors"
+	"io"
 	"kscep/internal/biz"
 	"net/url"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-kratos/kratos/v2/log"
 )
 
 type SCEPService struct {
-	uc  *biz.SCEPUsecase
-	log *log.Helper
+	uc          *biz.SCEPUsecase
+	challengeUc *biz.ChallengeUsecase
+	approvalUc  *biz.ApprovalUsecase
+	metrics     *biz.Metrics
+	log         *log.Helper
 }
 
-func NewSCEPService(uc *biz.SCEPUsecase, logger log.Logger) *SCEPService {
+func NewSCEPService(uc *biz.SCEPUsecase, challengeUc *biz.ChallengeUsecase, approvalUc *biz.ApprovalUsecase, metrics *biz.Metrics, logger log.Logger) *SCEPService {
 	return &SCEPService{
-		uc:  uc,
-		log: log.NewHelper(log.With(logger, "module", "service/scep")),
+		uc:          uc,
+		challengeUc: challengeUc,
+		approvalUc:  approvalUc,
+		metrics:     metrics,
+		log:         log.NewHelper(log.With(logger, "module", "service/scep")),
 	}
 }
 
 func (sc *SCEPService) RegisterServiceRouter(r *gin.RouterGroup) {
-	groupGroupRouter := r.Group("/scep")
+	groupGroupRouter := r.Group("/scep", sc.instrument)
 	{
 		groupGroupRouter.GET("", sc.scep)
 		groupGroupRouter.POST("", sc.sceppost)
+		groupGroupRouter.GET("/:profile", sc.scep)
+		groupGroupRouter.POST("/:profile", sc.sceppost)
+	}
+}
+
+// RegisterAdminRouter registers the SCEP administration routes. r must be
+// protected by the admin authentication middleware.
+func (sc *SCEPService) RegisterAdminRouter(r *gin.RouterGroup) {
+	groupGroupRouter := r.Group("/scep")
+	{
+		groupGroupRouter.POST("/challenges", sc.issueChallenge)
+		groupGroupRouter.GET("/requests", sc.listPending)
+		groupGroupRouter.GET("/requests/:id", sc.getPending)
+		groupGroupRouter.POST("/requests/:id/approve", sc.approvePending)
+		groupGroupRouter.POST("/requests/:id/reject", sc.rejectPending)
+	}
+}
+
+// instrument counts the SCEP requests by operation and status code.
+func (sc *SCEPService) instrument(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	sc.metrics.ObserveRequest(c.Query("operation"), c.Writer.Status(), time.Since(start))
+}
+
+// IssueChallengeRequest is the body of an issue challenge request. Both
+// fields are optional.
+type IssueChallengeRequest struct {
+	// TTL is the challenge lifetime in seconds.
+	TTL        int64  `json:"ttl"`
+	CommonName string `json:"common_name"`
+}
+
+type IssueChallengeResponse struct {
+	Challenge  string    `json:"challenge"`
+	CommonName string    `json:"common_name,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// issueChallenge mints a one-time challenge password, like the NDES
+// mscep_admin page.
+func (s *SCEPService) issueChallenge(c *gin.Context) {
+	var req IssueChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ClientError(err, c)
+		return
+	}
+	if req.TTL < 0 {
+		ClientError(errors.New("ttl must not be negative"), c)
+		return
+	}
+	ch, err := s.challengeUc.Issue(c, time.Duration(req.TTL)*time.Second, req.CommonName)
+	if errors.Is(err, biz.DynamicChallengeDisabledErr) {
+		ClientError(err, c)
+		return
+	}
+	if err != nil {
+		ServerInternalError(err, c)
+		return
+	}
+	c.JSON(201, IssueChallengeResponse{
+		Challenge:  ch.Value,
+		CommonName: ch.CommonName,
+		ExpiresAt:  ch.ExpiresAt,
+	})
+}
+
+// PendingRequestView is the admin API representation of a request waiting
+// for manual approval.
+type PendingRequestView struct {
+	ID            string    `json:"id"`
+	TransactionID string    `json:"transaction_id"`
+	Profile       string    `json:"profile,omitempty"`
+	Subject       string    `json:"subject"`
+	DNSNames      []string  `json:"dns_names,omitempty"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason,omitempty"`
+	Serial        string    `json:"serial,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func newPendingRequestView(pr *biz.PendingRequest) PendingRequestView {
+	v := PendingRequestView{
+		ID:            pr.ID,
+		TransactionID: pr.TransactionID,
+		Profile:       pr.Profile,
+		Subject:       pr.CSR.Subject.String(),
+		DNSNames:      pr.CSR.DNSNames,
+		Status:        string(pr.Status),
+		Reason:        pr.Reason,
+		CreatedAt:     pr.CreatedAt,
+		UpdatedAt:     pr.UpdatedAt,
+	}
+	if pr.Certificate != nil {
+		v.Serial = pr.Certificate.SerialNumber.Text(16)
+	}
+	return v
+}
+
+// RejectRequest is the body of a reject request.
+type RejectRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *SCEPService) listPending(c *gin.Context) {
+	prs, err := s.approvalUc.List(c, biz.PendingStatus(c.Query("status")))
+	if err != nil {
+		ServerInternalError(err, c)
+		return
+	}
+	views := make([]PendingRequestView, 0, len(prs))
+	for _, pr := range prs {
+		views = append(views, newPendingRequestView(pr))
+	}
+	c.JSON(200, views)
+}
+
+func (s *SCEPService) getPending(c *gin.Context) {
+	pr, err := s.approvalUc.Get(c, c.Param("id"))
+	if err != nil {
+		pendingError(err, c)
+		return
+	}
+	c.JSON(200, newPendingRequestView(pr))
+}
+
+func (s *SCEPService) approvePending(c *gin.Context) {
+	pr, err := s.approvalUc.Approve(c, c.Param("id"))
+	if err != nil {
+		pendingError(err, c)
+		return
+	}
+	c.JSON(200, newPendingRequestView(pr))
+}
+
+func (s *SCEPService) rejectPending(c *gin.Context) {
+	var req RejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		ClientError(err, c)
+		return
+	}
+	pr, err := s.approvalUc.Reject(c, c.Param("id"), req.Reason)
+	if err != nil {
+		pendingError(err, c)
+		return
+	}
+	c.JSON(200, newPendingRequestView(pr))
+}
+
+func pendingError(err error, c *gin.Context) {
+	switch {
+	case errors.Is(err, biz.PendingNotFoundErr):
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+	case errors.Is(err, biz.PendingNotPendingErr):
+		ResultErr(409, biz.SCEPResponse{Err: err}, c)
+	default:
+		ServerInternalError(err, c)
 	}
 }
 
@@ -36,13 +203,18 @@ func (s *SCEPService) scep(c *gin.Context) {
 	var req biz.SCEPRequest
 	var resp biz.SCEPResponse = biz.SCEPResponse{Operation: req.Operation}
 	req.Operation = c.Query("operation")
+	resp.Operation = req.Operation
+	if err := s.uc.CheckProfile(c.Param("profile")); err != nil {
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+		return
+	}
 	if req.Operation == "" {
 		resp.Err = biz.MissingOperationErr
 		ClientError(resp.Err, c)
 		return
 	}
 	msg := c.Query("message")
-	if msg == "" && (req.Operation != "GetCACaps" && req.Operation != "GetCACert") {
+	if msg == "" && (req.Operation != "GetCACaps" && req.Operation != "GetCACert" && req.Operation != "GetNextCACert") {
 		resp.Err = biz.MissingMessageErr
 		ClientError(resp.Err, c)
 		return
@@ -65,16 +237,20 @@ func (s *SCEPService) scep(c *gin.Context) {
 	}
 	switch req.Operation {
 	case "GetCACaps":
-		resp.Data, resp.Err = s.uc.GetCACaps(c)
+		resp.Data, resp.Err = s.uc.GetCACaps(c, string(req.Message))
 	case "GetCACert":
 		resp.Data, resp.CACertNum, resp.Err = s.uc.GetCACert(c, string(req.Message))
 	case "PKIOperation":
-		resp.Data, resp.Err = s.uc.PKIOperation(c, req.Message)
+		resp.Data, resp.Err = s.uc.PKIOperation(c, c.Param("profile"), req.Message)
 	case "GetNextCACert":
-		resp.Data, resp.Err = s.uc.GetNextCACert(c)
+		resp.Data, resp.Err = s.uc.GetNextCACert(c, string(req.Message))
 	default:
 		resp.Err = biz.UnsupportedOperationErr
 	}
+	if errors.Is(resp.Err, biz.MissingNextCaCertErr) {
+		ResultErr(404, resp, c)
+		return
+	}
 	if resp.Err != nil {
 		ClientError(resp.Err, c)
 		return
@@ -84,17 +260,29 @@ func (s *SCEPService) scep(c *gin.Context) {
 
 func (s *SCEPService) sceppost(c *gin.Context) {
 	var req biz.SCEPRequest
+	req.Operation = c.Query("operation")
 	var resp biz.SCEPResponse = biz.SCEPResponse{Operation: req.Operation}
-	if err := c.ShouldBindQuery(&req); err != nil {
+	if err := s.uc.CheckProfile(c.Param("profile")); err != nil {
+		ResultErr(404, biz.SCEPResponse{Err: err}, c)
+		return
+	}
+	// the pkiMessage is the raw, not base64 encoded, request body
+	msg, err := io.ReadAll(io.LimitReader(c.Request.Body, biz.MaxPayloadSize))
+	if err != nil {
 		resp.Err = err
 		ClientError(resp.Err, c)
+		return
 	}
+	req.Message = msg
 	switch req.Operation {
 	case "PKIOperation":
-		resp.Data, resp.Err = s.uc.PKIOperation(c, req.Message)
+		resp.Data, resp.Err = s.uc.PKIOperation(c, c.Param("profile"), req.Message)
 	default:
 		resp.Err = biz.UnsupportedOperationErr
+	}
+	if resp.Err != nil {
 		ClientError(resp.Err, c)
+		return
 	}
 	Ok(resp, c)
 }
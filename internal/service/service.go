@@ -6,4 +6,8 @@ import "github.com/google/wire"
 var ProviderSet = wire.NewSet(
 	NewHelloWorldService,
 	NewSCEPService,
+	NewRevocationService,
+	NewOCSPService,
+	NewCertificateService,
+	NewESTService,
 )
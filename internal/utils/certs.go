@@ -1,8 +1,8 @@
 package utils
 
 import (
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -11,6 +11,10 @@ import (
 	"math/big"
 	"os"
 	"time"
+
+	"kscep/internal/gmscep"
+
+	"github.com/emmansun/gmsm/smx509"
 )
 
 const (
@@ -31,10 +35,46 @@ func LoadPEMCertFromFile(path string) (*x509.Certificate, error) {
 		return nil, errors.New("unmatched type or headers")
 	}
 
-	return x509.ParseCertificate(pemBlock.Bytes)
+	return gmscep.ParseCertificate(pemBlock.Bytes)
+}
+
+// LoadPEMKeyFromFile loads a PKCS#1 RSA, SEC 1 EC or SM2, or PKCS#8 private
+// key.
+func LoadPEMKeyFromFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBlock, _ := pem.Decode(data)
+	if pemBlock == nil {
+		return nil, errors.New("PEM decode failed")
+	}
+	switch pemBlock.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
+	case "EC PRIVATE KEY", "PRIVATE KEY":
+		// smx509 returns an *sm2.PrivateKey for SM2 keys
+		var key interface{}
+		if pemBlock.Type == "EC PRIVATE KEY" {
+			key, err = smx509.ParseTypedECPrivateKey(pemBlock.Bytes)
+		} else {
+			key, err = smx509.ParsePKCS8PrivateKey(pemBlock.Bytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	default:
+		return nil, errors.New("unmatched type or headers")
+	}
 }
 
-func LoadOrSign(path string, priv *rsa.PrivateKey, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+func LoadOrSign(path string, priv crypto.Signer, csr *x509.CertificateRequest) (*x509.Certificate, error) {
 	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
 		if os.IsExist(err) {
@@ -58,7 +98,7 @@ func LoadOrSign(path string, priv *rsa.PrivateKey, csr *x509.CertificateRequest)
 	return self, nil
 }
 
-func selfSign(priv *rsa.PrivateKey, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+func selfSign(priv crypto.Signer, csr *x509.CertificateRequest) (*x509.Certificate, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
@@ -81,11 +121,12 @@ func selfSign(priv *rsa.PrivateKey, csr *x509.CertificateRequest) (*x509.Certifi
 		BasicConstraintsValid: true,
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	// smx509 signs with SM2 and SM3 for SM2 keys, as crypto/x509 otherwise
+	derBytes, err := smx509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 	if err != nil {
 		return nil, err
 	}
-	return x509.ParseCertificate(derBytes)
+	return gmscep.ParseCertificate(derBytes)
 }
 
 // PemCert converts DER-encoded bytes to PEM-encoded bytes.
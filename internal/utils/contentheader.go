@@ -4,6 +4,15 @@ const (
 	CertChainHeader = "application/x-x509-ca-ra-cert"
 	LeafHeader      = "application/x-x509-ca-cert"
 	PkiOpHeader     = "application/x-pki-message"
+	NextCAHeader    = "application/x-x509-next-ca-cert"
+	CRLHeader       = "application/pkix-crl"
+	OCSPHeader      = "application/ocsp-response"
+	CertHeader      = "application/pkix-cert"
+	PEMHeader       = "application/x-pem-file"
+	// EST (RFC 7030) responses, base64 encoded
+	ESTCACertsHeader = "application/pkcs7-mime"
+	ESTCertsHeader   = "application/pkcs7-mime; smime-type=certs-only"
+	CSRAttrsHeader   = "application/csrattrs"
 )
 
 func ContentHeader(op string, certNum int) string {
@@ -15,6 +24,8 @@ func ContentHeader(op string, certNum int) string {
 		return LeafHeader
 	case "PKIOperation":
 		return PkiOpHeader
+	case "GetNextCACert":
+		return NextCAHeader
 	default:
 		return "text/plain"
 	}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,17 +10,23 @@ import (
 	"errors"
 	"os"
 
+	"kscep/internal/gmscep"
+
+	"github.com/emmansun/gmsm/sm2"
+	"github.com/emmansun/gmsm/smx509"
 	"github.com/ploynomail/scep/x509util"
 )
 
 const (
 	csrPEMBlockType           = "CERTIFICATE REQUEST"
 	rsaPrivateKeyPEMBlockType = "RSA PRIVATE KEY"
+	ecPrivateKeyPEMBlockType  = "EC PRIVATE KEY"
 )
 
 type CsrOptions struct {
 	Cn, Org, Country, OU, Locality, Province, DnsName, Challenge string
-	Key                                                          *rsa.PrivateKey
+	// Key is an *rsa.PrivateKey, or an *sm2.PrivateKey for GM/T 0089
+	Key crypto.Signer
 }
 
 func LoadCSRfromFile(path string) (*x509.CertificateRequest, error) {
@@ -34,7 +41,7 @@ func LoadCSRfromFile(path string) (*x509.CertificateRequest, error) {
 	if pemBlock.Type != csrPEMBlockType || len(pemBlock.Headers) != 0 {
 		return nil, errors.New("unmatched type or headers")
 	}
-	return x509.ParseCertificateRequest(pemBlock.Bytes)
+	return gmscep.ParseCertificateRequest(pemBlock.Bytes)
 }
 
 // convert DER to PEM format
@@ -65,18 +72,26 @@ func LoadOrMakeCSR(path string, opts *CsrOptions) (*x509.CertificateRequest, err
 		Locality:           SubjOrNil(opts.Locality),
 		CommonName:         opts.Cn,
 	}
-	template := x509util.CertificateRequest{
-		CertificateRequest: x509.CertificateRequest{
-			Subject:            subject,
-			SignatureAlgorithm: x509.SHA256WithRSA,
-			DNSNames:           SubjOrNil(opts.DnsName),
-		},
-	}
-	if opts.Challenge != "" {
-		template.ChallengePassword = opts.Challenge
+	var derBytes []byte
+	if key, ok := opts.Key.(*sm2.PrivateKey); ok {
+		template := &x509.CertificateRequest{
+			Subject:  subject,
+			DNSNames: SubjOrNil(opts.DnsName),
+		}
+		derBytes, err = gmscep.CreateCertificateRequest(rand.Reader, template, opts.Challenge, key)
+	} else {
+		template := x509util.CertificateRequest{
+			CertificateRequest: x509.CertificateRequest{
+				Subject:            subject,
+				SignatureAlgorithm: x509.SHA256WithRSA,
+				DNSNames:           SubjOrNil(opts.DnsName),
+			},
+		}
+		if opts.Challenge != "" {
+			template.ChallengePassword = opts.Challenge
+		}
+		derBytes, err = x509util.CreateCertificateRequest(rand.Reader, &template, opts.Key)
 	}
-
-	derBytes, err := x509util.CreateCertificateRequest(rand.Reader, &template, opts.Key)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +102,7 @@ func LoadOrMakeCSR(path string, opts *CsrOptions) (*x509.CertificateRequest, err
 	if err := pem.Encode(file, pemBlock); err != nil {
 		return nil, err
 	}
-	return x509.ParseCertificateRequest(derBytes)
+	return gmscep.ParseCertificateRequest(derBytes)
 }
 
 // load key if it exists or create a new one
@@ -136,6 +151,53 @@ func loadKeyFromFile(path string) (*rsa.PrivateKey, error) {
 	return x509.ParsePKCS1PrivateKey(pemBlock.Bytes)
 }
 
+// load the SM2 key if it exists or create a new one
+func LoadOrMakeSM2Key(path string) (*sm2.PrivateKey, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return loadSM2KeyFromFile(path)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	priv, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	privBytes, err := smx509.MarshalSM2PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pemBlock := &pem.Block{
+		Type:  ecPrivateKeyPEMBlockType,
+		Bytes: privBytes,
+	}
+	if err = pem.Encode(file, pemBlock); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
+
+// load a PEM SM2 private key from disk
+func loadSM2KeyFromFile(path string) (*sm2.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBlock, _ := pem.Decode(data)
+	if pemBlock == nil {
+		return nil, errors.New("PEM decode failed")
+	}
+	if pemBlock.Type != ecPrivateKeyPEMBlockType {
+		return nil, errors.New("unmatched type or headers")
+	}
+
+	return smx509.ParseSM2PrivateKey(pemBlock.Bytes)
+}
+
 // create a new RSA private key
 func newRSAKey(bits int) (*rsa.PrivateKey, error) {
 	private, err := rsa.GenerateKey(rand.Reader, bits)